@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"log"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,8 +16,12 @@ import (
 	ad "github.com/asadlive84/shopper/api-gateway/internal/adapters/http"
 	"github.com/asadlive84/shopper/api-gateway/internal/application/core/api"
 	"github.com/asadlive84/shopper/api-gateway/internal/event"
+	"github.com/asadlive84/shopper/api-gateway/internal/keys"
 	"github.com/asadlive84/shopper/api-gateway/internal/logger"
+	"github.com/asadlive84/shopper/api-gateway/internal/mtls"
 	"github.com/asadlive84/shopper/api-gateway/internal/rabbitmq"
+	"github.com/asadlive84/shopper/api-gateway/internal/social"
+	"github.com/asadlive84/shopper/api-gateway/internal/tenant"
 	"github.com/asadlive84/shopper/api-gateway/internal/tracing"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -24,14 +31,43 @@ import (
 )
 
 func main() {
-	cfg := config.NewConfig()
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
 
-	zapLogger := logger.NewLogger()
+	// The log level follows changes of the configuration; other settings only take
+	// effect on restart
+	logLevel := zap.NewAtomicLevel()
+	if err := logLevel.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		log.Fatalf("Invalid log level: %v", err)
+	}
+	zapLogger := logger.NewLogger(logLevel)
 	defer zapLogger.Sync()
 
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go config.Watch(watchCtx, cfg, os.Args[1:], func(next *config.Config) {
+		if err := logLevel.UnmarshalText([]byte(next.LogLevel)); err != nil {
+			zapLogger.Error("Failed to apply log level", zap.Error(err))
+		}
+		zapLogger.Info("Configuration reloaded", zap.String("log_level", next.LogLevel))
+	}, func(err error) {
+		zapLogger.Error("Invalid configuration, keeping the running one", zap.Error(err))
+	})
+
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 
-	tp, err := tracing.InitTracer("api-gateway", cfg.JaegerEndpoint)
+	tp, err := tracing.InitTracer(tracing.Config{
+		ServiceName: "api-gateway",
+		Version:     cfg.Tracing.Version,
+		Environment: cfg.Environment,
+		Instance:    cfg.Tracing.Instance,
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
 	if err != nil {
 		zapLogger.Fatal("Failed to initialize tracer", zap.Error(err))
 	}
@@ -47,24 +83,84 @@ func main() {
 	}
 	defer rabbitClient.Close()
 
-	err = rabbitClient.Setup(cfg.RabbitMQ.Exchange, cfg.RabbitMQ.Queue)
+	err = rabbitClient.Setup(cfg.RabbitMQ.Exchange, cfg.RabbitMQ.Queue, cfg.RabbitMQ.Tenants)
 	if err != nil {
 		zapLogger.Fatal("Failed to setup RabbitMQ", zap.Error(err))
 	}
 
 
 	
-	zapLogger.Info("Connecting to User gRPC service", zap.String("addr", cfg.GRPCUserServiceAddr))
-	grpcClient := grpc.NewUserGRPCClient(cfg.GRPCUserServiceAddr)
+	// Mutual TLS towards user-svc, with certificates reloaded as they are rotated
+	var userServiceTLS *tls.Config
+	if cfg.UserServiceTLS.CertFile != "" {
+		certs, err := mtls.NewReloader(cfg.UserServiceTLS.CertFile, cfg.UserServiceTLS.KeyFile, cfg.UserServiceTLS.CAFile, zapLogger)
+		if err != nil {
+			zapLogger.Fatal("Failed to load user service TLS certificates", zap.Error(err))
+		}
+		certCtx, stopCerts := context.WithCancel(context.Background())
+		defer stopCerts()
+		go certs.Run(certCtx, cfg.UserServiceTLS.ReloadInterval)
+		userServiceTLS = certs.ClientConfig(cfg.UserServiceTLS.ServerName)
+	}
+	zapLogger.Info("Connecting to User gRPC service", zap.String("addr", cfg.GRPCUserServiceAddr), zap.Bool("mtls", userServiceTLS != nil))
+	grpcClient := grpc.NewUserGRPCClient(cfg.GRPCUserServiceAddr, userServiceTLS)
 	
 	eventManager := event.NewEventManager(zapLogger, rabbitClient, grpcClient)
 	go eventManager.ConsumeEvents(cfg.RabbitMQ.Queue)
 
 
-	apiService := api.NewAPIService(grpcClient, cfg.JWTSecret, zapLogger)
+	// Access token keys: our own rotating signing keys plus the key sets of trusted issuers
+	signingKeys, err := keys.NewManager(cfg.Keys.Dir, cfg.Keys.Rotation, cfg.Keys.Overlap, zapLogger)
+	if err != nil {
+		zapLogger.Fatal("Failed to load signing keys", zap.Error(err))
+	}
+	keyCtx, stopKeys := context.WithCancel(context.Background())
+	defer stopKeys()
+	go signingKeys.Run(keyCtx, time.Minute)
+
+	issuers := []keys.Issuer{{Name: cfg.Keys.Issuer, Keys: signingKeys.Source()}}
+	jwksClient := &http.Client{Timeout: 5 * time.Second}
+	for _, trusted := range cfg.Keys.Trusted {
+		issuers = append(issuers, keys.Issuer{Name: trusted.Issuer, Keys: keys.HTTPSource(trusted.JWKSURL, jwksClient)})
+	}
+	verifier := keys.NewVerifier(cfg.Keys.Audience, cfg.Keys.Refresh, cfg.JWTSecret, zapLogger, issuers...)
+	if err := verifier.Refresh(keyCtx); err != nil {
+		zapLogger.Warn("Failed to fetch trusted key sets", zap.Error(err))
+	}
 
-	r := gin.Default()
-	handler := ad.NewHandler(apiService, cfg.JWTSecret, zapLogger, rabbitClient, grpcClient)
+	apiService := api.NewAPIService(grpcClient, signingKeys, cfg.Keys.Issuer, cfg.Keys.Audience, zapLogger)
+
+	tenants := tenant.NewResolver(cfg.Tenancy.Hosts, cfg.Tenancy.Header, cfg.Tenancy.Default)
+
+	// External identity providers users can log in with
+	providers := make([]social.Config, 0, len(cfg.Social.Providers))
+	for _, p := range cfg.Social.Providers {
+		providers = append(providers, social.Config(p))
+	}
+	stateSecret := []byte(cfg.Social.StateSecret)
+	if len(stateSecret) == 0 {
+		if len(providers) > 0 {
+			zapLogger.Warn("SOCIAL_STATE_SECRET not set; social logins only complete on the replica that started them")
+		}
+		stateSecret = make([]byte, 32)
+		if _, err := rand.Read(stateSecret); err != nil {
+			zapLogger.Fatal("Failed to generate social login state secret", zap.Error(err))
+		}
+	}
+	socialLogin, err := social.NewRelyingParty(providers, cfg.Social.BaseURL, stateSecret, &http.Client{Timeout: 10 * time.Second}, zapLogger)
+	if err != nil {
+		zapLogger.Fatal("Invalid identity provider configuration", zap.Error(err))
+	}
+
+	// Panics are recovered by the handler's own middleware, which logs the request ID
+	r := gin.New()
+	// Only the configured proxies may name the client in X-Forwarded-For; anyone else could
+	// pick the address its requests are rate limited and logged under
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		zapLogger.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
+	r.Use(gin.Logger())
+	handler := ad.NewHandler(apiService, signingKeys, verifier, tenants, socialLogin, zapLogger, rabbitClient, grpcClient)
 	handler.SetupRoutes(r)
 
 	// HTTP server with graceful shutdown
@@ -81,11 +177,11 @@ func main() {
 
 	// Prometheus metrics server
 	promServer := &http.Server{
-		Addr:    ":9092",
+		Addr:    cfg.MetricsPort,
 		Handler: promhttp.Handler(),
 	}
 	go func() {
-		zapLogger.Info("Starting Prometheus metrics server", zap.String("port", ":9092"))
+		zapLogger.Info("Starting Prometheus metrics server", zap.String("port", cfg.MetricsPort))
 		if err := promServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			zapLogger.Fatal("Failed to start Prometheus server", zap.Error(err))
 		}
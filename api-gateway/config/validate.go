@@ -0,0 +1,141 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/api-gateway/internal/tenant"
+	"github.com/asadlive84/shopper/api-gateway/internal/tracing"
+	"go.uber.org/zap/zapcore"
+)
+
+// Production is the Environment in which unsafe settings, such as the default
+// credentials of the local development setup, are refused
+const Production = "production"
+
+// minSecretLength is the least number of bytes of an HMAC secret accepted in production
+const minSecretLength = 32
+
+// defaultJWTSecret is the placeholder JWT_SECRET of old example configurations
+const defaultJWTSecret = "your-secret-key"
+
+// validator collects the problems of a configuration, each under the setting it is about
+type validator []error
+
+func (v *validator) check(ok bool, key, format string, args ...interface{}) {
+	if !ok {
+		*v = append(*v, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+}
+
+func (v *validator) required(key, value string) {
+	v.check(strings.TrimSpace(value) != "", key, "is required")
+}
+
+func (v *validator) address(key, value string) {
+	_, port, err := net.SplitHostPort(value)
+	if err == nil {
+		var n int
+		n, err = strconv.Atoi(port)
+		if err == nil && (n < 0 || n > 65535) {
+			err = errors.New("port out of range")
+		}
+	}
+	v.check(err == nil, key, "invalid address %q, e.g. \":8080\"", value)
+}
+
+func (v *validator) url(key, value string) {
+	u, err := url.Parse(value)
+	v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", key, "invalid URL %q", value)
+}
+
+func (v *validator) positive(key string, d time.Duration) {
+	v.check(d > 0, key, "must be positive, got %s", d)
+}
+
+// Validate reports every setting that is missing or invalid, and in production every
+// setting left at an unsafe value
+func (c *Config) Validate() error {
+	var v validator
+	v.check(c.Environment == "development" || c.Environment == Production, "APP_ENV", "must be development or production, got %q", c.Environment)
+	_, err := zapcore.ParseLevel(c.LogLevel)
+	v.check(err == nil, "LOG_LEVEL", "must be debug, info, warn or error, got %q", c.LogLevel)
+	v.address("HTTP_PORT", c.HTTPPort)
+	v.address("METRICS_PORT", c.MetricsPort)
+	v.address("GRPC_USER_SERVICE_ADDR", c.GRPCUserServiceAddr)
+	for _, proxy := range c.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		v.check(cidrErr == nil || net.ParseIP(proxy) != nil, "TRUSTED_PROXIES", "must list IP addresses or CIDRs, got %q", proxy)
+	}
+	v.check(c.ReloadInterval >= 0, "CONFIG_RELOAD_INTERVAL", "must not be negative, got %s", c.ReloadInterval)
+	v.check(slices.Contains(tracing.Exporters, c.Tracing.Exporter), "TRACING_EXPORTER", "must be one of %s, got %q", strings.Join(tracing.Exporters, ", "), c.Tracing.Exporter)
+	v.check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "TRACING_SAMPLE_RATIO", "must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+
+	if c.UserServiceTLS.CertFile != "" || c.UserServiceTLS.KeyFile != "" || c.UserServiceTLS.CAFile != "" {
+		v.required("GRPC_USER_SERVICE_TLS_CERT_FILE", c.UserServiceTLS.CertFile)
+		v.required("GRPC_USER_SERVICE_TLS_KEY_FILE", c.UserServiceTLS.KeyFile)
+		v.required("GRPC_USER_SERVICE_TLS_CA_FILE", c.UserServiceTLS.CAFile)
+		v.positive("GRPC_USER_SERVICE_TLS_RELOAD_INTERVAL", c.UserServiceTLS.ReloadInterval)
+	}
+
+	v.positive("JWT_KEY_ROTATION", c.Keys.Rotation)
+	v.positive("JWT_KEY_OVERLAP", c.Keys.Overlap)
+	v.positive("JWKS_REFRESH", c.Keys.Refresh)
+	v.required("JWT_ISSUER", c.Keys.Issuer)
+	v.required("JWT_AUDIENCE", c.Keys.Audience)
+	for _, trusted := range c.Keys.Trusted {
+		v.check(trusted.Issuer != c.Keys.Issuer, "TRUSTED_ISSUERS", "must not list the gateway's own issuer %q", trusted.Issuer)
+		v.url("TRUSTED_ISSUERS", trusted.JWKSURL)
+	}
+
+	v.required("RABBITMQ_URL", c.RabbitMQ.URL)
+	v.required("RABBITMQ_QUEUE", c.RabbitMQ.Queue)
+	v.required("RABBITMQ_EXCHANGE", c.RabbitMQ.Exchange)
+	v.check(len(c.RabbitMQ.Tenants) > 0, "RABBITMQ_TENANTS", "is required")
+	for _, id := range c.RabbitMQ.Tenants {
+		v.check(tenant.Valid(id), "RABBITMQ_TENANTS", "invalid tenant %q", id)
+	}
+
+	v.check(tenant.Valid(c.Tenancy.Default), "DEFAULT_TENANT", "invalid tenant %q", c.Tenancy.Default)
+	v.required("TENANT_HEADER", c.Tenancy.Header)
+	for host, id := range c.Tenancy.Hosts {
+		v.check(tenant.Valid(id), "TENANT_HOSTS", "invalid tenant %q of host %q", id, host)
+	}
+
+	if len(c.Social.Providers) > 0 {
+		v.url("SOCIAL_BASE_URL", c.Social.BaseURL)
+	}
+	for _, p := range c.Social.Providers {
+		prefix := "SOCIAL_" + strings.ToUpper(strings.ReplaceAll(p.Name, "-", "_")) + "_"
+		v.required(prefix+"CLIENT_ID", p.ClientID)
+		v.check(p.Issuer != "" || p.AuthURL != "" && p.TokenURL != "" && p.UserInfoURL != "",
+			prefix+"ISSUER", "an issuer or the authorization, token and user info URLs are required")
+	}
+
+	if c.Environment == Production {
+		c.validateProduction(&v)
+	}
+	return errors.Join(v...)
+}
+
+// validateProduction refuses the defaults of the local development setup, which are
+// public, and settings that are only meant for it
+func (c *Config) validateProduction(v *validator) {
+	v.check(c.JWTSecret != defaultJWTSecret, "JWT_SECRET", "must not be the example secret in production")
+	v.check(c.JWTSecret == "" || c.JWTSecret == defaultJWTSecret || len(c.JWTSecret) >= minSecretLength, "JWT_SECRET", "must be at least %d bytes in production", minSecretLength)
+	v.check(c.Keys.Dir != "", "JWT_KEY_DIR", "is required in production; keys kept in memory are lost on restart")
+	if u, err := url.Parse(c.RabbitMQ.URL); err == nil && u.User != nil {
+		password, _ := u.User.Password()
+		v.check(u.User.Username() != "guest" || password != "guest", "RABBITMQ_URL", "must not use the default guest credentials in production")
+	}
+	if len(c.Social.Providers) > 0 {
+		v.check(len(c.Social.StateSecret) >= minSecretLength, "SOCIAL_STATE_SECRET", "must be at least %d bytes in production", minSecretLength)
+		v.check(strings.HasPrefix(c.Social.BaseURL, "https://"), "SOCIAL_BASE_URL", "must be an https URL in production")
+	}
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Watch loads the configuration again, with the same args and options as cfg was loaded
+// with, whenever the process receives SIGHUP or cfg.File changes, until ctx is done. Each
+// valid configuration is passed to apply; the error of an invalid one is passed to fail
+// and the running configuration is kept. Only settings that are safe to change on the fly,
+// such as the log level, should be taken from it by apply.
+func Watch(ctx context.Context, cfg *Config, args []string, apply func(*Config), fail func(error), options ...Option) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if cfg.File != "" && cfg.ReloadInterval > 0 {
+		ticker := time.NewTicker(cfg.ReloadInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	last := modTime(cfg.File)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-tick:
+			mtime := modTime(cfg.File)
+			if mtime.Equal(last) {
+				continue
+			}
+			last = mtime
+		}
+		next, err := Load(args, options...)
+		if err != nil {
+			fail(err)
+			continue
+		}
+		apply(next)
+	}
+}
+
+// modTime returns when a file was last modified, or the zero time when it cannot be read
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -2,10 +2,17 @@ package grpc
 
 import (
 	"context"
+	"crypto/tls"
 	"log"
 
 	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/asadlive84/shopper/api-gateway/internal/clientip"
+	"github.com/asadlive84/shopper/api-gateway/internal/idempotency"
+	"github.com/asadlive84/shopper/api-gateway/internal/requestid"
+	"github.com/asadlive84/shopper/api-gateway/internal/tenant"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
@@ -13,10 +20,19 @@ type UserGRPCClient struct {
 	client pb.UserServiceClient
 }
 
-func NewUserGRPCClient(addr string) *UserGRPCClient {
+// NewUserGRPCClient connects to user-svc at addr, with mutual TLS when tlsConfig is set
+// and in plaintext otherwise. Every call gets a client span whose trace context is sent
+// along in the call's metadata.
+func NewUserGRPCClient(addr string, tlsConfig *tls.Config) *UserGRPCClient {
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
 	var opts []grpc.DialOption
 	opts = append(opts,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(tenant.UnaryClientInterceptor(), idempotency.UnaryClientInterceptor(), requestid.UnaryClientInterceptor(), clientip.UnaryClientInterceptor()),
 	)
 	conn, err := grpc.NewClient(addr, opts...)
 	if err != nil {
@@ -37,6 +53,10 @@ func (c *UserGRPCClient) AuthenticateUser(ctx context.Context, req *pb.Authentic
 	return c.client.AuthenticateUser(ctx, req)
 }
 
+func (c *UserGRPCClient) GetSession(ctx context.Context, req *pb.GetSessionRequest) (*pb.GetSessionResponse, error) {
+	return c.client.GetSession(ctx, req)
+}
+
 func (c *UserGRPCClient) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
 	return c.client.ListUsers(ctx, req)
 }
@@ -44,3 +64,163 @@ func (c *UserGRPCClient) ListUsers(ctx context.Context, req *pb.ListUsersRequest
 func (c *UserGRPCClient) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error) {
 	return c.client.UpdateUser(ctx, req)
 }
+
+func (c *UserGRPCClient) ExportMyData(ctx context.Context, req *pb.ExportMyDataRequest) (*pb.ExportMyDataResponse, error) {
+	return c.client.ExportMyData(ctx, req)
+}
+
+func (c *UserGRPCClient) RequestErasure(ctx context.Context, req *pb.RequestErasureRequest) (*pb.RequestErasureResponse, error) {
+	return c.client.RequestErasure(ctx, req)
+}
+
+func (c *UserGRPCClient) GetErasureRequest(ctx context.Context, req *pb.GetErasureRequestRequest) (*pb.GetErasureRequestResponse, error) {
+	return c.client.GetErasureRequest(ctx, req)
+}
+
+func (c *UserGRPCClient) CreateOrganization(ctx context.Context, req *pb.CreateOrganizationRequest) (*pb.CreateOrganizationResponse, error) {
+	return c.client.CreateOrganization(ctx, req)
+}
+
+func (c *UserGRPCClient) GetOrganization(ctx context.Context, req *pb.GetOrganizationRequest) (*pb.GetOrganizationResponse, error) {
+	return c.client.GetOrganization(ctx, req)
+}
+
+func (c *UserGRPCClient) ListMyOrganizations(ctx context.Context, req *pb.ListMyOrganizationsRequest) (*pb.ListMyOrganizationsResponse, error) {
+	return c.client.ListMyOrganizations(ctx, req)
+}
+
+func (c *UserGRPCClient) ListOrganizationMembers(ctx context.Context, req *pb.ListOrganizationMembersRequest) (*pb.ListOrganizationMembersResponse, error) {
+	return c.client.ListOrganizationMembers(ctx, req)
+}
+
+func (c *UserGRPCClient) UpdateOrganizationMember(ctx context.Context, req *pb.UpdateOrganizationMemberRequest) (*pb.UpdateOrganizationMemberResponse, error) {
+	return c.client.UpdateOrganizationMember(ctx, req)
+}
+
+func (c *UserGRPCClient) RemoveOrganizationMember(ctx context.Context, req *pb.RemoveOrganizationMemberRequest) (*pb.RemoveOrganizationMemberResponse, error) {
+	return c.client.RemoveOrganizationMember(ctx, req)
+}
+
+func (c *UserGRPCClient) InviteOrganizationMember(ctx context.Context, req *pb.InviteOrganizationMemberRequest) (*pb.InviteOrganizationMemberResponse, error) {
+	return c.client.InviteOrganizationMember(ctx, req)
+}
+
+func (c *UserGRPCClient) ListOrganizationInvitations(ctx context.Context, req *pb.ListOrganizationInvitationsRequest) (*pb.ListOrganizationInvitationsResponse, error) {
+	return c.client.ListOrganizationInvitations(ctx, req)
+}
+
+func (c *UserGRPCClient) RevokeOrganizationInvitation(ctx context.Context, req *pb.RevokeOrganizationInvitationRequest) (*pb.RevokeOrganizationInvitationResponse, error) {
+	return c.client.RevokeOrganizationInvitation(ctx, req)
+}
+
+func (c *UserGRPCClient) ListMyInvitations(ctx context.Context, req *pb.ListMyInvitationsRequest) (*pb.ListMyInvitationsResponse, error) {
+	return c.client.ListMyInvitations(ctx, req)
+}
+
+func (c *UserGRPCClient) AcceptOrganizationInvitation(ctx context.Context, req *pb.AcceptOrganizationInvitationRequest) (*pb.AcceptOrganizationInvitationResponse, error) {
+	return c.client.AcceptOrganizationInvitation(ctx, req)
+}
+
+func (c *UserGRPCClient) CreateApiKey(ctx context.Context, req *pb.CreateApiKeyRequest) (*pb.CreateApiKeyResponse, error) {
+	return c.client.CreateApiKey(ctx, req)
+}
+
+func (c *UserGRPCClient) ListApiKeys(ctx context.Context, req *pb.ListApiKeysRequest) (*pb.ListApiKeysResponse, error) {
+	return c.client.ListApiKeys(ctx, req)
+}
+
+func (c *UserGRPCClient) GetApiKey(ctx context.Context, req *pb.GetApiKeyRequest) (*pb.GetApiKeyResponse, error) {
+	return c.client.GetApiKey(ctx, req)
+}
+
+func (c *UserGRPCClient) UpdateApiKey(ctx context.Context, req *pb.UpdateApiKeyRequest) (*pb.UpdateApiKeyResponse, error) {
+	return c.client.UpdateApiKey(ctx, req)
+}
+
+func (c *UserGRPCClient) RevokeApiKey(ctx context.Context, req *pb.RevokeApiKeyRequest) (*pb.RevokeApiKeyResponse, error) {
+	return c.client.RevokeApiKey(ctx, req)
+}
+
+func (c *UserGRPCClient) AuthenticateApiKey(ctx context.Context, req *pb.AuthenticateApiKeyRequest) (*pb.AuthenticateApiKeyResponse, error) {
+	return c.client.AuthenticateApiKey(ctx, req)
+}
+
+func (c *UserGRPCClient) LinkExternalIdentity(ctx context.Context, req *pb.LinkExternalIdentityRequest) (*pb.LinkExternalIdentityResponse, error) {
+	return c.client.LinkExternalIdentity(ctx, req)
+}
+
+func (c *UserGRPCClient) RequestLoginLink(ctx context.Context, req *pb.RequestLoginLinkRequest) (*pb.RequestLoginLinkResponse, error) {
+	return c.client.RequestLoginLink(ctx, req)
+}
+
+func (c *UserGRPCClient) RedeemLoginLink(ctx context.Context, req *pb.RedeemLoginLinkRequest) (*pb.RedeemLoginLinkResponse, error) {
+	return c.client.RedeemLoginLink(ctx, req)
+}
+
+func (c *UserGRPCClient) BeginPasskeyRegistration(ctx context.Context, req *pb.BeginPasskeyRegistrationRequest) (*pb.PasskeyChallengeResponse, error) {
+	return c.client.BeginPasskeyRegistration(ctx, req)
+}
+
+func (c *UserGRPCClient) FinishPasskeyRegistration(ctx context.Context, req *pb.FinishPasskeyRegistrationRequest) (*pb.FinishPasskeyRegistrationResponse, error) {
+	return c.client.FinishPasskeyRegistration(ctx, req)
+}
+
+func (c *UserGRPCClient) BeginPasskeyLogin(ctx context.Context, req *pb.BeginPasskeyLoginRequest) (*pb.PasskeyChallengeResponse, error) {
+	return c.client.BeginPasskeyLogin(ctx, req)
+}
+
+func (c *UserGRPCClient) FinishPasskeyLogin(ctx context.Context, req *pb.FinishPasskeyLoginRequest) (*pb.FinishPasskeyLoginResponse, error) {
+	return c.client.FinishPasskeyLogin(ctx, req)
+}
+
+func (c *UserGRPCClient) ListPasskeys(ctx context.Context, req *pb.ListPasskeysRequest) (*pb.ListPasskeysResponse, error) {
+	return c.client.ListPasskeys(ctx, req)
+}
+
+func (c *UserGRPCClient) DeletePasskey(ctx context.Context, req *pb.DeletePasskeyRequest) (*pb.DeletePasskeyResponse, error) {
+	return c.client.DeletePasskey(ctx, req)
+}
+
+func (c *UserGRPCClient) SetTwoFactor(ctx context.Context, req *pb.SetTwoFactorRequest) (*pb.SetTwoFactorResponse, error) {
+	return c.client.SetTwoFactor(ctx, req)
+}
+
+func (c *UserGRPCClient) InviteUser(ctx context.Context, req *pb.InviteUserRequest) (*pb.InviteUserResponse, error) {
+	return c.client.InviteUser(ctx, req)
+}
+
+func (c *UserGRPCClient) ListUserInvites(ctx context.Context, req *pb.ListUserInvitesRequest) (*pb.ListUserInvitesResponse, error) {
+	return c.client.ListUserInvites(ctx, req)
+}
+
+func (c *UserGRPCClient) ResendUserInvite(ctx context.Context, req *pb.ResendUserInviteRequest) (*pb.ResendUserInviteResponse, error) {
+	return c.client.ResendUserInvite(ctx, req)
+}
+
+func (c *UserGRPCClient) RevokeUserInvite(ctx context.Context, req *pb.RevokeUserInviteRequest) (*pb.RevokeUserInviteResponse, error) {
+	return c.client.RevokeUserInvite(ctx, req)
+}
+
+func (c *UserGRPCClient) AcceptUserInvite(ctx context.Context, req *pb.AcceptUserInviteRequest) (*pb.AcceptUserInviteResponse, error) {
+	return c.client.AcceptUserInvite(ctx, req)
+}
+
+func (c *UserGRPCClient) Impersonate(ctx context.Context, req *pb.ImpersonateRequest) (*pb.ImpersonateResponse, error) {
+	return c.client.Impersonate(ctx, req)
+}
+
+func (c *UserGRPCClient) GetImpersonation(ctx context.Context, req *pb.GetImpersonationRequest) (*pb.GetImpersonationResponse, error) {
+	return c.client.GetImpersonation(ctx, req)
+}
+
+func (c *UserGRPCClient) EndImpersonation(ctx context.Context, req *pb.EndImpersonationRequest) (*pb.EndImpersonationResponse, error) {
+	return c.client.EndImpersonation(ctx, req)
+}
+
+func (c *UserGRPCClient) RequestEmailChange(ctx context.Context, req *pb.RequestEmailChangeRequest) (*pb.RequestEmailChangeResponse, error) {
+	return c.client.RequestEmailChange(ctx, req)
+}
+
+func (c *UserGRPCClient) ConfirmEmailChange(ctx context.Context, req *pb.ConfirmEmailChangeRequest) (*pb.ConfirmEmailChangeResponse, error) {
+	return c.client.ConfirmEmailChange(ctx, req)
+}
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Scopes an API key can be granted; they match the scopes user-svc accepts
+const (
+	scopeUsersRead  = "users:read"
+	scopeUsersWrite = "users:write"
+	scopeOrgsRead   = "orgs:read"
+	scopeOrgsWrite  = "orgs:write"
+	scopeDataExport = "data:export"
+)
+
+// CreateApiKey creates an API key for the caller, e.g.
+// {"name": "ci", "scopes": ["users:read"], "expires_at": "2026-01-01T00:00:00Z", "organization_id": "..."}.
+// The secret is only returned in this response.
+func (h *Handler) CreateApiKey(c *gin.Context) {
+	var body struct {
+		Name           string     `json:"name" binding:"required"`
+		Scopes         []string   `json:"scopes" binding:"required"`
+		ExpiresAt      *time.Time `json:"expires_at"`
+		OrganizationID string     `json:"organization_id"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.log(c).Error("Failed to parse create API key request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req := &pb.CreateApiKeyRequest{
+		UserId:         currentUserID(c),
+		Name:           body.Name,
+		Scopes:         body.Scopes,
+		OrganizationId: body.OrganizationID,
+	}
+	if body.ExpiresAt != nil {
+		req.ExpiresAt = timestamppb.New(*body.ExpiresAt)
+	}
+	res, err := h.apiService.CreateApiKey(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"api_key": res.GetApiKey(), "secret": res.GetSecret()})
+}
+
+func (h *Handler) ListApiKeys(c *gin.Context) {
+	keys, err := h.apiService.ListApiKeys(c.Request.Context(), currentUserID(c))
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+func (h *Handler) GetApiKey(c *gin.Context) {
+	key, err := h.apiService.GetApiKey(c.Request.Context(), currentUserID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+// UpdateApiKey renames a key or replaces its scopes, e.g. {"scopes": ["users:read"]}
+func (h *Handler) UpdateApiKey(c *gin.Context) {
+	var body struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.log(c).Error("Failed to parse update API key request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, err := h.apiService.UpdateApiKey(c.Request.Context(), &pb.UpdateApiKeyRequest{
+		UserId: currentUserID(c),
+		Id:     c.Param("id"),
+		Name:   body.Name,
+		Scopes: body.Scopes,
+	})
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+func (h *Handler) RevokeApiKey(c *gin.Context) {
+	if err := h.apiService.RevokeApiKey(c.Request.Context(), currentUserID(c), c.Param("id")); err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"net/http"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RequestEmailChange starts changing the caller's email address, e.g.
+// {"password": "...", "new_email": "jane@example.org"}. The address changes once the
+// link mailed to the new address is followed; the old address is told about it.
+func (h *Handler) RequestEmailChange(c *gin.Context) {
+	var body struct {
+		Password string `json:"password" binding:"required"`
+		NewEmail string `json:"new_email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.log(c).Error("Failed to parse email change request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := h.apiService.RequestEmailChange(c.Request.Context(), &pb.RequestEmailChangeRequest{
+		UserId:   currentUserID(c),
+		Password: body.Password,
+		NewEmail: body.NewEmail,
+	})
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    "A confirmation link has been sent to the new address",
+		"expires_at": res.GetExpiresAt().AsTime(),
+	})
+}
+
+// ConfirmEmailChange confirms an email change with the token mailed to the new address,
+// e.g. {"token": "..."}. The user must log in again with the new address.
+func (h *Handler) ConfirmEmailChange(c *gin.Context) {
+	var body struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.log(c).Error("Failed to parse email change confirmation", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := h.apiService.ConfirmEmailChange(tokenContext(c, body.Token), body.Token)
+	if err != nil {
+		h.log(c).Warn("Email change confirmation failed", zap.Error(err))
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
@@ -0,0 +1,30 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/asadlive84/shopper/api-gateway/internal/application/core/api"
+)
+
+// statusFor maps an error returned by the API service onto an HTTP status code
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, api.ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.Is(err, api.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, api.ErrAlreadyExists), errors.Is(err, api.ErrFailedPrecondition), errors.Is(err, api.ErrAborted):
+		return http.StatusConflict
+	case errors.Is(err, api.ErrUnauthenticated), errors.Is(err, api.ErrSecondFactorRequired):
+		return http.StatusUnauthorized
+	case errors.Is(err, api.ErrPermissionDenied):
+		return http.StatusForbidden
+	case errors.Is(err, api.ErrResourceExhausted):
+		return http.StatusTooManyRequests
+	case errors.Is(err, api.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -1,73 +1,130 @@
 package http
 
 import (
-	"bytes"
-	"encoding/json"
 	"net/http"
-	"net/url"
 
 	pb "github.com/asadlive84/shopper-proto/golang/user"
 	"github.com/asadlive84/shopper/api-gateway/internal/adapters/grpc"
 	"github.com/asadlive84/shopper/api-gateway/internal/event"
+	"github.com/asadlive84/shopper/api-gateway/internal/keys"
 	"github.com/asadlive84/shopper/api-gateway/internal/ports"
 	"github.com/asadlive84/shopper/api-gateway/internal/rabbitmq"
+	"github.com/asadlive84/shopper/api-gateway/internal/requestid"
+	"github.com/asadlive84/shopper/api-gateway/internal/social"
+	"github.com/asadlive84/shopper/api-gateway/internal/tenant"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 
 	"go.uber.org/zap"
 )
 
-type HydraClient struct {
-	logger     *zap.Logger
-	httpClient *http.Client
-	adminURL   string
-	publicURL  string
-}
-
 type Handler struct {
 	apiService    ports.APIPort
-	jwtSecret     string
+	signingKeys   *keys.Manager
+	verifier      *keys.Verifier
+	tenants       *tenant.Resolver
+	social        *social.RelyingParty
 	logger        *zap.Logger
 	rabbitClient  *rabbitmq.Client
-	hydra         *HydraClient
 	websocketConn *websocket.Conn
 	eventManager  *event.EventManager
 }
 
-func NewHydraClient(logger *zap.Logger) *HydraClient {
-	return &HydraClient{
-		logger:     logger,
-		httpClient: &http.Client{},
-		adminURL:   "http://127.0.0.1:4445",
-		publicURL:  "http://127.0.0.1:4444",
-	}
-}
-
-func NewHandler(apiService ports.APIPort, jwtSecret string, logger *zap.Logger, rabbitClient *rabbitmq.Client, grpcClient *grpc.UserGRPCClient) *Handler {
+func NewHandler(apiService ports.APIPort, signingKeys *keys.Manager, verifier *keys.Verifier, tenants *tenant.Resolver, socialLogin *social.RelyingParty, logger *zap.Logger, rabbitClient *rabbitmq.Client, grpcClient *grpc.UserGRPCClient) *Handler {
 	return &Handler{
 		apiService:   apiService,
-		jwtSecret:    jwtSecret,
+		signingKeys:  signingKeys,
+		verifier:     verifier,
+		tenants:      tenants,
+		social:       socialLogin,
 		logger:       logger,
 		rabbitClient: rabbitClient,
-		hydra:        NewHydraClient(logger),
 		eventManager: event.NewEventManager(logger, rabbitClient, grpcClient),
 	}
 }
 
+// log returns the handler's logger annotated with the ID of the request being served
+func (h *Handler) log(c *gin.Context) *zap.Logger {
+	return requestid.Logger(c.Request.Context(), h.logger)
+}
+
 func (h *Handler) SetupRoutes(r *gin.Engine) {
-	r.Use(LoggerMiddleware(h.logger), TracingMiddleware(), PrometheusMiddleware())
+	r.Use(RequestIDMiddleware(), LoggerMiddleware(h.logger), RecoveryMiddleware(h.logger))
+	r.Use(TracingMiddleware(), PrometheusMiddleware())
 	r.Use(CORSMiddleware())
+	r.Use(TenantMiddleware(h.tenants, h.logger))
+	r.Use(IdempotencyMiddleware(h.logger))
 
+	r.GET("/.well-known/jwks.json", h.JWKS)
 	r.POST("/login", h.Login)
-	// r.GET("/callback", h.Callback)
-
-	r.POST("/user", h.CreateUser)
+	r.POST("/login/link", h.RequestLoginLink)
+	r.GET("/login/link", h.RedeemLoginLink)
+	r.POST("/login/passkey/begin", h.BeginPasskeyLogin)
+	r.POST("/login/passkey/finish", h.FinishPasskeyLogin)
 
-	protected := r.Group("/", JWTAuthMiddleware(h.jwtSecret, h.logger))
-	protected.GET("/user/:id", h.GetUser)
+	// Logging in with external identity providers
+	r.GET("/auth/providers", h.ListSocialProviders)
+	r.GET("/auth/:provider/login", h.SocialLogin)
+	r.GET("/auth/:provider/callback", h.SocialCallback)
 
-	protected.GET("/users", h.ListUsers)
-	protected.PUT("/user/:id", h.UpdateUser)
+	r.POST("/user", h.CreateUser)
+	r.POST("/invites/accept", h.AcceptUserInvite)
+	r.POST("/email/confirm", h.ConfirmEmailChange)
+
+	protected := r.Group("/", JWTAuthMiddleware(h.verifier, h.apiService, h.logger))
+	usersRead, usersWrite := RequireScope(scopeUsersRead), RequireScope(scopeUsersWrite)
+	orgsRead, orgsWrite := RequireScope(scopeOrgsRead), RequireScope(scopeOrgsWrite)
+	interactive := InteractiveOnly()
+	// Operations only the account owner may perform are closed to support users impersonating them
+	sensitive := NotImpersonated()
+
+	protected.GET("/user/:id", usersRead, h.GetUser)
+
+	protected.GET("/users", usersRead, h.ListUsers)
+	protected.PUT("/user/:id", usersWrite, h.UpdateUser)
+
+	// Inviting users is limited to admins by user-svc
+	protected.POST("/invites", usersWrite, sensitive, h.InviteUser)
+	protected.GET("/invites", usersRead, sensitive, h.ListUserInvites)
+	protected.POST("/invites/:id/resend", usersWrite, sensitive, h.ResendUserInvite)
+	protected.DELETE("/invites/:id", usersWrite, sensitive, h.RevokeUserInvite)
+
+	// Support users act as a customer with a short-lived token; ending it is done with that token
+	protected.POST("/users/:id/impersonate", interactive, sensitive, h.Impersonate)
+	protected.POST("/impersonation/end", h.EndImpersonation)
+
+	protected.GET("/me/export", RequireScope(scopeDataExport), sensitive, h.ExportMyData)
+	protected.POST("/me/erasure", interactive, sensitive, h.RequestErasure)
+	protected.GET("/me/erasure/:id", interactive, sensitive, h.GetErasureRequest)
+
+	protected.POST("/orgs", orgsWrite, h.CreateOrganization)
+	protected.GET("/orgs", orgsRead, h.ListMyOrganizations)
+	protected.GET("/orgs/:id", orgsRead, h.GetOrganization)
+	protected.GET("/orgs/:id/members", orgsRead, h.ListOrganizationMembers)
+	protected.PUT("/orgs/:id/members/:user_id", orgsWrite, h.UpdateOrganizationMember)
+	protected.DELETE("/orgs/:id/members/:user_id", orgsWrite, h.RemoveOrganizationMember)
+	protected.POST("/orgs/:id/invitations", orgsWrite, h.InviteOrganizationMember)
+	protected.GET("/orgs/:id/invitations", orgsRead, h.ListOrganizationInvitations)
+	protected.DELETE("/orgs/:id/invitations/:invitation_id", orgsWrite, h.RevokeOrganizationInvitation)
+	protected.GET("/me/invitations", orgsRead, h.ListMyInvitations)
+	protected.POST("/me/invitations/:id/accept", interactive, sensitive, h.AcceptOrganizationInvitation)
+	protected.PUT("/me/organization", interactive, sensitive, h.SwitchOrganization)
+
+	// API keys are managed by their owner only; a key cannot mint or inspect keys
+	protected.POST("/me/api-keys", interactive, sensitive, h.CreateApiKey)
+	protected.GET("/me/api-keys", interactive, sensitive, h.ListApiKeys)
+	protected.GET("/me/api-keys/:id", interactive, sensitive, h.GetApiKey)
+	protected.PUT("/me/api-keys/:id", interactive, sensitive, h.UpdateApiKey)
+	protected.DELETE("/me/api-keys/:id", interactive, sensitive, h.RevokeApiKey)
+
+	protected.POST("/me/identities/:provider", interactive, sensitive, h.LinkSocialIdentity)
+
+	protected.POST("/me/passkeys/begin", interactive, sensitive, h.BeginPasskeyRegistration)
+	protected.POST("/me/passkeys/finish", interactive, sensitive, h.FinishPasskeyRegistration)
+	protected.GET("/me/passkeys", interactive, sensitive, h.ListPasskeys)
+	protected.DELETE("/me/passkeys/:id", interactive, sensitive, h.DeletePasskey)
+	protected.PUT("/me/two-factor", interactive, sensitive, h.SetTwoFactor)
+	protected.POST("/me/email", interactive, sensitive, h.RequestEmailChange)
 
 	r.GET("/ws", CORSMiddleware(), h.WebSocketHandler)
 
@@ -76,84 +133,37 @@ func (h *Handler) SetupRoutes(r *gin.Engine) {
 func (h *Handler) Login(c *gin.Context) {
 	var req pb.AuthenticateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.Error("Failed to parse login request", zap.Error(err))
+		h.log(c).Error("Failed to parse login request", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	res, err := h.apiService.AuthenticateUser(c.Request.Context(), &req)
+	if respondSecondFactor(c, err) {
+		return
+	}
 	if err != nil {
-		h.logger.Warn("Authentication failed", zap.String("email", req.GetEmail()), zap.Error(err))
+		h.log(c).Warn("Authentication failed", zap.String("email", req.GetEmail()), zap.Error(err))
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 	msg := "User logged in: " + req.GetEmail()
 	if err := h.rabbitClient.Publish(c.Request.Context(), "user_exchange", msg); err != nil {
-		h.logger.Error("Failed to publish to RabbitMQ", zap.Error(err))
+		h.log(c).Error("Failed to publish to RabbitMQ", zap.Error(err))
 	}
 	c.JSON(http.StatusOK, gin.H{"token": res.GetToken(), "user": res.GetUser()})
 }
 
-func (h *Handler) Callback(c *gin.Context) {
-	code := c.Query("code")
-	data := url.Values{}
-	data.Set("grant_type", "authorization_code")
-	data.Set("code", code)
-	data.Set("redirect_uri", "http://127.0.0.1:8080/callback")
-	data.Set("client_id", "your-client-id")
-	data.Set("client_secret", "your-client-secret")
-
-	req, _ := http.NewRequest("POST", h.hydra.publicURL+"/oauth2/token", bytes.NewBufferString(data.Encode()))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	resp, err := h.hydra.httpClient.Do(req)
-	if err != nil {
-		h.logger.Error("Failed to get token", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	defer resp.Body.Close()
-
-	var tokenResp struct {
-		AccessToken string `json:"access_token"`
-	}
-	json.NewDecoder(resp.Body).Decode(&tokenResp)
-	c.SetCookie("access_token", tokenResp.AccessToken, 3600, "/", "localhost", false, true)
-	c.Redirect(http.StatusFound, "/user")
-}
-
-func (h *Handler) AuthMiddleware(c *gin.Context) {
-	token, err := c.Cookie("access_token")
-	if err != nil {
-		c.Redirect(http.StatusFound, "/login")
-		return
-	}
-	data := url.Values{}
-	data.Set("token", token)
-	req, _ := http.NewRequest("POST", h.hydra.adminURL+"/admin/oauth2/introspect", bytes.NewBufferString(data.Encode()))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	resp, err := h.hydra.httpClient.Do(req)
-	if err != nil {
-		h.logger.Error("Failed to introspect token", zap.Error(err))
-		c.Redirect(http.StatusFound, "/login")
-		return
-	}
-	defer resp.Body.Close()
-
-	var introspectResp struct {
-		Active bool `json:"active"`
-	}
-	json.NewDecoder(resp.Body).Decode(&introspectResp)
-	if !introspectResp.Active {
-		c.Redirect(http.StatusFound, "/login")
-		return
-	}
-	c.Next()
+// JWKS publishes the public keys access tokens issued by the gateway are signed with
+func (h *Handler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.signingKeys.JWKS())
 }
 
 func (h *Handler) GetUser(c *gin.Context) {
 	id := c.Param("id")
 	user, err := h.apiService.GetUser(c.Request.Context(), id)
 	if err != nil {
-		h.logger.Error("Failed to get user", zap.String("id", id), zap.Error(err))
+		h.log(c).Error("Failed to get user", zap.String("id", id), zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -163,14 +173,14 @@ func (h *Handler) GetUser(c *gin.Context) {
 func (h *Handler) CreateUser(c *gin.Context) {
 	var req pb.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.Error("Failed to parse create user request", zap.Error(err))
+		h.log(c).Error("Failed to parse create user request", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	user, err := h.apiService.CreateUser(c.Request.Context(), &req)
 	if err != nil {
-		h.logger.Error("Failed to create user", zap.String("email", req.GetEmail()), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.log(c).Error("Failed to create user", zap.String("email", req.GetEmail()), zap.Error(err))
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusCreated, user)
@@ -179,13 +189,13 @@ func (h *Handler) CreateUser(c *gin.Context) {
 func (h *Handler) ListUsers(c *gin.Context) {
 	var req pb.ListUsersRequest
 	if err := c.ShouldBindQuery(&req); err != nil {
-		h.logger.Error("Failed to parse list users query", zap.Error(err))
+		h.log(c).Error("Failed to parse list users query", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	res, err := h.apiService.ListUsers(c.Request.Context(), &req)
 	if err != nil {
-		h.logger.Error("Failed to list users", zap.Error(err))
+		h.log(c).Error("Failed to list users", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -196,14 +206,14 @@ func (h *Handler) UpdateUser(c *gin.Context) {
 	id := c.Param("id")
 	var req pb.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.Error("Failed to parse update user request", zap.Error(err))
+		h.log(c).Error("Failed to parse update user request", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	req.Id = id
 	user, err := h.apiService.UpdateUser(c.Request.Context(), &req)
 	if err != nil {
-		h.logger.Error("Failed to update user", zap.String("id", id), zap.Error(err))
+		h.log(c).Error("Failed to update user", zap.String("id", id), zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/asadlive84/shopper/api-gateway/internal/application/core/api"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Impersonate lets a support user with the users:impersonate permission act as the
+// user :id for a short time, e.g. {"reason": "Ticket #1234: checkout fails"}. The
+// returned token is used in place of the caller's own until it expires or is ended.
+func (h *Handler) Impersonate(c *gin.Context) {
+	var body struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.log(c).Error("Failed to parse impersonate request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	token, res, err := h.apiService.Impersonate(c.Request.Context(), currentUserID(c), c.Param("id"), body.Reason)
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	h.log(c).Info("Impersonation started",
+		zap.String("impersonator_id", currentUserID(c)),
+		zap.String("user_id", res.GetUser().GetId()),
+		zap.String("impersonation_id", res.GetImpersonation().GetId()))
+	c.JSON(http.StatusCreated, gin.H{
+		"token":         token,
+		"user":          res.GetUser(),
+		"impersonation": res.GetImpersonation(),
+	})
+}
+
+// EndImpersonation ends the impersonation the caller's token was issued for; the
+// token stops working immediately
+func (h *Handler) EndImpersonation(c *gin.Context) {
+	actor, id := impersonatorID(c), claimString(c, api.ImpersonationClaim)
+	if actor == "" || id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not impersonating a user"})
+		return
+	}
+	imp, err := h.apiService.EndImpersonation(c.Request.Context(), actor, id)
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	h.log(c).Info("Impersonation ended",
+		zap.String("impersonator_id", actor),
+		zap.String("user_id", imp.GetUserId()),
+		zap.String("impersonation_id", id))
+	c.JSON(http.StatusOK, gin.H{"impersonation": imp})
+}
@@ -0,0 +1,103 @@
+package http
+
+import (
+	"net/http"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/asadlive84/shopper/api-gateway/internal/application/core/api"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// InviteUser invites someone to create an account with a pre-assigned role, e.g.
+// {"email": "jane@example.com", "name": "Jane", "role": "moderator"}. Admins only.
+func (h *Handler) InviteUser(c *gin.Context) {
+	var body struct {
+		Email string `json:"email" binding:"required,email"`
+		Name  string `json:"name"`
+		Role  string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.log(c).Error("Failed to parse invite request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	role := pb.Role_ROLE_USER
+	if body.Role != "" {
+		if role = api.ParseRole(body.Role); role == pb.Role_ROLE_UNSPECIFIED {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role must be one of admin, user, moderator"})
+			return
+		}
+	}
+
+	invite, err := h.apiService.InviteUser(c.Request.Context(), &pb.InviteUserRequest{
+		ActorId: currentUserID(c),
+		Email:   body.Email,
+		Name:    body.Name,
+		Role:    role,
+	})
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, invite)
+}
+
+// ListUserInvites lists the invites that were neither accepted nor revoked. Admins only.
+func (h *Handler) ListUserInvites(c *gin.Context) {
+	invites, err := h.apiService.ListUserInvites(c.Request.Context(), currentUserID(c))
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"invites": invites})
+}
+
+// ResendUserInvite mails an invite again; the link in the earlier mail stops working. Admins only.
+func (h *Handler) ResendUserInvite(c *gin.Context) {
+	invite, err := h.apiService.ResendUserInvite(c.Request.Context(), currentUserID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, invite)
+}
+
+// RevokeUserInvite revokes an invite and deletes its pending account. Admins only.
+func (h *Handler) RevokeUserInvite(c *gin.Context) {
+	if err := h.apiService.RevokeUserInvite(c.Request.Context(), currentUserID(c), c.Param("id")); err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AcceptUserInvite activates an invited account with the invitee's own password, e.g.
+// {"token": "...", "password": "...", "name": "Jane"}, and responds like Login
+func (h *Handler) AcceptUserInvite(c *gin.Context) {
+	var body struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		Name     string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.log(c).Error("Failed to parse accept invite request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := h.apiService.AcceptUserInvite(tokenContext(c, body.Token), &pb.AcceptUserInviteRequest{
+		Token:    body.Token,
+		Password: body.Password,
+		Name:     body.Name,
+	})
+	if err != nil {
+		h.log(c).Warn("Accepting invite failed", zap.Error(err))
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	msg := "User logged in: " + res.GetUser().GetEmail()
+	if err := h.rabbitClient.Publish(c.Request.Context(), "user_exchange", msg); err != nil {
+		h.log(c).Error("Failed to publish to RabbitMQ", zap.Error(err))
+	}
+	c.JSON(http.StatusOK, gin.H{"token": res.GetToken(), "user": res.GetUser()})
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// loginDeviceCookie holds the secret login links are bound to. It identifies the browser
+// that asked for a link, so the link only logs in from there.
+const (
+	loginDeviceCookie = "login_device"
+	loginDeviceMaxAge = 365 * 24 * time.Hour
+)
+
+// RequestLoginLink has a passwordless login link mailed, e.g. {"email": "jane@example.com"}.
+// The answer is the same whether or not the address has an account.
+func (h *Handler) RequestLoginLink(c *gin.Context) {
+	var body struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.log(c).Error("Failed to parse login link request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	device, err := c.Cookie(loginDeviceCookie)
+	if err != nil || device == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			h.log(c).Error("Failed to generate login device secret", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		device = base64.RawURLEncoding.EncodeToString(b)
+	}
+	// Set on every request so a device that keeps using links keeps its secret
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     loginDeviceCookie,
+		Value:    device,
+		Path:     "/login/link",
+		MaxAge:   int(loginDeviceMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   secureRequest(c),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	res, err := h.apiService.RequestLoginLink(c.Request.Context(), body.Email, device)
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"expires_at": res.GetExpiresAt().AsTime()})
+}
+
+// RedeemLoginLink logs in with the token of a mailed login link and responds like Login
+func (h *Handler) RedeemLoginLink(c *gin.Context) {
+	token := c.Query("token")
+	device, _ := c.Cookie(loginDeviceCookie)
+	if token == "" || device == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Open the login link in the browser you requested it from"})
+		return
+	}
+	res, err := h.apiService.RedeemLoginLink(tokenContext(c, token), token, device)
+	if respondSecondFactor(c, err) {
+		return
+	}
+	if err != nil {
+		h.log(c).Warn("Login link redemption failed", zap.Error(err))
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	msg := "User logged in: " + res.GetUser().GetEmail()
+	if err := h.rabbitClient.Publish(c.Request.Context(), "user_exchange", msg); err != nil {
+		h.log(c).Error("Failed to publish to RabbitMQ", zap.Error(err))
+	}
+	c.JSON(http.StatusOK, gin.H{"token": res.GetToken(), "user": res.GetUser()})
+}
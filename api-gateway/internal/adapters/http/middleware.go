@@ -1,14 +1,26 @@
 package http
 
 import (
+	"context"
+	"errors"
+	"github.com/asadlive84/shopper/api-gateway/internal/application/core/api"
+	"github.com/asadlive84/shopper/api-gateway/internal/clientip"
+	"github.com/asadlive84/shopper/api-gateway/internal/idempotency"
+	"github.com/asadlive84/shopper/api-gateway/internal/keys"
 	"github.com/asadlive84/shopper/api-gateway/internal/monitoring"
+	"github.com/asadlive84/shopper/api-gateway/internal/ports"
+	"github.com/asadlive84/shopper/api-gateway/internal/requestid"
+	"github.com/asadlive84/shopper/api-gateway/internal/tenant"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"net/http"
+	"runtime/debug"
+	"slices"
 	"strings"
 	"time"
 
@@ -41,12 +53,20 @@ func LoggerMiddleware(logger *zap.Logger) gin.HandlerFunc {
 		latency := time.Since(start)
 		status := c.Writer.Status()
 
-		logger.Info("HTTP Request",
+		fields := []zap.Field{
 			zap.String("method", method),
 			zap.String("path", path),
 			zap.Int("status", status),
 			zap.Duration("latency", latency),
-		)
+			zap.String("request_id", requestid.FromContext(c.Request.Context())),
+		}
+		if actor := impersonatorID(c); actor != "" {
+			fields = append(fields,
+				zap.String("user_id", currentUserID(c)),
+				zap.String("impersonator_id", actor),
+				zap.String("impersonation_id", claimString(c, api.ImpersonationClaim)))
+		}
+		logger.Info("HTTP Request", fields...)
 
 		monitoring.RequestCounter.WithLabelValues(method, path, http.StatusText(status)).Inc()
 		monitoring.RequestLatency.WithLabelValues(method, path).Observe(latency.Seconds())
@@ -56,7 +76,9 @@ func LoggerMiddleware(logger *zap.Logger) gin.HandlerFunc {
 func TracingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tracer := otel.Tracer("api-gateway")
-		ctx, span := tracer.Start(c.Request.Context(), c.Request.URL.Path, trace.WithAttributes(
+		// Continue the trace of callers that send one, e.g. the front-end
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := tracer.Start(ctx, c.Request.URL.Path, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
 			attribute.String("http.method", c.Request.Method),
 		))
 		defer span.End()
@@ -65,44 +87,308 @@ func TracingMiddleware() gin.HandlerFunc {
 		c.Next()
 
 		span.SetAttributes(attribute.Int("http.status_code", c.Writer.Status()))
+		if actor := impersonatorID(c); actor != "" {
+			span.SetAttributes(
+				attribute.String("enduser.id", currentUserID(c)),
+				attribute.String("impersonation.actor_id", actor),
+				attribute.String("impersonation.id", claimString(c, api.ImpersonationClaim)),
+			)
+		}
 	}
 }
 
-func JWTAuthMiddleware(jwtSecret string, logger *zap.Logger) gin.HandlerFunc {
+// tenantExplicitKey is set when the request named its tenant through host or header
+const tenantExplicitKey = "tenant_explicit"
+
+// TenantMiddleware resolves the tenant of the request and stores it in the request context
+func TenantMiddleware(resolver *tenant.Resolver, logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		id, explicit, err := resolver.Resolve(c.Request)
+		if err != nil {
+			requestid.Logger(c.Request.Context(), logger).Warn("Failed to resolve tenant", zap.String("host", c.Request.Host), zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		c.Set(tenantExplicitKey, explicit)
+		c.Request = c.Request.WithContext(tenant.NewContext(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// tokenContext returns the request context in the tenant token is bound to. Links mailed
+// to users carry no tenant header, so they would otherwise be redeemed in the tenant of
+// the host or the default one.
+func tokenContext(c *gin.Context, token string) context.Context {
+	if id, ok := tenant.FromToken(token); ok {
+		return tenant.NewContext(c.Request.Context(), id)
+	}
+	return c.Request.Context()
+}
+
+// IdempotencyMiddleware stores the Idempotency-Key of a mutating request in the request
+// context, from where it is forwarded to user-svc. Retrying the request with the same key
+// then returns the original result instead of running it again.
+func IdempotencyMiddleware(logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotency.Header)
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			key = ""
+		}
+		if key == "" {
+			c.Next()
+			return
+		}
+		if !idempotency.Valid(key) {
+			requestid.Logger(c.Request.Context(), logger).Warn("Invalid idempotency key", zap.String("path", c.FullPath()))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be 1 to 255 printable ASCII characters"})
+			c.Abort()
+			return
+		}
+		c.Request = c.Request.WithContext(idempotency.NewContext(c.Request.Context(), key))
+		c.Next()
+	}
+}
+
+// RequestIDMiddleware gives every request an ID, taken from the X-Request-ID header when
+// the client sent a well-formed one. The ID is echoed in the response, stored in the
+// request context and from there forwarded to user-svc and onto published messages, along
+// with the address of the client. X-Forwarded-For only names the client when a trusted
+// proxy sent it.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		c.Header(requestid.Header, id)
+		ctx := requestid.NewContext(c.Request.Context(), id)
+		c.Request = c.Request.WithContext(clientip.NewContext(ctx, c.ClientIP()))
+		c.Next()
+	}
+}
+
+// RecoveryMiddleware turns a panic in a later handler into a 500 response, logging it with
+// its stack trace. Panics with http.ErrAbortHandler are passed on, as the client is gone.
+func RecoveryMiddleware(logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+			requestid.Logger(c.Request.Context(), logger).Error("Panic while handling request",
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.Request.URL.Path),
+				zap.Any("panic", r),
+				zap.ByteString("stack", debug.Stack()))
+			monitoring.PanicsRecovered.Inc()
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "request_id": requestid.FromContext(c.Request.Context())})
+		}()
+		c.Next()
+	}
+}
+
+// apiKeyHeader carries an API key in place of a bearer token
+const apiKeyHeader = "X-API-Key"
+
+// JWTAuthMiddleware authenticates the caller with a bearer token or, for machine
+// clients, an API key. Both leave the caller's claims in the gin context.
+func JWTAuthMiddleware(verifier *keys.Verifier, apiService ports.APIPort, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := c.GetHeader(apiKeyHeader); key != "" {
+			authenticateAPIKey(c, apiService, key, logger)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			logger.Warn("Missing Authorization header")
+			requestid.Logger(c.Request.Context(), logger).Warn("Missing Authorization header")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
 			c.Abort()
 			return
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
+		claims, err := verifier.Parse(c.Request.Context(), tokenString)
+		if err != nil {
+			requestid.Logger(c.Request.Context(), logger).Warn("Invalid JWT token", zap.Error(err))
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			c.Abort()
+			return
+		}
+		c.Set(claimsKey, claims)
+
+		// A token is only valid for the tenant it was issued by. Tokens issued before
+		// tenants existed carry no claim and belong to the default tenant.
+		tokenTenant := claimString(c, "tenant_id")
+		if tokenTenant == "" {
+			tokenTenant = tenant.Default
+		}
+		requested := tenant.FromContext(c.Request.Context())
+		if c.GetBool(tenantExplicitKey) && requested != tokenTenant {
+			requestid.Logger(c.Request.Context(), logger).Warn("Token used for another tenant", zap.String("token_tenant", tokenTenant), zap.String("tenant_id", requested))
+			c.JSON(http.StatusForbidden, gin.H{"error": "Token is not valid for this tenant"})
+			c.Abort()
+			return
+		}
+		c.Request = c.Request.WithContext(tenant.NewContext(c.Request.Context(), tokenTenant))
+
+		// Tokens stop working as soon as their login session is revoked, e.g. when the
+		// account is locked or its email address changes
+		if id := claimString(c, api.SessionClaim); id != "" {
+			if err := apiService.CheckSession(c.Request.Context(), id, currentUserID(c)); err != nil {
+				requestid.Logger(c.Request.Context(), logger).Warn("Session token rejected", zap.String("session_id", id), zap.Error(err))
+				if errors.Is(err, api.ErrUnavailable) {
+					c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service unavailable"})
+				} else {
+					c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended"})
+				}
+				c.Abort()
+				return
 			}
-			return []byte(jwtSecret), nil
-		})
+		}
 
-		if err != nil || !token.Valid {
-			logger.Warn("Invalid JWT token", zap.Error(err))
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		// Impersonation tokens stop working as soon as the impersonation is ended
+		if id := claimString(c, api.ImpersonationClaim); id != "" {
+			if err := apiService.CheckImpersonation(c.Request.Context(), id); err != nil {
+				requestid.Logger(c.Request.Context(), logger).Warn("Impersonation token rejected", zap.String("impersonation_id", id), zap.Error(err))
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Impersonation has ended"})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// authenticateAPIKey resolves an API key within the tenant of the request. The key's
+// owner becomes the caller, limited to the scopes granted to the key.
+func authenticateAPIKey(c *gin.Context, apiService ports.APIPort, key string, logger *zap.Logger) {
+	res, err := apiService.AuthenticateApiKey(c.Request.Context(), key)
+	if err != nil {
+		requestid.Logger(c.Request.Context(), logger).Warn("API key rejected", zap.Error(err))
+		status := statusFor(err)
+		if status == http.StatusNotFound || status == http.StatusBadRequest {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, gin.H{"error": "Invalid or expired API key"})
+		c.Abort()
+		return
+	}
+
+	user := res.GetUser()
+	claims := jwt.MapClaims{
+		"user_id":    user.GetId(),
+		"email":      user.GetEmail(),
+		"role":       user.GetRole().String(),
+		"tenant_id":  tenant.FromContext(c.Request.Context()),
+		"auth":       authAPIKey,
+		"api_key_id": res.GetApiKey().GetId(),
+	}
+	if m := res.GetMembership(); m != nil {
+		claims["org_id"] = m.GetOrganizationId()
+		claims["org_role"] = api.OrgRoleName(m.GetRole())
+	}
+	c.Set(claimsKey, claims)
+	c.Set(scopesKey, res.GetApiKey().GetScopes())
+	c.Next()
+}
+
+// RequireScope rejects API key requests whose key was not granted scope.
+// Requests authenticated with a token are not limited by scopes.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if claimString(c, "auth") != authAPIKey {
+			c.Next()
+			return
+		}
+		if !slices.Contains(c.GetStringSlice(scopesKey), scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key lacks scope " + scope})
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
+
+// InteractiveOnly rejects API key requests, for operations that need the account owner present
+func InteractiveOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if claimString(c, "auth") == authAPIKey {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not available with an API key"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
 
+// NotImpersonated rejects requests made while impersonating a user, for operations
+// only the account owner may perform, such as changing credentials
+func NotImpersonated() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if impersonatorID(c) != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not available while impersonating a user"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
 
+const (
+	// claimsKey is the gin context key under which JWTAuthMiddleware stores the caller's claims
+	claimsKey = "jwt_claims"
+	// scopesKey is the gin context key holding the scopes of an API key
+	scopesKey = "api_key_scopes"
+	// authAPIKey is the "auth" claim of callers authenticated with an API key
+	authAPIKey = "api_key"
+)
+
+// claimString returns a string claim of the authenticated caller, or "" if absent
+func claimString(c *gin.Context, name string) string {
+	claims, ok := c.Get(claimsKey)
+	if !ok {
+		return ""
+	}
+	value, _ := claims.(jwt.MapClaims)[name].(string)
+	return value
+}
+
+// currentUserID returns the ID of the authenticated caller
+func currentUserID(c *gin.Context) string {
+	return claimString(c, "user_id")
+}
+
+// impersonatorID returns the ID of the support user acting as the caller, from the
+// "act" claim of an impersonation token, or "" if the caller is not impersonated
+func impersonatorID(c *gin.Context) string {
+	claims, ok := c.Get(claimsKey)
+	if !ok {
+		return ""
+	}
+	act, _ := claims.(jwt.MapClaims)["act"].(map[string]interface{})
+	sub, _ := act["sub"].(string)
+	return sub
+}
+
 func CORSMiddleware() gin.HandlerFunc {
     return func(c *gin.Context) {
         // Set CORS headers
         c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
         c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-        c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+        c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Tenant-ID, X-API-Key, Idempotency-Key, X-Request-ID, Traceparent, Tracestate")
+        c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 
         // Handle preflight requests
         if c.Request.Method == "OPTIONS" {
@@ -0,0 +1,200 @@
+package http
+
+import (
+	"net/http"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/asadlive84/shopper/api-gateway/internal/application/core/api"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// CreateOrganization creates an organization owned by the caller
+func (h *Handler) CreateOrganization(c *gin.Context) {
+	var body struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.log(c).Error("Failed to parse create organization request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org, err := h.apiService.CreateOrganization(c.Request.Context(), currentUserID(c), body.Name)
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, org)
+}
+
+// ListMyOrganizations lists the organizations the caller belongs to
+func (h *Handler) ListMyOrganizations(c *gin.Context) {
+	memberships, err := h.apiService.ListMyOrganizations(c.Request.Context(), currentUserID(c))
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"memberships": memberships})
+}
+
+func (h *Handler) GetOrganization(c *gin.Context) {
+	res, err := h.apiService.GetOrganization(c.Request.Context(), currentUserID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ListOrganizationMembers(c *gin.Context) {
+	members, err := h.apiService.ListOrganizationMembers(c.Request.Context(), currentUserID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+// UpdateOrganizationMember changes a member's role, e.g. {"role": "buyer"}
+func (h *Handler) UpdateOrganizationMember(c *gin.Context) {
+	role, ok := h.bindOrgRole(c)
+	if !ok {
+		return
+	}
+	member, err := h.apiService.UpdateOrganizationMember(c.Request.Context(), &pb.UpdateOrganizationMemberRequest{
+		ActorId:        currentUserID(c),
+		OrganizationId: c.Param("id"),
+		UserId:         c.Param("user_id"),
+		Role:           role,
+	})
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+// RemoveOrganizationMember removes a member; members may remove themselves to leave
+func (h *Handler) RemoveOrganizationMember(c *gin.Context) {
+	err := h.apiService.RemoveOrganizationMember(c.Request.Context(), currentUserID(c), c.Param("id"), c.Param("user_id"))
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// InviteOrganizationMember invites an email address, e.g. {"email": "a@b.c", "role": "viewer"}
+func (h *Handler) InviteOrganizationMember(c *gin.Context) {
+	var body struct {
+		Email string `json:"email" binding:"required,email"`
+		Role  string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.log(c).Error("Failed to parse invitation request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	role := api.ParseOrgRole(body.Role)
+	if role == pb.OrganizationRole_ORGANIZATION_ROLE_UNSPECIFIED {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be one of owner, admin, buyer, viewer"})
+		return
+	}
+
+	inv, err := h.apiService.InviteOrganizationMember(c.Request.Context(), &pb.InviteOrganizationMemberRequest{
+		ActorId:        currentUserID(c),
+		OrganizationId: c.Param("id"),
+		Email:          body.Email,
+		Role:           role,
+	})
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, inv)
+}
+
+func (h *Handler) ListOrganizationInvitations(c *gin.Context) {
+	invs, err := h.apiService.ListOrganizationInvitations(c.Request.Context(), currentUserID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"invitations": invs})
+}
+
+func (h *Handler) RevokeOrganizationInvitation(c *gin.Context) {
+	err := h.apiService.RevokeOrganizationInvitation(c.Request.Context(), currentUserID(c), c.Param("invitation_id"))
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListMyInvitations lists the pending invitations addressed to the caller
+func (h *Handler) ListMyInvitations(c *gin.Context) {
+	invs, err := h.apiService.ListMyInvitations(c.Request.Context(), currentUserID(c))
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"invitations": invs})
+}
+
+// AcceptOrganizationInvitation accepts an invitation addressed to the caller with the
+// token mailed with it, e.g. {"token": "..."}
+func (h *Handler) AcceptOrganizationInvitation(c *gin.Context) {
+	var body struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.log(c).Error("Failed to parse invitation acceptance", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	member, err := h.apiService.AcceptOrganizationInvitation(c.Request.Context(), currentUserID(c), c.Param("id"), body.Token)
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+// SwitchOrganization returns a new access token whose active organization is
+// {"organization_id": "..."}; an empty ID clears the active organization
+func (h *Handler) SwitchOrganization(c *gin.Context) {
+	var body struct {
+		OrganizationID string `json:"organization_id"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.log(c).Error("Failed to parse switch organization request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.apiService.SwitchOrganization(c.Request.Context(), currentUserID(c), claimString(c, api.SessionClaim), body.OrganizationID)
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"token": res.GetToken(), "user": res.GetUser()})
+}
+
+// bindOrgRole reads {"role": "..."} from the body, answering 400 itself when it is missing or unknown
+func (h *Handler) bindOrgRole(c *gin.Context) (pb.OrganizationRole, bool) {
+	var body struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, false
+	}
+	role := api.ParseOrgRole(body.Role)
+	if role == pb.OrganizationRole_ORGANIZATION_ROLE_UNSPECIFIED {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be one of owner, admin, buyer, viewer"})
+		return 0, false
+	}
+	return role, true
+}
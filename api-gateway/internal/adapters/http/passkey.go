@@ -0,0 +1,142 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/asadlive84/shopper/api-gateway/internal/application/core/api"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// passkeyAnswer is the browser's answer to a passkey challenge: the PublicKeyCredential
+// returned by navigator.credentials.create or get, serialized with toJSON()
+type passkeyAnswer struct {
+	CeremonyID string          `json:"ceremony_id" binding:"required"`
+	Credential json.RawMessage `json:"credential" binding:"required"`
+}
+
+// BeginPasskeyLogin returns a challenge to pass to navigator.credentials.get
+func (h *Handler) BeginPasskeyLogin(c *gin.Context) {
+	res, err := h.apiService.BeginPasskeyLogin(c.Request.Context())
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, passkeyChallenge(res))
+}
+
+// FinishPasskeyLogin logs in with the answer to a login challenge and responds like Login
+func (h *Handler) FinishPasskeyLogin(c *gin.Context) {
+	var body passkeyAnswer
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.log(c).Error("Failed to parse passkey login request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := h.apiService.FinishPasskeyLogin(c.Request.Context(), body.CeremonyID, body.Credential)
+	if err != nil {
+		h.log(c).Warn("Passkey login failed", zap.Error(err))
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	msg := "User logged in: " + res.GetUser().GetEmail()
+	if err := h.rabbitClient.Publish(c.Request.Context(), "user_exchange", msg); err != nil {
+		h.log(c).Error("Failed to publish to RabbitMQ", zap.Error(err))
+	}
+	c.JSON(http.StatusOK, gin.H{"token": res.GetToken(), "user": res.GetUser()})
+}
+
+// BeginPasskeyRegistration returns a challenge to pass to navigator.credentials.create
+func (h *Handler) BeginPasskeyRegistration(c *gin.Context) {
+	res, err := h.apiService.BeginPasskeyRegistration(c.Request.Context(), currentUserID(c))
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, passkeyChallenge(res))
+}
+
+// FinishPasskeyRegistration registers a passkey with the answer to a registration
+// challenge, e.g. {"ceremony_id": "...", "credential": {...}, "name": "Laptop"}
+func (h *Handler) FinishPasskeyRegistration(c *gin.Context) {
+	var body struct {
+		passkeyAnswer
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.log(c).Error("Failed to parse passkey registration request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	passkey, err := h.apiService.FinishPasskeyRegistration(c.Request.Context(), &pb.FinishPasskeyRegistrationRequest{
+		UserId:     currentUserID(c),
+		CeremonyId: body.CeremonyID,
+		Credential: body.Credential,
+		Name:       body.Name,
+	})
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"passkey": passkey})
+}
+
+func (h *Handler) ListPasskeys(c *gin.Context) {
+	passkeys, err := h.apiService.ListPasskeys(c.Request.Context(), currentUserID(c))
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"passkeys": passkeys})
+}
+
+func (h *Handler) DeletePasskey(c *gin.Context) {
+	if err := h.apiService.DeletePasskey(c.Request.Context(), currentUserID(c), c.Param("id")); err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SetTwoFactor turns two-factor authentication on or off for the caller, e.g. {"enabled": true}.
+// While it is on, the caller can only log in with a passkey.
+func (h *Handler) SetTwoFactor(c *gin.Context) {
+	var body struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.log(c).Error("Failed to parse two-factor request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.apiService.SetTwoFactor(c.Request.Context(), currentUserID(c), *body.Enabled); err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": *body.Enabled})
+}
+
+// respondSecondFactor answers a login that was refused because the user must log in
+// with a passkey, pointing the client at the passkey login. It reports whether err was
+// such a refusal.
+func respondSecondFactor(c *gin.Context, err error) bool {
+	if !errors.Is(err, api.ErrSecondFactorRequired) {
+		return false
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error":         "Two-factor authentication is on for this account; log in with a passkey",
+		"second_factor": "passkey",
+	})
+	return true
+}
+
+func passkeyChallenge(res *pb.PasskeyChallengeResponse) gin.H {
+	return gin.H{
+		"ceremony_id": res.GetCeremonyId(),
+		"options":     json.RawMessage(res.GetOptions()),
+		"expires_at":  res.GetExpiresAt().AsTime(),
+	}
+}
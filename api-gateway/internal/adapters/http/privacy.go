@@ -0,0 +1,66 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ExportMyData streams an archive with every record held about the caller
+func (h *Handler) ExportMyData(c *gin.Context) {
+	userID := currentUserID(c)
+	export, err := h.apiService.ExportMyData(c.Request.Context(), userID)
+	if err != nil {
+		h.log(c).Error("Failed to export user data", zap.String("user_id", userID), zap.Error(err))
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.GetFilename()))
+	c.Data(http.StatusOK, export.GetContentType(), export.GetArchive())
+}
+
+// RequestErasure starts erasing the caller's personal data
+func (h *Handler) RequestErasure(c *gin.Context) {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			h.log(c).Error("Failed to parse erasure request", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	userID := currentUserID(c)
+	erasure, err := h.apiService.RequestErasure(c.Request.Context(), &pb.RequestErasureRequest{
+		UserId: userID,
+		Reason: body.Reason,
+	})
+	if err != nil {
+		h.log(c).Error("Failed to request erasure", zap.String("user_id", userID), zap.Error(err))
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, erasure)
+}
+
+// GetErasureRequest reports the progress of one of the caller's erasure requests
+func (h *Handler) GetErasureRequest(c *gin.Context) {
+	id := c.Param("id")
+	erasure, err := h.apiService.GetErasureRequest(c.Request.Context(), id)
+	if err != nil {
+		h.log(c).Error("Failed to get erasure request", zap.String("id", id), zap.Error(err))
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	// Do not reveal whether someone else's request exists
+	if erasure.GetUserId() != currentUserID(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "erasure request not found"})
+		return
+	}
+	c.JSON(http.StatusOK, erasure)
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/asadlive84/shopper/api-gateway/internal/social"
+	"github.com/asadlive84/shopper/api-gateway/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// socialStateCookie carries the signed login state between the redirect to an identity
+// provider and its callback. It is scoped to /auth/ and never readable by scripts.
+const socialStateCookie = "social_login"
+
+// ListSocialProviders lists the identity providers users can log in with
+func (h *Handler) ListSocialProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.social.Providers()})
+}
+
+// SocialLogin sends the user to the identity provider to log in
+func (h *Handler) SocialLogin(c *gin.Context) {
+	provider := c.Param("provider")
+	authURL, cookie, err := h.social.Begin(c.Request.Context(), provider, tenant.FromContext(c.Request.Context()), "")
+	if err != nil {
+		h.socialError(c, provider, err)
+		return
+	}
+	setSocialStateCookie(c, cookie, int(social.StateTTL.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// LinkSocialIdentity starts linking an identity provider account to the caller. Browsers
+// cannot follow a redirect with the bearer token attached, so the authorization URL is
+// returned for the client to navigate to; the callback then links instead of logging in.
+func (h *Handler) LinkSocialIdentity(c *gin.Context) {
+	provider := c.Param("provider")
+	authURL, cookie, err := h.social.Begin(c.Request.Context(), provider, tenant.FromContext(c.Request.Context()), currentUserID(c))
+	if err != nil {
+		h.socialError(c, provider, err)
+		return
+	}
+	setSocialStateCookie(c, cookie, int(social.StateTTL.Seconds()))
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authURL})
+}
+
+// SocialCallback finishes a login at an identity provider and responds like Login
+func (h *Handler) SocialCallback(c *gin.Context) {
+	provider := c.Param("provider")
+	// The state cookie is single-use whatever the outcome
+	setSocialStateCookie(c, "", -1)
+	if reason := c.Query("error"); reason != "" {
+		h.log(c).Warn("Identity provider refused the login", zap.String("provider", provider), zap.String("error", reason))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login was cancelled or refused by the identity provider"})
+		return
+	}
+	cookie, err := c.Cookie(socialStateCookie)
+	if err != nil {
+		h.socialError(c, provider, social.ErrInvalidState)
+		return
+	}
+	state, identity, err := h.social.Complete(c.Request.Context(), provider, cookie, c.Query("state"), c.Query("code"))
+	if err != nil {
+		h.socialError(c, provider, err)
+		return
+	}
+
+	// The callback carries no tenant header, so the login finishes in the tenant it started in
+	ctx := tenant.NewContext(c.Request.Context(), state.TenantID)
+	res, err := h.apiService.LoginWithExternalIdentity(ctx, &pb.LinkExternalIdentityRequest{
+		Provider:      identity.Provider,
+		Subject:       identity.Subject,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		Name:          identity.Name,
+		UserId:        state.UserID,
+	})
+	if respondSecondFactor(c, err) {
+		return
+	}
+	if err != nil {
+		h.log(c).Warn("External identity login failed", zap.String("provider", provider), zap.Error(err))
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	msg := "User logged in: " + res.GetUser().GetEmail()
+	if err := h.rabbitClient.Publish(ctx, "user_exchange", msg); err != nil {
+		h.log(c).Error("Failed to publish to RabbitMQ", zap.Error(err))
+	}
+	c.JSON(http.StatusOK, gin.H{"token": res.GetToken(), "user": res.GetUser()})
+}
+
+// socialError responds to a failed social login step
+func (h *Handler) socialError(c *gin.Context, provider string, err error) {
+	switch {
+	case errors.Is(err, social.ErrUnknownProvider):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+	case errors.Is(err, social.ErrInvalidState):
+		h.log(c).Warn("Invalid social login state", zap.String("provider", provider))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login session is invalid or expired; please start again"})
+	case errors.Is(err, social.ErrProvider):
+		h.log(c).Error("Identity provider request failed", zap.String("provider", provider), zap.Error(err))
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Login with the identity provider failed"})
+	default:
+		h.log(c).Error("Social login failed", zap.String("provider", provider), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
+
+// setSocialStateCookie sets the login state cookie; a negative maxAge deletes it. The
+// cookie must be sent on the top-level redirect back from the provider, hence SameSite=Lax.
+func setSocialStateCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     socialStateCookie,
+		Value:    value,
+		Path:     "/auth/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   secureRequest(c),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// secureRequest reports whether the client reached the gateway over HTTPS, directly or
+// through a TLS-terminating proxy, so cookies can be marked Secure
+func secureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/asadlive84/shopper/api-gateway/internal/tenant"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
@@ -18,15 +19,15 @@ var upgrader = websocket.Upgrader{
 func (h *Handler) WebSocketHandler(c *gin.Context) {
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		h.logger.Error("Failed to upgrade to WebSocket", zap.Error(err))
+		h.log(c).Error("Failed to upgrade to WebSocket", zap.Error(err))
 		return
 	}
 	defer conn.Close()
 
-	h.eventManager.AddClient(conn)
+	h.eventManager.AddClient(conn, tenant.FromContext(c.Request.Context()))
 	defer h.eventManager.RemoveClient(conn)
 
-	h.logger.Info("WebSocket client connected", zap.String("remote_addr", c.Request.RemoteAddr))
+	h.log(c).Info("WebSocket client connected", zap.String("remote_addr", c.Request.RemoteAddr))
 
 	// পিং-পং যোগ করা
 	go func() {
@@ -42,13 +43,13 @@ func (h *Handler) WebSocketHandler(c *gin.Context) {
 		msgType, msg, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				h.logger.Error("WebSocket closed unexpectedly", zap.Error(err))
+				h.log(c).Error("WebSocket closed unexpectedly", zap.Error(err))
 			} else {
-				h.logger.Error("Failed to read WebSocket message", zap.Error(err))
+				h.log(c).Error("Failed to read WebSocket message", zap.Error(err))
 			}
 			break
 		}
-		h.logger.Info("Received message", zap.Int("type", msgType), zap.String("message", string(msg)))
-		h.eventManager.HandleWebSocketMessage(c, conn, msg)
+		h.log(c).Info("Received message", zap.Int("type", msgType), zap.String("message", string(msg)))
+		h.eventManager.HandleWebSocketMessage(c.Request.Context(), conn, msg)
 	}
 }
@@ -7,20 +7,25 @@ import (
 
 	pb "github.com/asadlive84/shopper-proto/golang/user"
 	"github.com/asadlive84/shopper/api-gateway/internal/ports"
+	"github.com/asadlive84/shopper/api-gateway/internal/tenant"
 	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/zap"
 )
 
 type APIService struct {
 	grpcClient ports.UserGRPCClientPort
-	jwtSecret  string
+	signer     ports.TokenSignerPort
+	issuer     string // The "iss" claim of the access tokens signed
+	audience   string // The "aud" claim of the access tokens signed
 	logger     *zap.Logger
 }
 
-func NewAPIService(grpcClient ports.UserGRPCClientPort, jwtSecret string, logger *zap.Logger) *APIService {
+func NewAPIService(grpcClient ports.UserGRPCClientPort, signer ports.TokenSignerPort, issuer, audience string, logger *zap.Logger) *APIService {
 	return &APIService{
 		grpcClient: grpcClient,
-		jwtSecret:  jwtSecret,
+		signer:     signer,
+		issuer:     issuer,
+		audience:   audience,
 		logger:     logger,
 	}
 }
@@ -37,7 +42,7 @@ func (s *APIService) GetUser(ctx context.Context, id string) (*pb.User, error) {
 func (s *APIService) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.User, error) {
 	res, err := s.grpcClient.CreateUser(ctx, req)
 	if err != nil {
-		return nil, s.handleGRPCError(err, "CreateUser", zap.String("email", req.Email))
+		return nil, s.handleGRPCError(ctx, err, "CreateUser", zap.String("email", req.Email))
 	}
 	if res == nil || res.User == nil {
 		return nil, fmt.Errorf("empty response received from server")
@@ -48,21 +53,13 @@ func (s *APIService) CreateUser(ctx context.Context, req *pb.CreateUserRequest)
 func (s *APIService) AuthenticateUser(ctx context.Context, req *pb.AuthenticateUserRequest) (*pb.AuthenticateUserResponse, error) {
 	res, err := s.grpcClient.AuthenticateUser(ctx, req)
 	if err != nil {
-		return nil, err
+		return nil, s.handleGRPCError(ctx, err, "AuthenticateUser", zap.String("email", req.GetEmail()))
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": res.GetUser().GetId(),
-		"email":   res.GetUser().GetEmail(),
-		"role":    res.GetUser().GetRole().String(),
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	})
-
 	fmt.Println("========================================")
-	fmt.Printf("token %+v\n", token)
 	fmt.Printf("res.GetUser() %+v\n", res.GetUser())
 	fmt.Println("========================================")
 
-	tokenString, err := token.SignedString([]byte(s.jwtSecret))
+	tokenString, err := s.issueToken(ctx, res.GetUser(), nil, res.GetToken())
 	if err != nil {
 		return nil, err
 	}
@@ -72,6 +69,50 @@ func (s *APIService) AuthenticateUser(ctx context.Context, req *pb.AuthenticateU
 	}, nil
 }
 
+// SessionClaim is the claim holding the ID of the user-svc login session a token was
+// issued for; the token stops working as soon as the session is revoked
+const SessionClaim = "sid"
+
+// issueToken signs an access token for the user's login session. When membership is set
+// the token carries it as the active organization, so downstream services can authorize
+// against it.
+func (s *APIService) issueToken(ctx context.Context, user *pb.User, membership *pb.OrganizationMember, sessionID string) (string, error) {
+	return s.signer.Sign(s.userClaims(ctx, user, membership, sessionID))
+}
+
+// userClaims are the claims of an access token for the user, valid for a day
+func (s *APIService) userClaims(ctx context.Context, user *pb.User, membership *pb.OrganizationMember, sessionID string) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"iss":       s.issuer,
+		"aud":       s.audience,
+		"user_id":   user.GetId(),
+		"email":     user.GetEmail(),
+		"role":      user.GetRole().String(),
+		"tenant_id": tenant.FromContext(ctx),
+		"exp":       time.Now().Add(time.Hour * 24).Unix(),
+	}
+	if sessionID != "" {
+		claims[SessionClaim] = sessionID
+	}
+	if membership != nil {
+		claims["org_id"] = membership.GetOrganizationId()
+		claims["org_role"] = OrgRoleName(membership.GetRole())
+	}
+	return claims
+}
+
+// CheckSession fails once the login session of a user's token was revoked or has expired
+func (s *APIService) CheckSession(ctx context.Context, id, userID string) error {
+	res, err := s.grpcClient.GetSession(ctx, &pb.GetSessionRequest{Id: id})
+	if err != nil {
+		return s.handleGRPCError(ctx, err, "GetSession", zap.String("session_id", id))
+	}
+	if res.GetSession().GetUserId() != userID {
+		return fmt.Errorf("%w: session belongs to another user", ErrUnauthenticated)
+	}
+	return nil
+}
+
 func (s *APIService) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
 	return s.grpcClient.ListUsers(ctx, req)
 }
@@ -83,3 +124,27 @@ func (s *APIService) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest)
 	}
 	return res.GetUser(), nil
 }
+
+func (s *APIService) ExportMyData(ctx context.Context, userID string) (*pb.ExportMyDataResponse, error) {
+	res, err := s.grpcClient.ExportMyData(ctx, &pb.ExportMyDataRequest{UserId: userID})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "ExportMyData", zap.String("user_id", userID))
+	}
+	return res, nil
+}
+
+func (s *APIService) RequestErasure(ctx context.Context, req *pb.RequestErasureRequest) (*pb.ErasureRequest, error) {
+	res, err := s.grpcClient.RequestErasure(ctx, req)
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "RequestErasure", zap.String("user_id", req.GetUserId()))
+	}
+	return res.GetRequest(), nil
+}
+
+func (s *APIService) GetErasureRequest(ctx context.Context, id string) (*pb.ErasureRequest, error) {
+	res, err := s.grpcClient.GetErasureRequest(ctx, &pb.GetErasureRequestRequest{Id: id})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "GetErasureRequest", zap.String("id", id))
+	}
+	return res.GetRequest(), nil
+}
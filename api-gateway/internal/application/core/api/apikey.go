@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"go.uber.org/zap"
+)
+
+// CreateApiKey creates an API key; the response carries the only copy of its secret
+func (s *APIService) CreateApiKey(ctx context.Context, req *pb.CreateApiKeyRequest) (*pb.CreateApiKeyResponse, error) {
+	res, err := s.grpcClient.CreateApiKey(ctx, req)
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "CreateApiKey", zap.String("user_id", req.GetUserId()))
+	}
+	return res, nil
+}
+
+func (s *APIService) ListApiKeys(ctx context.Context, userID string) ([]*pb.ApiKey, error) {
+	res, err := s.grpcClient.ListApiKeys(ctx, &pb.ListApiKeysRequest{UserId: userID})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "ListApiKeys", zap.String("user_id", userID))
+	}
+	return res.GetApiKeys(), nil
+}
+
+func (s *APIService) GetApiKey(ctx context.Context, userID, id string) (*pb.ApiKey, error) {
+	res, err := s.grpcClient.GetApiKey(ctx, &pb.GetApiKeyRequest{UserId: userID, Id: id})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "GetApiKey", zap.String("key_id", id))
+	}
+	return res.GetApiKey(), nil
+}
+
+func (s *APIService) UpdateApiKey(ctx context.Context, req *pb.UpdateApiKeyRequest) (*pb.ApiKey, error) {
+	res, err := s.grpcClient.UpdateApiKey(ctx, req)
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "UpdateApiKey", zap.String("key_id", req.GetId()))
+	}
+	return res.GetApiKey(), nil
+}
+
+func (s *APIService) RevokeApiKey(ctx context.Context, userID, id string) error {
+	_, err := s.grpcClient.RevokeApiKey(ctx, &pb.RevokeApiKeyRequest{UserId: userID, Id: id})
+	return s.handleGRPCError(ctx, err, "RevokeApiKey", zap.String("key_id", id))
+}
+
+// AuthenticateApiKey resolves an API key presented in a request to its owner
+func (s *APIService) AuthenticateApiKey(ctx context.Context, key string) (*pb.AuthenticateApiKeyResponse, error) {
+	res, err := s.grpcClient.AuthenticateApiKey(ctx, &pb.AuthenticateApiKeyRequest{Key: key})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "AuthenticateApiKey")
+	}
+	return res, nil
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"context"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"go.uber.org/zap"
+)
+
+// RequestEmailChange has a confirmation mailed to the new address of the user
+func (s *APIService) RequestEmailChange(ctx context.Context, req *pb.RequestEmailChangeRequest) (*pb.RequestEmailChangeResponse, error) {
+	res, err := s.grpcClient.RequestEmailChange(ctx, req)
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "RequestEmailChange", zap.String("user_id", req.GetUserId()))
+	}
+	return res, nil
+}
+
+// ConfirmEmailChange swaps in the new address; the user's sessions are revoked
+func (s *APIService) ConfirmEmailChange(ctx context.Context, token string) (*pb.User, error) {
+	res, err := s.grpcClient.ConfirmEmailChange(ctx, &pb.ConfirmEmailChangeRequest{Token: token})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "ConfirmEmailChange")
+	}
+	return res.GetUser(), nil
+}
@@ -1,17 +1,44 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
+	"github.com/asadlive84/shopper/api-gateway/internal/requestid"
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
-func (s *APIService) handleGRPCError(err error, contextMsg string, fields ...zap.Field) error {
+// Errors returned by handleGRPCError; callers match them with errors.Is to pick a response status
+var (
+	ErrInvalidInput  = errors.New("input validation failed")
+	ErrAlreadyExists = errors.New("resource already exists")
+	ErrNotFound      = errors.New("resource not found")
+	ErrInternal      = errors.New("internal server error")
+	ErrUnavailable   = errors.New("service unavailable")
+
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrFailedPrecondition = errors.New("operation not allowed in the current state")
+	ErrUnauthenticated    = errors.New("authentication failed")
+	ErrResourceExhausted  = errors.New("too many requests")
+	ErrAborted            = errors.New("request conflicts with one in progress")
+
+	// ErrSecondFactorRequired means the credentials were right, but the user has
+	// two-factor authentication on and must log in with a passkey instead
+	ErrSecondFactorRequired = errors.New("second factor required")
+)
+
+// reasonSecondFactorRequired is the ErrorInfo reason user-svc attaches to logins that need a passkey
+const reasonSecondFactorRequired = "SECOND_FACTOR_REQUIRED"
+
+func (s *APIService) handleGRPCError(ctx context.Context, err error, contextMsg string, fields ...zap.Field) error {
 	if err == nil {
 		return nil
 	}
+	logger := requestid.Logger(ctx, s.logger)
 
 	// Default fields for logging
 	logFields := append([]zap.Field{zap.String("context", contextMsg)}, fields...)
@@ -19,27 +46,56 @@ func (s *APIService) handleGRPCError(err error, contextMsg string, fields ...zap
 	if st, ok := status.FromError(err); ok {
 		switch st.Code() {
 		case codes.InvalidArgument:
-			s.logger.Error("Input validation failed", append(logFields, zap.String("details", st.Message()))...)
-			return fmt.Errorf("input validation failed: %s", st.Message())
+			logger.Error("Input validation failed", append(logFields, zap.String("details", st.Message()))...)
+			return fmt.Errorf("%w: %s", ErrInvalidInput, st.Message())
 		case codes.AlreadyExists:
-			s.logger.Error("Resource already exists", append(logFields, zap.String("details", st.Message()))...)
-			return fmt.Errorf("resource already exists: %s", st.Message())
+			logger.Error("Resource already exists", append(logFields, zap.String("details", st.Message()))...)
+			return fmt.Errorf("%w: %s", ErrAlreadyExists, st.Message())
 		case codes.NotFound:
-			s.logger.Error("Resource not found", append(logFields, zap.String("details", st.Message()))...)
-			return fmt.Errorf("resource not found: %s", st.Message())
+			logger.Error("Resource not found", append(logFields, zap.String("details", st.Message()))...)
+			return fmt.Errorf("%w: %s", ErrNotFound, st.Message())
+		case codes.PermissionDenied:
+			logger.Warn("Permission denied", append(logFields, zap.String("details", st.Message()))...)
+			return fmt.Errorf("%w: %s", ErrPermissionDenied, st.Message())
+		case codes.Unauthenticated:
+			logger.Warn("Authentication failed", append(logFields, zap.String("details", st.Message()))...)
+			return fmt.Errorf("%w: %s", ErrUnauthenticated, st.Message())
+		case codes.FailedPrecondition:
+			if hasReason(st, reasonSecondFactorRequired) {
+				logger.Info("Second factor required", logFields...)
+				return ErrSecondFactorRequired
+			}
+			logger.Warn("Failed precondition", append(logFields, zap.String("details", st.Message()))...)
+			return fmt.Errorf("%w: %s", ErrFailedPrecondition, st.Message())
+		case codes.ResourceExhausted:
+			logger.Warn("Resource exhausted", append(logFields, zap.String("details", st.Message()))...)
+			return fmt.Errorf("%w: %s", ErrResourceExhausted, st.Message())
+		case codes.Aborted:
+			logger.Warn("Request aborted", append(logFields, zap.String("details", st.Message()))...)
+			return fmt.Errorf("%w: %s", ErrAborted, st.Message())
 		case codes.Internal:
-			s.logger.Error("Internal server error", append(logFields, zap.String("details", st.Message()))...)
-			return fmt.Errorf("internal server error: %s", st.Message())
+			logger.Error("Internal server error", append(logFields, zap.String("details", st.Message()))...)
+			return fmt.Errorf("%w: %s", ErrInternal, st.Message())
 		case codes.Unavailable:
-			s.logger.Error("Service unavailable", append(logFields, zap.String("details", st.Message()))...)
-			return fmt.Errorf("service unavailable: %s", st.Message())
+			logger.Error("Service unavailable", append(logFields, zap.String("details", st.Message()))...)
+			return fmt.Errorf("%w: %s", ErrUnavailable, st.Message())
 		default:
-			s.logger.Error("Unknown gRPC error", append(logFields, zap.String("details", st.Message()))...)
+			logger.Error("Unknown gRPC error", append(logFields, zap.String("details", st.Message()))...)
 			return fmt.Errorf("unknown error: %s", st.Message())
 		}
 	}
 
 	// Non-gRPC error
-	s.logger.Error("Unexpected error", append(logFields, zap.Error(err))...)
+	logger.Error("Unexpected error", append(logFields, zap.Error(err))...)
 	return fmt.Errorf("unexpected error: %v", err)
 }
+
+// hasReason reports whether the status carries an ErrorInfo detail with the given reason
+func hasReason(st *status.Status, reason string) bool {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetReason() == reason {
+			return true
+		}
+	}
+	return false
+}
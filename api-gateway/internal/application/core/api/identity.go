@@ -0,0 +1,26 @@
+package api
+
+import (
+	"context"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"go.uber.org/zap"
+)
+
+// LoginWithExternalIdentity logs in with an identity an external provider authenticated,
+// linking it to the user named in the request or to an existing or new user, and issues
+// the same access token as a password login
+func (s *APIService) LoginWithExternalIdentity(ctx context.Context, req *pb.LinkExternalIdentityRequest) (*pb.AuthenticateUserResponse, error) {
+	res, err := s.grpcClient.LinkExternalIdentity(ctx, req)
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "LinkExternalIdentity", zap.String("provider", req.GetProvider()))
+	}
+	tokenString, err := s.issueToken(ctx, res.GetUser(), nil, res.GetToken())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AuthenticateUserResponse{
+		Token: tokenString,
+		User:  res.GetUser(),
+	}, nil
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"context"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"go.uber.org/zap"
+)
+
+// ImpersonationClaim is the claim holding the ID of the impersonation a token was issued for
+const ImpersonationClaim = "impersonation_id"
+
+// Impersonate starts acting as userID and issues a token for it. The token is the
+// user's own, except that it expires with the impersonation and names the actor in
+// an RFC 8693 "act" claim.
+func (s *APIService) Impersonate(ctx context.Context, actorID, userID, reason string) (string, *pb.ImpersonateResponse, error) {
+	res, err := s.grpcClient.Impersonate(ctx, &pb.ImpersonateRequest{ActorId: actorID, UserId: userID, Reason: reason})
+	if err != nil {
+		return "", nil, s.handleGRPCError(ctx, err, "Impersonate", zap.String("actor_id", actorID), zap.String("user_id", userID))
+	}
+	imp := res.GetImpersonation()
+	claims := s.userClaims(ctx, res.GetUser(), nil, "")
+	claims["exp"] = imp.GetExpiresAt().AsTime().Unix()
+	claims["act"] = map[string]interface{}{"sub": actorID}
+	claims[ImpersonationClaim] = imp.GetId()
+	tokenString, err := s.signer.Sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return tokenString, res, nil
+}
+
+// CheckImpersonation fails once the impersonation has ended or expired
+func (s *APIService) CheckImpersonation(ctx context.Context, id string) error {
+	_, err := s.grpcClient.GetImpersonation(ctx, &pb.GetImpersonationRequest{Id: id})
+	if err != nil {
+		return s.handleGRPCError(ctx, err, "GetImpersonation", zap.String("impersonation_id", id))
+	}
+	return nil
+}
+
+func (s *APIService) EndImpersonation(ctx context.Context, actorID, id string) (*pb.Impersonation, error) {
+	res, err := s.grpcClient.EndImpersonation(ctx, &pb.EndImpersonationRequest{Id: id, ActorId: actorID})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "EndImpersonation", zap.String("impersonation_id", id))
+	}
+	return res.GetImpersonation(), nil
+}
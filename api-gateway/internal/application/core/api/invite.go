@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"strings"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"go.uber.org/zap"
+)
+
+// ParseRole turns a role name such as "moderator" into its protobuf value
+func ParseRole(name string) pb.Role {
+	return pb.Role(pb.Role_value["ROLE_"+strings.ToUpper(name)])
+}
+
+func (s *APIService) InviteUser(ctx context.Context, req *pb.InviteUserRequest) (*pb.UserInvite, error) {
+	res, err := s.grpcClient.InviteUser(ctx, req)
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "InviteUser", zap.String("actor_id", req.GetActorId()))
+	}
+	return res.GetInvite(), nil
+}
+
+func (s *APIService) ListUserInvites(ctx context.Context, actorID string) ([]*pb.UserInvite, error) {
+	res, err := s.grpcClient.ListUserInvites(ctx, &pb.ListUserInvitesRequest{ActorId: actorID})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "ListUserInvites", zap.String("actor_id", actorID))
+	}
+	return res.GetInvites(), nil
+}
+
+func (s *APIService) ResendUserInvite(ctx context.Context, actorID, id string) (*pb.UserInvite, error) {
+	res, err := s.grpcClient.ResendUserInvite(ctx, &pb.ResendUserInviteRequest{ActorId: actorID, Id: id})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "ResendUserInvite", zap.String("invite_id", id))
+	}
+	return res.GetInvite(), nil
+}
+
+func (s *APIService) RevokeUserInvite(ctx context.Context, actorID, id string) error {
+	_, err := s.grpcClient.RevokeUserInvite(ctx, &pb.RevokeUserInviteRequest{ActorId: actorID, Id: id})
+	if err != nil {
+		return s.handleGRPCError(ctx, err, "RevokeUserInvite", zap.String("invite_id", id))
+	}
+	return nil
+}
+
+// AcceptUserInvite activates an invited account and issues the same access token as a password login
+func (s *APIService) AcceptUserInvite(ctx context.Context, req *pb.AcceptUserInviteRequest) (*pb.AuthenticateUserResponse, error) {
+	res, err := s.grpcClient.AcceptUserInvite(ctx, req)
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "AcceptUserInvite")
+	}
+	tokenString, err := s.issueToken(ctx, res.GetUser(), nil, res.GetToken())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AuthenticateUserResponse{
+		Token: tokenString,
+		User:  res.GetUser(),
+	}, nil
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"context"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"go.uber.org/zap"
+)
+
+// RequestLoginLink has a passwordless login link mailed to email, bound to deviceSecret
+func (s *APIService) RequestLoginLink(ctx context.Context, email, deviceSecret string) (*pb.RequestLoginLinkResponse, error) {
+	res, err := s.grpcClient.RequestLoginLink(ctx, &pb.RequestLoginLinkRequest{Email: email, DeviceSecret: deviceSecret})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "RequestLoginLink", zap.String("email", email))
+	}
+	return res, nil
+}
+
+// RedeemLoginLink logs in with a mailed login link and issues the same access token as a password login
+func (s *APIService) RedeemLoginLink(ctx context.Context, token, deviceSecret string) (*pb.AuthenticateUserResponse, error) {
+	res, err := s.grpcClient.RedeemLoginLink(ctx, &pb.RedeemLoginLinkRequest{Token: token, DeviceSecret: deviceSecret})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "RedeemLoginLink")
+	}
+	tokenString, err := s.issueToken(ctx, res.GetUser(), nil, res.GetToken())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AuthenticateUserResponse{
+		Token: tokenString,
+		User:  res.GetUser(),
+	}, nil
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"go.uber.org/zap"
+)
+
+// OrgRoleName turns ORGANIZATION_ROLE_BUYER into "buyer"
+func OrgRoleName(role pb.OrganizationRole) string {
+	return strings.ToLower(strings.TrimPrefix(role.String(), "ORGANIZATION_ROLE_"))
+}
+
+// ParseOrgRole turns a role name such as "buyer" into its protobuf value
+func ParseOrgRole(name string) pb.OrganizationRole {
+	return pb.OrganizationRole(pb.OrganizationRole_value["ORGANIZATION_ROLE_"+strings.ToUpper(name)])
+}
+
+func (s *APIService) CreateOrganization(ctx context.Context, actorID, name string) (*pb.Organization, error) {
+	res, err := s.grpcClient.CreateOrganization(ctx, &pb.CreateOrganizationRequest{ActorId: actorID, Name: name})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "CreateOrganization", zap.String("actor_id", actorID))
+	}
+	return res.GetOrganization(), nil
+}
+
+func (s *APIService) GetOrganization(ctx context.Context, actorID, orgID string) (*pb.GetOrganizationResponse, error) {
+	res, err := s.grpcClient.GetOrganization(ctx, &pb.GetOrganizationRequest{ActorId: actorID, OrganizationId: orgID})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "GetOrganization", zap.String("organization_id", orgID))
+	}
+	return res, nil
+}
+
+func (s *APIService) ListMyOrganizations(ctx context.Context, userID string) ([]*pb.OrganizationMember, error) {
+	res, err := s.grpcClient.ListMyOrganizations(ctx, &pb.ListMyOrganizationsRequest{UserId: userID})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "ListMyOrganizations", zap.String("user_id", userID))
+	}
+	return res.GetMemberships(), nil
+}
+
+func (s *APIService) ListOrganizationMembers(ctx context.Context, actorID, orgID string) ([]*pb.OrganizationMember, error) {
+	res, err := s.grpcClient.ListOrganizationMembers(ctx, &pb.ListOrganizationMembersRequest{ActorId: actorID, OrganizationId: orgID})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "ListOrganizationMembers", zap.String("organization_id", orgID))
+	}
+	return res.GetMembers(), nil
+}
+
+func (s *APIService) UpdateOrganizationMember(ctx context.Context, req *pb.UpdateOrganizationMemberRequest) (*pb.OrganizationMember, error) {
+	res, err := s.grpcClient.UpdateOrganizationMember(ctx, req)
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "UpdateOrganizationMember", zap.String("organization_id", req.GetOrganizationId()), zap.String("user_id", req.GetUserId()))
+	}
+	return res.GetMember(), nil
+}
+
+func (s *APIService) RemoveOrganizationMember(ctx context.Context, actorID, orgID, userID string) error {
+	_, err := s.grpcClient.RemoveOrganizationMember(ctx, &pb.RemoveOrganizationMemberRequest{
+		ActorId:        actorID,
+		OrganizationId: orgID,
+		UserId:         userID,
+	})
+	return s.handleGRPCError(ctx, err, "RemoveOrganizationMember", zap.String("organization_id", orgID), zap.String("user_id", userID))
+}
+
+func (s *APIService) InviteOrganizationMember(ctx context.Context, req *pb.InviteOrganizationMemberRequest) (*pb.OrganizationInvitation, error) {
+	res, err := s.grpcClient.InviteOrganizationMember(ctx, req)
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "InviteOrganizationMember", zap.String("organization_id", req.GetOrganizationId()))
+	}
+	return res.GetInvitation(), nil
+}
+
+func (s *APIService) ListOrganizationInvitations(ctx context.Context, actorID, orgID string) ([]*pb.OrganizationInvitation, error) {
+	res, err := s.grpcClient.ListOrganizationInvitations(ctx, &pb.ListOrganizationInvitationsRequest{ActorId: actorID, OrganizationId: orgID})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "ListOrganizationInvitations", zap.String("organization_id", orgID))
+	}
+	return res.GetInvitations(), nil
+}
+
+func (s *APIService) RevokeOrganizationInvitation(ctx context.Context, actorID, invitationID string) error {
+	_, err := s.grpcClient.RevokeOrganizationInvitation(ctx, &pb.RevokeOrganizationInvitationRequest{ActorId: actorID, InvitationId: invitationID})
+	return s.handleGRPCError(ctx, err, "RevokeOrganizationInvitation", zap.String("invitation_id", invitationID))
+}
+
+func (s *APIService) ListMyInvitations(ctx context.Context, userID string) ([]*pb.OrganizationInvitation, error) {
+	res, err := s.grpcClient.ListMyInvitations(ctx, &pb.ListMyInvitationsRequest{UserId: userID})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "ListMyInvitations", zap.String("user_id", userID))
+	}
+	return res.GetInvitations(), nil
+}
+
+func (s *APIService) AcceptOrganizationInvitation(ctx context.Context, userID, invitationID, token string) (*pb.OrganizationMember, error) {
+	res, err := s.grpcClient.AcceptOrganizationInvitation(ctx, &pb.AcceptOrganizationInvitationRequest{UserId: userID, InvitationId: invitationID, Token: token})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "AcceptOrganizationInvitation", zap.String("invitation_id", invitationID))
+	}
+	return res.GetMembership(), nil
+}
+
+// SwitchOrganization issues a new access token with orgID as the active organization,
+// for the same login session as the caller's token. An empty orgID issues a token
+// without an active organization.
+func (s *APIService) SwitchOrganization(ctx context.Context, userID, sessionID, orgID string) (*pb.AuthenticateUserResponse, error) {
+	user, err := s.grpcClient.GetUser(ctx, &pb.GetUserRequest{Id: userID})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "SwitchOrganization", zap.String("user_id", userID))
+	}
+	if !user.GetUser().GetIsActive() {
+		return nil, fmt.Errorf("%w: account is locked", ErrPermissionDenied)
+	}
+
+	var membership *pb.OrganizationMember
+	if orgID != "" {
+		org, err := s.grpcClient.GetOrganization(ctx, &pb.GetOrganizationRequest{ActorId: userID, OrganizationId: orgID})
+		if err != nil {
+			return nil, s.handleGRPCError(ctx, err, "SwitchOrganization", zap.String("organization_id", orgID))
+		}
+		membership = org.GetMembership()
+	}
+
+	token, err := s.issueToken(ctx, user.GetUser(), membership, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AuthenticateUserResponse{Token: token, User: user.GetUser()}, nil
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"go.uber.org/zap"
+)
+
+// BeginPasskeyRegistration returns the challenge for registering a passkey for the user
+func (s *APIService) BeginPasskeyRegistration(ctx context.Context, userID string) (*pb.PasskeyChallengeResponse, error) {
+	res, err := s.grpcClient.BeginPasskeyRegistration(ctx, &pb.BeginPasskeyRegistrationRequest{UserId: userID})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "BeginPasskeyRegistration", zap.String("user_id", userID))
+	}
+	return res, nil
+}
+
+func (s *APIService) FinishPasskeyRegistration(ctx context.Context, req *pb.FinishPasskeyRegistrationRequest) (*pb.Passkey, error) {
+	res, err := s.grpcClient.FinishPasskeyRegistration(ctx, req)
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "FinishPasskeyRegistration", zap.String("user_id", req.GetUserId()))
+	}
+	return res.GetPasskey(), nil
+}
+
+// BeginPasskeyLogin returns the challenge for logging in with a passkey
+func (s *APIService) BeginPasskeyLogin(ctx context.Context) (*pb.PasskeyChallengeResponse, error) {
+	res, err := s.grpcClient.BeginPasskeyLogin(ctx, &pb.BeginPasskeyLoginRequest{})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "BeginPasskeyLogin")
+	}
+	return res, nil
+}
+
+// FinishPasskeyLogin logs in with a passkey and issues the same access token as a password login
+func (s *APIService) FinishPasskeyLogin(ctx context.Context, ceremonyID string, credential []byte) (*pb.AuthenticateUserResponse, error) {
+	res, err := s.grpcClient.FinishPasskeyLogin(ctx, &pb.FinishPasskeyLoginRequest{CeremonyId: ceremonyID, Credential: credential})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "FinishPasskeyLogin")
+	}
+	tokenString, err := s.issueToken(ctx, res.GetUser(), nil, res.GetToken())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AuthenticateUserResponse{
+		Token: tokenString,
+		User:  res.GetUser(),
+	}, nil
+}
+
+func (s *APIService) ListPasskeys(ctx context.Context, userID string) ([]*pb.Passkey, error) {
+	res, err := s.grpcClient.ListPasskeys(ctx, &pb.ListPasskeysRequest{UserId: userID})
+	if err != nil {
+		return nil, s.handleGRPCError(ctx, err, "ListPasskeys", zap.String("user_id", userID))
+	}
+	return res.GetPasskeys(), nil
+}
+
+func (s *APIService) DeletePasskey(ctx context.Context, userID, id string) error {
+	_, err := s.grpcClient.DeletePasskey(ctx, &pb.DeletePasskeyRequest{UserId: userID, Id: id})
+	if err != nil {
+		return s.handleGRPCError(ctx, err, "DeletePasskey", zap.String("user_id", userID), zap.String("passkey_id", id))
+	}
+	return nil
+}
+
+// SetTwoFactor turns on or off the requirement for the user to log in with a passkey
+func (s *APIService) SetTwoFactor(ctx context.Context, userID string, enabled bool) error {
+	_, err := s.grpcClient.SetTwoFactor(ctx, &pb.SetTwoFactorRequest{UserId: userID, Enabled: enabled})
+	if err != nil {
+		return s.handleGRPCError(ctx, err, "SetTwoFactor", zap.String("user_id", userID))
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package clientip
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataKey is the gRPC metadata key carrying the address of the HTTP client a call is
+// made for; user-svc rate limits callers by it
+const MetadataKey = "x-forwarded-for"
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the client address
+func NewContext(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, contextKey{}, addr)
+}
+
+// FromContext returns the client address carried by ctx, or "" when there is none
+func FromContext(ctx context.Context) string {
+	addr, _ := ctx.Value(contextKey{}).(string)
+	return addr
+}
+
+// UnaryClientInterceptor sends the client address of the context along with every call
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if addr := FromContext(ctx); addr != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, MetadataKey, addr)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
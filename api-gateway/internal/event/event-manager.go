@@ -8,6 +8,7 @@ import (
 
 	"github.com/asadlive84/shopper/api-gateway/internal/adapters/grpc"
 	"github.com/asadlive84/shopper/api-gateway/internal/rabbitmq"
+	"github.com/asadlive84/shopper/api-gateway/internal/tenant"
 
 	// pb "github.com/asadlive84/shopper-proto/golang/user"
 	"github.com/gorilla/websocket"
@@ -22,7 +23,7 @@ type Event struct {
 type EventManager struct {
 	logger       *zap.Logger
 	rabbitClient *rabbitmq.Client
-	clients      map[*websocket.Conn]bool
+	clients      map[*websocket.Conn]string // Connected clients and their tenant
 	grpcClient   *grpc.UserGRPCClient
 }
 
@@ -30,7 +31,7 @@ func NewEventManager(logger *zap.Logger, rabbit *rabbitmq.Client, grpcClient *gr
 	return &EventManager{
 		logger:       logger,
 		rabbitClient: rabbit,
-		clients:      make(map[*websocket.Conn]bool),
+		clients:      make(map[*websocket.Conn]string),
 		grpcClient:   grpcClient,
 	}
 }
@@ -88,7 +89,7 @@ func (em *EventManager) handleLogout(ctx context.Context, userID, email string,
 		em.logger.Error("Failed to publish logout to RabbitMQ", zap.Error(err))
 	}
 	// ক্লায়েন্টদের কাছে JSON ফরম্যাটে ব্রডকাস্ট
-	em.broadcast(originalMsg)
+	em.broadcast(ctx, originalMsg)
 }
 
 // handleMessage, handleStatusUpdate, broadcast, ConsumeEvents, AddClient, RemoveClient আগের মতোই থাকবে
@@ -98,7 +99,7 @@ func (em *EventManager) handleMessage(ctx context.Context, userID, content strin
 	if err := em.rabbitClient.Publish(ctx, "message_notifications", string(msg)); err != nil {
 		em.logger.Error("Failed to publish message to RabbitMQ", zap.Error(err))
 	}
-	em.broadcast(msg)
+	em.broadcast(ctx, msg)
 }
 
 func (em *EventManager) handleStatusUpdate(ctx context.Context, userID, status string) {
@@ -107,11 +108,16 @@ func (em *EventManager) handleStatusUpdate(ctx context.Context, userID, status s
 	if err := em.rabbitClient.Publish(ctx, "status_notifications", string(msg)); err != nil {
 		em.logger.Error("Failed to publish status to RabbitMQ", zap.Error(err))
 	}
-	em.broadcast(msg)
+	em.broadcast(ctx, msg)
 }
 
-func (em *EventManager) broadcast(msg []byte) {
-	for client := range em.clients {
+// broadcast sends msg to the connected clients of the tenant in ctx
+func (em *EventManager) broadcast(ctx context.Context, msg []byte) {
+	id := tenant.FromContext(ctx)
+	for client, clientTenant := range em.clients {
+		if clientTenant != id {
+			continue
+		}
 		if err := client.WriteMessage(websocket.TextMessage, msg); err != nil {
 			em.logger.Error("Failed to send message to client", zap.Error(err))
 			client.Close()
@@ -125,7 +131,7 @@ func (em *EventManager) ConsumeEvents(queue string) {
 	fmt.Printf("queue %+v\n", queue)
 	fmt.Println("========================================")
 	err := em.rabbitClient.Consume(queue, func(ctx context.Context, msg string) {
-		em.logger.Info("Received event from RabbitMQ", zap.String("message", msg))
+		em.logger.Info("Received event from RabbitMQ", zap.String("tenant_id", tenant.FromContext(ctx)), zap.String("message", msg))
 		parts := strings.SplitN(msg, ":", 3)
 		if len(parts) < 2 {
 			em.logger.Warn("Invalid message format", zap.String("message", msg))
@@ -158,6 +164,10 @@ func (em *EventManager) ConsumeEvents(queue string) {
 			userID := parts[1]
 			content := parts[2]
 			em.logger.Info("Message received", zap.String("user_id", userID), zap.String("content", content))
+		case "user_erased":
+			// The gateway keeps no per-user state; connected clients learn about it from the broadcast below
+			userID := parts[1]
+			em.logger.Info("User erased", zap.String("user_id", userID))
 		case "status":
 			if len(parts) < 3 {
 				em.logger.Warn("Invalid status event format", zap.String("message", msg))
@@ -177,15 +187,15 @@ func (em *EventManager) ConsumeEvents(queue string) {
 		default:
 			em.logger.Warn("Unknown RabbitMQ event type", zap.String("type", eventType))
 		}
-		em.broadcast([]byte(msg))
+		em.broadcast(ctx, []byte(msg))
 	})
 	if err != nil {
 		em.logger.Error("Failed to consume from RabbitMQ", zap.Error(err))
 	}
 }
 
-func (em *EventManager) AddClient(conn *websocket.Conn) {
-	em.clients[conn] = true
+func (em *EventManager) AddClient(conn *websocket.Conn, tenantID string) {
+	em.clients[conn] = tenantID
 }
 
 func (em *EventManager) RemoveClient(conn *websocket.Conn) {
@@ -0,0 +1,54 @@
+package idempotency
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// Header is the HTTP header a client names the idempotency key of a request with
+	Header = "Idempotency-Key"
+	// MetadataKey is the gRPC metadata key the idempotency key is forwarded to user-svc with
+	MetadataKey = "idempotency-key"
+	// MaxLength bounds the keys clients may choose
+	MaxLength = 255
+)
+
+type contextKey struct{}
+
+// Valid reports whether key is a usable idempotency key: printable ASCII of at most MaxLength characters
+func Valid(key string) bool {
+	if key == "" || len(key) > MaxLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a copy of ctx carrying the idempotency key
+func NewContext(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// FromContext returns the idempotency key carried by ctx, or ""
+func FromContext(ctx context.Context) string {
+	key, _ := ctx.Value(contextKey{}).(string)
+	return key
+}
+
+// UnaryClientInterceptor forwards the idempotency key of the context, if any, with every call.
+// user-svc decides which calls honour it.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if key := FromContext(ctx); key != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, MetadataKey, key)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,58 @@
+package keys
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"math/big"
+)
+
+// JSONWebKey is the public half of an RSA signing key
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// JSONWebKeySet is a JWKS document, as served at /.well-known/jwks.json
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+func newJSONWebKey(id string, key *rsa.PublicKey) JSONWebKey {
+	n, e := publicComponents(key)
+	return JSONWebKey{KeyType: "RSA", Use: "sig", Algorithm: "RS256", KeyID: id, Modulus: n, Exponent: e}
+}
+
+// PublicKey decodes the RSA public key
+func (k JSONWebKey) PublicKey() (*rsa.PublicKey, error) {
+	if k.KeyType != "RSA" {
+		return nil, errors.New("unsupported key type " + k.KeyType)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(k.Modulus)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.Exponent)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+// publicComponents returns the base64url-encoded modulus and exponent of key
+func publicComponents(key *rsa.PublicKey) (n, e string) {
+	return base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint of key, used as its key ID
+func thumbprint(key *rsa.PublicKey) string {
+	n, e := publicComponents(key)
+	sum := sha256.Sum256([]byte(`{"e":"` + e + `","kty":"RSA","n":"` + n + `"}`))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
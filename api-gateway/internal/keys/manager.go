@@ -0,0 +1,234 @@
+// Package keys manages the RSA keys the gateway signs access tokens with and verifies
+// incoming tokens against the published key sets (JWKS) of every trusted issuer.
+package keys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// createdHeader is the PEM header recording when a stored key was generated
+const createdHeader = "Created"
+
+// Key is a signing key, identified by the RFC 7638 thumbprint of its public half
+type Key struct {
+	ID      string
+	Private *rsa.PrivateKey
+	Created time.Time
+}
+
+// Manager holds the gateway's signing keys. The newest key signs; a key replaced by a
+// newer one is retired but stays published for the overlap window, so tokens it signed
+// keep verifying until they expire. The overlap must be at least the token lifetime.
+type Manager struct {
+	mu       sync.RWMutex
+	keys     []*Key // Newest first
+	dir      string
+	rotation time.Duration
+	overlap  time.Duration
+	logger   *zap.Logger
+}
+
+// NewManager loads the keys stored in dir and makes sure a current signing key exists.
+// With an empty dir keys live in memory only, so tokens do not survive a restart and
+// replicas do not share keys.
+func NewManager(dir string, rotation, overlap time.Duration, logger *zap.Logger) (*Manager, error) {
+	m := &Manager{dir: dir, rotation: rotation, overlap: overlap, logger: logger}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	if err := m.rotateIfDue(time.Now()); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Sign signs claims with the current signing key and names it in the "kid" header
+func (m *Manager) Sign(claims jwt.Claims) (string, error) {
+	m.mu.RLock()
+	key := m.keys[0]
+	m.mu.RUnlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// JWKS returns the public keys of the current and retired keys
+func (m *Manager) JWKS() JSONWebKeySet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(m.keys))}
+	for _, key := range m.keys {
+		set.Keys = append(set.Keys, newJSONWebKey(key.ID, &key.Private.PublicKey))
+	}
+	return set
+}
+
+// Source lets the manager serve as a key source of a Verifier without a network round trip.
+// It rereads the key directory, so keys just created by another replica are found.
+func (m *Manager) Source() Source {
+	return func(context.Context) (JSONWebKeySet, error) {
+		if err := m.load(); err != nil {
+			return JSONWebKeySet{}, err
+		}
+		return m.JWKS(), nil
+	}
+}
+
+// Run rotates keys on schedule until ctx is cancelled. Keys written to the shared
+// directory by other replicas are picked up on every tick.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := m.load(); err != nil {
+			m.logger.Error("Failed to reload signing keys", zap.Error(err))
+		}
+		if err := m.rotateIfDue(time.Now()); err != nil {
+			m.logger.Error("Failed to rotate signing key", zap.Error(err))
+		}
+	}
+}
+
+// Rotate generates a new signing key; the previous one is retired
+func (m *Manager) Rotate() error {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return fmt.Errorf("generate signing key: %w", err)
+	}
+	key := &Key{ID: thumbprint(&private.PublicKey), Private: private, Created: time.Now()}
+	if err := m.store(key); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.keys = append([]*Key{key}, m.keys...)
+	m.mu.Unlock()
+	m.logger.Info("Rotated signing key", zap.String("kid", key.ID))
+	return nil
+}
+
+// rotateIfDue rotates when there is no key or the signing key is older than the rotation
+// interval, then drops retired keys whose overlap window has passed
+func (m *Manager) rotateIfDue(now time.Time) error {
+	m.mu.RLock()
+	due := len(m.keys) == 0 || (m.rotation > 0 && now.Sub(m.keys[0].Created) >= m.rotation)
+	m.mu.RUnlock()
+	if due {
+		if err := m.Rotate(); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := 1; i < len(m.keys); i++ {
+		// A key was retired when the key after it in time was created
+		if retired := m.keys[i-1].Created; now.Sub(retired) > m.overlap {
+			for _, key := range m.keys[i:] {
+				m.remove(key)
+				m.logger.Info("Dropped retired signing key", zap.String("kid", key.ID))
+			}
+			m.keys = m.keys[:i]
+			break
+		}
+	}
+	return nil
+}
+
+// load reads the keys stored in the key directory, keeping keys already in memory
+func (m *Manager) load() error {
+	if m.dir == "" {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(m.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, file := range files {
+		id := strings.TrimSuffix(filepath.Base(file), ".pem")
+		if slices.ContainsFunc(m.keys, func(k *Key) bool { return k.ID == id }) {
+			continue
+		}
+		key, err := readKey(file)
+		if err != nil {
+			m.logger.Error("Skipping unreadable signing key", zap.String("file", file), zap.Error(err))
+			continue
+		}
+		m.keys = append(m.keys, key)
+	}
+	slices.SortFunc(m.keys, func(a, b *Key) int { return b.Created.Compare(a.Created) })
+	return nil
+}
+
+func (m *Manager) store(key *Key) error {
+	if m.dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return err
+	}
+	block := &pem.Block{
+		Type:    "RSA PRIVATE KEY",
+		Headers: map[string]string{createdHeader: key.Created.UTC().Format(time.RFC3339)},
+		Bytes:   x509.MarshalPKCS1PrivateKey(key.Private),
+	}
+	// Write to a temporary file first so other replicas never read a partial key
+	path := filepath.Join(m.dir, key.ID+".pem")
+	if err := os.WriteFile(path+".tmp", pem.EncodeToMemory(block), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (m *Manager) remove(key *Key) {
+	if m.dir == "" {
+		return
+	}
+	if err := os.Remove(filepath.Join(m.dir, key.ID+".pem")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		m.logger.Error("Failed to delete retired signing key", zap.String("kid", key.ID), zap.Error(err))
+	}
+}
+
+func readKey(file string) (*Key, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "RSA PRIVATE KEY" {
+		return nil, errors.New("no RSA private key found")
+	}
+	private, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	created, err := time.Parse(time.RFC3339, block.Headers[createdHeader])
+	if err != nil {
+		return nil, fmt.Errorf("missing or invalid %s header: %w", createdHeader, err)
+	}
+	return &Key{ID: thumbprint(&private.PublicKey), Private: private, Created: created}, nil
+}
@@ -0,0 +1,188 @@
+package keys
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// minRefreshInterval limits how often an unknown kid can trigger a refetch, so tokens
+// with made-up kids cannot be used to hammer the key sources
+const minRefreshInterval = 30 * time.Second
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Source fetches a key set
+type Source func(ctx context.Context) (JSONWebKeySet, error)
+
+// HTTPSource fetches the key set published at url
+func HTTPSource(url string, client *http.Client) Source {
+	return func(ctx context.Context) (JSONWebKeySet, error) {
+		var set JSONWebKeySet
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return set, err
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			return set, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return set, fmt.Errorf("fetch %s: %s", url, res.Status)
+		}
+		err = json.NewDecoder(res.Body).Decode(&set)
+		return set, err
+	}
+}
+
+// Issuer is an issuer whose tokens a Verifier accepts, with the key set it signs them with
+type Issuer struct {
+	Name string // The "iss" claim of its tokens
+	Keys Source
+}
+
+// Verifier checks access tokens against the public keys of their issuer, cached by kid.
+// A token is only checked against the keys of the issuer its "iss" claim names, so one
+// trusted issuer cannot mint tokens claiming to come from another. The cache is
+// refreshed every refresh interval and whenever a token names a kid it does not know,
+// which is how keys rotated in by an issuer are picked up.
+type Verifier struct {
+	audience     string
+	issuers      []Issuer
+	refresh      time.Duration
+	legacySecret string
+	logger       *zap.Logger
+
+	mu      sync.RWMutex
+	keys    map[string]map[string]*rsa.PublicKey // by issuer, then kid
+	fetched time.Time
+}
+
+// NewVerifier returns a verifier accepting tokens of issuers whose "aud" claim names
+// audience. A non-empty legacySecret also accepts HS256 tokens signed with that shared
+// secret, for tokens issued before the gateway switched to key sets; those predate the
+// iss and aud claims and are not checked for them.
+func NewVerifier(audience string, refresh time.Duration, legacySecret string, logger *zap.Logger, issuers ...Issuer) *Verifier {
+	return &Verifier{
+		audience:     audience,
+		issuers:      issuers,
+		refresh:      refresh,
+		legacySecret: legacySecret,
+		logger:       logger,
+		keys:         map[string]map[string]*rsa.PublicKey{},
+	}
+}
+
+// Parse verifies a token and returns its claims
+func (v *Verifier) Parse(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	methods := []string{jwt.SigningMethodRS256.Alg()}
+	if v.legacySecret != "" {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+			return []byte(v.legacySecret), nil
+		}
+		issuer, _ := claims.GetIssuer()
+		kid, _ := token.Header["kid"].(string)
+		return v.key(ctx, issuer, kid)
+	}, jwt.WithValidMethods(methods), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if _, legacy := token.Method.(*jwt.SigningMethodHMAC); legacy {
+		return claims, nil
+	}
+	// The signature was checked with a key of the issuer named by "iss", so only the
+	// audience is left: tokens issued to anyone but the gateway, e.g. to an OpenID
+	// client, are refused
+	audience, err := claims.GetAudience()
+	if err != nil || !slices.Contains(audience, v.audience) {
+		return nil, fmt.Errorf("%w: token is not meant for %q", jwt.ErrTokenInvalidAudience, v.audience)
+	}
+	return claims, nil
+}
+
+// key returns the public key named kid of issuer, refreshing the cache when it is stale
+// or lacks the key
+func (v *Verifier) key(ctx context.Context, issuer, kid string) (*rsa.PublicKey, error) {
+	if !slices.ContainsFunc(v.issuers, func(i Issuer) bool { return i.Name == issuer }) {
+		return nil, fmt.Errorf("%w: untrusted issuer %q", jwt.ErrTokenInvalidIssuer, issuer)
+	}
+	v.mu.RLock()
+	key, ok := v.keys[issuer][kid]
+	stale := time.Since(v.fetched) > v.refresh
+	recent := time.Since(v.fetched) < minRefreshInterval
+	v.mu.RUnlock()
+	if ok && !stale {
+		return key, nil
+	}
+	if !ok && recent {
+		return nil, ErrUnknownKey
+	}
+
+	if err := v.Refresh(ctx); err != nil {
+		v.logger.Warn("Failed to refresh signing keys", zap.Error(err))
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if key, ok := v.keys[issuer][kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// Refresh refetches every issuer's key set. Keys of an issuer whose key set cannot be
+// fetched are kept from the previous fetch, so an issuer being briefly unreachable does
+// not log its users out.
+func (v *Verifier) Refresh(ctx context.Context) error {
+	keys := map[string]map[string]*rsa.PublicKey{}
+	failed := map[string]bool{}
+	var errs []error
+	for _, issuer := range v.issuers {
+		set, err := issuer.Keys(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("issuer %s: %w", issuer.Name, err))
+			failed[issuer.Name] = true
+			continue
+		}
+		if keys[issuer.Name] == nil {
+			keys[issuer.Name] = map[string]*rsa.PublicKey{}
+		}
+		for _, jwk := range set.Keys {
+			key, err := jwk.PublicKey()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("issuer %s key %s: %w", issuer.Name, jwk.KeyID, err))
+				continue
+			}
+			keys[issuer.Name][jwk.KeyID] = key
+		}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for name := range failed {
+		if keys[name] == nil {
+			keys[name] = map[string]*rsa.PublicKey{}
+		}
+		for kid, key := range v.keys[name] {
+			if _, ok := keys[name][kid]; !ok {
+				keys[name][kid] = key
+			}
+		}
+	}
+	v.keys = keys
+	v.fetched = time.Now()
+	return errors.Join(errs...)
+}
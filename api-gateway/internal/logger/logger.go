@@ -5,8 +5,11 @@ import (
     "go.uber.org/zap/zapcore"
 )
 
-func NewLogger() *zap.Logger {
+// NewLogger returns a production logger logging at level, which can be changed while
+// the gateway runs
+func NewLogger(level zap.AtomicLevel) *zap.Logger {
     config := zap.NewProductionConfig()
+    config.Level = level
     config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
     logger, err := config.Build()
     if err != nil {
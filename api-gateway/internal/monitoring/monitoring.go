@@ -26,4 +26,16 @@ var (
 			Help: "Total number of users created",
 		},
 	)
-)
\ No newline at end of file
+	PanicsRecovered = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "api_gateway_panics_recovered_total",
+			Help: "Total number of handler panics turned into 500 responses",
+		},
+	)
+	TLSCertificateExpiry = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "api_gateway_tls_certificate_expiry_timestamp_seconds",
+			Help: "When the client certificate presented to user-svc expires, as a Unix timestamp",
+		},
+	)
+)
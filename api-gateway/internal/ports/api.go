@@ -9,7 +9,51 @@ type APIPort interface {
     GetUser(ctx context.Context, id string) (*pb.User, error)
     CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.User, error)
     AuthenticateUser(ctx context.Context, req *pb.AuthenticateUserRequest) (*pb.AuthenticateUserResponse, error)
+    CheckSession(ctx context.Context, id, userID string) error
     ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error)
     UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.User, error)
-    
+    ExportMyData(ctx context.Context, userID string) (*pb.ExportMyDataResponse, error)
+    RequestErasure(ctx context.Context, req *pb.RequestErasureRequest) (*pb.ErasureRequest, error)
+    GetErasureRequest(ctx context.Context, id string) (*pb.ErasureRequest, error)
+    CreateOrganization(ctx context.Context, actorID, name string) (*pb.Organization, error)
+    GetOrganization(ctx context.Context, actorID, orgID string) (*pb.GetOrganizationResponse, error)
+    ListMyOrganizations(ctx context.Context, userID string) ([]*pb.OrganizationMember, error)
+    ListOrganizationMembers(ctx context.Context, actorID, orgID string) ([]*pb.OrganizationMember, error)
+    UpdateOrganizationMember(ctx context.Context, req *pb.UpdateOrganizationMemberRequest) (*pb.OrganizationMember, error)
+    RemoveOrganizationMember(ctx context.Context, actorID, orgID, userID string) error
+    InviteOrganizationMember(ctx context.Context, req *pb.InviteOrganizationMemberRequest) (*pb.OrganizationInvitation, error)
+    ListOrganizationInvitations(ctx context.Context, actorID, orgID string) ([]*pb.OrganizationInvitation, error)
+    RevokeOrganizationInvitation(ctx context.Context, actorID, invitationID string) error
+    ListMyInvitations(ctx context.Context, userID string) ([]*pb.OrganizationInvitation, error)
+    AcceptOrganizationInvitation(ctx context.Context, userID, invitationID, token string) (*pb.OrganizationMember, error)
+    SwitchOrganization(ctx context.Context, userID, sessionID, orgID string) (*pb.AuthenticateUserResponse, error)
+    CreateApiKey(ctx context.Context, req *pb.CreateApiKeyRequest) (*pb.CreateApiKeyResponse, error)
+    ListApiKeys(ctx context.Context, userID string) ([]*pb.ApiKey, error)
+    GetApiKey(ctx context.Context, userID, id string) (*pb.ApiKey, error)
+    UpdateApiKey(ctx context.Context, req *pb.UpdateApiKeyRequest) (*pb.ApiKey, error)
+    RevokeApiKey(ctx context.Context, userID, id string) error
+    AuthenticateApiKey(ctx context.Context, key string) (*pb.AuthenticateApiKeyResponse, error)
+    LoginWithExternalIdentity(ctx context.Context, req *pb.LinkExternalIdentityRequest) (*pb.AuthenticateUserResponse, error)
+    RequestLoginLink(ctx context.Context, email, deviceSecret string) (*pb.RequestLoginLinkResponse, error)
+    RedeemLoginLink(ctx context.Context, token, deviceSecret string) (*pb.AuthenticateUserResponse, error)
+    BeginPasskeyRegistration(ctx context.Context, userID string) (*pb.PasskeyChallengeResponse, error)
+    FinishPasskeyRegistration(ctx context.Context, req *pb.FinishPasskeyRegistrationRequest) (*pb.Passkey, error)
+    BeginPasskeyLogin(ctx context.Context) (*pb.PasskeyChallengeResponse, error)
+    FinishPasskeyLogin(ctx context.Context, ceremonyID string, credential []byte) (*pb.AuthenticateUserResponse, error)
+    ListPasskeys(ctx context.Context, userID string) ([]*pb.Passkey, error)
+    DeletePasskey(ctx context.Context, userID, id string) error
+    SetTwoFactor(ctx context.Context, userID string, enabled bool) error
+
+    InviteUser(ctx context.Context, req *pb.InviteUserRequest) (*pb.UserInvite, error)
+    ListUserInvites(ctx context.Context, actorID string) ([]*pb.UserInvite, error)
+    ResendUserInvite(ctx context.Context, actorID, id string) (*pb.UserInvite, error)
+    RevokeUserInvite(ctx context.Context, actorID, id string) error
+    AcceptUserInvite(ctx context.Context, req *pb.AcceptUserInviteRequest) (*pb.AuthenticateUserResponse, error)
+
+    Impersonate(ctx context.Context, actorID, userID, reason string) (string, *pb.ImpersonateResponse, error)
+    CheckImpersonation(ctx context.Context, id string) error
+    EndImpersonation(ctx context.Context, actorID, id string) (*pb.Impersonation, error)
+
+    RequestEmailChange(ctx context.Context, req *pb.RequestEmailChangeRequest) (*pb.RequestEmailChangeResponse, error)
+    ConfirmEmailChange(ctx context.Context, token string) (*pb.User, error)
 }
\ No newline at end of file
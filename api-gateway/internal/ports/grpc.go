@@ -9,6 +9,50 @@ type UserGRPCClientPort interface {
     GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.GetUserResponse, error)
     CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error)
     AuthenticateUser(ctx context.Context, req *pb.AuthenticateUserRequest) (*pb.AuthenticateUserResponse, error)
+    GetSession(ctx context.Context, req *pb.GetSessionRequest) (*pb.GetSessionResponse, error)
     ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error)
     UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error)
+    ExportMyData(ctx context.Context, req *pb.ExportMyDataRequest) (*pb.ExportMyDataResponse, error)
+    RequestErasure(ctx context.Context, req *pb.RequestErasureRequest) (*pb.RequestErasureResponse, error)
+    GetErasureRequest(ctx context.Context, req *pb.GetErasureRequestRequest) (*pb.GetErasureRequestResponse, error)
+    CreateOrganization(ctx context.Context, req *pb.CreateOrganizationRequest) (*pb.CreateOrganizationResponse, error)
+    GetOrganization(ctx context.Context, req *pb.GetOrganizationRequest) (*pb.GetOrganizationResponse, error)
+    ListMyOrganizations(ctx context.Context, req *pb.ListMyOrganizationsRequest) (*pb.ListMyOrganizationsResponse, error)
+    ListOrganizationMembers(ctx context.Context, req *pb.ListOrganizationMembersRequest) (*pb.ListOrganizationMembersResponse, error)
+    UpdateOrganizationMember(ctx context.Context, req *pb.UpdateOrganizationMemberRequest) (*pb.UpdateOrganizationMemberResponse, error)
+    RemoveOrganizationMember(ctx context.Context, req *pb.RemoveOrganizationMemberRequest) (*pb.RemoveOrganizationMemberResponse, error)
+    InviteOrganizationMember(ctx context.Context, req *pb.InviteOrganizationMemberRequest) (*pb.InviteOrganizationMemberResponse, error)
+    ListOrganizationInvitations(ctx context.Context, req *pb.ListOrganizationInvitationsRequest) (*pb.ListOrganizationInvitationsResponse, error)
+    RevokeOrganizationInvitation(ctx context.Context, req *pb.RevokeOrganizationInvitationRequest) (*pb.RevokeOrganizationInvitationResponse, error)
+    ListMyInvitations(ctx context.Context, req *pb.ListMyInvitationsRequest) (*pb.ListMyInvitationsResponse, error)
+    AcceptOrganizationInvitation(ctx context.Context, req *pb.AcceptOrganizationInvitationRequest) (*pb.AcceptOrganizationInvitationResponse, error)
+    CreateApiKey(ctx context.Context, req *pb.CreateApiKeyRequest) (*pb.CreateApiKeyResponse, error)
+    ListApiKeys(ctx context.Context, req *pb.ListApiKeysRequest) (*pb.ListApiKeysResponse, error)
+    GetApiKey(ctx context.Context, req *pb.GetApiKeyRequest) (*pb.GetApiKeyResponse, error)
+    UpdateApiKey(ctx context.Context, req *pb.UpdateApiKeyRequest) (*pb.UpdateApiKeyResponse, error)
+    RevokeApiKey(ctx context.Context, req *pb.RevokeApiKeyRequest) (*pb.RevokeApiKeyResponse, error)
+    AuthenticateApiKey(ctx context.Context, req *pb.AuthenticateApiKeyRequest) (*pb.AuthenticateApiKeyResponse, error)
+    LinkExternalIdentity(ctx context.Context, req *pb.LinkExternalIdentityRequest) (*pb.LinkExternalIdentityResponse, error)
+    RequestLoginLink(ctx context.Context, req *pb.RequestLoginLinkRequest) (*pb.RequestLoginLinkResponse, error)
+    RedeemLoginLink(ctx context.Context, req *pb.RedeemLoginLinkRequest) (*pb.RedeemLoginLinkResponse, error)
+    BeginPasskeyRegistration(ctx context.Context, req *pb.BeginPasskeyRegistrationRequest) (*pb.PasskeyChallengeResponse, error)
+    FinishPasskeyRegistration(ctx context.Context, req *pb.FinishPasskeyRegistrationRequest) (*pb.FinishPasskeyRegistrationResponse, error)
+    BeginPasskeyLogin(ctx context.Context, req *pb.BeginPasskeyLoginRequest) (*pb.PasskeyChallengeResponse, error)
+    FinishPasskeyLogin(ctx context.Context, req *pb.FinishPasskeyLoginRequest) (*pb.FinishPasskeyLoginResponse, error)
+    ListPasskeys(ctx context.Context, req *pb.ListPasskeysRequest) (*pb.ListPasskeysResponse, error)
+    DeletePasskey(ctx context.Context, req *pb.DeletePasskeyRequest) (*pb.DeletePasskeyResponse, error)
+    SetTwoFactor(ctx context.Context, req *pb.SetTwoFactorRequest) (*pb.SetTwoFactorResponse, error)
+
+    InviteUser(ctx context.Context, req *pb.InviteUserRequest) (*pb.InviteUserResponse, error)
+    ListUserInvites(ctx context.Context, req *pb.ListUserInvitesRequest) (*pb.ListUserInvitesResponse, error)
+    ResendUserInvite(ctx context.Context, req *pb.ResendUserInviteRequest) (*pb.ResendUserInviteResponse, error)
+    RevokeUserInvite(ctx context.Context, req *pb.RevokeUserInviteRequest) (*pb.RevokeUserInviteResponse, error)
+    AcceptUserInvite(ctx context.Context, req *pb.AcceptUserInviteRequest) (*pb.AcceptUserInviteResponse, error)
+
+    Impersonate(ctx context.Context, req *pb.ImpersonateRequest) (*pb.ImpersonateResponse, error)
+    GetImpersonation(ctx context.Context, req *pb.GetImpersonationRequest) (*pb.GetImpersonationResponse, error)
+    EndImpersonation(ctx context.Context, req *pb.EndImpersonationRequest) (*pb.EndImpersonationResponse, error)
+
+    RequestEmailChange(ctx context.Context, req *pb.RequestEmailChangeRequest) (*pb.RequestEmailChangeResponse, error)
+    ConfirmEmailChange(ctx context.Context, req *pb.ConfirmEmailChangeRequest) (*pb.ConfirmEmailChangeResponse, error)
 }
\ No newline at end of file
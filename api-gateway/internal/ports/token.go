@@ -0,0 +1,8 @@
+package ports
+
+import "github.com/golang-jwt/jwt/v5"
+
+// TokenSignerPort signs the access tokens the gateway issues
+type TokenSignerPort interface {
+	Sign(claims jwt.Claims) (string, error)
+}
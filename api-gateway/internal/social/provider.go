@@ -0,0 +1,271 @@
+// Package social logs users in with external OAuth2 and OpenID Connect identity
+// providers such as Google or GitHub, the gateway acting as the relying party.
+package social
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asadlive84/shopper/api-gateway/internal/keys"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// jwksRefresh is how often the cached signing keys of a provider are refetched
+const jwksRefresh = time.Hour
+
+var (
+	ErrUnknownProvider = errors.New("unknown identity provider")
+	ErrInvalidState    = errors.New("invalid or expired login state")
+	ErrProvider        = errors.New("identity provider error")
+)
+
+// Config describes an identity provider. OpenID Connect providers only need an issuer;
+// their endpoints are discovered from it. Plain OAuth2 providers such as GitHub name
+// their endpoints instead and identify the user through the user info endpoint.
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	AuthURL      string // Overrides the discovered authorization endpoint
+	TokenURL     string // Overrides the discovered token endpoint
+	UserInfoURL  string // Overrides the discovered user info endpoint
+}
+
+// Identity is the account a provider authenticated
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider talks to one identity provider
+type Provider struct {
+	cfg    Config
+	client *http.Client
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	ready    bool
+	verifier *keys.Verifier // Verifies ID tokens; nil for plain OAuth2 providers
+}
+
+func newProvider(cfg Config, client *http.Client, logger *zap.Logger) (*Provider, error) {
+	if cfg.Name == "" || cfg.ClientID == "" {
+		return nil, fmt.Errorf("identity provider %q: name and client ID are required", cfg.Name)
+	}
+	if cfg.Issuer == "" && (cfg.AuthURL == "" || cfg.TokenURL == "" || cfg.UserInfoURL == "") {
+		return nil, fmt.Errorf("identity provider %q: an issuer or the authorization, token and user info URLs are required", cfg.Name)
+	}
+	return &Provider{cfg: cfg, client: client, logger: logger.With(zap.String("provider", cfg.Name))}, nil
+}
+
+// openID reports whether the provider is asked for an ID token
+func (p *Provider) openID() bool {
+	return p.cfg.Issuer != "" && slices.Contains(p.cfg.Scopes, "openid")
+}
+
+// discover fills in the endpoints the configuration leaves out from the issuer's
+// discovery document. It runs on first use rather than at startup, so a provider
+// being unreachable does not keep the gateway from starting.
+func (p *Provider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ready {
+		return nil
+	}
+	if p.cfg.Issuer == "" {
+		p.ready = true
+		return nil
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	discoveryURL := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, discoveryURL, "", &doc); err != nil {
+		return fmt.Errorf("%w: discovery: %v", ErrProvider, err)
+	}
+	if doc.Issuer != p.cfg.Issuer {
+		return fmt.Errorf("%w: discovery document names issuer %q", ErrProvider, doc.Issuer)
+	}
+	if p.cfg.AuthURL == "" {
+		p.cfg.AuthURL = doc.AuthorizationEndpoint
+	}
+	if p.cfg.TokenURL == "" {
+		p.cfg.TokenURL = doc.TokenEndpoint
+	}
+	if p.cfg.UserInfoURL == "" {
+		p.cfg.UserInfoURL = doc.UserInfoEndpoint
+	}
+	if doc.JWKSURI != "" {
+		p.verifier = keys.NewVerifier(p.cfg.ClientID, jwksRefresh, "", p.logger, keys.Issuer{Name: p.cfg.Issuer, Keys: keys.HTTPSource(doc.JWKSURI, p.client)})
+	}
+	p.ready = true
+	return nil
+}
+
+// authCodeURL returns the URL the user is sent to for logging in at the provider
+func (p *Provider) authCodeURL(ctx context.Context, state *State, redirectURI string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {redirectURI},
+		"state":                 {state.State},
+		"code_challenge":        {state.Challenge()},
+		"code_challenge_method": {"S256"},
+	}
+	if len(p.cfg.Scopes) > 0 {
+		params.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	}
+	if p.openID() {
+		params.Set("nonce", state.Nonce)
+	}
+	sep := "?"
+	if strings.Contains(p.cfg.AuthURL, "?") {
+		sep = "&"
+	}
+	return p.cfg.AuthURL + sep + params.Encode(), nil
+}
+
+// exchange redeems an authorization code and returns the identity it was issued for
+func (p *Provider) exchange(ctx context.Context, code string, state *State, redirectURI string) (*Identity, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {state.Verifier},
+	}
+	if p.cfg.ClientSecret != "" {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: token request: %v", ErrProvider, err)
+	}
+	defer res.Body.Close()
+
+	var tokens struct {
+		AccessToken      string `json:"access_token"`
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("%w: token response: %v", ErrProvider, err)
+	}
+	if tokens.Error != "" || res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: token request: %s %s %s", ErrProvider, res.Status, tokens.Error, tokens.ErrorDescription)
+	}
+
+	if p.openID() {
+		if tokens.IDToken == "" {
+			return nil, fmt.Errorf("%w: no ID token issued", ErrProvider)
+		}
+		return p.verifyIDToken(ctx, tokens.IDToken, state.Nonce)
+	}
+	if tokens.AccessToken == "" {
+		return nil, fmt.Errorf("%w: no access token issued", ErrProvider)
+	}
+	var info jwt.MapClaims
+	if err := p.getJSON(ctx, p.cfg.UserInfoURL, tokens.AccessToken, &info); err != nil {
+		return nil, fmt.Errorf("%w: user info: %v", ErrProvider, err)
+	}
+	return p.identity(info)
+}
+
+// verifyIDToken checks the signature, issuer, audience and nonce of an ID token; the
+// verifier only accepts tokens of the provider's issuer that were issued to this client
+func (p *Provider) verifyIDToken(ctx context.Context, idToken, nonce string) (*Identity, error) {
+	if p.verifier == nil {
+		return nil, fmt.Errorf("%w: the provider publishes no signing keys", ErrProvider)
+	}
+	claims, err := p.verifier.Parse(ctx, idToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: ID token: %v", ErrProvider, err)
+	}
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return nil, fmt.Errorf("%w: ID token nonce mismatch", ErrProvider)
+	}
+	return p.identity(claims)
+}
+
+// identity reads an identity from ID token claims or a user info response. Providers
+// without a "sub" claim, like GitHub, identify the account by a numeric "id".
+func (p *Provider) identity(claims jwt.MapClaims) (*Identity, error) {
+	identity := &Identity{Provider: p.cfg.Name}
+	identity.Subject, _ = claims["sub"].(string)
+	if identity.Subject == "" {
+		if id, ok := claims["id"].(float64); ok {
+			identity.Subject = strconv.FormatInt(int64(id), 10)
+		} else if id, ok := claims["id"].(string); ok {
+			identity.Subject = id
+		}
+	}
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject identifier", ErrProvider)
+	}
+	identity.Email, _ = claims["email"].(string)
+	// Some providers send email_verified as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	identity.Name, _ = claims["name"].(string)
+	if identity.Name == "" {
+		identity.Name, _ = claims["login"].(string)
+	}
+	return identity, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url, accessToken string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch %s: %s", url, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
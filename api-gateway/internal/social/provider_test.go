@@ -0,0 +1,345 @@
+package social
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asadlive84/shopper/api-gateway/internal/keys"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	testProvider = "test"
+	testClientID = "gateway-client"
+	testCode     = "authorization-code"
+)
+
+// testIssuer is an OpenID provider that authorizes every login. The ID token it issues
+// can be altered per test to check what the relying party refuses.
+type testIssuer struct {
+	*httptest.Server
+	t   *testing.T
+	key *rsa.PrivateKey
+
+	mu        sync.Mutex
+	challenge string // code_challenge of the last authorization request
+	nonce     string // nonce of the last authorization request
+	verifier  string // code_verifier of the last token request
+	// idToken alters the claims of the ID token before it is signed
+	idToken func(claims jwt.MapClaims)
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &testIssuer{t: t, key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", issuer.discovery)
+	mux.HandleFunc("/jwks.json", issuer.jwks)
+	mux.HandleFunc("/token", issuer.token)
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+func (i *testIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 i.URL,
+		"authorization_endpoint": i.URL + "/authorize",
+		"token_endpoint":         i.URL + "/token",
+		"userinfo_endpoint":      i.URL + "/userinfo",
+		"jwks_uri":               i.URL + "/jwks.json",
+	})
+}
+
+func (i *testIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(keys.JSONWebKeySet{Keys: []keys.JSONWebKey{{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: "RS256",
+		KeyID:     "test-key",
+		Modulus:   base64.RawURLEncoding.EncodeToString(i.key.N.Bytes()),
+		Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.key.E)).Bytes()),
+	}}})
+}
+
+// authorize plays the user logging in at the provider, which remembers the PKCE
+// challenge and nonce of the authorization request
+func (i *testIssuer) authorize(authURL string) {
+	i.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	if got := u.Query().Get("code_challenge_method"); got != "S256" {
+		i.t.Fatalf("code_challenge_method = %q, want S256", got)
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.challenge = u.Query().Get("code_challenge")
+	i.nonce = u.Query().Get("nonce")
+}
+
+// token redeems testCode, refusing a code_verifier that does not match the challenge
+// like a provider enforcing PKCE does
+func (i *testIssuer) token(w http.ResponseWriter, r *http.Request) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.verifier = r.PostFormValue("code_verifier")
+	sum := sha256.Sum256([]byte(i.verifier))
+	switch {
+	case r.PostFormValue("code") != testCode || r.PostFormValue("client_id") != testClientID:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	case base64.RawURLEncoding.EncodeToString(sum[:]) != i.challenge:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":            i.URL,
+		"sub":            "subject-1",
+		"aud":            testClientID,
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          i.nonce,
+		"email":          "ada@example.com",
+		"email_verified": true,
+		"name":           "Ada",
+	}
+	if i.idToken != nil {
+		i.idToken(claims)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+	idToken, err := token.SignedString(i.key)
+	if err != nil {
+		i.t.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "access-token", "id_token": idToken})
+}
+
+func newTestRelyingParty(t *testing.T, issuer *testIssuer) *RelyingParty {
+	t.Helper()
+	rp, err := NewRelyingParty([]Config{{
+		Name:     testProvider,
+		Issuer:   issuer.URL,
+		ClientID: testClientID,
+		Scopes:   []string{"openid", "email", "profile"},
+	}}, "https://gateway.example", []byte("state-secret"), issuer.Client(), zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rp
+}
+
+// begin starts a login and lets the issuer authorize it, returning the state the
+// provider echoes in the callback and the state cookie
+func begin(t *testing.T, rp *RelyingParty, issuer *testIssuer) (state, cookie string) {
+	t.Helper()
+	authURL, cookie, err := rp.Begin(context.Background(), testProvider, "tenant-a", "")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	issuer.authorize(authURL)
+	u, _ := url.Parse(authURL)
+	return u.Query().Get("state"), cookie
+}
+
+func TestCompleteOpenIDLogin(t *testing.T) {
+	issuer := newTestIssuer(t)
+	rp := newTestRelyingParty(t, issuer)
+	state, cookie := begin(t, rp, issuer)
+
+	login, identity, err := rp.Complete(context.Background(), testProvider, cookie, state, testCode)
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if login.TenantID != "tenant-a" {
+		t.Errorf("TenantID = %q, want tenant-a", login.TenantID)
+	}
+	want := Identity{Provider: testProvider, Subject: "subject-1", Email: "ada@example.com", EmailVerified: true, Name: "Ada"}
+	if *identity != want {
+		t.Errorf("identity = %+v, want %+v", *identity, want)
+	}
+}
+
+func TestCompleteRejectsStateMismatch(t *testing.T) {
+	issuer := newTestIssuer(t)
+	rp := newTestRelyingParty(t, issuer)
+	state, cookie := begin(t, rp, issuer)
+	_, otherCookie := begin(t, rp, issuer)
+
+	tests := []struct {
+		name          string
+		cookie, state string
+	}{
+		{"state of another login", otherCookie, state},
+		{"no state", cookie, ""},
+		{"forged state", cookie, "forged"},
+		{"tampered cookie", strings.Replace(cookie, ".", "x.", 1), state},
+		{"no cookie", "", state},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := rp.Complete(context.Background(), testProvider, tt.cookie, tt.state, testCode)
+			if !errors.Is(err, ErrInvalidState) {
+				t.Errorf("Complete = %v, want %v", err, ErrInvalidState)
+			}
+		})
+	}
+}
+
+func TestCompleteRejectsNonceMismatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		nonce interface{}
+	}{
+		{"other nonce", "replayed-nonce"},
+		{"no nonce", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := newTestIssuer(t)
+			issuer.idToken = func(claims jwt.MapClaims) {
+				if tt.nonce == nil {
+					delete(claims, "nonce")
+				} else {
+					claims["nonce"] = tt.nonce
+				}
+			}
+			rp := newTestRelyingParty(t, issuer)
+			state, cookie := begin(t, rp, issuer)
+
+			_, _, err := rp.Complete(context.Background(), testProvider, cookie, state, testCode)
+			if !errors.Is(err, ErrProvider) || !strings.Contains(err.Error(), "nonce") {
+				t.Errorf("Complete = %v, want a nonce mismatch", err)
+			}
+		})
+	}
+}
+
+func TestCompleteSendsPKCEVerifier(t *testing.T) {
+	issuer := newTestIssuer(t)
+	rp := newTestRelyingParty(t, issuer)
+	state, cookie := begin(t, rp, issuer)
+
+	if _, _, err := rp.Complete(context.Background(), testProvider, cookie, state, testCode); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	login, err := rp.states.decode(cookie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issuer.verifier != login.Verifier {
+		t.Errorf("code_verifier = %q, want the one in the state cookie", issuer.verifier)
+	}
+	if issuer.challenge != login.Challenge() {
+		t.Errorf("code_challenge = %q, want %q", issuer.challenge, login.Challenge())
+	}
+
+	// A login whose challenge the provider did not see fails the provider's PKCE check
+	state, cookie = begin(t, rp, issuer)
+	issuer.challenge = "challenge-of-another-login"
+	_, _, err = rp.Complete(context.Background(), testProvider, cookie, state, testCode)
+	if !errors.Is(err, ErrProvider) {
+		t.Errorf("Complete = %v, want %v", err, ErrProvider)
+	}
+}
+
+func TestCompleteRejectsIDTokenIssuerAndAudience(t *testing.T) {
+	tests := []struct {
+		name  string
+		alter func(claims jwt.MapClaims)
+	}{
+		{"other issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }},
+		{"no issuer", func(c jwt.MapClaims) { delete(c, "iss") }},
+		{"other audience", func(c jwt.MapClaims) { c["aud"] = "another-client" }},
+		{"no audience", func(c jwt.MapClaims) { delete(c, "aud") }},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := newTestIssuer(t)
+			issuer.idToken = tt.alter
+			rp := newTestRelyingParty(t, issuer)
+			state, cookie := begin(t, rp, issuer)
+
+			_, identity, err := rp.Complete(context.Background(), testProvider, cookie, state, testCode)
+			if !errors.Is(err, ErrProvider) {
+				t.Errorf("Complete = %+v, %v, want %v", identity, err, ErrProvider)
+			}
+		})
+	}
+
+	t.Run("audience among others", func(t *testing.T) {
+		issuer := newTestIssuer(t)
+		issuer.idToken = func(c jwt.MapClaims) { c["aud"] = []string{"another-client", testClientID} }
+		rp := newTestRelyingParty(t, issuer)
+		state, cookie := begin(t, rp, issuer)
+
+		if _, _, err := rp.Complete(context.Background(), testProvider, cookie, state, testCode); err != nil {
+			t.Errorf("Complete: %v", err)
+		}
+	})
+}
+
+// The user service only links a login to an existing account by email when the provider
+// verified the address, so the identity must not report unverified addresses as verified
+func TestCompleteReportsEmailVerified(t *testing.T) {
+	tests := []struct {
+		name     string
+		verified interface{}
+		want     bool
+	}{
+		{"verified", true, true},
+		{"unverified", false, false},
+		{"verified as string", "true", true},
+		{"unverified as string", "false", false},
+		{"not stated", nil, false},
+		{"unexpected type", 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := newTestIssuer(t)
+			issuer.idToken = func(claims jwt.MapClaims) {
+				if tt.verified == nil {
+					delete(claims, "email_verified")
+				} else {
+					claims["email_verified"] = tt.verified
+				}
+			}
+			rp := newTestRelyingParty(t, issuer)
+			state, cookie := begin(t, rp, issuer)
+
+			_, identity, err := rp.Complete(context.Background(), testProvider, cookie, state, testCode)
+			if err != nil {
+				t.Fatalf("Complete: %v", err)
+			}
+			if identity.EmailVerified != tt.want {
+				t.Errorf("EmailVerified = %v, want %v", identity.EmailVerified, tt.want)
+			}
+		})
+	}
+}
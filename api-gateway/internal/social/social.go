@@ -0,0 +1,96 @@
+package social
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// StateTTL is how long a user has to log in at the provider
+const StateTTL = 10 * time.Minute
+
+// RelyingParty runs logins against a set of identity providers
+type RelyingParty struct {
+	providers map[string]*Provider
+	names     []string
+	baseURL   string
+	states    *stateCodec
+}
+
+// NewRelyingParty returns a relying party for the configured providers. Providers send
+// users back to baseURL/auth/<provider>/callback, which must be registered with them.
+// stateSecret signs the login state cookies; replicas behind a load balancer must share it.
+func NewRelyingParty(configs []Config, baseURL string, stateSecret []byte, client *http.Client, logger *zap.Logger) (*RelyingParty, error) {
+	rp := &RelyingParty{
+		providers: map[string]*Provider{},
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		states:    &stateCodec{secret: stateSecret},
+	}
+	for _, cfg := range configs {
+		if _, ok := rp.providers[cfg.Name]; ok {
+			return nil, fmt.Errorf("identity provider %q configured twice", cfg.Name)
+		}
+		provider, err := newProvider(cfg, client, logger)
+		if err != nil {
+			return nil, err
+		}
+		rp.providers[cfg.Name] = provider
+		rp.names = append(rp.names, cfg.Name)
+	}
+	return rp, nil
+}
+
+// Providers returns the names of the configured providers
+func (rp *RelyingParty) Providers() []string {
+	return rp.names
+}
+
+// Begin starts a login at provider. It returns the URL to send the user to and the
+// signed state, which must come back with the callback in a cookie.
+func (rp *RelyingParty) Begin(ctx context.Context, provider, tenantID, userID string) (authURL, cookie string, err error) {
+	p, ok := rp.providers[provider]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+	state, err := newState(provider, tenantID, userID, StateTTL)
+	if err != nil {
+		return "", "", err
+	}
+	if authURL, err = p.authCodeURL(ctx, state, rp.redirectURI(provider)); err != nil {
+		return "", "", err
+	}
+	if cookie, err = rp.states.encode(state); err != nil {
+		return "", "", err
+	}
+	return authURL, cookie, nil
+}
+
+// Complete finishes a login from the provider's callback: it checks the returned state
+// against the cookie, redeems the code and returns the login state and the identity
+func (rp *RelyingParty) Complete(ctx context.Context, provider, cookie, state, code string) (*State, *Identity, error) {
+	p, ok := rp.providers[provider]
+	if !ok {
+		return nil, nil, ErrUnknownProvider
+	}
+	login, err := rp.states.decode(cookie)
+	if err != nil {
+		return nil, nil, err
+	}
+	if login.Provider != provider || state == "" || state != login.State {
+		return nil, nil, ErrInvalidState
+	}
+	identity, err := p.exchange(ctx, code, login, rp.redirectURI(provider))
+	if err != nil {
+		return nil, nil, err
+	}
+	return login, identity, nil
+}
+
+// redirectURI is where provider sends users back to
+func (rp *RelyingParty) redirectURI(provider string) string {
+	return rp.baseURL + "/auth/" + provider + "/callback"
+}
@@ -0,0 +1,100 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// MetadataKey is the gRPC metadata key (and AMQP header) carrying the tenant ID
+	MetadataKey = "x-tenant-id"
+	// Default is the tenant used when a request does not name one
+	Default = "default"
+)
+
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+type contextKey struct{}
+
+// Valid reports whether id is a well-formed tenant ID
+func Valid(id string) bool {
+	return validID.MatchString(id)
+}
+
+// NewContext returns a copy of ctx carrying the tenant ID
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant ID carried by ctx, or Default
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(contextKey{}).(string); ok && id != "" {
+		return id
+	}
+	return Default
+}
+
+// FromToken returns the tenant a token handed out of band, such as in a mailed link, was
+// bound to by the user service. Tokens minted before tenants were bound to them have none.
+func FromToken(token string) (string, bool) {
+	id, _, ok := strings.Cut(token, ".")
+	if !ok || !Valid(id) {
+		return "", false
+	}
+	return id, true
+}
+
+// RoutingKey is the RabbitMQ routing key for messages published on behalf of the tenant in ctx
+func RoutingKey(ctx context.Context) string {
+	return FromContext(ctx)
+}
+
+// Resolver works out which tenant an HTTP request is meant for
+type Resolver struct {
+	hosts    map[string]string // Host name to tenant ID
+	header   string            // Header naming the tenant explicitly
+	fallback string            // Tenant used when neither host nor header name one
+}
+
+func NewResolver(hosts map[string]string, header, fallback string) *Resolver {
+	if fallback == "" {
+		fallback = Default
+	}
+	return &Resolver{hosts: hosts, header: header, fallback: fallback}
+}
+
+// Resolve returns the tenant of r and whether the request named it explicitly, through
+// its host or the tenant header. The header wins over the host when both are present.
+func (r *Resolver) Resolve(req *http.Request) (id string, explicit bool, err error) {
+	if r.header != "" {
+		if id := req.Header.Get(r.header); id != "" {
+			if !Valid(id) {
+				return "", false, fmt.Errorf("invalid tenant ID %q", id)
+			}
+			return id, true, nil
+		}
+	}
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if id, ok := r.hosts[strings.ToLower(host)]; ok {
+		return id, true, nil
+	}
+	return r.fallback, false, nil
+}
+
+// UnaryClientInterceptor sends the tenant ID of the context along with every call
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx = metadata.AppendToOutgoingContext(ctx, MetadataKey, FromContext(ctx))
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -2,26 +2,41 @@ package main
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	pb "github.com/asadlive84/shopper-proto/golang/user"
 	"github.com/asadlive84/shopper/user-svc/config"
 	"github.com/asadlive84/shopper/user-svc/internal/adapters/db/mongodb"
 	"github.com/asadlive84/shopper/user-svc/internal/adapters/db/postgresql"
 	gc "github.com/asadlive84/shopper/user-svc/internal/adapters/grpc"
+	"github.com/asadlive84/shopper/user-svc/internal/adapters/hashing"
+	"github.com/asadlive84/shopper/user-svc/internal/adapters/oidc"
 	"github.com/asadlive84/shopper/user-svc/internal/adapters/rabbitmq"
 	"github.com/asadlive84/shopper/user-svc/internal/application/core"
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/health"
 	"github.com/asadlive84/shopper/user-svc/internal/logger"
 	"github.com/asadlive84/shopper/user-svc/internal/monitoring"
+	"github.com/asadlive84/shopper/user-svc/internal/mtls"
+	"github.com/asadlive84/shopper/user-svc/internal/ratelimit"
+	"github.com/asadlive84/shopper/user-svc/internal/requestid"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
 	"github.com/asadlive84/shopper/user-svc/internal/tracing"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 // ChainUnaryInterceptors combines multiple unary interceptors
@@ -47,19 +62,62 @@ func ChainUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) grpc.Un
 	}
 }
 
+// rateRules converts configured rates to the rate limiter's rules
+func rateRules(rates map[string]config.Rate) map[string]ratelimit.Rule {
+	rules := make(map[string]ratelimit.Rule, len(rates))
+	for method, rate := range rates {
+		rules[method] = ratelimit.Rule{Rate: rate.PerSecond, Burst: rate.Burst}
+	}
+	return rules
+}
+
+// rateLimitConfig converts the configured rate limits to the rate limiter's configuration
+func rateLimitConfig(cfg *config.Config) ratelimit.Config {
+	return ratelimit.Config{
+		Default:       ratelimit.Rule{Rate: cfg.RateLimit.Default.PerSecond, Burst: cfg.RateLimit.Default.Burst},
+		Methods:       rateRules(cfg.RateLimit.Methods),
+		CallerDefault: ratelimit.Rule{Rate: cfg.RateLimit.CallerDefault.PerSecond, Burst: cfg.RateLimit.CallerDefault.Burst},
+		CallerMethods: rateRules(cfg.RateLimit.CallerMethods),
+		Exempt:        []string{healthpb.Health_ServiceDesc.ServiceName},
+		// Without mTLS no peer is known to be the gateway, so calls are limited by peer
+		TrustForwardedFor: func(ctx context.Context) bool {
+			return mtls.PeerNamed(ctx, cfg.TLS.GatewaySANs)
+		},
+		MaxInFlight:   cfg.RateLimit.MaxInFlight,
+		MinInFlight:   cfg.RateLimit.MinInFlight,
+		TargetLatency: cfg.RateLimit.TargetLatency,
+	}
+}
+
 func main() {
 	// Load configuration
-	cfg := config.NewConfig()
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
 
-	// Initialize logger
-	zapLogger := logger.NewLogger()
+	// Initialize logger; its level is reloaded with the configuration
+	logLevel := zap.NewAtomicLevel()
+	if err := logLevel.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		log.Fatalf("Invalid log level: %v", err)
+	}
+	zapLogger := logger.NewLogger(logLevel)
 	defer zapLogger.Sync()
 
 	// Set OpenTelemetry global propagator
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 
-	// Initialize tracer (Jaeger)
-	tp, err := tracing.InitTracer("user-service", cfg.JaegerEndpoint)
+	// Initialize tracer; spans are exported as configured
+	tp, err := tracing.InitTracer(tracing.Config{
+		ServiceName: "user-service",
+		Version:     cfg.Tracing.Version,
+		Environment: cfg.Environment,
+		Instance:    cfg.Tracing.Instance,
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
 	if err != nil {
 		zapLogger.Fatal("Failed to initialize tracer", zap.Error(err))
 	}
@@ -76,7 +134,7 @@ func main() {
 	}
 
 	// MongoDB setup
-	mongoDB, err := mongodb.Adapter(cfg.MongoDB.URI, cfg.MongoDB.Database)
+	mongoDB, err := mongodb.Adapter(cfg.MongoDB.URI, cfg.MongoDB.Database, cfg.MongoDB.Retention)
 	if err != nil {
 		zapLogger.Fatal("Failed to connect to MongoDB", zap.Error(err))
 	}
@@ -88,35 +146,140 @@ func main() {
 	}
 	defer rabbitClient.Close()
 
-	err = rabbitClient.Setup(cfg.RabbitMQ.Exchange, cfg.RabbitMQ.Queue)
+	err = rabbitClient.Setup(cfg.RabbitMQ.Exchange, cfg.RabbitMQ.Queue, cfg.RabbitMQ.Tenants)
 	if err != nil {
 		zapLogger.Fatal("Failed to setup RabbitMQ", zap.Error(err))
 	}
+	// Mail events carry secrets such as login links, so they get an exchange of their own
+	err = rabbitClient.Setup(cfg.RabbitMQ.MailExchange, cfg.RabbitMQ.MailQueue, cfg.RabbitMQ.Tenants)
+	if err != nil {
+		zapLogger.Fatal("Failed to setup RabbitMQ mail exchange", zap.Error(err))
+	}
 
 	// Start RabbitMQ consumer in a goroutine
 	go func() {
 		err := rabbitClient.Consume(cfg.RabbitMQ.Queue, func(ctx context.Context, msg string) {
-			zapLogger.Info("Consumed message", zap.String("message", msg))
+			zapLogger.Info("Consumed message", zap.String("tenant_id", tenant.FromContext(ctx)), zap.String("message", msg))
 		})
 		if err != nil {
 			zapLogger.Error("Failed to start RabbitMQ consumer", zap.Error(err))
 		}
 	}()
 
+	// bcrypt runs on a pool of its own, so a flood of logins cannot starve cheaper calls
+	hasher := hashing.Adapter(cfg.Hashing.Workers, cfg.Hashing.QueueSize)
+	defer hasher.Close()
+
 	// Initialize core API service
-	apiService := core.NewApplication(postgresDB, mongoDB, rabbitClient, zapLogger)
-	// hydraClient := monitoring.NewHydraClient()
-	// gRPC server with interceptors
+	apiService := core.NewApplication(postgresDB, mongoDB, rabbitClient, hasher, zapLogger)
+	if err := apiService.ResumeErasures(context.Background()); err != nil {
+		zapLogger.Error("Failed to resume erasure requests", zap.Error(err))
+	}
+	if err := apiService.EnablePasskeys(cfg.WebAuthn.RPID, cfg.WebAuthn.RPName, cfg.WebAuthn.Origins); err != nil {
+		zapLogger.Fatal("Failed to configure passkeys", zap.Error(err))
+	}
+
+	// Periodic cleanup of expired Postgres artifacts
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go apiService.RunRetentionJob(jobCtx, cfg.Retention.Interval, domain.RetentionPolicy{
+		Sessions:           cfg.Retention.Sessions,
+		DeletedUsers:       cfg.Retention.DeletedUsers,
+		Invitations:        cfg.Retention.Invitations,
+		APIKeys:            cfg.Retention.APIKeys,
+		AuthorizationCodes: cfg.Retention.AuthorizationCodes,
+		LoginLinks:         cfg.Retention.LoginLinks,
+		PasskeyCeremonies:  cfg.Retention.PasskeyCeremonies,
+		UserInvites:        cfg.Retention.UserInvites,
+		Impersonations:     cfg.Retention.Impersonations,
+		EmailChanges:       cfg.Retention.EmailChanges,
+		IdempotencyKeys:    cfg.Retention.IdempotencyKeys,
+		UserEvents:         cfg.Retention.UserEvents,
+	})
+	// Keys the OpenID Connect provider signs tokens with
+	signingKeys, err := oidc.LoadKeySet(cfg.OIDC.SigningKeyFiles)
+	if err != nil {
+		zapLogger.Fatal("Failed to load OIDC signing keys", zap.Error(err))
+	}
+	if len(cfg.OIDC.SigningKeyFiles) == 0 {
+		zapLogger.Warn("No OIDC signing keys configured, using a generated key; issued tokens will not survive a restart")
+	}
+	// Limits calls per method and per caller, and sheds load while calls slow down
+	limiter := ratelimit.New(rateLimitConfig(cfg))
+	// The log level and rate limits follow changes of the configuration; other settings
+	// only take effect on restart
+	go config.Watch(jobCtx, cfg, os.Args[1:], func(next *config.Config) {
+		if err := logLevel.UnmarshalText([]byte(next.LogLevel)); err != nil {
+			zapLogger.Error("Failed to apply log level", zap.Error(err))
+		}
+		limiter.SetConfig(rateLimitConfig(next))
+		zapLogger.Info("Configuration reloaded", zap.String("log_level", next.LogLevel))
+	}, func(err error) {
+		zapLogger.Error("Invalid configuration, keeping the running one", zap.Error(err))
+	})
+	// Mutual TLS: clients must present a certificate from the CA naming an allowed SAN
+	var tlsCreds grpc.ServerOption
+	var authorizer *mtls.Authorizer
+	if cfg.TLS.CertFile != "" {
+		certs, err := mtls.NewReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.CAFile, zapLogger)
+		if err != nil {
+			zapLogger.Fatal("Failed to load gRPC TLS certificates", zap.Error(err))
+		}
+		go certs.Run(jobCtx, cfg.TLS.ReloadInterval)
+		tlsCreds = grpc.Creds(credentials.NewTLS(certs.ServerConfig()))
+		authorizer = mtls.NewAuthorizer(cfg.TLS.AllowedSANs)
+		zapLogger.Info("gRPC mutual TLS enabled", zap.Strings("allowed_sans", cfg.TLS.AllowedSANs))
+	} else {
+		zapLogger.Warn("GRPC_TLS_CERT_FILE not set; the gRPC server accepts plaintext calls from anyone")
+	}
+	// gRPC server with interceptors. The stats handler starts the span of each call,
+	// continuing the trace the caller sent in its metadata.
 	grpcOpts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler(
+			otelgrpc.WithFilter(filters.Not(filters.HealthCheck())),
+			otelgrpc.WithMessageEvents(otelgrpc.ReceivedEvents, otelgrpc.SentEvents),
+		)),
 		grpc.UnaryInterceptor(ChainUnaryInterceptors(
+			authorizer.UnaryServerInterceptor(),
+			requestid.UnaryServerInterceptor(),
+			tenant.UnaryServerInterceptor(),
 			monitoring.PrometheusInterceptor(),
 			logger.LoggingInterceptor(zapLogger),
 			monitoring.TracingInterceptor(),
-			// monitoring.AuthInterceptor(hydraClient),
+			limiter.UnaryServerInterceptor(),
+			// Inside the metrics, logging and tracing interceptors, so they see the Internal error
+			logger.RecoveryInterceptor(zapLogger),
+			gc.IdempotencyInterceptor(apiService, zapLogger),
+			// monitoring.AuthInterceptor(signingKeys),
 		)),
+		grpc.ChainStreamInterceptor(
+			authorizer.StreamServerInterceptor(),
+			requestid.StreamServerInterceptor(),
+			tenant.StreamServerInterceptor(),
+			monitoring.PrometheusStreamInterceptor(),
+			logger.LoggingStreamInterceptor(zapLogger),
+			monitoring.TracingStreamInterceptor(),
+			limiter.StreamServerInterceptor(),
+			logger.RecoveryStreamInterceptor(zapLogger),
+			// Streams follow every user of a tenant, so they take a service client's token
+			monitoring.AuthStreamInterceptor(signingKeys,
+				jwt.WithIssuer(cfg.OIDC.Issuer), jwt.WithAudience(oidc.ServiceAudience(cfg.OIDC.Issuer))),
+		),
+	}
+	if tlsCreds != nil {
+		grpcOpts = append(grpcOpts, tlsCreds)
 	}
 	grpcServer := grpc.NewServer(grpcOpts...)
 
+	// grpc.health.v1 reports the service SERVING while its dependencies pass their probes
+	checker := health.NewChecker([]health.Probe{
+		{Name: "postgres", Check: postgresDB.Ping},
+		{Name: "mongodb", Check: mongoDB.Ping},
+		{Name: "rabbitmq", Check: rabbitClient.Ping},
+	}, cfg.Health.Interval, cfg.Health.Timeout, zapLogger, pb.UserService_ServiceDesc.ServiceName)
+	checker.Register(grpcServer)
+	go checker.Run(jobCtx)
+
 	// Start gRPC server in a goroutine
 	go func() {
 		zapLogger.Info("Starting User gRPC server", zap.String("port", cfg.GRPCPort))
@@ -125,13 +288,34 @@ func main() {
 		}
 	}()
 
-	// Prometheus metrics server
+	// OpenID Connect provider
+	oidcServer := &http.Server{
+		Addr: cfg.OIDC.HTTPPort,
+		Handler: oidc.NewServer(apiService, signingKeys, oidc.Config{
+			Issuer:            cfg.OIDC.Issuer,
+			AccessTokenTTL:    cfg.OIDC.AccessTokenTTL,
+			IDTokenTTL:        cfg.OIDC.IDTokenTTL,
+			RegistrationToken: cfg.OIDC.RegistrationToken,
+		}, zapLogger).Handler(),
+	}
+	go func() {
+		zapLogger.Info("Starting OIDC provider", zap.String("port", cfg.OIDC.HTTPPort), zap.String("issuer", cfg.OIDC.Issuer))
+		if err := oidcServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			zapLogger.Fatal("Failed to start OIDC provider", zap.Error(err))
+		}
+	}()
+
+	// Prometheus metrics server, which also serves the liveness and readiness endpoints
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", promhttp.Handler())
+	metricsMux.Handle("/healthz", checker.LivenessHandler())
+	metricsMux.Handle("/readyz", checker.ReadinessHandler())
 	promServer := &http.Server{
-		Addr:    ":9091",
-		Handler: promhttp.Handler(),
+		Addr:    cfg.MetricsPort,
+		Handler: metricsMux,
 	}
 	go func() {
-		zapLogger.Info("Starting Prometheus metrics server", zap.String("port", ":9091"))
+		zapLogger.Info("Starting Prometheus metrics server", zap.String("port", cfg.MetricsPort))
 		if err := promServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			zapLogger.Fatal("Failed to start Prometheus server", zap.Error(err))
 		}
@@ -143,6 +327,11 @@ func main() {
 	<-sigChan
 	zapLogger.Info("Received shutdown signal, shutting down...")
 
+	// Stop advertising readiness first, so no new calls are routed here while draining
+	checker.Shutdown()
+	stopJobs()
+	apiService.CloseWatches()
+
 	// Stop gRPC server gracefully
 	grpcServer.GracefulStop()
 	zapLogger.Info("gRPC server stopped")
@@ -150,6 +339,9 @@ func main() {
 	// Stop Prometheus server gracefully
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
+	if err := oidcServer.Shutdown(shutdownCtx); err != nil {
+		zapLogger.Error("Failed to shutdown OIDC provider", zap.Error(err))
+	}
 	if err := promServer.Shutdown(shutdownCtx); err != nil {
 		zapLogger.Error("Failed to shutdown Prometheus server", zap.Error(err))
 	} else {
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/ports"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type admin struct {
+	db      ports.DBPort
+	mongoDB ports.MongoDBPort
+	actor   string
+	out     printer
+}
+
+type command func(ctx context.Context, a *admin, args []string) error
+
+var commands = map[string]command{
+	"create":          createUser,
+	"get":             getUser,
+	"reset-password":  resetPassword,
+	"lock":            lockUser,
+	"unlock":          unlockUser,
+	"set-role":        setRole,
+	"grant":           grantPermission,
+	"revoke":          revokePermission,
+	"revoke-sessions": revokeSessions,
+	"audit":           auditLog,
+}
+
+func createUser(ctx context.Context, a *admin, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	name := fs.String("name", "", "full name")
+	email := fs.String("email", "", "email address")
+	passwordFile := fs.String("password-file", "-", "file holding the initial password; - reads it from standard input")
+	roleName := fs.String("role", "user", "role: admin, user or moderator")
+	fs.Parse(args)
+
+	if *name == "" || *email == "" {
+		return errors.New("create: -name and -email are required")
+	}
+	role, err := domain.ParseRole(*roleName)
+	if err != nil {
+		return err
+	}
+	password, err := readPassword(*passwordFile)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+
+	user := &domain.User{
+		ID:       uuid.Must(uuid.NewRandom()),
+		Name:     *name,
+		Email:    *email,
+		Password: string(hashed),
+		Role:     role,
+		IsActive: true,
+	}
+	if err := a.db.CreateUser(ctx, user); err != nil {
+		return fmt.Errorf("create user: %w", err)
+	}
+	a.audit(ctx, "user.create", user.ID.String(), "role="+role.String())
+	return a.out.users(user)
+}
+
+func getUser(ctx context.Context, a *admin, args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	email := fs.String("email", "", "email address")
+	fs.Parse(args)
+
+	user, err := a.lookup(ctx, *email)
+	if err != nil {
+		return err
+	}
+	return a.out.users(user)
+}
+
+func resetPassword(ctx context.Context, a *admin, args []string) error {
+	fs := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	email := fs.String("email", "", "email address")
+	passwordFile := fs.String("password-file", "-", "file holding the new password; - reads it from standard input")
+	fs.Parse(args)
+
+	user, err := a.lookup(ctx, *email)
+	if err != nil {
+		return err
+	}
+	password, err := readPassword(*passwordFile)
+	if err != nil {
+		return fmt.Errorf("reset-password: %w", err)
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+	if err := a.db.UpdatePassword(ctx, user.ID.String(), string(hashed)); err != nil {
+		return fmt.Errorf("update password: %w", err)
+	}
+	a.audit(ctx, "user.reset_password", user.ID.String(), "")
+	return a.out.result("password reset", user)
+}
+
+func lockUser(ctx context.Context, a *admin, args []string) error {
+	fs := flag.NewFlagSet("lock", flag.ExitOnError)
+	email := fs.String("email", "", "email address")
+	reason := fs.String("reason", "", "reason recorded in the audit log")
+	fs.Parse(args)
+
+	user, err := a.lookup(ctx, *email)
+	if err != nil {
+		return err
+	}
+	if err := a.db.SetActive(ctx, user.ID.String(), false); err != nil {
+		return fmt.Errorf("lock user: %w", err)
+	}
+	if _, err := a.db.RevokeSessions(ctx, user.ID.String()); err != nil {
+		return fmt.Errorf("revoke sessions: %w", err)
+	}
+	a.audit(ctx, "user.lock", user.ID.String(), *reason)
+	user.IsActive = false
+	return a.out.result("account locked", user)
+}
+
+func unlockUser(ctx context.Context, a *admin, args []string) error {
+	fs := flag.NewFlagSet("unlock", flag.ExitOnError)
+	email := fs.String("email", "", "email address")
+	fs.Parse(args)
+
+	user, err := a.lookup(ctx, *email)
+	if err != nil {
+		return err
+	}
+	if err := a.db.SetActive(ctx, user.ID.String(), true); err != nil {
+		return fmt.Errorf("unlock user: %w", err)
+	}
+	a.audit(ctx, "user.unlock", user.ID.String(), "")
+	user.IsActive = true
+	return a.out.result("account unlocked", user)
+}
+
+func setRole(ctx context.Context, a *admin, args []string) error {
+	fs := flag.NewFlagSet("set-role", flag.ExitOnError)
+	email := fs.String("email", "", "email address")
+	roleName := fs.String("role", "", "role: admin, user or moderator")
+	fs.Parse(args)
+
+	role, err := domain.ParseRole(*roleName)
+	if err != nil {
+		return err
+	}
+	user, err := a.lookup(ctx, *email)
+	if err != nil {
+		return err
+	}
+	if err := a.db.UpdateRole(ctx, user.ID.String(), role); err != nil {
+		return fmt.Errorf("update role: %w", err)
+	}
+	a.audit(ctx, "user.set_role", user.ID.String(), fmt.Sprintf("%s -> %s", user.Role, role))
+	user.Role = role
+	return a.out.result("role assigned", user)
+}
+
+// knownPermissions are the permissions the services check
+var knownPermissions = []string{domain.PermissionImpersonate}
+
+func grantPermission(ctx context.Context, a *admin, args []string) error {
+	return changePermission(ctx, a, "grant", "granted", args)
+}
+
+func revokePermission(ctx context.Context, a *admin, args []string) error {
+	return changePermission(ctx, a, "revoke", "revoked", args)
+}
+
+func changePermission(ctx context.Context, a *admin, name, done string, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	email := fs.String("email", "", "email address")
+	permission := fs.String("permission", "", "permission: "+strings.Join(knownPermissions, ", "))
+	fs.Parse(args)
+
+	if !slices.Contains(knownPermissions, *permission) {
+		return fmt.Errorf("%s: unknown permission %q", name, *permission)
+	}
+	user, err := a.lookup(ctx, *email)
+	if err != nil {
+		return err
+	}
+	permissions := slices.DeleteFunc(slices.Clone(user.Permissions), func(p string) bool { return p == *permission })
+	if name == "grant" {
+		permissions = append(permissions, *permission)
+	}
+	if err := a.db.SetPermissions(ctx, user.ID.String(), permissions); err != nil {
+		return fmt.Errorf("%s permission: %w", name, err)
+	}
+	a.audit(ctx, "user."+name+"_permission", user.ID.String(), *permission)
+	user.Permissions = permissions
+	return a.out.result("permission "+done, user)
+}
+
+func revokeSessions(ctx context.Context, a *admin, args []string) error {
+	fs := flag.NewFlagSet("revoke-sessions", flag.ExitOnError)
+	email := fs.String("email", "", "email address")
+	fs.Parse(args)
+
+	user, err := a.lookup(ctx, *email)
+	if err != nil {
+		return err
+	}
+	revoked, err := a.db.RevokeSessions(ctx, user.ID.String())
+	if err != nil {
+		return fmt.Errorf("revoke sessions: %w", err)
+	}
+	a.audit(ctx, "user.revoke_sessions", user.ID.String(), fmt.Sprintf("revoked=%d", revoked))
+	return a.out.result(fmt.Sprintf("%d session(s) revoked", revoked), user)
+}
+
+func auditLog(ctx context.Context, a *admin, args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	email := fs.String("email", "", "only show entries for this user")
+	limit := fs.Int64("n", 20, "number of entries to show")
+	follow := fs.Bool("f", false, "keep polling for new entries")
+	interval := fs.Duration("interval", 2*time.Second, "poll interval when following")
+	fs.Parse(args)
+
+	filter := domain.AuditFilter{Limit: *limit}
+	if *email != "" {
+		user, err := a.lookup(ctx, *email)
+		if err != nil {
+			return err
+		}
+		filter.UserID = user.ID.String()
+	}
+
+	entries, err := a.mongoDB.ListAudit(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("list audit log: %w", err)
+	}
+	// ListAudit returns newest first; print oldest first like tail does
+	reverse(entries)
+	if err := a.out.audit(entries); err != nil {
+		return err
+	}
+	if !*follow {
+		return nil
+	}
+
+	filter.Limit = 0
+	filter.Since = time.Now()
+	if len(entries) > 0 {
+		filter.Since = entries[len(entries)-1].Timestamp
+	}
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		entries, err := a.mongoDB.ListAudit(ctx, filter)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("list audit log: %w", err)
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		reverse(entries)
+		filter.Since = entries[len(entries)-1].Timestamp
+		if err := a.out.audit(entries); err != nil {
+			return err
+		}
+	}
+}
+
+func (a *admin) lookup(ctx context.Context, email string) (*domain.User, error) {
+	if email == "" {
+		return nil, errors.New("-email is required")
+	}
+	user, err := a.db.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, fmt.Errorf("no user with email %q", email)
+		}
+		return nil, fmt.Errorf("look up user: %w", err)
+	}
+	return user, nil
+}
+
+// readPassword reads a password from the first line of file, or of standard input when
+// file is "-". Passwords are never taken as flags, which end up in shell history and ps.
+func readPassword(file string) (string, error) {
+	in := os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return "", fmt.Errorf("read password: %w", err)
+		}
+		defer f.Close()
+		in = f
+	} else if info, err := in.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(errWriter, "Password: ")
+	}
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("password is empty")
+	}
+	return password, nil
+}
+
+// audit records the action; failures are reported but do not fail the command
+func (a *admin) audit(ctx context.Context, action, userID, details string) {
+	err := a.mongoDB.RecordAudit(ctx, &domain.AuditEntry{
+		Actor:   a.actor,
+		Action:  action,
+		UserID:  userID,
+		Details: details,
+	})
+	if err != nil {
+		fmt.Fprintln(errWriter, "warning: failed to write audit entry:", err)
+	}
+}
+
+func reverse(entries []*domain.AuditEntry) {
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+}
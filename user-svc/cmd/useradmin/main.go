@@ -0,0 +1,112 @@
+// Command useradmin is an operator tool for inspecting and fixing user accounts.
+//
+// It talks to Postgres and MongoDB directly through the service's ports, using the
+// same configuration as the user service itself: its environment, and the YAML file
+// named by -config or CONFIG_FILE.
+//
+//	useradmin [-config file] [-o table|json] [-actor name] [-tenant id] <command> [flags]
+//
+// Passwords are read from standard input, or the file named by -password-file, never
+// from flags.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/asadlive84/shopper/user-svc/config"
+	"github.com/asadlive84/shopper/user-svc/internal/adapters/db/mongodb"
+	"github.com/asadlive84/shopper/user-svc/internal/adapters/db/postgresql"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+)
+
+const usage = `usage: useradmin [-config file] [-o table|json] [-actor name] [-tenant id] <command> [flags]
+
+commands:
+  create           create a user                (-name, -email, -password-file, -role)
+  get              look up a user by email      (-email)
+  reset-password   set a new password           (-email, -password-file)
+  lock             lock an account              (-email, -reason)
+  unlock           unlock an account            (-email)
+  set-role         assign a role                (-email, -role)
+  grant            grant a permission           (-email, -permission)
+  revoke           revoke a permission          (-email, -permission)
+  revoke-sessions  revoke all active sessions   (-email)
+  audit            show the audit log           (-email, -n, -f)
+
+passwords are read from standard input unless -password-file names a file
+`
+
+func main() {
+	global := flag.NewFlagSet("useradmin", flag.ExitOnError)
+	global.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	configFile := global.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration file of the user service")
+	output := global.String("o", "table", "output format: table or json")
+	actor := global.String("actor", defaultActor(), "operator name recorded in the audit log")
+	tenantID := global.String("tenant", tenant.Default, "tenant whose accounts are operated on")
+	global.Parse(os.Args[1:])
+
+	if global.NArg() == 0 {
+		global.Usage()
+		os.Exit(2)
+	}
+
+	if !tenant.Valid(*tenantID) {
+		fatal(fmt.Errorf("invalid tenant ID %q", *tenantID))
+	}
+
+	printer, err := newPrinter(*output, os.Stdout)
+	if err != nil {
+		fatal(err)
+	}
+
+	cmd, ok := commands[global.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", global.Arg(0))
+		global.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(tenant.NewContext(context.Background(), *tenantID), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.Load([]string{"-config", *configFile})
+	if err != nil {
+		fatal(fmt.Errorf("load configuration: %w", err))
+	}
+	postgresDB, err := postgresql.Adapter(cfg.Postgres.DSN)
+	if err != nil {
+		fatal(fmt.Errorf("connect to Postgres: %w", err))
+	}
+	mongoDB, err := mongodb.Adapter(cfg.MongoDB.URI, cfg.MongoDB.Database, nil)
+	if err != nil {
+		fatal(fmt.Errorf("connect to MongoDB: %w", err))
+	}
+	defer mongoDB.Close(context.Background())
+
+	a := &admin{
+		db:      postgresDB,
+		mongoDB: mongoDB,
+		actor:   *actor,
+		out:     printer,
+	}
+	if err := cmd(ctx, a, global.Args()[1:]); err != nil {
+		fatal(err)
+	}
+}
+
+func defaultActor() string {
+	if user := os.Getenv("USER"); user != "" {
+		return "useradmin:" + user
+	}
+	return "useradmin"
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "useradmin:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+)
+
+var errWriter io.Writer = os.Stderr
+
+type printer interface {
+	users(users ...*domain.User) error
+	result(message string, user *domain.User) error
+	audit(entries []*domain.AuditEntry) error
+}
+
+func newPrinter(format string, w io.Writer) (printer, error) {
+	switch format {
+	case "table":
+		return &tablePrinter{w: w}, nil
+	case "json":
+		return &jsonPrinter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q (want table or json)", format)
+	}
+}
+
+// userView is the operator-facing representation of a user; it never includes the password hash
+type userView struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	Permissions []string   `json:"permissions,omitempty"`
+	Active      bool       `json:"active"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLogin   *time.Time `json:"last_login,omitempty"`
+}
+
+func newUserView(user *domain.User) userView {
+	return userView{
+		ID:          user.ID.String(),
+		Name:        user.Name,
+		Email:       user.Email,
+		Role:        user.Role.String(),
+		Permissions: user.Permissions,
+		Active:      user.IsActive,
+		CreatedAt:   user.CreatedAt,
+		LastLogin:   user.LastLogin,
+	}
+}
+
+type jsonPrinter struct {
+	enc *json.Encoder
+}
+
+func (p *jsonPrinter) users(users ...*domain.User) error {
+	for _, user := range users {
+		if err := p.enc.Encode(newUserView(user)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *jsonPrinter) result(message string, user *domain.User) error {
+	return p.enc.Encode(struct {
+		Result string   `json:"result"`
+		User   userView `json:"user"`
+	}{message, newUserView(user)})
+}
+
+// audit emits one JSON object per line so the output can be piped while following
+func (p *jsonPrinter) audit(entries []*domain.AuditEntry) error {
+	for _, entry := range entries {
+		if err := p.enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type tablePrinter struct {
+	w           io.Writer
+	auditHeader bool
+}
+
+func (p *tablePrinter) users(users ...*domain.User) error {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tEMAIL\tROLE\tACTIVE\tCREATED\tLAST LOGIN")
+	for _, user := range users {
+		v := newUserView(user)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\t%s\t%s\n",
+			v.ID, v.Name, v.Email, v.Role, v.Active, formatTime(&v.CreatedAt), formatTime(v.LastLogin))
+	}
+	return tw.Flush()
+}
+
+func (p *tablePrinter) result(message string, user *domain.User) error {
+	fmt.Fprintf(p.w, "%s: %s (%s)\n", message, user.Email, user.ID)
+	return nil
+}
+
+func (p *tablePrinter) audit(entries []*domain.AuditEntry) error {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	if !p.auditHeader {
+		fmt.Fprintln(tw, "TIME\tACTOR\tACTION\tUSER\tDETAILS")
+		p.auditHeader = true
+	}
+	for _, entry := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			formatTime(&entry.Timestamp), entry.Actor, entry.Action, entry.UserID, entry.Details)
+	}
+	return tw.Flush()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
@@ -0,0 +1,350 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// flagKeys maps the shorthand command line flags onto the settings they override; any
+// other setting is overridden with -set KEY=VALUE
+var flagKeys = map[string]string{
+	"env":          "APP_ENV",
+	"log-level":    "LOG_LEVEL",
+	"grpc-port":    "GRPC_PORT",
+	"metrics-port": "METRICS_PORT",
+}
+
+// source looks settings up by their environment variable name in the command line flags,
+// the environment and the configuration file, in that order, and collects the errors of
+// settings that could not be parsed.
+//
+// The file is YAML. Its keys are joined with "_" down to the values and upper-cased, so
+// that
+//
+//	oidc:
+//	  issuer: https://shop.example.com
+//
+// sets OIDC_ISSUER. Lists are joined with commas, and a mapping of plain values also sets
+// its own key to "key=value" pairs joined with commas, so that rate limits by method can
+// be written as a mapping under rate_limit.methods.
+type source struct {
+	file   string
+	values map[string]string   // Settings of the file
+	groups map[string][]string // Settings of the file by the mappings they are part of
+	flags  map[string]string
+	used   map[string]bool
+	errs   []error
+}
+
+// newSource parses the command line flags in args and reads the configuration file they
+// or CONFIG_FILE name
+func newSource(args []string) (*source, error) {
+	s := &source{
+		values: make(map[string]string),
+		groups: make(map[string][]string),
+		flags:  make(map[string]string),
+		used:   make(map[string]bool),
+	}
+
+	fs := flag.NewFlagSet("user-svc", flag.ContinueOnError)
+	fs.StringVar(&s.file, "config", os.Getenv("CONFIG_FILE"), "YAML configuration `file`")
+	fs.Func("set", "override a setting by its environment variable name, as `KEY=VALUE`; repeatable", func(value string) error {
+		key, value, ok := strings.Cut(value, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return errors.New("expected KEY=VALUE")
+		}
+		s.flags[strings.TrimSpace(key)] = value
+		return nil
+	})
+	shorthands := make(map[string]*string)
+	for name, key := range flagKeys {
+		shorthands[name] = fs.String(name, "", "override "+key)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	// Only flags actually given override the other layers
+	fs.Visit(func(f *flag.Flag) {
+		if key, ok := flagKeys[f.Name]; ok {
+			s.flags[key] = *shorthands[f.Name]
+		}
+	})
+
+	if s.file != "" {
+		data, err := os.ReadFile(s.file)
+		if err != nil {
+			return nil, fmt.Errorf("reading configuration file: %w", err)
+		}
+		var doc map[string]interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parsing configuration file %s: %w", s.file, err)
+		}
+		if err := s.flatten("", doc, nil); err != nil {
+			return nil, fmt.Errorf("parsing configuration file %s: %w", s.file, err)
+		}
+	}
+	return s, nil
+}
+
+// flatten records the values of a mapping of the file under prefix
+func (s *source) flatten(prefix string, doc map[string]interface{}, groups []string) error {
+	var pairs []string
+	plain := true
+	for name, value := range doc {
+		key := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			plain = false
+			if err := s.flatten(key, v, append(groups[:len(groups):len(groups)], key)); err != nil {
+				return err
+			}
+		case []interface{}:
+			plain = false
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				if !scalar(item) {
+					return fmt.Errorf("%s: lists may only hold plain values", key)
+				}
+				items = append(items, fmt.Sprint(item))
+			}
+			s.values[key] = strings.Join(items, ",")
+			s.groups[key] = groups
+		default:
+			if value == nil {
+				value = ""
+			}
+			s.values[key] = fmt.Sprint(value)
+			s.groups[key] = groups
+			pairs = append(pairs, name+"="+fmt.Sprint(value))
+		}
+	}
+	if prefix != "" && plain {
+		sort.Strings(pairs)
+		s.values[prefix] = strings.Join(pairs, ",")
+	}
+	return nil
+}
+
+func scalar(value interface{}) bool {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return true
+}
+
+// lookup returns the value of a setting from the highest layer that sets it
+func (s *source) lookup(key string) (string, bool) {
+	s.used[key] = true
+	if value, ok := s.flags[key]; ok {
+		return value, true
+	}
+	if value, ok := os.LookupEnv(key); ok {
+		return value, true
+	}
+	value, ok := s.values[key]
+	return value, ok
+}
+
+func (s *source) invalid(key, kind, value string) {
+	s.errs = append(s.errs, fmt.Errorf("%s: invalid %s %q", key, kind, value))
+}
+
+// err returns the errors of the settings that could not be parsed, and of settings of the
+// file or -set flags that are not used, most likely misspelt
+func (s *source) err() error {
+	errs := s.errs
+	var unknown []string
+	for key := range s.values {
+		groups, leaf := s.groups[key]
+		if s.used[key] || !leaf {
+			continue
+		}
+		known := false
+		for _, group := range groups {
+			known = known || s.used[group]
+		}
+		if !known {
+			unknown = append(unknown, key)
+		}
+	}
+	for key := range s.flags {
+		if !s.used[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs = append(errs, fmt.Errorf("%s: unknown setting", key))
+	}
+	return errors.Join(errs...)
+}
+
+// getEnv returns a setting, or defaultValue when no layer sets it
+func (s *source) getEnv(key, defaultValue string) string {
+	if value, exists := s.lookup(key); exists {
+		return value
+	}
+	return defaultValue
+}
+
+// getSecret returns a setting that may instead be read from the file named by KEY_FILE,
+// e.g. a mounted secret; surrounding whitespace of the file is dropped
+func (s *source) getSecret(key, defaultValue string) string {
+	if path, exists := s.lookup(key + "_FILE"); exists && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			s.errs = append(s.errs, fmt.Errorf("%s_FILE: %w", key, err))
+			return defaultValue
+		}
+		s.used[key] = true
+		return strings.TrimSpace(string(data))
+	}
+	return s.getEnv(key, defaultValue)
+}
+
+// getEnvAsInt returns a setting as an integer
+func (s *source) getEnvAsInt(key string, defaultValue int) int {
+	if value, exists := s.lookup(key); exists {
+		intValue, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			s.invalid(key, "integer", value)
+			return defaultValue
+		}
+		return intValue
+	}
+	return defaultValue
+}
+
+// getEnvAsFloat returns a setting as a floating point number
+func (s *source) getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value, exists := s.lookup(key); exists {
+		floatValue, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			s.invalid(key, "number", value)
+			return defaultValue
+		}
+		return floatValue
+	}
+	return defaultValue
+}
+
+// getEnvAsBool returns a setting as a boolean ("true", "false", "1", "0", ...)
+func (s *source) getEnvAsBool(key string, defaultValue bool) bool {
+	if value, exists := s.lookup(key); exists {
+		boolValue, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			s.invalid(key, "boolean", value)
+			return defaultValue
+		}
+		return boolValue
+	}
+	return defaultValue
+}
+
+// getTracing reads the tracing settings. JAEGER_ENDPOINT, the only tracing setting of
+// earlier versions, still selects the Jaeger exporter when TRACING_EXPORTER is not set.
+func (s *source) getTracing() Tracing {
+	exporter, endpoint := "otlp-grpc", ""
+	if jaegerEndpoint, exists := s.lookup("JAEGER_ENDPOINT"); exists {
+		exporter, endpoint = "jaeger", jaegerEndpoint
+	}
+	return Tracing{
+		Exporter:    s.getEnv("TRACING_EXPORTER", exporter),
+		Endpoint:    s.getEnv("TRACING_ENDPOINT", endpoint),
+		Insecure:    s.getEnvAsBool("TRACING_INSECURE", true),
+		SampleRatio: s.getEnvAsFloat("TRACING_SAMPLE_RATIO", 1),
+		Version:     s.getEnv("SERVICE_VERSION", ""),
+		Instance:    s.getEnv("SERVICE_INSTANCE_ID", ""),
+	}
+}
+
+// getEnvAsDuration returns a setting as a duration (e.g. "720h")
+func (s *source) getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := s.lookup(key); exists {
+		duration, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			s.invalid(key, "duration", value)
+			return defaultValue
+		}
+		return duration
+	}
+	return defaultValue
+}
+
+// getEnvAsList returns a comma separated setting as a list
+func (s *source) getEnvAsList(key string, defaultValue []string) []string {
+	value, exists := s.lookup(key)
+	if !exists {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvAsRate returns a setting as a rate ("PerSecond:Burst")
+func (s *source) getEnvAsRate(key string, defaultValue Rate) Rate {
+	if value, exists := s.lookup(key); exists {
+		rate, ok := parseRate(value)
+		if !ok {
+			s.invalid(key, "rate", value)
+			return defaultValue
+		}
+		return rate
+	}
+	return defaultValue
+}
+
+// getEnvAsRates returns a comma separated setting of "Name=PerSecond:Burst" entries as
+// rates by name
+func (s *source) getEnvAsRates(key string, defaultValue map[string]Rate) map[string]Rate {
+	if _, exists := s.lookup(key); !exists {
+		return defaultValue
+	}
+	rates := make(map[string]Rate)
+	for _, item := range s.getEnvAsList(key, nil) {
+		name, value, _ := strings.Cut(item, "=")
+		rate, ok := parseRate(value)
+		if !ok || strings.TrimSpace(name) == "" {
+			s.invalid(key, "rate", item)
+			continue
+		}
+		rates[strings.TrimSpace(name)] = rate
+	}
+	return rates
+}
+
+func parseRate(value string) (Rate, bool) {
+	perSecond, burst, ok := strings.Cut(strings.TrimSpace(value), ":")
+	if !ok {
+		return Rate{}, false
+	}
+	p, err := strconv.ParseFloat(perSecond, 64)
+	if err != nil || p < 0 {
+		return Rate{}, false
+	}
+	b, err := strconv.Atoi(burst)
+	if err != nil || b < 0 {
+		return Rate{}, false
+	}
+	return Rate{PerSecond: p, Burst: b}, true
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"github.com/asadlive84/shopper/user-svc/internal/tracing"
+	"go.uber.org/zap/zapcore"
+)
+
+// Production is the Environment in which unsafe settings, such as the default
+// credentials of the local development setup, are refused
+const Production = "production"
+
+// maxMongoRetention is the longest expiry a MongoDB TTL index accepts, in whole seconds
+// that fit an int32
+const maxMongoRetention = math.MaxInt32 * time.Second
+
+// validator collects the problems of a configuration, each under the setting it is about
+type validator []error
+
+func (v *validator) check(ok bool, key, format string, args ...interface{}) {
+	if !ok {
+		*v = append(*v, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+}
+
+func (v *validator) required(key, value string) {
+	v.check(strings.TrimSpace(value) != "", key, "is required")
+}
+
+func (v *validator) address(key, value string) {
+	_, port, err := net.SplitHostPort(value)
+	if err == nil {
+		var n int
+		n, err = strconv.Atoi(port)
+		if err == nil && (n < 0 || n > 65535) {
+			err = errors.New("port out of range")
+		}
+	}
+	v.check(err == nil, key, "invalid listen address %q, e.g. \":8080\"", value)
+}
+
+func (v *validator) url(key, value string) {
+	u, err := url.Parse(value)
+	v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", key, "invalid URL %q", value)
+}
+
+func (v *validator) positive(key string, d time.Duration) {
+	v.check(d > 0, key, "must be positive, got %s", d)
+}
+
+func (v *validator) notNegative(key string, d time.Duration) {
+	v.check(d >= 0, key, "must not be negative, got %s", d)
+}
+
+// Validate reports every setting that is missing or invalid, and in production every
+// setting left at an unsafe value
+func (c *Config) Validate() error {
+	var v validator
+	v.check(c.Environment == "development" || c.Environment == Production, "APP_ENV", "must be development or production, got %q", c.Environment)
+	_, err := zapcore.ParseLevel(c.LogLevel)
+	v.check(err == nil, "LOG_LEVEL", "must be debug, info, warn or error, got %q", c.LogLevel)
+	v.address("GRPC_PORT", c.GRPCPort)
+	v.address("METRICS_PORT", c.MetricsPort)
+	v.notNegative("CONFIG_RELOAD_INTERVAL", c.ReloadInterval)
+	v.check(slices.Contains(tracing.Exporters, c.Tracing.Exporter), "TRACING_EXPORTER", "must be one of %s, got %q", strings.Join(tracing.Exporters, ", "), c.Tracing.Exporter)
+	v.check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "TRACING_SAMPLE_RATIO", "must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+
+	v.required("RABBITMQ_URL", c.RabbitMQ.URL)
+	v.required("RABBITMQ_QUEUE", c.RabbitMQ.Queue)
+	v.required("RABBITMQ_EXCHANGE", c.RabbitMQ.Exchange)
+	v.required("RABBITMQ_MAIL_EXCHANGE", c.RabbitMQ.MailExchange)
+	v.required("RABBITMQ_MAIL_QUEUE", c.RabbitMQ.MailQueue)
+	v.check(len(c.RabbitMQ.Tenants) > 0, "RABBITMQ_TENANTS", "is required")
+	for _, id := range c.RabbitMQ.Tenants {
+		v.check(tenant.Valid(id), "RABBITMQ_TENANTS", "invalid tenant %q", id)
+	}
+	v.required("POSTGRES_DSN", c.Postgres.DSN)
+	v.required("MONGODB_URI", c.MongoDB.URI)
+	v.required("MONGODB_DATABASE", c.MongoDB.Database)
+	for collection, retention := range c.MongoDB.Retention {
+		v.notNegative("RETENTION_MONGODB_"+strings.ToUpper(collection), retention)
+		v.check(retention <= maxMongoRetention, "RETENTION_MONGODB_"+strings.ToUpper(collection), "must be at most %s, got %s", maxMongoRetention, retention)
+	}
+
+	v.positive("RETENTION_INTERVAL", c.Retention.Interval)
+	v.notNegative("RETENTION_SESSIONS", c.Retention.Sessions)
+	v.notNegative("RETENTION_DELETED_USERS", c.Retention.DeletedUsers)
+	v.notNegative("RETENTION_INVITATIONS", c.Retention.Invitations)
+	v.notNegative("RETENTION_API_KEYS", c.Retention.APIKeys)
+	v.notNegative("RETENTION_AUTHORIZATION_CODES", c.Retention.AuthorizationCodes)
+	v.notNegative("RETENTION_LOGIN_LINKS", c.Retention.LoginLinks)
+	v.notNegative("RETENTION_PASSKEY_CEREMONIES", c.Retention.PasskeyCeremonies)
+	v.notNegative("RETENTION_USER_INVITES", c.Retention.UserInvites)
+	v.notNegative("RETENTION_IMPERSONATIONS", c.Retention.Impersonations)
+	v.notNegative("RETENTION_EMAIL_CHANGES", c.Retention.EmailChanges)
+	v.notNegative("RETENTION_IDEMPOTENCY_KEYS", c.Retention.IdempotencyKeys)
+	v.notNegative("RETENTION_USER_EVENTS", c.Retention.UserEvents)
+
+	v.url("OIDC_ISSUER", c.OIDC.Issuer)
+	v.address("OIDC_HTTP_PORT", c.OIDC.HTTPPort)
+	v.positive("OIDC_ACCESS_TOKEN_TTL", c.OIDC.AccessTokenTTL)
+	v.positive("OIDC_ID_TOKEN_TTL", c.OIDC.IDTokenTTL)
+
+	v.required("WEBAUTHN_RP_ID", c.WebAuthn.RPID)
+	v.required("WEBAUTHN_RP_NAME", c.WebAuthn.RPName)
+	v.check(len(c.WebAuthn.Origins) > 0, "WEBAUTHN_RP_ORIGINS", "is required")
+	for _, origin := range c.WebAuthn.Origins {
+		v.url("WEBAUTHN_RP_ORIGINS", origin)
+	}
+
+	v.positive("HEALTH_CHECK_INTERVAL", c.Health.Interval)
+	v.positive("HEALTH_CHECK_TIMEOUT", c.Health.Timeout)
+
+	v.check(c.RateLimit.MaxInFlight >= 0, "LOAD_SHED_MAX_IN_FLIGHT", "must not be negative, got %d", c.RateLimit.MaxInFlight)
+	if c.RateLimit.MaxInFlight > 0 {
+		v.check(c.RateLimit.MinInFlight >= 1 && c.RateLimit.MinInFlight <= c.RateLimit.MaxInFlight,
+			"LOAD_SHED_MIN_IN_FLIGHT", "must be between 1 and LOAD_SHED_MAX_IN_FLIGHT, got %d", c.RateLimit.MinInFlight)
+		v.positive("LOAD_SHED_TARGET_LATENCY", c.RateLimit.TargetLatency)
+	}
+
+	v.check(c.Hashing.Workers >= 1, "PASSWORD_HASH_WORKERS", "must be at least 1, got %d", c.Hashing.Workers)
+	v.check(c.Hashing.QueueSize >= 0, "PASSWORD_HASH_QUEUE_SIZE", "must not be negative, got %d", c.Hashing.QueueSize)
+
+	if c.TLS.CertFile != "" || c.TLS.KeyFile != "" || c.TLS.CAFile != "" {
+		v.required("GRPC_TLS_CERT_FILE", c.TLS.CertFile)
+		v.required("GRPC_TLS_KEY_FILE", c.TLS.KeyFile)
+		v.required("GRPC_TLS_CA_FILE", c.TLS.CAFile)
+		v.positive("GRPC_TLS_RELOAD_INTERVAL", c.TLS.ReloadInterval)
+	}
+
+	if c.Environment == Production {
+		c.validateProduction(&v)
+	}
+	return errors.Join(v...)
+}
+
+// validateProduction refuses the defaults of the local development setup, which are
+// public, and settings that are only meant for it
+func (c *Config) validateProduction(v *validator) {
+	if u, err := url.Parse(c.RabbitMQ.URL); err == nil && u.User != nil {
+		password, _ := u.User.Password()
+		v.check(u.User.Username() != "guest" || password != "guest", "RABBITMQ_URL", "must not use the default guest credentials in production")
+	}
+	for _, field := range strings.Fields(c.Postgres.DSN) {
+		v.check(field != "password=postgres", "POSTGRES_DSN", "must not use the default password in production")
+	}
+	v.check(strings.HasPrefix(c.OIDC.Issuer, "https://"), "OIDC_ISSUER", "must be an https URL in production")
+	v.check(len(c.OIDC.SigningKeyFiles) > 0, "OIDC_SIGNING_KEY_FILES", "is required in production; a throwaway key invalidates every token on restart")
+	v.check(c.WebAuthn.RPID != "localhost", "WEBAUTHN_RP_ID", "must be the domain of the shop in production")
+	for _, origin := range c.WebAuthn.Origins {
+		v.check(strings.HasPrefix(origin, "https://"), "WEBAUTHN_RP_ORIGINS", "must be https URLs in production, got %q", origin)
+	}
+}
@@ -2,11 +2,26 @@ package mongodb
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+const (
+	logsCollection  = "logs"
+	auditCollection = "audit"
+
+	// ttlIndexName is the TTL index on "timestamp" that enforces a collection's retention
+	ttlIndexName = "timestamp_ttl"
 )
 
 type MongoDB struct {
@@ -14,19 +29,279 @@ type MongoDB struct {
 	db     *mongo.Database
 }
 
-func Adapter(uri, database string) (*MongoDB, error) {
+// Adapter connects to MongoDB and makes sure every collection in retention has a TTL
+// index matching its configured retention. A zero retention keeps documents forever.
+func Adapter(uri, database string, retention map[string]time.Duration) (*MongoDB, error) {
 	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
 	if err != nil {
 		return nil, err
 	}
-	return &MongoDB{
+	m := &MongoDB{
 		client: client,
 		db:     client.Database(database),
-	}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for collection, keep := range retention {
+		if err := m.ensureTTLIndex(ctx, collection, keep); err != nil {
+			return nil, fmt.Errorf("failed to apply retention to %s: %w", collection, err)
+		}
+	}
+	return m, nil
 }
 
-func (m *MongoDB) LogMessage(ctx context.Context, msg string) error {
-	collection := m.db.Collection("logs")
-	_, err := collection.InsertOne(ctx, bson.M{"message": msg, "timestamp": time.Now()})
+// ensureTTLIndex creates, updates or drops the TTL index of a collection so it matches keep
+func (m *MongoDB) ensureTTLIndex(ctx context.Context, collection string, keep time.Duration) error {
+	indexes := m.db.Collection(collection).Indexes()
+	cursor, err := indexes.List(ctx)
+	if err != nil {
+		return err
+	}
+	var existing []struct {
+		Name               string `bson:"name"`
+		ExpireAfterSeconds *int32 `bson:"expireAfterSeconds"`
+	}
+	if err := cursor.All(ctx, &existing); err != nil {
+		return err
+	}
+
+	if keep/time.Second > math.MaxInt32 {
+		return fmt.Errorf("retention %s exceeds the longest TTL MongoDB supports", keep)
+	}
+	seconds := int32(keep / time.Second)
+	for _, index := range existing {
+		if index.Name != ttlIndexName {
+			continue
+		}
+		switch {
+		case keep <= 0:
+			_, err := indexes.DropOne(ctx, ttlIndexName)
+			return err
+		case index.ExpireAfterSeconds != nil && *index.ExpireAfterSeconds == seconds:
+			return nil
+		default:
+			// collMod changes the expiry in place instead of rebuilding the index
+			return m.db.RunCommand(ctx, bson.D{
+				{Key: "collMod", Value: collection},
+				{Key: "index", Value: bson.D{
+					{Key: "name", Value: ttlIndexName},
+					{Key: "expireAfterSeconds", Value: seconds},
+				}},
+			}).Err()
+		}
+	}
+	if keep <= 0 {
+		return nil
+	}
+
+	_, err = indexes.CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "timestamp", Value: 1}},
+		Options: options.Index().SetName(ttlIndexName).SetExpireAfterSeconds(seconds),
+	})
 	return err
 }
+
+// LogMessage records a free-text log entry about the user with the given ID
+func (m *MongoDB) LogMessage(ctx context.Context, userID, msg string) error {
+	collection := m.db.Collection(logsCollection)
+	_, err := collection.InsertOne(ctx, bson.M{
+		"tenant_id": tenant.FromContext(ctx),
+		"user_id":   userID,
+		"message":   msg,
+		"timestamp": time.Now(),
+	})
+	return err
+}
+
+// tenantFilter matches the documents of the tenant in ctx. Documents written before
+// tenants existed carry no tenant_id and belong to the default tenant.
+func tenantFilter(ctx context.Context) bson.M {
+	id := tenant.FromContext(ctx)
+	if id == tenant.Default {
+		return bson.M{"tenant_id": bson.M{"$in": bson.A{id, nil}}}
+	}
+	return bson.M{"tenant_id": id}
+}
+
+type auditDocument struct {
+	TenantID  string    `bson:"tenant_id,omitempty"`
+	Actor     string    `bson:"actor"`
+	Action    string    `bson:"action"`
+	UserID    string    `bson:"user_id,omitempty"`
+	Details   string    `bson:"details,omitempty"`
+	Timestamp time.Time `bson:"timestamp"`
+}
+
+func (m *MongoDB) RecordAudit(ctx context.Context, entry *domain.AuditEntry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	entry.TenantID = tenant.FromContext(ctx)
+	_, err := m.db.Collection(auditCollection).InsertOne(ctx, auditDocument{
+		TenantID:  entry.TenantID,
+		Actor:     entry.Actor,
+		Action:    entry.Action,
+		UserID:    entry.UserID,
+		Details:   entry.Details,
+		Timestamp: entry.Timestamp,
+	})
+	return err
+}
+
+// ListAudit returns matching audit entries, newest first
+func (m *MongoDB) ListAudit(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEntry, error) {
+	query := tenantFilter(ctx)
+	if filter.UserID != "" {
+		query["user_id"] = filter.UserID
+	}
+	if !filter.Since.IsZero() {
+		query["timestamp"] = bson.M{"$gt": filter.Since}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+
+	cursor, err := m.db.Collection(auditCollection).Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []auditDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	entries := make([]*domain.AuditEntry, 0, len(docs))
+	for _, doc := range docs {
+		entries = append(entries, &domain.AuditEntry{
+			TenantID:  doc.TenantID,
+			Actor:     doc.Actor,
+			Action:    doc.Action,
+			UserID:    doc.UserID,
+			Details:   doc.Details,
+			Timestamp: doc.Timestamp,
+		})
+	}
+	return entries, nil
+}
+
+// PseudonymizeAudit replaces the user's ID with a pseudonym wherever it appears as subject or actor
+func (m *MongoDB) PseudonymizeAudit(ctx context.Context, userID, pseudonym string) (int64, error) {
+	collection := m.db.Collection(auditCollection)
+	subject := tenantFilter(ctx)
+	subject["user_id"] = userID
+	bySubject, err := collection.UpdateMany(ctx,
+		subject,
+		bson.M{"$set": bson.M{"user_id": pseudonym}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	actor := tenantFilter(ctx)
+	actor["actor"] = userID
+	byActor, err := collection.UpdateMany(ctx,
+		actor,
+		bson.M{"$set": bson.M{"actor": pseudonym}},
+	)
+	if err != nil {
+		return bySubject.ModifiedCount, err
+	}
+	return bySubject.ModifiedCount + byActor.ModifiedCount, nil
+}
+
+type logDocument struct {
+	ID        interface{} `bson:"_id"`
+	Message   string      `bson:"message"`
+	Timestamp time.Time   `bson:"timestamp"`
+}
+
+// emailPattern matches any of emails as a whole address, so "a@b.com" does not match
+// within "aa@b.com". The delimiters around the address are captured as groups 1 and 3.
+func emailPattern(emails []string) string {
+	quoted := make([]string, len(emails))
+	for i, email := range emails {
+		quoted[i] = regexp.QuoteMeta(email)
+	}
+	return `(^|[^\w.+-])(` + strings.Join(quoted, "|") + `)($|[^\w.-])`
+}
+
+// userLogs matches the log entries about the user: those recorded with the user's ID, and
+// those recorded before entries carried one whose message names one of emails
+func userLogs(ctx context.Context, userID string, emails []string) bson.M {
+	query := tenantFilter(ctx)
+	about := bson.A{bson.M{"user_id": userID}}
+	if len(emails) > 0 {
+		about = append(about, bson.M{
+			"user_id": bson.M{"$exists": false},
+			"message": bson.M{"$regex": emailPattern(emails), "$options": "i"},
+		})
+	}
+	query["$or"] = about
+	return query
+}
+
+// ListLogs returns the free-text log entries about the user, oldest first
+func (m *MongoDB) ListLogs(ctx context.Context, userID string, emails []string) ([]*domain.LogEntry, error) {
+	cursor, err := m.db.Collection(logsCollection).Find(ctx,
+		userLogs(ctx, userID, emails),
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var docs []logDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	entries := make([]*domain.LogEntry, len(docs))
+	for i, doc := range docs {
+		entries[i] = &domain.LogEntry{Message: doc.Message, Timestamp: doc.Timestamp}
+	}
+	return entries, nil
+}
+
+// ScrubLogs rewrites the log entries about the user, replacing every one of emails in
+// their message and the user's ID with replacement
+func (m *MongoDB) ScrubLogs(ctx context.Context, userID string, emails []string, replacement string) (int64, error) {
+	collection := m.db.Collection(logsCollection)
+	cursor, err := collection.Find(ctx, userLogs(ctx, userID, emails))
+	if err != nil {
+		return 0, err
+	}
+	var docs []logDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return 0, err
+	}
+
+	var find *regexp.Regexp
+	if len(emails) > 0 {
+		find = regexp.MustCompile("(?i)" + emailPattern(emails))
+	}
+	var scrubbed int64
+	for _, doc := range docs {
+		msg := doc.Message
+		// Adjacent addresses share a delimiter, so replace until none is left
+		for find != nil && find.MatchString(msg) {
+			msg = find.ReplaceAllString(msg, "${1}"+replacement+"${3}")
+		}
+		_, err := collection.UpdateByID(ctx, doc.ID, bson.M{"$set": bson.M{
+			"message": msg,
+			"user_id": replacement,
+		}})
+		if err != nil {
+			return scrubbed, err
+		}
+		scrubbed++
+	}
+	return scrubbed, nil
+}
+
+// Ping checks that the primary of the MongoDB deployment can be reached
+func (m *MongoDB) Ping(ctx context.Context) error {
+	return m.client.Ping(ctx, readpref.Primary())
+}
+
+func (m *MongoDB) Close(ctx context.Context) error {
+	return m.client.Disconnect(ctx)
+}
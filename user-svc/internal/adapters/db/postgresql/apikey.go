@@ -0,0 +1,161 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// APIKey is a hashed API key of a user
+type APIKey struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID       string     `gorm:"type:varchar(64);not null;default:'default'"`
+	UserID         uuid.UUID  `gorm:"type:uuid;index;not null"`
+	Name           string     `gorm:"type:varchar(255);not null"`
+	Prefix         string     `gorm:"type:varchar(32);uniqueIndex;not null"`
+	Hash           string     `gorm:"type:varchar(64);not null"` // Hex SHA-256 of the full key
+	Scopes         string     `gorm:"type:text;not null"`        // Comma-separated
+	OrganizationID *uuid.UUID `gorm:"type:uuid"`                 // Nullable
+	CreatedAt      time.Time  `gorm:"autoCreateTime"`
+	ExpiresAt      time.Time  `gorm:"index;not null"`
+	LastUsedAt     *time.Time `gorm:""` // Nullable
+	RevokedAt      *time.Time `gorm:""` // Nullable
+}
+
+func (k *APIKey) toDomain() *domain.APIKey {
+	key := &domain.APIKey{
+		ID:             k.ID,
+		TenantID:       k.TenantID,
+		UserID:         k.UserID,
+		Name:           k.Name,
+		Prefix:         k.Prefix,
+		Hash:           k.Hash,
+		OrganizationID: k.OrganizationID,
+		CreatedAt:      k.CreatedAt,
+		ExpiresAt:      k.ExpiresAt,
+		LastUsedAt:     k.LastUsedAt,
+		RevokedAt:      k.RevokedAt,
+	}
+	if k.Scopes != "" {
+		key.Scopes = strings.Split(k.Scopes, ",")
+	}
+	return key
+}
+
+func (p *PostgresDB) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	model := &APIKey{
+		ID:             key.ID,
+		TenantID:       tenant.FromContext(ctx),
+		UserID:         key.UserID,
+		Name:           key.Name,
+		Prefix:         key.Prefix,
+		Hash:           key.Hash,
+		Scopes:         strings.Join(key.Scopes, ","),
+		OrganizationID: key.OrganizationID,
+		ExpiresAt:      key.ExpiresAt,
+	}
+	if err := p.db.WithContext(ctx).Create(model).Error; err != nil {
+		return err
+	}
+	key.ID = model.ID
+	key.TenantID = model.TenantID
+	key.CreatedAt = model.CreatedAt
+	return nil
+}
+
+// apiKeys scopes a query on the api_keys table to the tenant carried by ctx
+func (p *PostgresDB) apiKeys(ctx context.Context) *gorm.DB {
+	return p.db.WithContext(ctx).Model(&APIKey{}).Where("tenant_id = ?", tenant.FromContext(ctx))
+}
+
+// ListAPIKeys returns the user's keys that are neither revoked nor expired
+func (p *PostgresDB) ListAPIKeys(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	var keys []APIKey
+	err := p.apiKeys(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("created_at").
+		Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*domain.APIKey, 0, len(keys))
+	for i := range keys {
+		result = append(result, keys[i].toDomain())
+	}
+	return result, nil
+}
+
+func (p *PostgresDB) GetAPIKey(ctx context.Context, userID, id string) (*domain.APIKey, error) {
+	var key APIKey
+	err := p.apiKeys(ctx).Where("user_id = ? AND revoked_at IS NULL", userID).First(&key, "id = ?", id).Error
+	return apiKeyResult(&key, err)
+}
+
+func (p *PostgresDB) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	var key APIKey
+	err := p.apiKeys(ctx).First(&key, "prefix = ?", prefix).Error
+	return apiKeyResult(&key, err)
+}
+
+func (p *PostgresDB) UpdateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	res := p.apiKeys(ctx).Where("id = ? AND revoked_at IS NULL", key.ID).Updates(map[string]interface{}{
+		"name":   key.Name,
+		"scopes": strings.Join(key.Scopes, ","),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (p *PostgresDB) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	res := p.apiKeys(ctx).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// RevokeAPIKeys revokes every active key of the user
+func (p *PostgresDB) RevokeAPIKeys(ctx context.Context, userID string) (int64, error) {
+	res := p.db.WithContext(ctx).Model(&APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now())
+	return res.RowsAffected, res.Error
+}
+
+func (p *PostgresDB) TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return p.db.WithContext(ctx).Model(&APIKey{}).Where("id = ?", id).Update("last_used_at", at).Error
+}
+
+// PurgeAPIKeys deletes keys that expired or were revoked before the given time
+func (p *PostgresDB) PurgeAPIKeys(ctx context.Context, before time.Time) (int64, error) {
+	res := p.db.WithContext(ctx).
+		Where("expires_at < ? OR revoked_at < ?", before, before).
+		Delete(&APIKey{})
+	return res.RowsAffected, res.Error
+}
+
+func apiKeyResult(key *APIKey, err error) (*domain.APIKey, error) {
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return key.toDomain(), nil
+}
@@ -0,0 +1,149 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EmailChange is a pending change of a user's email address
+type EmailChange struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID    string     `gorm:"type:varchar(64);not null;default:'default'"`
+	UserID      uuid.UUID  `gorm:"type:uuid;index;not null"`
+	OldEmail    string     `gorm:"type:varchar(255);not null"`
+	NewEmail    string     `gorm:"type:varchar(255);not null"`
+	TokenHash   string     `gorm:"type:varchar(64);uniqueIndex;not null"` // Hex SHA-256 of the token
+	CreatedAt   time.Time  `gorm:"autoCreateTime"`
+	ExpiresAt   time.Time  `gorm:"index;not null"`
+	ConfirmedAt *time.Time `gorm:""` // Nullable
+}
+
+func (e *EmailChange) toDomain() *domain.EmailChange {
+	return &domain.EmailChange{
+		ID:          e.ID,
+		TenantID:    e.TenantID,
+		UserID:      e.UserID,
+		OldEmail:    e.OldEmail,
+		NewEmail:    e.NewEmail,
+		TokenHash:   e.TokenHash,
+		CreatedAt:   e.CreatedAt,
+		ExpiresAt:   e.ExpiresAt,
+		ConfirmedAt: e.ConfirmedAt,
+	}
+}
+
+// CreateEmailChange records a requested email change, replacing any change the user
+// still had pending so only the newest token can be confirmed
+func (p *PostgresDB) CreateEmailChange(ctx context.Context, change *domain.EmailChange) error {
+	model := &EmailChange{
+		TenantID:  tenant.FromContext(ctx),
+		UserID:    change.UserID,
+		OldEmail:  change.OldEmail,
+		NewEmail:  change.NewEmail,
+		TokenHash: change.TokenHash,
+		ExpiresAt: change.ExpiresAt,
+	}
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("tenant_id = ? AND user_id = ? AND confirmed_at IS NULL", model.TenantID, model.UserID).
+			Delete(&EmailChange{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(model).Error
+	})
+	if err != nil {
+		return err
+	}
+	change.ID = model.ID
+	change.TenantID = model.TenantID
+	change.CreatedAt = model.CreatedAt
+	return nil
+}
+
+// ConfirmEmailChange swaps the user's address for the one of a pending, unexpired
+// change, revokes the user's sessions and expires their unused login links, all in one
+// transaction. The swap is a plain update of the email column, so the unique index on
+// it still rejects an address that was taken in the meantime; the change is then left
+// pending and ErrUserAlreadyExists is returned. The swap also only applies while the
+// user still has the old address.
+func (p *PostgresDB) ConfirmEmailChange(ctx context.Context, tokenHash string) (*domain.EmailChange, error) {
+	var change EmailChange
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND token_hash = ? AND confirmed_at IS NULL AND expires_at > ?", tenant.FromContext(ctx), tokenHash, time.Now()).
+			First(&change).Error
+		if err != nil {
+			return err
+		}
+		res := tx.Model(&User{}).
+			Where("tenant_id = ? AND id = ? AND email = ?", change.TenantID, change.UserID, change.OldEmail).
+			Update("email", change.NewEmail)
+		if res.Error != nil {
+			if strings.Contains(res.Error.Error(), "duplicate key value violates unique constraint") {
+				return domain.ErrUserAlreadyExists
+			}
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return domain.ErrInvalidEmailChange
+		}
+		now := time.Now()
+		change.ConfirmedAt = &now
+		if err := tx.Model(&change).Update("confirmed_at", now).Error; err != nil {
+			return err
+		}
+		// Login links mailed to the old address must not outlive the sessions
+		if err := tx.Model(&LoginLink{}).
+			Where("user_id = ? AND used_at IS NULL AND expires_at > ?", change.UserID, now).
+			Update("expires_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Session{}).
+			Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", change.UserID, now).
+			Update("revoked_at", now).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrInvalidEmailChange
+		}
+		return nil, err
+	}
+	return change.toDomain(), nil
+}
+
+func (p *PostgresDB) emailChanges(ctx context.Context) *gorm.DB {
+	return p.db.WithContext(ctx).Model(&EmailChange{}).Where("tenant_id = ?", tenant.FromContext(ctx))
+}
+
+// ListEmailChanges returns the email changes the user requested, oldest first
+func (p *PostgresDB) ListEmailChanges(ctx context.Context, userID string) ([]*domain.EmailChange, error) {
+	var changes []EmailChange
+	if err := p.emailChanges(ctx).Where("user_id = ?", userID).Order("created_at").Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	result := make([]*domain.EmailChange, 0, len(changes))
+	for i := range changes {
+		result = append(result, changes[i].toDomain())
+	}
+	return result, nil
+}
+
+// DeleteEmailChanges removes every email change the user requested
+func (p *PostgresDB) DeleteEmailChanges(ctx context.Context, userID string) (int64, error) {
+	res := p.emailChanges(ctx).Where("user_id = ?", userID).Delete(&EmailChange{})
+	return res.RowsAffected, res.Error
+}
+
+// PurgeEmailChanges deletes email changes that expired before the given time
+func (p *PostgresDB) PurgeEmailChanges(ctx context.Context, before time.Time) (int64, error) {
+	res := p.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&EmailChange{})
+	return res.RowsAffected, res.Error
+}
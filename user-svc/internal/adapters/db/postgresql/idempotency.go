@@ -0,0 +1,133 @@
+package postgresql
+
+import (
+	"context"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotentCall records a call made with an idempotency key and, once it completed, its response
+type IdempotentCall struct {
+	TenantID     string     `gorm:"type:varchar(64);primaryKey"`
+	Key          string     `gorm:"column:idempotency_key;type:varchar(255);primaryKey"`
+	Method       string     `gorm:"type:varchar(255);primaryKey"`
+	Fingerprint  string     `gorm:"type:varchar(64);not null"` // Hex SHA-256 of method and request
+	ResponseType string     `gorm:"type:varchar(255)"`
+	Response     []byte     `gorm:"type:bytea"`
+	LockedUntil  time.Time  `gorm:"not null"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime"`
+	CompletedAt  *time.Time `gorm:""` // Nullable
+	ExpiresAt    time.Time  `gorm:"index;not null"`
+}
+
+func (c *IdempotentCall) toDomain() *domain.IdempotentCall {
+	return &domain.IdempotentCall{
+		TenantID:     c.TenantID,
+		Key:          c.Key,
+		Method:       c.Method,
+		Fingerprint:  c.Fingerprint,
+		ResponseType: c.ResponseType,
+		Response:     c.Response,
+		LockedUntil:  c.LockedUntil,
+		CreatedAt:    c.CreatedAt,
+		CompletedAt:  c.CompletedAt,
+		ExpiresAt:    c.ExpiresAt,
+	}
+}
+
+// idempotentCall scopes a query to the call of method with key in the tenant carried by ctx
+func (p *PostgresDB) idempotentCall(ctx context.Context, key, method string) *gorm.DB {
+	return p.db.WithContext(ctx).Model(&IdempotentCall{}).
+		Where("tenant_id = ? AND idempotency_key = ? AND method = ?", tenant.FromContext(ctx), key, method)
+}
+
+// ClaimIdempotencyKey locks the key of call for its method until call.LockedUntil and
+// returns nil. A key already taken is only claimed again when its record expired, or when
+// the earlier call of the same request never completed and its lock lapsed. Otherwise the
+// key is left alone and the record holding it is returned, for the caller to replay or refuse.
+func (p *PostgresDB) ClaimIdempotencyKey(ctx context.Context, call *domain.IdempotentCall) (*domain.IdempotentCall, error) {
+	model := &IdempotentCall{
+		TenantID:    tenant.FromContext(ctx),
+		Key:         call.Key,
+		Method:      call.Method,
+		Fingerprint: call.Fingerprint,
+		LockedUntil: call.LockedUntil,
+		ExpiresAt:   call.ExpiresAt,
+	}
+	var held *IdempotentCall
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(model)
+		if res.Error != nil || res.RowsAffected == 1 {
+			return res.Error
+		}
+
+		var existing IdempotentCall
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND idempotency_key = ? AND method = ?", model.TenantID, model.Key, model.Method).
+			First(&existing).Error
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		abandoned := existing.CompletedAt == nil && existing.LockedUntil.Before(now) && existing.Fingerprint == model.Fingerprint
+		if existing.ExpiresAt.After(now) && !abandoned {
+			held = &existing
+			return nil
+		}
+		model.CreatedAt = now
+		return tx.Model(&IdempotentCall{}).
+			Where("tenant_id = ? AND idempotency_key = ? AND method = ?", model.TenantID, model.Key, model.Method).
+			Updates(map[string]interface{}{
+				"fingerprint":   model.Fingerprint,
+				"response_type": "",
+				"response":      nil,
+				"locked_until":  model.LockedUntil,
+				"created_at":    now,
+				"completed_at":  nil,
+				"expires_at":    model.ExpiresAt,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if held != nil {
+		return held.toDomain(), nil
+	}
+	call.TenantID = model.TenantID
+	call.CreatedAt = model.CreatedAt
+	return nil, nil
+}
+
+// CompleteIdempotentCall stores the response of a call that holds its key, so retries replay it
+func (p *PostgresDB) CompleteIdempotentCall(ctx context.Context, call *domain.IdempotentCall) error {
+	now := time.Now()
+	err := p.idempotentCall(ctx, call.Key, call.Method).
+		Where("fingerprint = ? AND completed_at IS NULL", call.Fingerprint).
+		Updates(map[string]interface{}{
+			"response_type": call.ResponseType,
+			"response":      call.Response,
+			"completed_at":  now,
+		}).Error
+	if err != nil {
+		return err
+	}
+	call.CompletedAt = &now
+	return nil
+}
+
+// ReleaseIdempotencyKey frees the key of a call that did not complete, so a retry runs it again
+func (p *PostgresDB) ReleaseIdempotencyKey(ctx context.Context, call *domain.IdempotentCall) error {
+	return p.idempotentCall(ctx, call.Key, call.Method).
+		Where("fingerprint = ? AND completed_at IS NULL", call.Fingerprint).
+		Delete(&IdempotentCall{}).Error
+}
+
+// PurgeIdempotentCalls deletes recorded calls that expired before the given time
+func (p *PostgresDB) PurgeIdempotentCalls(ctx context.Context, before time.Time) (int64, error) {
+	res := p.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&IdempotentCall{})
+	return res.RowsAffected, res.Error
+}
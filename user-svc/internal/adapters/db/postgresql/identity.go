@@ -0,0 +1,104 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ExternalIdentity links an account at an external identity provider to a user
+type ExternalIdentity struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID    string     `gorm:"type:varchar(64);not null;default:'default';uniqueIndex:idx_external_identities_subject"`
+	UserID      uuid.UUID  `gorm:"type:uuid;index;not null"`
+	Provider    string     `gorm:"type:varchar(64);not null;uniqueIndex:idx_external_identities_subject"`
+	Subject     string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_external_identities_subject"`
+	Email       string     `gorm:"type:varchar(255)"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime"`
+	LastLoginAt *time.Time `gorm:""` // Nullable
+}
+
+func (i *ExternalIdentity) toDomain() *domain.ExternalIdentity {
+	return &domain.ExternalIdentity{
+		ID:          i.ID,
+		TenantID:    i.TenantID,
+		UserID:      i.UserID,
+		Provider:    i.Provider,
+		Subject:     i.Subject,
+		Email:       i.Email,
+		CreatedAt:   i.CreatedAt,
+		LastLoginAt: i.LastLoginAt,
+	}
+}
+
+// externalIdentities scopes a query on the external_identities table to the tenant carried by ctx
+func (p *PostgresDB) externalIdentities(ctx context.Context) *gorm.DB {
+	return p.db.WithContext(ctx).Model(&ExternalIdentity{}).Where("tenant_id = ?", tenant.FromContext(ctx))
+}
+
+// CreateExternalIdentity links an identity; linking one that is already linked fails
+// with domain.ErrIdentityLinked
+func (p *PostgresDB) CreateExternalIdentity(ctx context.Context, identity *domain.ExternalIdentity) error {
+	model := &ExternalIdentity{
+		TenantID:    tenant.FromContext(ctx),
+		UserID:      identity.UserID,
+		Provider:    identity.Provider,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		LastLoginAt: identity.LastLoginAt,
+	}
+	if err := p.db.WithContext(ctx).Create(model).Error; err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return domain.ErrIdentityLinked
+		}
+		return err
+	}
+	identity.ID = model.ID
+	identity.TenantID = model.TenantID
+	identity.CreatedAt = model.CreatedAt
+	return nil
+}
+
+func (p *PostgresDB) GetExternalIdentity(ctx context.Context, provider, subject string) (*domain.ExternalIdentity, error) {
+	var identity ExternalIdentity
+	err := p.externalIdentities(ctx).First(&identity, "provider = ? AND subject = ?", provider, subject).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrIdentityNotFound
+		}
+		return nil, err
+	}
+	return identity.toDomain(), nil
+}
+
+func (p *PostgresDB) ListExternalIdentities(ctx context.Context, userID string) ([]*domain.ExternalIdentity, error) {
+	var identities []ExternalIdentity
+	if err := p.externalIdentities(ctx).Where("user_id = ?", userID).Order("created_at").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	result := make([]*domain.ExternalIdentity, 0, len(identities))
+	for i := range identities {
+		result = append(result, identities[i].toDomain())
+	}
+	return result, nil
+}
+
+// TouchExternalIdentity records a login with the identity and the email the provider reported with it
+func (p *PostgresDB) TouchExternalIdentity(ctx context.Context, id uuid.UUID, email string, at time.Time) error {
+	return p.db.WithContext(ctx).Model(&ExternalIdentity{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"email":         email,
+		"last_login_at": at,
+	}).Error
+}
+
+// DeleteExternalIdentities unlinks every identity of the user
+func (p *PostgresDB) DeleteExternalIdentities(ctx context.Context, userID string) (int64, error) {
+	res := p.externalIdentities(ctx).Where("user_id = ?", userID).Delete(&ExternalIdentity{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,123 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Impersonation records a period in which a support user acts as another user
+type Impersonation struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID  string     `gorm:"type:varchar(64);not null;default:'default'"`
+	ActorID   uuid.UUID  `gorm:"type:uuid;index;not null"`
+	UserID    uuid.UUID  `gorm:"type:uuid;index;not null"`
+	Reason    string     `gorm:"type:text;not null"`
+	StartedAt time.Time  `gorm:"autoCreateTime"`
+	ExpiresAt time.Time  `gorm:"index;not null"`
+	EndedAt   *time.Time `gorm:""` // Nullable
+}
+
+func (i *Impersonation) toDomain() *domain.Impersonation {
+	return &domain.Impersonation{
+		ID:        i.ID,
+		TenantID:  i.TenantID,
+		ActorID:   i.ActorID,
+		UserID:    i.UserID,
+		Reason:    i.Reason,
+		StartedAt: i.StartedAt,
+		ExpiresAt: i.ExpiresAt,
+		EndedAt:   i.EndedAt,
+	}
+}
+
+func (p *PostgresDB) CreateImpersonation(ctx context.Context, imp *domain.Impersonation) error {
+	model := &Impersonation{
+		TenantID:  tenant.FromContext(ctx),
+		ActorID:   imp.ActorID,
+		UserID:    imp.UserID,
+		Reason:    imp.Reason,
+		ExpiresAt: imp.ExpiresAt,
+	}
+	if err := p.db.WithContext(ctx).Create(model).Error; err != nil {
+		return err
+	}
+	imp.ID = model.ID
+	imp.TenantID = model.TenantID
+	imp.StartedAt = model.StartedAt
+	return nil
+}
+
+func (p *PostgresDB) GetImpersonation(ctx context.Context, id string) (*domain.Impersonation, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, domain.ErrImpersonationNotFound
+	}
+	var imp Impersonation
+	err := p.db.WithContext(ctx).Where("tenant_id = ?", tenant.FromContext(ctx)).First(&imp, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrImpersonationNotFound
+		}
+		return nil, err
+	}
+	return imp.toDomain(), nil
+}
+
+// EndImpersonation marks an impersonation of actorID as ended at the given time. The
+// row is locked while it is read, so an impersonation ends only once.
+func (p *PostgresDB) EndImpersonation(ctx context.Context, id, actorID string, at time.Time) (*domain.Impersonation, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, domain.ErrImpersonationNotFound
+	}
+	var imp Impersonation
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND id = ? AND actor_id = ?", tenant.FromContext(ctx), id, actorID).
+			First(&imp).Error
+		if err != nil {
+			return err
+		}
+		if imp.EndedAt != nil {
+			return domain.ErrImpersonationEnded
+		}
+		imp.EndedAt = &at
+		return tx.Model(&imp).Update("ended_at", at).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrImpersonationNotFound
+		}
+		return nil, err
+	}
+	return imp.toDomain(), nil
+}
+
+// EndExpiredImpersonations marks the impersonations of every tenant that expired by now
+// without being ended as ended at their expiry, and returns them. Each is returned once.
+func (p *PostgresDB) EndExpiredImpersonations(ctx context.Context, now time.Time) ([]*domain.Impersonation, error) {
+	var imps []Impersonation
+	err := p.db.WithContext(ctx).Model(&imps).Clauses(clause.Returning{}).
+		Where("ended_at IS NULL AND expires_at <= ?", now).
+		Update("ended_at", gorm.Expr("expires_at")).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*domain.Impersonation, 0, len(imps))
+	for i := range imps {
+		result = append(result, imps[i].toDomain())
+	}
+	return result, nil
+}
+
+// PurgeImpersonations deletes impersonations that expired before the given time; the
+// audit log keeps their start and end
+func (p *PostgresDB) PurgeImpersonations(ctx context.Context, before time.Time) (int64, error) {
+	res := p.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&Impersonation{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,264 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserInvite is an admin's invitation for a pending account
+type UserInvite struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID   string     `gorm:"type:varchar(64);not null;default:'default'"`
+	UserID     uuid.UUID  `gorm:"type:uuid;index;not null"`
+	Email      string     `gorm:"type:varchar(255);index;not null"`
+	Role       int32      `gorm:"not null"`
+	InvitedBy  uuid.UUID  `gorm:"type:uuid;not null"`
+	TokenHash  string     `gorm:"type:varchar(64);uniqueIndex;not null"` // Hex SHA-256 of the token
+	SendCount  int        `gorm:"not null;default:1"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime"`
+	SentAt     time.Time  `gorm:"not null"`
+	ExpiresAt  time.Time  `gorm:"index;not null"`
+	AcceptedAt *time.Time `gorm:""` // Nullable
+	RevokedAt  *time.Time `gorm:""` // Nullable
+}
+
+func (i *UserInvite) toDomain() *domain.UserInvite {
+	return &domain.UserInvite{
+		ID:         i.ID,
+		TenantID:   i.TenantID,
+		UserID:     i.UserID,
+		Email:      i.Email,
+		Role:       domain.Role(i.Role),
+		InvitedBy:  i.InvitedBy,
+		TokenHash:  i.TokenHash,
+		SendCount:  i.SendCount,
+		CreatedAt:  i.CreatedAt,
+		SentAt:     i.SentAt,
+		ExpiresAt:  i.ExpiresAt,
+		AcceptedAt: i.AcceptedAt,
+		RevokedAt:  i.RevokedAt,
+	}
+}
+
+// userInvites scopes a query on the user_invites table to the tenant carried by ctx
+func (p *PostgresDB) userInvites(ctx context.Context) *gorm.DB {
+	return p.db.WithContext(ctx).Model(&UserInvite{}).Where("tenant_id = ?", tenant.FromContext(ctx))
+}
+
+// CreateUserInvite creates the inactive account of user together with its invite
+func (p *PostgresDB) CreateUserInvite(ctx context.Context, user *domain.User, invite *domain.UserInvite) error {
+	userModel := userFromDomain(user)
+	userModel.TenantID = tenant.FromContext(ctx)
+	model := &UserInvite{
+		TenantID:  userModel.TenantID,
+		Email:     user.Email,
+		Role:      int32(invite.Role),
+		InvitedBy: invite.InvitedBy,
+		TokenHash: invite.TokenHash,
+		SendCount: 1,
+		SentAt:    time.Now(),
+		ExpiresAt: invite.ExpiresAt,
+	}
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(userModel).Error; err != nil {
+			return err
+		}
+		// IsActive has a column default, so gorm skips it when false; persist it explicitly
+		if err := tx.Model(userModel).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		model.UserID = userModel.ID
+		return tx.Create(model).Error
+	})
+	if err != nil {
+		return err
+	}
+	user.ID = userModel.ID
+	user.TenantID = userModel.TenantID
+	user.IsActive = false
+	user.CreatedAt = userModel.CreatedAt
+	*invite = *model.toDomain()
+	return nil
+}
+
+func (p *PostgresDB) GetUserInvite(ctx context.Context, id string) (*domain.UserInvite, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, domain.ErrUserInviteNotFound
+	}
+	var invite UserInvite
+	if err := p.userInvites(ctx).First(&invite, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrUserInviteNotFound
+		}
+		return nil, err
+	}
+	return invite.toDomain(), nil
+}
+
+// GetOpenUserInvite returns the invite with the token hash while it can still be accepted
+func (p *PostgresDB) GetOpenUserInvite(ctx context.Context, tokenHash string) (*domain.UserInvite, error) {
+	var invite UserInvite
+	err := p.userInvites(ctx).
+		Where("token_hash = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", tokenHash, time.Now()).
+		First(&invite).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrInvalidUserInvite
+		}
+		return nil, err
+	}
+	return invite.toDomain(), nil
+}
+
+// ListUserInvites returns the invites that were neither accepted nor revoked, expired
+// ones included so they can be resent
+func (p *PostgresDB) ListUserInvites(ctx context.Context) ([]*domain.UserInvite, error) {
+	var invites []UserInvite
+	err := p.userInvites(ctx).
+		Where("accepted_at IS NULL AND revoked_at IS NULL").
+		Order("created_at").
+		Find(&invites).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*domain.UserInvite, 0, len(invites))
+	for i := range invites {
+		result = append(result, invites[i].toDomain())
+	}
+	return result, nil
+}
+
+// RenewUserInvite replaces the token of an open invite and extends its expiry, so
+// only the newest mail can be used
+func (p *PostgresDB) RenewUserInvite(ctx context.Context, id, tokenHash string, expiresAt time.Time) (*domain.UserInvite, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, domain.ErrUserInviteNotFound
+	}
+	res := p.userInvites(ctx).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"token_hash": tokenHash,
+			"expires_at": expiresAt,
+			"sent_at":    time.Now(),
+			"send_count": gorm.Expr("send_count + 1"),
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, domain.ErrUserInviteNotFound
+	}
+	return p.GetUserInvite(ctx, id)
+}
+
+// RevokeUserInvite revokes an open invite and deletes its pending account. The account
+// never held data of its own, so it is removed outright rather than soft-deleted, which
+// also frees the address for a new invite.
+func (p *PostgresDB) RevokeUserInvite(ctx context.Context, id string) (*domain.UserInvite, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, domain.ErrUserInviteNotFound
+	}
+	var invite UserInvite
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND id = ? AND accepted_at IS NULL AND revoked_at IS NULL", tenant.FromContext(ctx), id).
+			First(&invite).Error
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		invite.RevokedAt = &now
+		if err := tx.Model(&invite).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("id = ? AND is_active = ?", invite.UserID, false).Delete(&User{}).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrUserInviteNotFound
+		}
+		return nil, err
+	}
+	return invite.toDomain(), nil
+}
+
+// AcceptUserInvite consumes an open, unexpired invite and activates its account with
+// the given password hash, and name unless it is empty. The invite row is locked while
+// it is read, so a token is accepted only once.
+func (p *PostgresDB) AcceptUserInvite(ctx context.Context, tokenHash, passwordHash, name string) (*domain.UserInvite, error) {
+	var invite UserInvite
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND token_hash = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", tenant.FromContext(ctx), tokenHash, time.Now()).
+			First(&invite).Error
+		if err != nil {
+			return err
+		}
+		fields := map[string]interface{}{"password": passwordHash, "is_active": true}
+		if name = strings.TrimSpace(name); name != "" {
+			fields["name"] = name
+		}
+		res := tx.Model(&User{}).Where("id = ?", invite.UserID).Updates(fields)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return domain.ErrUserNotFound
+		}
+		now := time.Now()
+		invite.AcceptedAt = &now
+		return tx.Model(&invite).Update("accepted_at", now).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrInvalidUserInvite
+		}
+		return nil, err
+	}
+	return invite.toDomain(), nil
+}
+
+// ListInvitesOfUser returns the invites that created the user's account, oldest first
+func (p *PostgresDB) ListInvitesOfUser(ctx context.Context, userID string) ([]*domain.UserInvite, error) {
+	var invites []UserInvite
+	if err := p.userInvites(ctx).Where("user_id = ?", userID).Order("created_at").Find(&invites).Error; err != nil {
+		return nil, err
+	}
+	result := make([]*domain.UserInvite, 0, len(invites))
+	for i := range invites {
+		result = append(result, invites[i].toDomain())
+	}
+	return result, nil
+}
+
+// DeleteInvitesOfUser removes the invites that created the user's account
+func (p *PostgresDB) DeleteInvitesOfUser(ctx context.Context, userID string) (int64, error) {
+	res := p.userInvites(ctx).Where("user_id = ?", userID).Delete(&UserInvite{})
+	return res.RowsAffected, res.Error
+}
+
+// PurgeUserInvites deletes invites that expired, were accepted or were revoked before
+// the given time. Accounts still pending under an expired invite are deleted with it,
+// since nobody can activate them any more.
+func (p *PostgresDB) PurgeUserInvites(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		abandoned := tx.Model(&UserInvite{}).Select("user_id").
+			Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at < ?", before)
+		if err := tx.Unscoped().Where("id IN (?) AND is_active = ?", abandoned, false).Delete(&User{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("expires_at < ? OR accepted_at < ? OR revoked_at < ?", before, before, before).Delete(&UserInvite{})
+		purged = res.RowsAffected
+		return res.Error
+	})
+	return purged, err
+}
@@ -0,0 +1,142 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginLink is a hashed passwordless login token
+type LoginLink struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID   string     `gorm:"type:varchar(64);not null;default:'default'"`
+	UserID     uuid.UUID  `gorm:"type:uuid;index;not null"`
+	Email      string     `gorm:"type:varchar(255);index;not null"`
+	TokenHash  string     `gorm:"type:varchar(64);uniqueIndex;not null"` // Hex SHA-256 of the token
+	DeviceHash string     `gorm:"type:varchar(64);not null"`             // Hex SHA-256 of the device secret
+	CreatedAt  time.Time  `gorm:"autoCreateTime"`
+	ExpiresAt  time.Time  `gorm:"index;not null"`
+	UsedAt     *time.Time `gorm:""` // Nullable
+}
+
+func (l *LoginLink) toDomain() *domain.LoginLink {
+	return &domain.LoginLink{
+		ID:         l.ID,
+		TenantID:   l.TenantID,
+		UserID:     l.UserID,
+		Email:      l.Email,
+		TokenHash:  l.TokenHash,
+		DeviceHash: l.DeviceHash,
+		CreatedAt:  l.CreatedAt,
+		ExpiresAt:  l.ExpiresAt,
+		UsedAt:     l.UsedAt,
+	}
+}
+
+// CreateLoginLink stores a link unless limit links were already requested for its user
+// since the given time, failing with ErrTooManyLoginLinks then. The user's row is locked
+// while counting, so parallel requests cannot all pass the limit.
+func (p *PostgresDB) CreateLoginLink(ctx context.Context, link *domain.LoginLink, limit int, since time.Time) error {
+	model := &LoginLink{
+		TenantID:   tenant.FromContext(ctx),
+		UserID:     link.UserID,
+		Email:      link.Email,
+		TokenHash:  link.TokenHash,
+		DeviceHash: link.DeviceHash,
+		ExpiresAt:  link.ExpiresAt,
+	}
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ?", tenant.FromContext(ctx)).
+			Select("id").
+			First(&user, "id = ?", link.UserID).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return domain.ErrUserNotFound
+			}
+			return err
+		}
+		var recent int64
+		err = tx.Model(&LoginLink{}).
+			Where("user_id = ? AND created_at > ?", link.UserID, since).
+			Count(&recent).Error
+		if err != nil {
+			return err
+		}
+		if recent >= int64(limit) {
+			return domain.ErrTooManyLoginLinks
+		}
+		return tx.Create(model).Error
+	})
+	if err != nil {
+		return err
+	}
+	link.ID = model.ID
+	link.TenantID = model.TenantID
+	link.CreatedAt = model.CreatedAt
+	return nil
+}
+
+// ConsumeLoginLink marks an unused, unexpired link as used and returns it. The row is
+// locked while deviceHash is checked, so a link can be redeemed only once; a link
+// presented from another device is left untouched for its rightful owner.
+func (p *PostgresDB) ConsumeLoginLink(ctx context.Context, tokenHash, deviceHash string) (*domain.LoginLink, error) {
+	var link LoginLink
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", tenant.FromContext(ctx), tokenHash, time.Now()).
+			First(&link).Error
+		if err != nil {
+			return err
+		}
+		if link.DeviceHash != deviceHash {
+			return domain.ErrInvalidLoginLink
+		}
+		now := time.Now()
+		link.UsedAt = &now
+		return tx.Model(&link).Update("used_at", now).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrInvalidLoginLink
+		}
+		return nil, err
+	}
+	return link.toDomain(), nil
+}
+
+func (p *PostgresDB) loginLinks(ctx context.Context) *gorm.DB {
+	return p.db.WithContext(ctx).Model(&LoginLink{}).Where("tenant_id = ?", tenant.FromContext(ctx))
+}
+
+// ListLoginLinks returns the links requested for the user, oldest first
+func (p *PostgresDB) ListLoginLinks(ctx context.Context, userID string) ([]*domain.LoginLink, error) {
+	var links []LoginLink
+	if err := p.loginLinks(ctx).Where("user_id = ?", userID).Order("created_at").Find(&links).Error; err != nil {
+		return nil, err
+	}
+	result := make([]*domain.LoginLink, 0, len(links))
+	for i := range links {
+		result = append(result, links[i].toDomain())
+	}
+	return result, nil
+}
+
+// DeleteLoginLinks removes every link requested for the user
+func (p *PostgresDB) DeleteLoginLinks(ctx context.Context, userID string) (int64, error) {
+	res := p.loginLinks(ctx).Where("user_id = ?", userID).Delete(&LoginLink{})
+	return res.RowsAffected, res.Error
+}
+
+// PurgeLoginLinks deletes links that expired before the given time
+func (p *PostgresDB) PurgeLoginLinks(ctx context.Context, before time.Time) (int64, error) {
+	res := p.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&LoginLink{})
+	return res.RowsAffected, res.Error
+}
@@ -1,8 +1,10 @@
 package postgresql
 
 import (
+	"strings"
 	"time"
 
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -14,24 +16,25 @@ type User struct {
 	gorm.Model
 	ID uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
 
-	Name              string         `gorm:"type:varchar(255);not null"` // Required field
-	Email             string         `gorm:"uniqueIndex;not null"`       // Required field
-	Password          string         `gorm:"not null"`                   // Required field
-	PhoneNumber       *string        `gorm:"type:varchar(20)"`           // Nullable
-	IsActive          bool           `gorm:"default:true"`               // Default value
-	Age               *int32         `gorm:""`                           // Nullable
-	Role              *int32         `gorm:""`                           // Nullable
-	Permissions       *string        `gorm:"type:text"`                  // Nullable (JSON format or comma-separated)
-	CreatedAt         time.Time      `gorm:"autoCreateTime"`             // Auto-generated
-	UpdatedAt         time.Time      `gorm:"autoUpdateTime"`             // Auto-generated
-	LastLogin         *time.Time     `gorm:""`                           // Nullable
-	Status            *int32         `gorm:""`                           // Nullable
-	ProfilePictureUrl *string        `gorm:"type:text"`                  // Nullable
-	Metadata          *string        `gorm:"type:text"`                  // Nullable (Store as JSON string)
-	IsDeleted         bool           `gorm:"default:false"`              // Default value
-	DeletedAt         gorm.DeletedAt `gorm:"index"`                      // Soft delete
-	TwoFactorEnabled  bool           `gorm:"default:false"`              // Default value
-	TwoFactorSecret   *string        `gorm:""`                           // Nullable
+	TenantID          string         `gorm:"type:varchar(64);not null;default:'default';uniqueIndex:idx_users_tenant_email,priority:1"` // Emails are unique per tenant
+	Name              string         `gorm:"type:varchar(255);not null"`                                                                // Required field
+	Email             string         `gorm:"not null;uniqueIndex:idx_users_tenant_email,priority:2"`                                    // Required field
+	Password          string         `gorm:"not null"`                                                                                  // Required field
+	PhoneNumber       *string        `gorm:"type:varchar(20)"`                                                                          // Nullable
+	IsActive          bool           `gorm:"default:true"`                                                                              // Default value
+	Age               *int32         `gorm:""`                                                                                          // Nullable
+	Role              *int32         `gorm:""`                                                                                          // Nullable
+	Permissions       *string        `gorm:"type:text"`                                                                                 // Nullable (JSON format or comma-separated)
+	CreatedAt         time.Time      `gorm:"autoCreateTime"`                                                                            // Auto-generated
+	UpdatedAt         time.Time      `gorm:"autoUpdateTime"`                                                                            // Auto-generated
+	LastLogin         *time.Time     `gorm:""`                                                                                          // Nullable
+	Status            *int32         `gorm:""`                                                                                          // Nullable
+	ProfilePictureUrl *string        `gorm:"type:text"`                                                                                 // Nullable
+	Metadata          *string        `gorm:"type:text"`                                                                                 // Nullable (Store as JSON string)
+	IsDeleted         bool           `gorm:"default:false"`                                                                             // Default value
+	DeletedAt         gorm.DeletedAt `gorm:"index"`                                                                                     // Soft delete
+	TwoFactorEnabled  bool           `gorm:"default:false"`                                                                             // Default value
+	TwoFactorSecret   *string        `gorm:""`                                                                                          // Nullable
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
@@ -40,3 +43,127 @@ func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
 	}
 	return
 }
+
+// Session is a login session issued by AuthenticateUser
+type Session struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID    uuid.UUID  `gorm:"type:uuid;index;not null"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+	ExpiresAt time.Time  `gorm:"index;not null"`
+	RevokedAt *time.Time `gorm:""` // Nullable
+}
+
+func (u *User) toDomain() *domain.User {
+	user := &domain.User{
+		ID:        u.ID,
+		TenantID:  u.TenantID,
+		Name:      u.Name,
+		Email:     u.Email,
+		Password:  u.Password,
+		IsActive:  u.IsActive,
+		CreatedAt: u.CreatedAt,
+		LastLogin: u.LastLogin,
+
+		TwoFactorEnabled: u.TwoFactorEnabled,
+	}
+	if u.Role != nil {
+		user.Role = domain.Role(*u.Role)
+	}
+	if u.Permissions != nil && *u.Permissions != "" {
+		user.Permissions = strings.Split(*u.Permissions, ",")
+	}
+	return user
+}
+
+func userFromDomain(user *domain.User) *User {
+	role := int32(user.Role)
+	model := &User{
+		ID:       user.ID,
+		TenantID: user.TenantID,
+		Name:     user.Name,
+		Email:    user.Email,
+		Password: user.Password,
+		IsActive: user.IsActive,
+		Role:     &role,
+	}
+	if len(user.Permissions) > 0 {
+		permissions := strings.Join(user.Permissions, ",")
+		model.Permissions = &permissions
+	}
+	return model
+}
+
+// ErasureRequest tracks a right-to-erasure request until all of its steps have run
+type ErasureRequest struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID       string     `gorm:"type:varchar(64);not null;default:'default'"`
+	UserID         uuid.UUID  `gorm:"type:uuid;index;not null"`
+	Email          *string    `gorm:""`                   // Nullable; cleared on completion
+	Pseudonym      *string    `gorm:""`                   // Nullable; cleared on completion
+	Reason         *string    `gorm:"type:text"`          // Nullable
+	Status         int32      `gorm:"index;not null"`     // domain.ErasureStatus
+	CompletedSteps string     `gorm:"type:text;not null"` // Comma-separated step names
+	Error          *string    `gorm:"type:text"`          // Nullable
+	RequestedAt    time.Time  `gorm:"autoCreateTime"`     // Auto-generated
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime"`     // Auto-generated
+	CompletedAt    *time.Time `gorm:""`                   // Nullable
+}
+
+func (r *ErasureRequest) toDomain() *domain.ErasureRequest {
+	req := &domain.ErasureRequest{
+		ID:          r.ID,
+		TenantID:    r.TenantID,
+		UserID:      r.UserID,
+		Status:      domain.ErasureStatus(r.Status),
+		RequestedAt: r.RequestedAt,
+		CompletedAt: r.CompletedAt,
+	}
+	if r.Email != nil {
+		req.Email = *r.Email
+	}
+	if r.Pseudonym != nil {
+		req.Pseudonym = *r.Pseudonym
+	}
+	if r.Reason != nil {
+		req.Reason = *r.Reason
+	}
+	if r.Error != nil {
+		req.Error = *r.Error
+	}
+	if r.CompletedSteps != "" {
+		req.CompletedSteps = strings.Split(r.CompletedSteps, ",")
+	}
+	return req
+}
+
+func erasureRequestFromDomain(req *domain.ErasureRequest) *ErasureRequest {
+	return &ErasureRequest{
+		ID:             req.ID,
+		TenantID:       req.TenantID,
+		UserID:         req.UserID,
+		Email:          nullable(req.Email),
+		Pseudonym:      nullable(req.Pseudonym),
+		Reason:         nullable(req.Reason),
+		Status:         int32(req.Status),
+		CompletedSteps: strings.Join(req.CompletedSteps, ","),
+		Error:          nullable(req.Error),
+		CompletedAt:    req.CompletedAt,
+	}
+}
+
+func (s *Session) toDomain() *domain.Session {
+	return &domain.Session{
+		ID:        s.ID,
+		UserID:    s.UserID,
+		CreatedAt: s.CreatedAt,
+		ExpiresAt: s.ExpiresAt,
+		RevokedAt: s.RevokedAt,
+	}
+}
+
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
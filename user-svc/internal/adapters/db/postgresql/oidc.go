@@ -0,0 +1,153 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OIDCClient is an application registered with the OpenID Connect provider
+type OIDCClient struct {
+	ID           string    `gorm:"type:varchar(64);primaryKey"`
+	TenantID     string    `gorm:"type:varchar(64);not null;default:'default'"`
+	Name         string    `gorm:"type:varchar(255);not null"`
+	SecretHash   string    `gorm:"type:varchar(64)"`   // Empty for public clients
+	RedirectURIs string    `gorm:"type:text;not null"` // Space-separated
+	Public       bool      `gorm:"not null;default:false"`
+	Service      bool      `gorm:"not null;default:false"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
+
+func (OIDCClient) TableName() string { return "oidc_clients" }
+
+func (c *OIDCClient) toDomain() *domain.OIDCClient {
+	return &domain.OIDCClient{
+		ID:           c.ID,
+		TenantID:     c.TenantID,
+		Name:         c.Name,
+		SecretHash:   c.SecretHash,
+		RedirectURIs: strings.Fields(c.RedirectURIs),
+		Public:       c.Public,
+		Service:      c.Service,
+		CreatedAt:    c.CreatedAt,
+	}
+}
+
+// OIDCAuthorizationCode is an issued authorization code, stored by hash
+type OIDCAuthorizationCode struct {
+	CodeHash      string     `gorm:"type:varchar(64);primaryKey"`
+	TenantID      string     `gorm:"type:varchar(64);not null;default:'default'"`
+	ClientID      string     `gorm:"type:varchar(64);not null"`
+	UserID        uuid.UUID  `gorm:"type:uuid;index;not null"`
+	RedirectURI   string     `gorm:"type:text;not null"`
+	Scope         string     `gorm:"type:text;not null"`
+	Nonce         string     `gorm:"type:text"`
+	CodeChallenge string     `gorm:"type:varchar(128);not null"`
+	AuthTime      time.Time  `gorm:"not null"`
+	ExpiresAt     time.Time  `gorm:"index;not null"`
+	UsedAt        *time.Time `gorm:""` // Nullable
+}
+
+func (OIDCAuthorizationCode) TableName() string { return "oidc_authorization_codes" }
+
+func (c *OIDCAuthorizationCode) toDomain() *domain.AuthorizationCode {
+	return &domain.AuthorizationCode{
+		CodeHash:      c.CodeHash,
+		TenantID:      c.TenantID,
+		ClientID:      c.ClientID,
+		UserID:        c.UserID,
+		RedirectURI:   c.RedirectURI,
+		Scope:         c.Scope,
+		Nonce:         c.Nonce,
+		CodeChallenge: c.CodeChallenge,
+		AuthTime:      c.AuthTime,
+		ExpiresAt:     c.ExpiresAt,
+		UsedAt:        c.UsedAt,
+	}
+}
+
+func (p *PostgresDB) CreateOIDCClient(ctx context.Context, client *domain.OIDCClient) error {
+	model := &OIDCClient{
+		ID:           client.ID,
+		TenantID:     tenant.FromContext(ctx),
+		Name:         client.Name,
+		SecretHash:   client.SecretHash,
+		RedirectURIs: strings.Join(client.RedirectURIs, " "),
+		Public:       client.Public,
+		Service:      client.Service,
+	}
+	if err := p.db.WithContext(ctx).Create(model).Error; err != nil {
+		return err
+	}
+	client.TenantID = model.TenantID
+	client.CreatedAt = model.CreatedAt
+	return nil
+}
+
+// GetOIDCClient looks a client up by its ID. Client IDs are unique across tenants,
+// so the lookup is not scoped: the client determines the tenant of the login.
+func (p *PostgresDB) GetOIDCClient(ctx context.Context, id string) (*domain.OIDCClient, error) {
+	var client OIDCClient
+	if err := p.db.WithContext(ctx).First(&client, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrClientNotFound
+		}
+		return nil, err
+	}
+	return client.toDomain(), nil
+}
+
+func (p *PostgresDB) CreateAuthorizationCode(ctx context.Context, code *domain.AuthorizationCode) error {
+	return p.db.WithContext(ctx).Create(&OIDCAuthorizationCode{
+		CodeHash:      code.CodeHash,
+		TenantID:      tenant.FromContext(ctx),
+		ClientID:      code.ClientID,
+		UserID:        code.UserID,
+		RedirectURI:   code.RedirectURI,
+		Scope:         code.Scope,
+		Nonce:         code.Nonce,
+		CodeChallenge: code.CodeChallenge,
+		AuthTime:      code.AuthTime,
+		ExpiresAt:     code.ExpiresAt,
+	}).Error
+}
+
+// ConsumeAuthorizationCode marks a code as used and returns it. A code can be consumed
+// once; later attempts fail with ErrInvalidGrant, as do unknown codes.
+func (p *PostgresDB) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*domain.AuthorizationCode, error) {
+	var code OIDCAuthorizationCode
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ?", tenant.FromContext(ctx)).
+			First(&code, "code_hash = ?", codeHash).Error
+		if err != nil {
+			return err
+		}
+		if code.UsedAt != nil {
+			return domain.ErrInvalidGrant
+		}
+		now := time.Now()
+		code.UsedAt = &now
+		return tx.Model(&code).Update("used_at", now).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrInvalidGrant
+		}
+		return nil, err
+	}
+	return code.toDomain(), nil
+}
+
+// PurgeAuthorizationCodes deletes codes that expired before the given time
+func (p *PostgresDB) PurgeAuthorizationCodes(ctx context.Context, before time.Time) (int64, error) {
+	res := p.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&OIDCAuthorizationCode{})
+	return res.RowsAffected, res.Error
+}
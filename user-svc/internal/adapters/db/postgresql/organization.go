@@ -0,0 +1,333 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Organization is a business customer account shared by its members
+type Organization struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID  string    `gorm:"type:varchar(64);not null;default:'default';index"`
+	Name      string    `gorm:"type:varchar(255);not null"`
+	CreatedBy uuid.UUID `gorm:"type:uuid;not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// OrganizationMember links a user to an organization
+type OrganizationMember struct {
+	OrganizationID uuid.UUID     `gorm:"type:uuid;primaryKey"`
+	UserID         uuid.UUID     `gorm:"type:uuid;primaryKey;index"`
+	Role           int32         `gorm:"not null"` // domain.OrgRole
+	CreatedAt      time.Time     `gorm:"autoCreateTime"`
+	Organization   *Organization `gorm:"foreignKey:OrganizationID"`
+}
+
+// OrganizationInvitation invites an email address to join an organization
+type OrganizationInvitation struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID       string     `gorm:"type:varchar(64);not null;default:'default'"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null"`
+	Email          string     `gorm:"index;not null"`
+	TokenHash      string     `gorm:"not null;default:''"` // SHA-256 of the mailed token
+	Role           int32      `gorm:"not null"`            // domain.OrgRole
+	InvitedBy      uuid.UUID  `gorm:"type:uuid;not null"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime"`
+	ExpiresAt      time.Time  `gorm:"index;not null"`
+	AcceptedAt     *time.Time `gorm:""` // Nullable
+	RevokedAt      *time.Time `gorm:""` // Nullable
+}
+
+func (o *Organization) toDomain() *domain.Organization {
+	return &domain.Organization{
+		ID:        o.ID,
+		TenantID:  o.TenantID,
+		Name:      o.Name,
+		CreatedBy: o.CreatedBy,
+		CreatedAt: o.CreatedAt,
+	}
+}
+
+func (m *OrganizationMember) toDomain() *domain.Membership {
+	membership := &domain.Membership{
+		OrganizationID: m.OrganizationID,
+		UserID:         m.UserID,
+		Role:           domain.OrgRole(m.Role),
+		JoinedAt:       m.CreatedAt,
+	}
+	if m.Organization != nil {
+		membership.Organization = m.Organization.toDomain()
+	}
+	return membership
+}
+
+func (i *OrganizationInvitation) toDomain() *domain.OrgInvitation {
+	return &domain.OrgInvitation{
+		ID:             i.ID,
+		OrganizationID: i.OrganizationID,
+		Email:          i.Email,
+		TokenHash:      i.TokenHash,
+		Role:           domain.OrgRole(i.Role),
+		InvitedBy:      i.InvitedBy,
+		CreatedAt:      i.CreatedAt,
+		ExpiresAt:      i.ExpiresAt,
+		AcceptedAt:     i.AcceptedAt,
+		RevokedAt:      i.RevokedAt,
+	}
+}
+
+// CreateOrganization stores the organization together with its owner's membership
+func (p *PostgresDB) CreateOrganization(ctx context.Context, org *domain.Organization) error {
+	model := &Organization{
+		ID:        org.ID,
+		TenantID:  tenant.FromContext(ctx),
+		Name:      org.Name,
+		CreatedBy: org.CreatedBy,
+	}
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(model).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrganizationID: model.ID,
+			UserID:         org.CreatedBy,
+			Role:           int32(domain.OrgRoleOwner),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	org.ID = model.ID
+	org.TenantID = model.TenantID
+	org.CreatedAt = model.CreatedAt
+	return nil
+}
+
+func (p *PostgresDB) GetOrganization(ctx context.Context, id string) (*domain.Organization, error) {
+	var org Organization
+	err := p.db.WithContext(ctx).Where("tenant_id = ?", tenant.FromContext(ctx)).First(&org, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrOrganizationNotFound
+		}
+		return nil, err
+	}
+	return org.toDomain(), nil
+}
+
+func (p *PostgresDB) GetMembership(ctx context.Context, orgID, userID string) (*domain.Membership, error) {
+	var member OrganizationMember
+	err := p.db.WithContext(ctx).First(&member, "organization_id = ? AND user_id = ?", orgID, userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrNotMember
+		}
+		return nil, err
+	}
+	return member.toDomain(), nil
+}
+
+// ListUserMemberships returns the user's memberships with their organizations, oldest first
+func (p *PostgresDB) ListUserMemberships(ctx context.Context, userID string) ([]*domain.Membership, error) {
+	var members []OrganizationMember
+	err := p.db.WithContext(ctx).
+		Joins("Organization").
+		Where(`"Organization"."tenant_id" = ?`, tenant.FromContext(ctx)).
+		Where("organization_members.user_id = ?", userID).
+		Order("organization_members.created_at").
+		Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	return membershipsToDomain(members), nil
+}
+
+func (p *PostgresDB) ListMembers(ctx context.Context, orgID string) ([]*domain.Membership, error) {
+	var members []OrganizationMember
+	if err := p.db.WithContext(ctx).Where("organization_id = ?", orgID).Order("created_at").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return membershipsToDomain(members), nil
+}
+
+// UpdateMemberRole changes the role of a member. Demoting the last owner fails with
+// ErrLastOwner.
+func (p *PostgresDB) UpdateMemberRole(ctx context.Context, orgID, userID string, role domain.OrgRole) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if role != domain.OrgRoleOwner {
+			if err := keepOwner(tx, orgID, userID); err != nil {
+				return err
+			}
+		}
+		res := tx.Model(&OrganizationMember{}).
+			Where("organization_id = ? AND user_id = ?", orgID, userID).
+			Update("role", int32(role))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return domain.ErrNotMember
+		}
+		return nil
+	})
+}
+
+// RemoveMember removes a member. Removing the last owner fails with ErrLastOwner.
+func (p *PostgresDB) RemoveMember(ctx context.Context, orgID, userID string) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := keepOwner(tx, orgID, userID); err != nil {
+			return err
+		}
+		res := tx.Where("organization_id = ? AND user_id = ?", orgID, userID).Delete(&OrganizationMember{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return domain.ErrNotMember
+		}
+		return nil
+	})
+}
+
+// keepOwner locks the owners of the organization and fails with ErrLastOwner if userID is
+// the only one left. The lock is held until tx ends, so two owners demoting or removing
+// each other at the same time cannot both succeed.
+func keepOwner(tx *gorm.DB, orgID, userID string) error {
+	var owners []OrganizationMember
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("organization_id = ? AND role = ?", orgID, int32(domain.OrgRoleOwner)).
+		Order("user_id").
+		Find(&owners).Error
+	if err != nil {
+		return err
+	}
+	if len(owners) == 1 && owners[0].UserID.String() == userID {
+		return domain.ErrLastOwner
+	}
+	return nil
+}
+
+func (p *PostgresDB) CreateInvitation(ctx context.Context, inv *domain.OrgInvitation) error {
+	model := &OrganizationInvitation{
+		ID:             inv.ID,
+		TenantID:       tenant.FromContext(ctx),
+		OrganizationID: inv.OrganizationID,
+		Email:          strings.ToLower(inv.Email),
+		TokenHash:      inv.TokenHash,
+		Role:           int32(inv.Role),
+		InvitedBy:      inv.InvitedBy,
+		ExpiresAt:      inv.ExpiresAt,
+	}
+	if err := p.db.WithContext(ctx).Create(model).Error; err != nil {
+		return err
+	}
+	inv.ID = model.ID
+	inv.Email = model.Email
+	inv.CreatedAt = model.CreatedAt
+	return nil
+}
+
+func (p *PostgresDB) GetInvitation(ctx context.Context, id string) (*domain.OrgInvitation, error) {
+	var inv OrganizationInvitation
+	err := p.db.WithContext(ctx).Where("tenant_id = ?", tenant.FromContext(ctx)).First(&inv, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrInvitationNotFound
+		}
+		return nil, err
+	}
+	return inv.toDomain(), nil
+}
+
+// ListPendingInvitations returns the invitations of an organization that can still be accepted
+func (p *PostgresDB) ListPendingInvitations(ctx context.Context, orgID string) ([]*domain.OrgInvitation, error) {
+	return p.listPendingInvitations(ctx, "organization_id = ?", orgID)
+}
+
+// ListPendingInvitationsForEmail returns the invitations addressed to email that can still be accepted
+func (p *PostgresDB) ListPendingInvitationsForEmail(ctx context.Context, email string) ([]*domain.OrgInvitation, error) {
+	return p.listPendingInvitations(ctx, "email = ?", strings.ToLower(email))
+}
+
+func (p *PostgresDB) listPendingInvitations(ctx context.Context, query string, arg interface{}) ([]*domain.OrgInvitation, error) {
+	var invs []OrganizationInvitation
+	err := p.db.WithContext(ctx).
+		Where("tenant_id = ?", tenant.FromContext(ctx)).
+		Where(query, arg).
+		Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", time.Now()).
+		Order("created_at").
+		Find(&invs).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*domain.OrgInvitation, 0, len(invs))
+	for i := range invs {
+		result = append(result, invs[i].toDomain())
+	}
+	return result, nil
+}
+
+// AcceptInvitation marks the invitation accepted and adds the membership in one transaction.
+// It fails with ErrInvitationExpired if the invitation was accepted or revoked concurrently.
+func (p *PostgresDB) AcceptInvitation(ctx context.Context, inv *domain.OrgInvitation, member *domain.Membership) error {
+	model := &OrganizationMember{
+		OrganizationID: member.OrganizationID,
+		UserID:         member.UserID,
+		Role:           int32(member.Role),
+	}
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&OrganizationInvitation{}).
+			Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", inv.ID).
+			Update("accepted_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return domain.ErrInvitationExpired
+		}
+		return tx.Create(model).Error
+	})
+	if err != nil {
+		return err
+	}
+	member.JoinedAt = model.CreatedAt
+	return nil
+}
+
+func (p *PostgresDB) RevokeInvitation(ctx context.Context, id string) error {
+	res := p.db.WithContext(ctx).Model(&OrganizationInvitation{}).
+		Where("id = ? AND tenant_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id, tenant.FromContext(ctx)).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrInvitationNotFound
+	}
+	return nil
+}
+
+// PurgeInvitations deletes invitations that expired, were accepted or were revoked before the given time
+func (p *PostgresDB) PurgeInvitations(ctx context.Context, before time.Time) (int64, error) {
+	res := p.db.WithContext(ctx).
+		Where("expires_at < ? OR accepted_at < ? OR revoked_at < ?", before, before, before).
+		Delete(&OrganizationInvitation{})
+	return res.RowsAffected, res.Error
+}
+
+func membershipsToDomain(members []OrganizationMember) []*domain.Membership {
+	result := make([]*domain.Membership, 0, len(members))
+	for i := range members {
+		result = append(result, members[i].toDomain())
+	}
+	return result
+}
@@ -0,0 +1,217 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Passkey is a registered WebAuthn credential
+type Passkey struct {
+	ID              uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID        string     `gorm:"type:varchar(64);not null;default:'default'"`
+	UserID          uuid.UUID  `gorm:"type:uuid;index;not null"`
+	Name            string     `gorm:"type:varchar(255);not null"`
+	CredentialID    []byte     `gorm:"type:bytea;uniqueIndex;not null"` // Credential IDs are globally unique
+	PublicKey       []byte     `gorm:"type:bytea;not null"`             // COSE-encoded public key
+	AttestationType string     `gorm:"type:varchar(32)"`
+	Transports      string     `gorm:"type:text"` // Comma-separated
+	AAGUID          []byte     `gorm:"type:bytea"`
+	SignCount       int64      `gorm:"not null"`
+	BackupEligible  bool       `gorm:"not null"`
+	BackupState     bool       `gorm:"not null"`
+	CloneWarning    bool       `gorm:"not null"`
+	CreatedAt       time.Time  `gorm:"autoCreateTime"`
+	LastUsedAt      *time.Time `gorm:""` // Nullable
+}
+
+// PasskeyCeremony holds the challenge of a passkey registration or login in progress
+type PasskeyCeremony struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID  string     `gorm:"type:varchar(64);not null;default:'default'"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index"` // Nullable
+	Kind      string     `gorm:"type:varchar(16);not null"`
+	Session   []byte     `gorm:"type:bytea;not null"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+	ExpiresAt time.Time  `gorm:"index;not null"`
+	UsedAt    *time.Time `gorm:""` // Nullable
+}
+
+func (k *Passkey) toDomain() *domain.Passkey {
+	passkey := &domain.Passkey{
+		ID:              k.ID,
+		TenantID:        k.TenantID,
+		UserID:          k.UserID,
+		Name:            k.Name,
+		CredentialID:    k.CredentialID,
+		PublicKey:       k.PublicKey,
+		AttestationType: k.AttestationType,
+		AAGUID:          k.AAGUID,
+		SignCount:       uint32(k.SignCount),
+		BackupEligible:  k.BackupEligible,
+		BackupState:     k.BackupState,
+		CloneWarning:    k.CloneWarning,
+		CreatedAt:       k.CreatedAt,
+		LastUsedAt:      k.LastUsedAt,
+	}
+	if k.Transports != "" {
+		passkey.Transports = strings.Split(k.Transports, ",")
+	}
+	return passkey
+}
+
+func (c *PasskeyCeremony) toDomain() *domain.PasskeyCeremony {
+	return &domain.PasskeyCeremony{
+		ID:        c.ID,
+		TenantID:  c.TenantID,
+		UserID:    c.UserID,
+		Kind:      domain.PasskeyCeremonyKind(c.Kind),
+		Session:   c.Session,
+		CreatedAt: c.CreatedAt,
+		ExpiresAt: c.ExpiresAt,
+		UsedAt:    c.UsedAt,
+	}
+}
+
+// passkeys scopes a query on the passkeys table to the tenant carried by ctx
+func (p *PostgresDB) passkeys(ctx context.Context) *gorm.DB {
+	return p.db.WithContext(ctx).Model(&Passkey{}).Where("tenant_id = ?", tenant.FromContext(ctx))
+}
+
+func (p *PostgresDB) CreatePasskey(ctx context.Context, passkey *domain.Passkey) error {
+	model := &Passkey{
+		TenantID:        tenant.FromContext(ctx),
+		UserID:          passkey.UserID,
+		Name:            passkey.Name,
+		CredentialID:    passkey.CredentialID,
+		PublicKey:       passkey.PublicKey,
+		AttestationType: passkey.AttestationType,
+		Transports:      strings.Join(passkey.Transports, ","),
+		AAGUID:          passkey.AAGUID,
+		SignCount:       int64(passkey.SignCount),
+		BackupEligible:  passkey.BackupEligible,
+		BackupState:     passkey.BackupState,
+	}
+	if err := p.db.WithContext(ctx).Create(model).Error; err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return domain.ErrPasskeyExists
+		}
+		return err
+	}
+	passkey.ID = model.ID
+	passkey.TenantID = model.TenantID
+	passkey.CreatedAt = model.CreatedAt
+	return nil
+}
+
+func (p *PostgresDB) ListPasskeys(ctx context.Context, userID string) ([]*domain.Passkey, error) {
+	var passkeys []Passkey
+	if err := p.passkeys(ctx).Where("user_id = ?", userID).Order("created_at").Find(&passkeys).Error; err != nil {
+		return nil, err
+	}
+	result := make([]*domain.Passkey, 0, len(passkeys))
+	for i := range passkeys {
+		result = append(result, passkeys[i].toDomain())
+	}
+	return result, nil
+}
+
+func (p *PostgresDB) GetPasskeyByCredentialID(ctx context.Context, credentialID []byte) (*domain.Passkey, error) {
+	var passkey Passkey
+	if err := p.passkeys(ctx).First(&passkey, "credential_id = ?", credentialID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrPasskeyNotFound
+		}
+		return nil, err
+	}
+	return passkey.toDomain(), nil
+}
+
+// UpdatePasskeyUsage records the outcome of a login with the passkey: its new sign
+// count, backup state and clone warning, and when it was used
+func (p *PostgresDB) UpdatePasskeyUsage(ctx context.Context, passkey *domain.Passkey) error {
+	return p.passkeys(ctx).Where("id = ?", passkey.ID).Updates(map[string]interface{}{
+		"sign_count":    int64(passkey.SignCount),
+		"backup_state":  passkey.BackupState,
+		"clone_warning": passkey.CloneWarning,
+		"last_used_at":  passkey.LastUsedAt,
+	}).Error
+}
+
+func (p *PostgresDB) DeletePasskey(ctx context.Context, userID, id string) error {
+	res := p.passkeys(ctx).Where("user_id = ? AND id = ?", userID, id).Delete(&Passkey{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrPasskeyNotFound
+	}
+	return nil
+}
+
+// DeletePasskeys removes every passkey of the user
+func (p *PostgresDB) DeletePasskeys(ctx context.Context, userID string) (int64, error) {
+	res := p.passkeys(ctx).Where("user_id = ?", userID).Delete(&Passkey{})
+	return res.RowsAffected, res.Error
+}
+
+func (p *PostgresDB) SetTwoFactor(ctx context.Context, id string, enabled bool) error {
+	return p.updateUser(ctx, id, map[string]interface{}{"two_factor_enabled": enabled})
+}
+
+func (p *PostgresDB) CreatePasskeyCeremony(ctx context.Context, ceremony *domain.PasskeyCeremony) error {
+	model := &PasskeyCeremony{
+		TenantID:  tenant.FromContext(ctx),
+		UserID:    ceremony.UserID,
+		Kind:      string(ceremony.Kind),
+		Session:   ceremony.Session,
+		ExpiresAt: ceremony.ExpiresAt,
+	}
+	if err := p.db.WithContext(ctx).Create(model).Error; err != nil {
+		return err
+	}
+	ceremony.ID = model.ID
+	ceremony.TenantID = model.TenantID
+	ceremony.CreatedAt = model.CreatedAt
+	return nil
+}
+
+// ConsumePasskeyCeremony marks an unused, unexpired ceremony of the given kind as used
+// and returns it. The row is locked while it is read, so a challenge is answered only once.
+func (p *PostgresDB) ConsumePasskeyCeremony(ctx context.Context, id string, kind domain.PasskeyCeremonyKind) (*domain.PasskeyCeremony, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, domain.ErrInvalidCeremony
+	}
+	var ceremony PasskeyCeremony
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND id = ? AND kind = ? AND used_at IS NULL AND expires_at > ?", tenant.FromContext(ctx), id, string(kind), time.Now()).
+			First(&ceremony).Error
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		ceremony.UsedAt = &now
+		return tx.Model(&ceremony).Update("used_at", now).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrInvalidCeremony
+		}
+		return nil, err
+	}
+	return ceremony.toDomain(), nil
+}
+
+// PurgePasskeyCeremonies deletes ceremonies that expired before the given time
+func (p *PostgresDB) PurgePasskeyCeremonies(ctx context.Context, before time.Time) (int64, error) {
+	res := p.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&PasskeyCeremony{})
+	return res.RowsAffected, res.Error
+}
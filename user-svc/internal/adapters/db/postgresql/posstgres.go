@@ -2,45 +2,289 @@ package postgresql
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"time"
 
 	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"github.com/google/uuid"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-
-
 func Adapter(dsn string) (*PostgresDB, error) {
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
 		return nil, err
 	}
-	db.AutoMigrate(&User{})
+	// Emails used to be unique across all tenants; that index now lives on (tenant_id, email)
+	if db.Migrator().HasIndex(&User{}, "idx_users_email") {
+		if err := db.Migrator().DropIndex(&User{}, "idx_users_email"); err != nil {
+			return nil, err
+		}
+	}
+	db.AutoMigrate(&User{}, &Session{}, &ErasureRequest{}, &Organization{}, &OrganizationMember{}, &OrganizationInvitation{}, &APIKey{}, &OIDCClient{}, &OIDCAuthorizationCode{}, &ExternalIdentity{}, &LoginLink{}, &Passkey{}, &PasskeyCeremony{}, &UserInvite{}, &Impersonation{}, &EmailChange{}, &IdempotentCall{}, &UserEvent{})
 	return &PostgresDB{db: db}, nil
 }
 
+// Ping checks that the database can be reached
+func (p *PostgresDB) Ping(ctx context.Context) error {
+	sqlDB, err := p.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// users scopes a query on the users table to the tenant carried by ctx
+func (p *PostgresDB) users(ctx context.Context) *gorm.DB {
+	return p.db.WithContext(ctx).Model(&User{}).Where("tenant_id = ?", tenant.FromContext(ctx))
+}
+
 func (p *PostgresDB) CreateUser(ctx context.Context, user *domain.User) error {
-	return p.db.WithContext(ctx).Create(user).Error
+	model := userFromDomain(user)
+	model.TenantID = tenant.FromContext(ctx)
+	if err := p.db.WithContext(ctx).Create(model).Error; err != nil {
+		return err
+	}
+	// IsActive has a column default, so gorm skips it when false; persist it explicitly
+	if !user.IsActive {
+		if err := p.db.WithContext(ctx).Model(model).Update("is_active", false).Error; err != nil {
+			return err
+		}
+	}
+	user.ID = model.ID
+	user.TenantID = model.TenantID
+	user.CreatedAt = model.CreatedAt
+	return nil
 }
 
 func (p *PostgresDB) GetUser(ctx context.Context, id string) (*domain.User, error) {
 	var user User
-	err := p.db.WithContext(ctx).First(&user, "id = ?", id).Error
-	return &domain.User{
-		ID:       user.ID,
-		Name:     user.Name,
-		Email:    user.Email,
-		Password: user.Password,
-	}, err
+	err := p.users(ctx).First(&user, "id = ?", id).Error
+	return user.toDomain(), notFound(err)
 }
 
 func (p *PostgresDB) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
 	var user User
-	err := p.db.WithContext(ctx).First(&user, "email = ?", email).Error
-	return &domain.User{
-		ID:       user.ID,
-		Name:     user.Name,
-		Email:    user.Email,
-		Password: user.Password,
-	}, err
+	err := p.users(ctx).First(&user, "email = ?", email).Error
+	return user.toDomain(), notFound(err)
+}
+
+func (p *PostgresDB) UpdatePassword(ctx context.Context, id string, hashedPassword string) error {
+	return p.updateUser(ctx, id, map[string]interface{}{"password": hashedPassword})
+}
+
+func (p *PostgresDB) SetActive(ctx context.Context, id string, active bool) error {
+	return p.updateUser(ctx, id, map[string]interface{}{"is_active": active})
+}
+
+func (p *PostgresDB) UpdateRole(ctx context.Context, id string, role domain.Role) error {
+	return p.updateUser(ctx, id, map[string]interface{}{"role": int32(role)})
+}
+
+// SetPermissions replaces the permissions granted to the user
+func (p *PostgresDB) SetPermissions(ctx context.Context, id string, permissions []string) error {
+	return p.updateUser(ctx, id, map[string]interface{}{"permissions": strings.Join(permissions, ",")})
+}
+
+func (p *PostgresDB) TouchLastLogin(ctx context.Context, id string, at time.Time) error {
+	return p.updateUser(ctx, id, map[string]interface{}{"last_login": at})
+}
+
+// AnonymizeUser overwrites every personal field of the user and soft-deletes the row.
+// The row itself is kept so foreign references and the erasure trail stay intact.
+func (p *PostgresDB) AnonymizeUser(ctx context.Context, id string, pseudonym string) error {
+	res := p.users(ctx).Unscoped().Where("id = ?", id).Updates(map[string]interface{}{
+		"name":                "Erased User",
+		"email":               pseudonym + "@erased.invalid",
+		"password":            "",
+		"phone_number":        nil,
+		"age":                 nil,
+		"permissions":         nil,
+		"profile_picture_url": nil,
+		"metadata":            nil,
+		"last_login":          nil,
+		"two_factor_enabled":  false,
+		"two_factor_secret":   nil,
+		"is_active":           false,
+		"is_deleted":          true,
+		"deleted_at":          time.Now(),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+func (p *PostgresDB) updateUser(ctx context.Context, id string, fields map[string]interface{}) error {
+	res := p.users(ctx).Where("id = ?", id).Updates(fields)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+func (p *PostgresDB) CreateSession(ctx context.Context, session *domain.Session) error {
+	model := &Session{
+		ID:        session.ID,
+		UserID:    session.UserID,
+		ExpiresAt: session.ExpiresAt,
+	}
+	if err := p.db.WithContext(ctx).Create(model).Error; err != nil {
+		return err
+	}
+	session.ID = model.ID
+	session.CreatedAt = model.CreatedAt
+	return nil
+}
+
+func (p *PostgresDB) GetSession(ctx context.Context, id string) (*domain.Session, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, domain.ErrSessionNotFound
+	}
+	var session Session
+	if err := p.db.WithContext(ctx).First(&session, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrSessionNotFound
+		}
+		return nil, err
+	}
+	return session.toDomain(), nil
+}
+
+func (p *PostgresDB) ListSessions(ctx context.Context, userID string) ([]*domain.Session, error) {
+	var sessions []Session
+	if err := p.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	result := make([]*domain.Session, 0, len(sessions))
+	for i := range sessions {
+		result = append(result, sessions[i].toDomain())
+	}
+	return result, nil
+}
+
+func (p *PostgresDB) RevokeSessions(ctx context.Context, userID string) (int64, error) {
+	res := p.db.WithContext(ctx).Model(&Session{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Update("revoked_at", time.Now())
+	return res.RowsAffected, res.Error
+}
+
+func (p *PostgresDB) CreateErasureRequest(ctx context.Context, req *domain.ErasureRequest) error {
+	model := erasureRequestFromDomain(req)
+	model.TenantID = tenant.FromContext(ctx)
+	if err := p.db.WithContext(ctx).Create(model).Error; err != nil {
+		return err
+	}
+	req.ID = model.ID
+	req.TenantID = model.TenantID
+	req.RequestedAt = model.RequestedAt
+	return nil
+}
+
+func (p *PostgresDB) UpdateErasureRequest(ctx context.Context, req *domain.ErasureRequest) error {
+	model := erasureRequestFromDomain(req)
+	return p.db.WithContext(ctx).Model(&ErasureRequest{}).Where("id = ?", req.ID).
+		Select("email", "pseudonym", "status", "completed_steps", "error", "completed_at").
+		Updates(model).Error
+}
+
+func (p *PostgresDB) GetErasureRequest(ctx context.Context, id string) (*domain.ErasureRequest, error) {
+	var req ErasureRequest
+	err := p.db.WithContext(ctx).Where("tenant_id = ?", tenant.FromContext(ctx)).First(&req, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrErasureNotFound
+		}
+		return nil, err
+	}
+	return req.toDomain(), nil
+}
+
+// ListUnfinishedErasureRequests returns the unfinished requests of every tenant, oldest first
+func (p *PostgresDB) ListUnfinishedErasureRequests(ctx context.Context) ([]*domain.ErasureRequest, error) {
+	var reqs []ErasureRequest
+	err := p.db.WithContext(ctx).
+		Where("status <> ?", int32(domain.ErasureStatusCompleted)).
+		Order("requested_at").
+		Find(&reqs).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*domain.ErasureRequest, 0, len(reqs))
+	for i := range reqs {
+		result = append(result, reqs[i].toDomain())
+	}
+	return result, nil
+}
+
+// PurgeSessions deletes sessions that expired or were revoked before the given time
+func (p *PostgresDB) PurgeSessions(ctx context.Context, before time.Time) (int64, error) {
+	res := p.db.WithContext(ctx).
+		Where("expires_at < ? OR revoked_at < ?", before, before).
+		Delete(&Session{})
+	return res.RowsAffected, res.Error
+}
+
+// PurgeDeletedUsers permanently removes users soft-deleted before the given time, with their
+// sessions, organization memberships, API keys, authorization codes, external identities, login links and passkeys
+func (p *PostgresDB) PurgeDeletedUsers(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		deleted := tx.Unscoped().Model(&User{}).Select("id").Where("deleted_at < ?", before)
+		if err := tx.Where("user_id IN (?)", deleted).Delete(&Session{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id IN (?)", deleted).Delete(&OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id IN (?)", deleted).Delete(&APIKey{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id IN (?)", deleted).Delete(&OIDCAuthorizationCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id IN (?)", deleted).Delete(&ExternalIdentity{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id IN (?)", deleted).Delete(&LoginLink{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id IN (?)", deleted).Delete(&Passkey{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id IN (?)", deleted).Delete(&PasskeyCeremony{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id IN (?)", deleted).Delete(&UserInvite{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id IN (?) OR actor_id IN (?)", deleted, deleted).Delete(&Impersonation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id IN (?)", deleted).Delete(&EmailChange{}).Error; err != nil {
+			return err
+		}
+		res := tx.Unscoped().Where("deleted_at < ?", before).Delete(&User{})
+		purged = res.RowsAffected
+		return res.Error
+	})
+	return purged, err
+}
+
+// notFound maps gorm's record-not-found error onto the domain error
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.ErrUserNotFound
+	}
+	return err
 }
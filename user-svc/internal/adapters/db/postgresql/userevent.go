@@ -0,0 +1,101 @@
+package postgresql
+
+import (
+	"context"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// userEventLock is the advisory lock that AppendUserEvent holds until its event commits
+const userEventLock = 0x75736572 // "user"
+
+// UserEvent is a change to a user, kept for WatchUsers to replay
+type UserEvent struct {
+	Sequence   int64     `gorm:"primaryKey;autoIncrement"`
+	TenantID   string    `gorm:"type:varchar(64);not null;default:'default'"`
+	Type       string    `gorm:"type:varchar(32);not null"`
+	UserID     uuid.UUID `gorm:"type:uuid;index;not null"`
+	OccurredAt time.Time `gorm:"autoCreateTime;index"`
+}
+
+func (e *UserEvent) toDomain() *domain.UserEvent {
+	return &domain.UserEvent{
+		Sequence:   e.Sequence,
+		TenantID:   e.TenantID,
+		Type:       domain.UserEventType(e.Type),
+		UserID:     e.UserID,
+		OccurredAt: e.OccurredAt,
+	}
+}
+
+// AppendUserEvent records a change to a user, assigning it the next sequence. Events are
+// appended one at a time, so they commit in sequence order: once an event is visible, no
+// event with a lower sequence can still show up and be skipped by a watch reading past it.
+func (p *PostgresDB) AppendUserEvent(ctx context.Context, event *domain.UserEvent) error {
+	model := &UserEvent{
+		TenantID: tenant.FromContext(ctx),
+		Type:     string(event.Type),
+		UserID:   event.UserID,
+	}
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", userEventLock).Error; err != nil {
+			return err
+		}
+		return tx.Create(model).Error
+	})
+	if err != nil {
+		return err
+	}
+	event.Sequence = model.Sequence
+	event.TenantID = model.TenantID
+	event.OccurredAt = model.OccurredAt
+	return nil
+}
+
+// ListUserEvents returns up to limit events of the tenant matching filter, oldest first
+func (p *PostgresDB) ListUserEvents(ctx context.Context, filter domain.UserEventFilter, limit int) ([]*domain.UserEvent, error) {
+	query := p.db.WithContext(ctx).
+		Where("tenant_id = ? AND sequence > ?", tenant.FromContext(ctx), filter.After)
+	if len(filter.UserIDs) > 0 {
+		query = query.Where("user_id IN ?", filter.UserIDs)
+	}
+	if len(filter.Types) > 0 {
+		types := make([]string, len(filter.Types))
+		for i, t := range filter.Types {
+			types[i] = string(t)
+		}
+		query = query.Where("type IN ?", types)
+	}
+	var models []UserEvent
+	if err := query.Order("sequence").Limit(limit).Find(&models).Error; err != nil {
+		return nil, err
+	}
+	events := make([]*domain.UserEvent, len(models))
+	for i := range models {
+		events[i] = models[i].toDomain()
+	}
+	return events, nil
+}
+
+// UserEventBounds returns the lowest and highest sequence still recorded across all
+// tenants, or zeros when no events are recorded
+func (p *PostgresDB) UserEventBounds(ctx context.Context) (oldest, newest int64, err error) {
+	var bounds struct {
+		Oldest int64
+		Newest int64
+	}
+	err = p.db.WithContext(ctx).Model(&UserEvent{}).
+		Select("COALESCE(MIN(sequence), 0) AS oldest, COALESCE(MAX(sequence), 0) AS newest").
+		Scan(&bounds).Error
+	return bounds.Oldest, bounds.Newest, err
+}
+
+// PurgeUserEvents deletes events that happened before the given time
+func (p *PostgresDB) PurgeUserEvents(ctx context.Context, before time.Time) (int64, error) {
+	res := p.db.WithContext(ctx).Where("occurred_at < ?", before).Delete(&UserEvent{})
+	return res.RowsAffected, res.Error
+}
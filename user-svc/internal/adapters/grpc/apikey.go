@@ -0,0 +1,115 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *UserServer) CreateApiKey(ctx context.Context, req *pb.CreateApiKeyRequest) (*pb.CreateApiKeyResponse, error) {
+	if req.GetUserId() == "" || req.GetName() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: user_id and name are required")
+	}
+
+	var expiresAt *time.Time
+	if req.GetExpiresAt() != nil {
+		t := req.GetExpiresAt().AsTime()
+		expiresAt = &t
+	}
+	key, secret, err := s.api.CreateAPIKey(ctx, req.GetUserId(), req.GetName(), req.GetScopes(), expiresAt, req.GetOrganizationId())
+	if err != nil {
+		return nil, s.apiKeyError(err, "creating api key", zap.String("user_id", req.GetUserId()))
+	}
+	return &pb.CreateApiKeyResponse{ApiKey: toPBApiKey(key), Secret: secret}, nil
+}
+
+func (s *UserServer) ListApiKeys(ctx context.Context, req *pb.ListApiKeysRequest) (*pb.ListApiKeysResponse, error) {
+	keys, err := s.api.ListAPIKeys(ctx, req.GetUserId())
+	if err != nil {
+		return nil, s.apiKeyError(err, "listing api keys", zap.String("user_id", req.GetUserId()))
+	}
+	result := make([]*pb.ApiKey, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, toPBApiKey(key))
+	}
+	return &pb.ListApiKeysResponse{ApiKeys: result}, nil
+}
+
+func (s *UserServer) GetApiKey(ctx context.Context, req *pb.GetApiKeyRequest) (*pb.GetApiKeyResponse, error) {
+	key, err := s.api.GetAPIKey(ctx, req.GetUserId(), req.GetId())
+	if err != nil {
+		return nil, s.apiKeyError(err, "fetching api key", zap.String("key_id", req.GetId()))
+	}
+	return &pb.GetApiKeyResponse{ApiKey: toPBApiKey(key)}, nil
+}
+
+func (s *UserServer) UpdateApiKey(ctx context.Context, req *pb.UpdateApiKeyRequest) (*pb.UpdateApiKeyResponse, error) {
+	key, err := s.api.UpdateAPIKey(ctx, req.GetUserId(), req.GetId(), req.GetName(), req.GetScopes())
+	if err != nil {
+		return nil, s.apiKeyError(err, "updating api key", zap.String("key_id", req.GetId()))
+	}
+	return &pb.UpdateApiKeyResponse{ApiKey: toPBApiKey(key)}, nil
+}
+
+func (s *UserServer) RevokeApiKey(ctx context.Context, req *pb.RevokeApiKeyRequest) (*pb.RevokeApiKeyResponse, error) {
+	if err := s.api.RevokeAPIKey(ctx, req.GetUserId(), req.GetId()); err != nil {
+		return nil, s.apiKeyError(err, "revoking api key", zap.String("key_id", req.GetId()))
+	}
+	return &pb.RevokeApiKeyResponse{Success: true}, nil
+}
+
+func (s *UserServer) AuthenticateApiKey(ctx context.Context, req *pb.AuthenticateApiKeyRequest) (*pb.AuthenticateApiKeyResponse, error) {
+	identity, err := s.api.AuthenticateAPIKey(ctx, req.GetKey())
+	if err != nil {
+		return nil, s.apiKeyError(err, "authenticating api key")
+	}
+	res := &pb.AuthenticateApiKeyResponse{
+		User:   toPBUser(identity.User),
+		ApiKey: toPBApiKey(identity.Key),
+	}
+	if identity.Membership != nil {
+		res.Membership = toPBMember(identity.Membership)
+	}
+	return res, nil
+}
+
+// apiKeyError maps an error of the API key workflows onto a gRPC status
+func (s *UserServer) apiKeyError(err error, action string, fields ...zap.Field) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidAPIKey):
+		return status.Errorf(codes.Unauthenticated, "Invalid API key")
+	case errors.Is(err, domain.ErrUserLocked):
+		return status.Errorf(codes.PermissionDenied, "Account is locked")
+	case errors.Is(err, domain.ErrAPIKeyNotFound):
+		return status.Errorf(codes.NotFound, "API key not found")
+	case errors.Is(err, domain.ErrInvalidScope), errors.Is(err, domain.ErrInvalidExpiry):
+		return status.Errorf(codes.InvalidArgument, "Invalid input: %v", err)
+	default:
+		return s.organizationError(err, action, fields...)
+	}
+}
+
+func toPBApiKey(key *domain.APIKey) *pb.ApiKey {
+	res := &pb.ApiKey{
+		Id:        key.ID.String(),
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: timestamppb.New(key.CreatedAt),
+		ExpiresAt: timestamppb.New(key.ExpiresAt),
+	}
+	if key.OrganizationID != nil {
+		res.OrganizationId = key.OrganizationID.String()
+	}
+	if key.LastUsedAt != nil {
+		res.LastUsedAt = timestamppb.New(*key.LastUsedAt)
+	}
+	return res
+}
@@ -0,0 +1,50 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *UserServer) RequestEmailChange(ctx context.Context, req *pb.RequestEmailChangeRequest) (*pb.RequestEmailChangeResponse, error) {
+	if req.GetUserId() == "" || req.GetPassword() == "" || req.GetNewEmail() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: user_id, password and new_email are required")
+	}
+	expiresAt, err := s.api.RequestEmailChange(ctx, req.GetUserId(), req.GetPassword(), req.GetNewEmail())
+	if err != nil {
+		return nil, s.emailChangeError(err, "requesting email change", zap.String("user_id", req.GetUserId()))
+	}
+	return &pb.RequestEmailChangeResponse{ExpiresAt: timestamppb.New(expiresAt)}, nil
+}
+
+func (s *UserServer) ConfirmEmailChange(ctx context.Context, req *pb.ConfirmEmailChangeRequest) (*pb.ConfirmEmailChangeResponse, error) {
+	user, err := s.api.ConfirmEmailChange(ctx, req.GetToken())
+	if err != nil {
+		return nil, s.emailChangeError(err, "confirming email change")
+	}
+	return &pb.ConfirmEmailChangeResponse{User: toPBUser(user)}, nil
+}
+
+// emailChangeError maps an error of the email change workflow onto a gRPC status
+func (s *UserServer) emailChangeError(err error, action string, fields ...zap.Field) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidCredentials):
+		return status.Errorf(codes.Unauthenticated, "Current password is incorrect")
+	case errors.Is(err, domain.ErrInvalidEmailChange):
+		return status.Errorf(codes.Unauthenticated, "Confirmation is invalid, expired or was already used")
+	case errors.Is(err, domain.ErrSameEmail):
+		return status.Errorf(codes.InvalidArgument, "New email address is the current one")
+	case errors.Is(err, domain.ErrUserAlreadyExists):
+		return status.Errorf(codes.AlreadyExists, "A user with this email already exists")
+	case errors.Is(err, domain.ErrUserLocked):
+		return status.Errorf(codes.PermissionDenied, "Account is locked")
+	default:
+		return s.organizationError(err, action, fields...)
+	}
+}
@@ -1,10 +1,12 @@
 package grpc
 
 import (
+	"context"
 	"net"
 
 	pb "github.com/asadlive84/shopper-proto/golang/user"
 	"github.com/asadlive84/shopper/user-svc/internal/ports"
+	"github.com/asadlive84/shopper/user-svc/internal/requestid"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 )
@@ -19,6 +21,11 @@ func NewUserServer(api ports.APIPort, logger *zap.Logger) *UserServer {
 	return &UserServer{api: api, logger: logger}
 }
 
+// log returns the server's logger annotated with the request ID carried by ctx
+func (s *UserServer) log(ctx context.Context) *zap.Logger {
+	return requestid.Logger(ctx, s.logger)
+}
+
 func StartGRPCServer(port string, api ports.APIPort, logger *zap.Logger, server *grpc.Server) error {
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
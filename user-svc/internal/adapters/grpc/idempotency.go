@@ -0,0 +1,188 @@
+package grpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/monitoring"
+	"github.com/asadlive84/shopper/user-svc/internal/ports"
+	"github.com/asadlive84/shopper/user-svc/internal/requestid"
+	"go.uber.org/zap"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// IdempotencyMetadataKey is the gRPC metadata key carrying the client's idempotency key
+const IdempotencyMetadataKey = "idempotency-key"
+
+// maxIdempotencyKeyLength bounds the keys clients may choose
+const maxIdempotencyKeyLength = 255
+
+// idempotentMethods are the mutating calls that honour an idempotency key. Calls whose
+// response carries a credential, such as logins or CreateApiKey, are left out: replaying
+// them would mean storing the credential in the clear.
+var idempotentMethods = map[string]bool{
+	pb.UserService_CreateUser_FullMethodName:                   true,
+	pb.UserService_UpdateUser_FullMethodName:                   true,
+	pb.UserService_UpdateUserRole_FullMethodName:               true,
+	pb.UserService_AssignPermissions_FullMethodName:            true,
+	pb.UserService_ResetPassword_FullMethodName:                true,
+	pb.UserService_VerifyEmail_FullMethodName:                  true,
+	pb.UserService_SoftDeleteUser_FullMethodName:               true,
+	pb.UserService_RequestErasure_FullMethodName:               true,
+	pb.UserService_CreateOrganization_FullMethodName:           true,
+	pb.UserService_UpdateOrganizationMember_FullMethodName:     true,
+	pb.UserService_RemoveOrganizationMember_FullMethodName:     true,
+	pb.UserService_InviteOrganizationMember_FullMethodName:     true,
+	pb.UserService_RevokeOrganizationInvitation_FullMethodName: true,
+	pb.UserService_AcceptOrganizationInvitation_FullMethodName: true,
+	pb.UserService_UpdateApiKey_FullMethodName:                 true,
+	pb.UserService_RevokeApiKey_FullMethodName:                 true,
+	pb.UserService_RequestLoginLink_FullMethodName:             true,
+	pb.UserService_FinishPasskeyRegistration_FullMethodName:    true,
+	pb.UserService_DeletePasskey_FullMethodName:                true,
+	pb.UserService_SetTwoFactor_FullMethodName:                 true,
+	pb.UserService_InviteUser_FullMethodName:                   true,
+	pb.UserService_ResendUserInvite_FullMethodName:             true,
+	pb.UserService_RevokeUserInvite_FullMethodName:             true,
+	pb.UserService_EndImpersonation_FullMethodName:             true,
+	pb.UserService_RequestEmailChange_FullMethodName:           true,
+	pb.UserService_ConfirmEmailChange_FullMethodName:           true,
+}
+
+// replayedCodes are the error codes a retry of the same request would get again, so they
+// are recorded and replayed like a response. Other failures free the key for the retry.
+var replayedCodes = map[codes.Code]bool{
+	codes.InvalidArgument:    true,
+	codes.NotFound:           true,
+	codes.AlreadyExists:      true,
+	codes.PermissionDenied:   true,
+	codes.FailedPrecondition: true,
+	codes.OutOfRange:         true,
+	codes.Unauthenticated:    true,
+}
+
+// statusType is the recorded response type of a call that failed
+var statusType = string((&spb.Status{}).ProtoReflect().Descriptor().FullName())
+
+// IdempotencyInterceptor makes the mutating calls retried with the same idempotency key
+// run once. The first call's response, or its error, is recorded and returned to every
+// retry of the same request; a different request under the key is refused.
+func IdempotencyInterceptor(api ports.APIPort, logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		key := idempotencyKey(ctx)
+		msg, ok := req.(proto.Message)
+		if key == "" || !ok || !idempotentMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return nil, status.Errorf(codes.InvalidArgument, "Idempotency key is longer than %d characters", maxIdempotencyKeyLength)
+		}
+		fingerprint, err := requestFingerprint(info.FullMethod, msg)
+		if err != nil {
+			requestid.Logger(ctx, logger).Error("Failed to fingerprint request", zap.String("method", info.FullMethod), zap.Error(err))
+			return nil, status.Errorf(codes.Internal, "Failed to process idempotency key")
+		}
+
+		prior, err := api.BeginIdempotentCall(ctx, key, info.FullMethod, fingerprint)
+		switch {
+		case errors.Is(err, domain.ErrIdempotencyKeyReused):
+			monitoring.IdempotentCalls.WithLabelValues(info.FullMethod, "reused").Inc()
+			return nil, status.Errorf(codes.InvalidArgument, "Idempotency key was already used for a different request")
+		case errors.Is(err, domain.ErrIdempotencyKeyInUse):
+			monitoring.IdempotentCalls.WithLabelValues(info.FullMethod, "in_progress").Inc()
+			return nil, status.Errorf(codes.Aborted, "A request with this idempotency key is still in progress")
+		case err != nil:
+			return nil, status.Errorf(codes.Internal, "Failed to process idempotency key")
+		case prior != nil:
+			monitoring.IdempotentCalls.WithLabelValues(info.FullMethod, "replayed").Inc()
+			requestid.Logger(ctx, logger).Info("Replaying idempotent call", zap.String("method", info.FullMethod), zap.String("idempotency_key", key))
+			return replayResponse(prior)
+		}
+		monitoring.IdempotentCalls.WithLabelValues(info.FullMethod, "executed").Inc()
+
+		resp, err := handler(ctx, req)
+
+		// The outcome is recorded even if the client went away, as its retry relies on it
+		ctx = context.WithoutCancel(ctx)
+		responseType, response, ok := recordedResponse(resp, err)
+		if !ok {
+			_ = api.AbandonIdempotentCall(ctx, key, info.FullMethod, fingerprint)
+			return resp, err
+		}
+		_ = api.FinishIdempotentCall(ctx, key, info.FullMethod, fingerprint, responseType, response)
+		return resp, err
+	}
+}
+
+// idempotencyKey returns the idempotency key of the incoming call, if any
+func idempotencyKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(IdempotencyMetadataKey); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// requestFingerprint identifies a request by its method and its deterministic serialization
+func requestFingerprint(method string, req proto.Message) (string, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// recordedResponse serializes the outcome of a call for replay. ok is false when the
+// outcome must not be replayed.
+func recordedResponse(resp interface{}, err error) (responseType string, response []byte, ok bool) {
+	var msg proto.Message
+	if err != nil {
+		st := status.Convert(err)
+		if !replayedCodes[st.Code()] {
+			return "", nil, false
+		}
+		msg = st.Proto()
+	} else if msg, ok = resp.(proto.Message); !ok {
+		return "", nil, false
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return "", nil, false
+	}
+	return string(msg.ProtoReflect().Descriptor().FullName()), data, true
+}
+
+// replayResponse rebuilds the recorded outcome of an earlier call
+func replayResponse(call *domain.IdempotentCall) (interface{}, error) {
+	if call.ResponseType == statusType {
+		var st spb.Status
+		if err := proto.Unmarshal(call.Response, &st); err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to replay idempotent call")
+		}
+		return nil, status.ErrorProto(&st)
+	}
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(call.ResponseType))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to replay idempotent call")
+	}
+	msg := mt.New().Interface()
+	if err := proto.Unmarshal(call.Response, msg); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to replay idempotent call")
+	}
+	return msg, nil
+}
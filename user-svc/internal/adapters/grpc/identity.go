@@ -0,0 +1,64 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *UserServer) LinkExternalIdentity(ctx context.Context, req *pb.LinkExternalIdentityRequest) (*pb.LinkExternalIdentityResponse, error) {
+	login, err := s.api.LinkExternalIdentity(ctx, &domain.ExternalProfile{
+		Provider:      req.GetProvider(),
+		Subject:       req.GetSubject(),
+		Email:         req.GetEmail(),
+		EmailVerified: req.GetEmailVerified(),
+		Name:          req.GetName(),
+	}, req.GetUserId())
+	if err != nil {
+		return nil, s.identityError(err, "linking external identity", zap.String("provider", req.GetProvider()))
+	}
+	return &pb.LinkExternalIdentityResponse{
+		Token:    login.Token,
+		User:     toPBUser(&login.User),
+		Identity: toPBExternalIdentity(login.Identity),
+		Created:  login.Created,
+	}, nil
+}
+
+// identityError maps an error of the external identity workflows onto a gRPC status
+func (s *UserServer) identityError(err error, action string, fields ...zap.Field) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidIdentity):
+		return status.Errorf(codes.InvalidArgument, "Invalid input: %v", err)
+	case errors.Is(err, domain.ErrIdentityLinked):
+		return status.Errorf(codes.AlreadyExists, "External identity is linked to another user")
+	case errors.Is(err, domain.ErrEmailNotVerified):
+		return status.Errorf(codes.FailedPrecondition, "An account with this email exists; log in to it to link the identity")
+	case errors.Is(err, domain.ErrUserLocked):
+		return status.Errorf(codes.PermissionDenied, "Account is locked")
+	case errors.Is(err, domain.ErrUserAlreadyExists):
+		return status.Errorf(codes.AlreadyExists, "User already exists")
+	default:
+		return s.organizationError(err, action, fields...)
+	}
+}
+
+func toPBExternalIdentity(identity *domain.ExternalIdentity) *pb.ExternalIdentity {
+	res := &pb.ExternalIdentity{
+		Id:        identity.ID.String(),
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		CreatedAt: timestamppb.New(identity.CreatedAt),
+	}
+	if identity.LastLoginAt != nil {
+		res.LastLoginAt = timestamppb.New(*identity.LastLoginAt)
+	}
+	return res
+}
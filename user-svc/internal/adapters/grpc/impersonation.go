@@ -0,0 +1,78 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *UserServer) Impersonate(ctx context.Context, req *pb.ImpersonateRequest) (*pb.ImpersonateResponse, error) {
+	if req.GetActorId() == "" || req.GetUserId() == "" || strings.TrimSpace(req.GetReason()) == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: actor_id, user_id and reason are required")
+	}
+	grant, err := s.api.Impersonate(ctx, req.GetActorId(), req.GetUserId(), req.GetReason())
+	if err != nil {
+		return nil, s.impersonationError(err, "starting impersonation", zap.String("actor_id", req.GetActorId()), zap.String("user_id", req.GetUserId()))
+	}
+	return &pb.ImpersonateResponse{
+		Impersonation: toPBImpersonation(&grant.Impersonation),
+		User:          toPBUser(&grant.User),
+	}, nil
+}
+
+func (s *UserServer) GetImpersonation(ctx context.Context, req *pb.GetImpersonationRequest) (*pb.GetImpersonationResponse, error) {
+	imp, err := s.api.GetImpersonation(ctx, req.GetId())
+	if err != nil {
+		return nil, s.impersonationError(err, "getting impersonation", zap.String("impersonation_id", req.GetId()))
+	}
+	return &pb.GetImpersonationResponse{Impersonation: toPBImpersonation(imp)}, nil
+}
+
+func (s *UserServer) EndImpersonation(ctx context.Context, req *pb.EndImpersonationRequest) (*pb.EndImpersonationResponse, error) {
+	if req.GetActorId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: actor_id is required")
+	}
+	imp, err := s.api.EndImpersonation(ctx, req.GetId(), req.GetActorId())
+	if err != nil {
+		return nil, s.impersonationError(err, "ending impersonation", zap.String("impersonation_id", req.GetId()))
+	}
+	return &pb.EndImpersonationResponse{Impersonation: toPBImpersonation(imp)}, nil
+}
+
+// impersonationError maps an error of the impersonation workflows onto a gRPC status
+func (s *UserServer) impersonationError(err error, action string, fields ...zap.Field) error {
+	switch {
+	case errors.Is(err, domain.ErrImpersonationNotFound):
+		return status.Errorf(codes.NotFound, "Impersonation not found")
+	case errors.Is(err, domain.ErrImpersonationEnded):
+		return status.Errorf(codes.Unauthenticated, "Impersonation has ended")
+	case errors.Is(err, domain.ErrCannotImpersonate):
+		return status.Errorf(codes.FailedPrecondition, "This user cannot be impersonated")
+	case errors.Is(err, domain.ErrPermissionDenied):
+		return status.Errorf(codes.PermissionDenied, "Impersonating users needs the %s permission", domain.PermissionImpersonate)
+	default:
+		return s.organizationError(err, action, fields...)
+	}
+}
+
+func toPBImpersonation(imp *domain.Impersonation) *pb.Impersonation {
+	pbImp := &pb.Impersonation{
+		Id:        imp.ID.String(),
+		ActorId:   imp.ActorID.String(),
+		UserId:    imp.UserID.String(),
+		Reason:    imp.Reason,
+		StartedAt: timestamppb.New(imp.StartedAt),
+		ExpiresAt: timestamppb.New(imp.ExpiresAt),
+	}
+	if imp.EndedAt != nil {
+		pbImp.EndedAt = timestamppb.New(*imp.EndedAt)
+	}
+	return pbImp
+}
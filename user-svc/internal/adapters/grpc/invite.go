@@ -0,0 +1,107 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *UserServer) InviteUser(ctx context.Context, req *pb.InviteUserRequest) (*pb.InviteUserResponse, error) {
+	if req.GetActorId() == "" || req.GetEmail() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: actor_id and email are required")
+	}
+	if _, ok := pb.Role_name[int32(req.GetRole())]; !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: unknown role")
+	}
+	invite, err := s.api.InviteUser(ctx, req.GetActorId(), req.GetEmail(), req.GetName(), domain.Role(req.GetRole()))
+	if err != nil {
+		return nil, s.userInviteError(err, "inviting user", zap.String("actor_id", req.GetActorId()))
+	}
+	return &pb.InviteUserResponse{Invite: toPBUserInvite(invite)}, nil
+}
+
+func (s *UserServer) ListUserInvites(ctx context.Context, req *pb.ListUserInvitesRequest) (*pb.ListUserInvitesResponse, error) {
+	if req.GetActorId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: actor_id is required")
+	}
+	invites, err := s.api.ListUserInvites(ctx, req.GetActorId())
+	if err != nil {
+		return nil, s.userInviteError(err, "listing user invites", zap.String("actor_id", req.GetActorId()))
+	}
+	result := make([]*pb.UserInvite, 0, len(invites))
+	for _, invite := range invites {
+		result = append(result, toPBUserInvite(invite))
+	}
+	return &pb.ListUserInvitesResponse{Invites: result}, nil
+}
+
+func (s *UserServer) ResendUserInvite(ctx context.Context, req *pb.ResendUserInviteRequest) (*pb.ResendUserInviteResponse, error) {
+	if req.GetActorId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: actor_id is required")
+	}
+	invite, err := s.api.ResendUserInvite(ctx, req.GetActorId(), req.GetId())
+	if err != nil {
+		return nil, s.userInviteError(err, "resending user invite", zap.String("invite_id", req.GetId()))
+	}
+	return &pb.ResendUserInviteResponse{Invite: toPBUserInvite(invite)}, nil
+}
+
+func (s *UserServer) RevokeUserInvite(ctx context.Context, req *pb.RevokeUserInviteRequest) (*pb.RevokeUserInviteResponse, error) {
+	if req.GetActorId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: actor_id is required")
+	}
+	if err := s.api.RevokeUserInvite(ctx, req.GetActorId(), req.GetId()); err != nil {
+		return nil, s.userInviteError(err, "revoking user invite", zap.String("invite_id", req.GetId()))
+	}
+	return &pb.RevokeUserInviteResponse{Success: true}, nil
+}
+
+func (s *UserServer) AcceptUserInvite(ctx context.Context, req *pb.AcceptUserInviteRequest) (*pb.AcceptUserInviteResponse, error) {
+	if req.GetToken() == "" || req.GetPassword() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: token and password are required")
+	}
+	login, err := s.api.AcceptUserInvite(ctx, req.GetToken(), req.GetPassword(), req.GetName())
+	if err != nil {
+		return nil, s.userInviteError(err, "accepting user invite")
+	}
+	return &pb.AcceptUserInviteResponse{
+		Token: login.Token,
+		User:  toPBUser(&login.User),
+	}, nil
+}
+
+// userInviteError maps an error of the user invite workflows onto a gRPC status
+func (s *UserServer) userInviteError(err error, action string, fields ...zap.Field) error {
+	switch {
+	case errors.Is(err, domain.ErrUserInviteNotFound):
+		return status.Errorf(codes.NotFound, "Invite not found")
+	case errors.Is(err, domain.ErrInvalidUserInvite):
+		return status.Errorf(codes.Unauthenticated, "Invite is invalid, expired or was already used")
+	case errors.Is(err, domain.ErrUserAlreadyExists):
+		return status.Errorf(codes.AlreadyExists, "A user with this email already exists")
+	case errors.Is(err, domain.ErrPermissionDenied):
+		return status.Errorf(codes.PermissionDenied, "Only admins can manage invites")
+	default:
+		return s.organizationError(err, action, fields...)
+	}
+}
+
+func toPBUserInvite(invite *domain.UserInvite) *pb.UserInvite {
+	return &pb.UserInvite{
+		Id:        invite.ID.String(),
+		UserId:    invite.UserID.String(),
+		Email:     invite.Email,
+		Role:      pb.Role(invite.Role),
+		InvitedBy: invite.InvitedBy.String(),
+		SendCount: int32(invite.SendCount),
+		CreatedAt: timestamppb.New(invite.CreatedAt),
+		SentAt:    timestamppb.New(invite.SentAt),
+		ExpiresAt: timestamppb.New(invite.ExpiresAt),
+	}
+}
@@ -0,0 +1,47 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *UserServer) RequestLoginLink(ctx context.Context, req *pb.RequestLoginLinkRequest) (*pb.RequestLoginLinkResponse, error) {
+	if req.GetEmail() == "" || req.GetDeviceSecret() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: email and device_secret are required")
+	}
+	expiresAt, err := s.api.RequestLoginLink(ctx, req.GetEmail(), req.GetDeviceSecret())
+	if err != nil {
+		return nil, s.loginLinkError(err, "requesting login link")
+	}
+	return &pb.RequestLoginLinkResponse{ExpiresAt: timestamppb.New(expiresAt)}, nil
+}
+
+func (s *UserServer) RedeemLoginLink(ctx context.Context, req *pb.RedeemLoginLinkRequest) (*pb.RedeemLoginLinkResponse, error) {
+	login, err := s.api.RedeemLoginLink(ctx, req.GetToken(), req.GetDeviceSecret())
+	if err != nil {
+		return nil, s.loginLinkError(err, "redeeming login link")
+	}
+	return &pb.RedeemLoginLinkResponse{
+		Token: login.Token,
+		User:  toPBUser(&login.User),
+	}, nil
+}
+
+// loginLinkError maps an error of the login link workflows onto a gRPC status
+func (s *UserServer) loginLinkError(err error, action string, fields ...zap.Field) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidLoginLink):
+		return status.Errorf(codes.Unauthenticated, "Login link is invalid, expired or was requested on another device")
+	case errors.Is(err, domain.ErrUserLocked):
+		return status.Errorf(codes.PermissionDenied, "Account is locked")
+	default:
+		return s.organizationError(err, action, fields...)
+	}
+}
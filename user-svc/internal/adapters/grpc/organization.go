@@ -0,0 +1,196 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *UserServer) CreateOrganization(ctx context.Context, req *pb.CreateOrganizationRequest) (*pb.CreateOrganizationResponse, error) {
+	if req.GetActorId() == "" || req.GetName() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: actor_id and name are required")
+	}
+
+	org, err := s.api.CreateOrganization(ctx, req.GetActorId(), req.GetName())
+	if err != nil {
+		return nil, s.organizationError(err, "creating organization", zap.String("actor_id", req.GetActorId()))
+	}
+	return &pb.CreateOrganizationResponse{Organization: toPBOrganization(org)}, nil
+}
+
+func (s *UserServer) GetOrganization(ctx context.Context, req *pb.GetOrganizationRequest) (*pb.GetOrganizationResponse, error) {
+	org, membership, err := s.api.GetOrganization(ctx, req.GetActorId(), req.GetOrganizationId())
+	if err != nil {
+		return nil, s.organizationError(err, "fetching organization", zap.String("organization_id", req.GetOrganizationId()))
+	}
+	return &pb.GetOrganizationResponse{
+		Organization: toPBOrganization(org),
+		Membership:   toPBMember(membership),
+	}, nil
+}
+
+func (s *UserServer) ListMyOrganizations(ctx context.Context, req *pb.ListMyOrganizationsRequest) (*pb.ListMyOrganizationsResponse, error) {
+	memberships, err := s.api.ListMyOrganizations(ctx, req.GetUserId())
+	if err != nil {
+		return nil, s.organizationError(err, "listing organizations", zap.String("user_id", req.GetUserId()))
+	}
+	return &pb.ListMyOrganizationsResponse{Memberships: toPBMembers(memberships)}, nil
+}
+
+func (s *UserServer) ListOrganizationMembers(ctx context.Context, req *pb.ListOrganizationMembersRequest) (*pb.ListOrganizationMembersResponse, error) {
+	members, err := s.api.ListOrganizationMembers(ctx, req.GetActorId(), req.GetOrganizationId())
+	if err != nil {
+		return nil, s.organizationError(err, "listing members", zap.String("organization_id", req.GetOrganizationId()))
+	}
+	return &pb.ListOrganizationMembersResponse{Members: toPBMembers(members)}, nil
+}
+
+func (s *UserServer) UpdateOrganizationMember(ctx context.Context, req *pb.UpdateOrganizationMemberRequest) (*pb.UpdateOrganizationMemberResponse, error) {
+	role := domain.OrgRole(req.GetRole())
+	if !role.Valid() {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: role is required")
+	}
+
+	member, err := s.api.UpdateOrganizationMember(ctx, req.GetActorId(), req.GetOrganizationId(), req.GetUserId(), role)
+	if err != nil {
+		return nil, s.organizationError(err, "updating member", zap.String("organization_id", req.GetOrganizationId()), zap.String("user_id", req.GetUserId()))
+	}
+	return &pb.UpdateOrganizationMemberResponse{Member: toPBMember(member)}, nil
+}
+
+func (s *UserServer) RemoveOrganizationMember(ctx context.Context, req *pb.RemoveOrganizationMemberRequest) (*pb.RemoveOrganizationMemberResponse, error) {
+	err := s.api.RemoveOrganizationMember(ctx, req.GetActorId(), req.GetOrganizationId(), req.GetUserId())
+	if err != nil {
+		return nil, s.organizationError(err, "removing member", zap.String("organization_id", req.GetOrganizationId()), zap.String("user_id", req.GetUserId()))
+	}
+	return &pb.RemoveOrganizationMemberResponse{Success: true}, nil
+}
+
+func (s *UserServer) InviteOrganizationMember(ctx context.Context, req *pb.InviteOrganizationMemberRequest) (*pb.InviteOrganizationMemberResponse, error) {
+	role := domain.OrgRole(req.GetRole())
+	if req.GetEmail() == "" || !role.Valid() {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: email and role are required")
+	}
+
+	inv, err := s.api.InviteOrganizationMember(ctx, req.GetActorId(), req.GetOrganizationId(), req.GetEmail(), role)
+	if err != nil {
+		return nil, s.organizationError(err, "inviting member", zap.String("organization_id", req.GetOrganizationId()))
+	}
+	return &pb.InviteOrganizationMemberResponse{Invitation: toPBInvitation(inv)}, nil
+}
+
+func (s *UserServer) ListOrganizationInvitations(ctx context.Context, req *pb.ListOrganizationInvitationsRequest) (*pb.ListOrganizationInvitationsResponse, error) {
+	invs, err := s.api.ListOrganizationInvitations(ctx, req.GetActorId(), req.GetOrganizationId())
+	if err != nil {
+		return nil, s.organizationError(err, "listing invitations", zap.String("organization_id", req.GetOrganizationId()))
+	}
+	return &pb.ListOrganizationInvitationsResponse{Invitations: toPBInvitations(invs)}, nil
+}
+
+func (s *UserServer) RevokeOrganizationInvitation(ctx context.Context, req *pb.RevokeOrganizationInvitationRequest) (*pb.RevokeOrganizationInvitationResponse, error) {
+	if err := s.api.RevokeOrganizationInvitation(ctx, req.GetActorId(), req.GetInvitationId()); err != nil {
+		return nil, s.organizationError(err, "revoking invitation", zap.String("invitation_id", req.GetInvitationId()))
+	}
+	return &pb.RevokeOrganizationInvitationResponse{Success: true}, nil
+}
+
+func (s *UserServer) ListMyInvitations(ctx context.Context, req *pb.ListMyInvitationsRequest) (*pb.ListMyInvitationsResponse, error) {
+	invs, err := s.api.ListMyInvitations(ctx, req.GetUserId())
+	if err != nil {
+		return nil, s.organizationError(err, "listing invitations", zap.String("user_id", req.GetUserId()))
+	}
+	return &pb.ListMyInvitationsResponse{Invitations: toPBInvitations(invs)}, nil
+}
+
+func (s *UserServer) AcceptOrganizationInvitation(ctx context.Context, req *pb.AcceptOrganizationInvitationRequest) (*pb.AcceptOrganizationInvitationResponse, error) {
+	member, err := s.api.AcceptOrganizationInvitation(ctx, req.GetUserId(), req.GetInvitationId(), req.GetToken())
+	if err != nil {
+		return nil, s.organizationError(err, "accepting invitation", zap.String("invitation_id", req.GetInvitationId()))
+	}
+	return &pb.AcceptOrganizationInvitationResponse{Membership: toPBMember(member)}, nil
+}
+
+// organizationError maps an error of the organization workflows onto a gRPC status
+func (s *UserServer) organizationError(err error, action string, fields ...zap.Field) error {
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		return status.Errorf(codes.NotFound, "User not found")
+	case errors.Is(err, domain.ErrOrganizationNotFound):
+		return status.Errorf(codes.NotFound, "Organization not found")
+	case errors.Is(err, domain.ErrNotMember):
+		return status.Errorf(codes.NotFound, "Member not found")
+	case errors.Is(err, domain.ErrInvitationNotFound):
+		return status.Errorf(codes.NotFound, "Invitation not found")
+	case errors.Is(err, domain.ErrAlreadyMember):
+		return status.Errorf(codes.AlreadyExists, "User is already a member of the organization")
+	case errors.Is(err, domain.ErrPermissionDenied):
+		return status.Errorf(codes.PermissionDenied, "Not allowed to perform this action in the organization")
+	case errors.Is(err, domain.ErrLastOwner):
+		return status.Errorf(codes.FailedPrecondition, "Organization must keep at least one owner")
+	case errors.Is(err, domain.ErrInvitationExpired):
+		return status.Errorf(codes.FailedPrecondition, "Invitation is no longer valid")
+	case errors.Is(err, domain.ErrSecondFactorRequired):
+		return secondFactorRequired()
+	case errors.Is(err, domain.ErrHashingUnavailable):
+		return hashingUnavailable()
+	default:
+		s.logger.Error("Organization request failed", append(fields, zap.String("action", action), zap.Error(err))...)
+		return status.Errorf(codes.Internal, "Internal server error while %s", action)
+	}
+}
+
+func toPBOrganization(org *domain.Organization) *pb.Organization {
+	return &pb.Organization{
+		Id:        org.ID.String(),
+		Name:      org.Name,
+		CreatedBy: org.CreatedBy.String(),
+		CreatedAt: timestamppb.New(org.CreatedAt),
+	}
+}
+
+func toPBMember(m *domain.Membership) *pb.OrganizationMember {
+	member := &pb.OrganizationMember{
+		OrganizationId: m.OrganizationID.String(),
+		UserId:         m.UserID.String(),
+		Role:           pb.OrganizationRole(m.Role),
+		JoinedAt:       timestamppb.New(m.JoinedAt),
+	}
+	if m.Organization != nil {
+		member.Organization = toPBOrganization(m.Organization)
+	}
+	return member
+}
+
+func toPBMembers(memberships []*domain.Membership) []*pb.OrganizationMember {
+	result := make([]*pb.OrganizationMember, 0, len(memberships))
+	for _, m := range memberships {
+		result = append(result, toPBMember(m))
+	}
+	return result
+}
+
+func toPBInvitation(inv *domain.OrgInvitation) *pb.OrganizationInvitation {
+	return &pb.OrganizationInvitation{
+		Id:             inv.ID.String(),
+		OrganizationId: inv.OrganizationID.String(),
+		Email:          inv.Email,
+		Role:           pb.OrganizationRole(inv.Role),
+		InvitedBy:      inv.InvitedBy.String(),
+		CreatedAt:      timestamppb.New(inv.CreatedAt),
+		ExpiresAt:      timestamppb.New(inv.ExpiresAt),
+	}
+}
+
+func toPBInvitations(invs []*domain.OrgInvitation) []*pb.OrganizationInvitation {
+	result := make([]*pb.OrganizationInvitation, 0, len(invs))
+	for _, inv := range invs {
+		result = append(result, toPBInvitation(inv))
+	}
+	return result
+}
@@ -0,0 +1,157 @@
+package grpc
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *UserServer) BeginPasskeyRegistration(ctx context.Context, req *pb.BeginPasskeyRegistrationRequest) (*pb.PasskeyChallengeResponse, error) {
+	if req.GetUserId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: user_id is required")
+	}
+	challenge, err := s.api.BeginPasskeyRegistration(ctx, req.GetUserId())
+	if err != nil {
+		return nil, s.passkeyError(err, "starting passkey registration", zap.String("user_id", req.GetUserId()))
+	}
+	return toPBPasskeyChallenge(challenge), nil
+}
+
+func (s *UserServer) FinishPasskeyRegistration(ctx context.Context, req *pb.FinishPasskeyRegistrationRequest) (*pb.FinishPasskeyRegistrationResponse, error) {
+	if req.GetUserId() == "" || req.GetCeremonyId() == "" || len(req.GetCredential()) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: user_id, ceremony_id and credential are required")
+	}
+	if len(req.GetName()) > 255 {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: name is too long")
+	}
+	passkey, err := s.api.FinishPasskeyRegistration(ctx, req.GetUserId(), req.GetCeremonyId(), req.GetName(), req.GetCredential())
+	if err != nil {
+		return nil, s.passkeyError(err, "finishing passkey registration", zap.String("user_id", req.GetUserId()))
+	}
+	return &pb.FinishPasskeyRegistrationResponse{Passkey: toPBPasskey(passkey)}, nil
+}
+
+func (s *UserServer) BeginPasskeyLogin(ctx context.Context, req *pb.BeginPasskeyLoginRequest) (*pb.PasskeyChallengeResponse, error) {
+	challenge, err := s.api.BeginPasskeyLogin(ctx)
+	if err != nil {
+		return nil, s.passkeyError(err, "starting passkey login")
+	}
+	return toPBPasskeyChallenge(challenge), nil
+}
+
+func (s *UserServer) FinishPasskeyLogin(ctx context.Context, req *pb.FinishPasskeyLoginRequest) (*pb.FinishPasskeyLoginResponse, error) {
+	if req.GetCeremonyId() == "" || len(req.GetCredential()) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: ceremony_id and credential are required")
+	}
+	login, err := s.api.FinishPasskeyLogin(ctx, req.GetCeremonyId(), req.GetCredential())
+	if err != nil {
+		return nil, s.passkeyError(err, "finishing passkey login")
+	}
+	return &pb.FinishPasskeyLoginResponse{
+		Token: login.Token,
+		User:  toPBUser(&login.User),
+	}, nil
+}
+
+func (s *UserServer) ListPasskeys(ctx context.Context, req *pb.ListPasskeysRequest) (*pb.ListPasskeysResponse, error) {
+	passkeys, err := s.api.ListPasskeys(ctx, req.GetUserId())
+	if err != nil {
+		return nil, s.passkeyError(err, "listing passkeys", zap.String("user_id", req.GetUserId()))
+	}
+	result := make([]*pb.Passkey, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		result = append(result, toPBPasskey(passkey))
+	}
+	return &pb.ListPasskeysResponse{Passkeys: result}, nil
+}
+
+func (s *UserServer) DeletePasskey(ctx context.Context, req *pb.DeletePasskeyRequest) (*pb.DeletePasskeyResponse, error) {
+	if err := s.api.DeletePasskey(ctx, req.GetUserId(), req.GetId()); err != nil {
+		return nil, s.passkeyError(err, "deleting passkey", zap.String("passkey_id", req.GetId()))
+	}
+	return &pb.DeletePasskeyResponse{}, nil
+}
+
+func (s *UserServer) SetTwoFactor(ctx context.Context, req *pb.SetTwoFactorRequest) (*pb.SetTwoFactorResponse, error) {
+	if err := s.api.SetTwoFactor(ctx, req.GetUserId(), req.GetEnabled()); err != nil {
+		return nil, s.passkeyError(err, "changing two-factor authentication", zap.String("user_id", req.GetUserId()))
+	}
+	return &pb.SetTwoFactorResponse{Enabled: req.GetEnabled()}, nil
+}
+
+// passkeyError maps an error of the passkey workflows onto a gRPC status
+func (s *UserServer) passkeyError(err error, action string, fields ...zap.Field) error {
+	switch {
+	case errors.Is(err, domain.ErrPasskeysDisabled):
+		return status.Errorf(codes.Unimplemented, "Passkeys are not enabled")
+	case errors.Is(err, domain.ErrPasskeyNotFound):
+		return status.Errorf(codes.NotFound, "Passkey not found")
+	case errors.Is(err, domain.ErrPasskeyExists):
+		return status.Errorf(codes.AlreadyExists, "Passkey is already registered")
+	case errors.Is(err, domain.ErrInvalidCeremony):
+		return status.Errorf(codes.FailedPrecondition, "Passkey challenge is invalid or expired; please start again")
+	case errors.Is(err, domain.ErrInvalidPasskey):
+		return status.Errorf(codes.Unauthenticated, "Passkey could not be verified")
+	case errors.Is(err, domain.ErrPasskeyCloned):
+		return status.Errorf(codes.PermissionDenied, "Passkey has been disabled because it may have been cloned")
+	case errors.Is(err, domain.ErrNoPasskeys):
+		return status.Errorf(codes.FailedPrecondition, "Register a passkey before turning on two-factor authentication")
+	case errors.Is(err, domain.ErrLastPasskey):
+		return status.Errorf(codes.FailedPrecondition, "Turn off two-factor authentication before removing the last passkey")
+	case errors.Is(err, domain.ErrUserLocked):
+		return status.Errorf(codes.PermissionDenied, "Account is locked")
+	default:
+		return s.organizationError(err, action, fields...)
+	}
+}
+
+// reasonSecondFactorRequired is the ErrorInfo reason of a login refused because the
+// user has two-factor authentication on and must log in with a passkey
+const reasonSecondFactorRequired = "SECOND_FACTOR_REQUIRED"
+
+// secondFactorRequired returns the status of a login that needs a passkey. Its ErrorInfo
+// detail lets the gateway tell it apart from other failed preconditions.
+func secondFactorRequired() error {
+	st := status.New(codes.FailedPrecondition, "Second factor required: log in with a passkey")
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   reasonSecondFactorRequired,
+		Domain:   "user-svc",
+		Metadata: map[string]string{"method": "passkey"},
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+func toPBPasskeyChallenge(challenge *domain.PasskeyChallenge) *pb.PasskeyChallengeResponse {
+	return &pb.PasskeyChallengeResponse{
+		CeremonyId: challenge.CeremonyID.String(),
+		Options:    challenge.Options,
+		ExpiresAt:  timestamppb.New(challenge.ExpiresAt),
+	}
+}
+
+func toPBPasskey(passkey *domain.Passkey) *pb.Passkey {
+	pbPasskey := &pb.Passkey{
+		Id:             passkey.ID.String(),
+		Name:           passkey.Name,
+		Aaguid:         hex.EncodeToString(passkey.AAGUID),
+		Transports:     passkey.Transports,
+		BackupEligible: passkey.BackupEligible,
+		CloneWarning:   passkey.CloneWarning,
+		CreatedAt:      timestamppb.New(passkey.CreatedAt),
+	}
+	if passkey.LastUsedAt != nil {
+		pbPasskey.LastUsedAt = timestamppb.New(*passkey.LastUsedAt)
+	}
+	return pbPasskey
+}
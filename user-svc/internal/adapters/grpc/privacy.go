@@ -0,0 +1,79 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *UserServer) ExportMyData(ctx context.Context, req *pb.ExportMyDataRequest) (*pb.ExportMyDataResponse, error) {
+	if req.GetUserId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: user_id is required")
+	}
+
+	export, err := s.api.ExportMyData(ctx, req.GetUserId())
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, status.Errorf(codes.NotFound, "User '%s' not found", req.GetUserId())
+		}
+		s.log(ctx).Error("Failed to export user data", zap.String("user_id", req.GetUserId()), zap.Error(err))
+		return nil, status.Errorf(codes.Internal, "Internal server error while exporting user data")
+	}
+
+	return &pb.ExportMyDataResponse{
+		Filename:    export.Filename,
+		ContentType: export.ContentType,
+		Archive:     export.Archive,
+	}, nil
+}
+
+func (s *UserServer) RequestErasure(ctx context.Context, req *pb.RequestErasureRequest) (*pb.RequestErasureResponse, error) {
+	if req.GetUserId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid input: user_id is required")
+	}
+
+	erasure, err := s.api.RequestErasure(ctx, req.GetUserId(), req.GetReason())
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, status.Errorf(codes.NotFound, "User '%s' not found", req.GetUserId())
+		}
+		s.log(ctx).Error("Failed to request erasure", zap.String("user_id", req.GetUserId()), zap.Error(err))
+		return nil, status.Errorf(codes.Internal, "Internal server error while requesting erasure")
+	}
+
+	return &pb.RequestErasureResponse{Request: toPBErasureRequest(erasure)}, nil
+}
+
+func (s *UserServer) GetErasureRequest(ctx context.Context, req *pb.GetErasureRequestRequest) (*pb.GetErasureRequestResponse, error) {
+	erasure, err := s.api.GetErasureRequest(ctx, req.GetId())
+	if err != nil {
+		if errors.Is(err, domain.ErrErasureNotFound) {
+			return nil, status.Errorf(codes.NotFound, "Erasure request '%s' not found", req.GetId())
+		}
+		s.log(ctx).Error("Failed to get erasure request", zap.String("id", req.GetId()), zap.Error(err))
+		return nil, status.Errorf(codes.Internal, "Internal server error while fetching erasure request")
+	}
+
+	return &pb.GetErasureRequestResponse{Request: toPBErasureRequest(erasure)}, nil
+}
+
+func toPBErasureRequest(req *domain.ErasureRequest) *pb.ErasureRequest {
+	pbReq := &pb.ErasureRequest{
+		Id:             req.ID.String(),
+		UserId:         req.UserID.String(),
+		Status:         pb.ErasureStatus(req.Status),
+		CompletedSteps: req.CompletedSteps,
+		Error:          req.Error,
+		RequestedAt:    timestamppb.New(req.RequestedAt),
+	}
+	if req.CompletedAt != nil {
+		pbReq.CompletedAt = timestamppb.New(*req.CompletedAt)
+	}
+	return pbReq
+}
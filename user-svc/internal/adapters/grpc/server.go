@@ -4,11 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	pb "github.com/asadlive84/shopper-proto/golang/user"
 	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"go.uber.org/zap"
 )
@@ -17,7 +21,7 @@ func (s *UserServer) CreateUser(ctx context.Context, req *pb.CreateUserRequest)
 	// Validate input fields
 	if req.Name == "" || req.Email == "" || req.Password == "" {
 		errMsg := "Invalid input: Name, Email, and Password are required fields"
-		s.logger.Error(errMsg)
+		s.log(ctx).Error(errMsg)
 		return nil, status.Errorf(codes.InvalidArgument, errMsg)
 	}
 
@@ -36,27 +40,26 @@ func (s *UserServer) CreateUser(ctx context.Context, req *pb.CreateUserRequest)
 		switch {
 		case errors.Is(err, domain.ErrUserAlreadyExists):
 			errMsg = fmt.Sprintf("User with email '%s' already exists", req.Email)
-			s.logger.Error(errMsg, zap.Error(err))
+			s.log(ctx).Error(errMsg, zap.Error(err))
 			return nil, status.Errorf(codes.AlreadyExists, errMsg)
 
+		case errors.Is(err, domain.ErrHashingUnavailable):
+			return nil, hashingUnavailable()
+
 		case errors.Is(err, domain.ErrDatabaseError):
 			errMsg = "Internal server error while creating the user"
-			s.logger.Error(errMsg, zap.Error(err))
+			s.log(ctx).Error(errMsg, zap.Error(err))
 			return nil, status.Errorf(codes.AlreadyExists, errMsg)
 
 		default:
 			errMsg = "An unexpected error occurred while creating the user"
-			s.logger.Error(errMsg, zap.Error(err))
+			s.log(ctx).Error(errMsg, zap.Error(err))
 			return nil, status.Errorf(codes.AlreadyExists, errMsg)
 		}
 	}
 
 	return &pb.CreateUserResponse{
-		User: &pb.User{
-			Id:       createdUser.ID.String(),
-			Name:     createdUser.Name,
-			Email:    createdUser.Email,
-		},
+		User: toPBUser(createdUser),
 	}, nil
 }
 
@@ -64,14 +67,14 @@ func (s *UserServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.G
 
 	user, err := s.api.GetUser(ctx, req.GetId())
 	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, status.Errorf(codes.NotFound, "User '%s' not found", req.GetId())
+		}
 		return nil, err
 
 	}
 	return &pb.GetUserResponse{
-		User: &pb.User{
-			Name:  user.Name,
-			Email: user.Email,
-		},
+		User: toPBUser(user),
 	}, nil
 }
 
@@ -82,14 +85,73 @@ func (s *UserServer) AuthenticateUser(ctx context.Context, req *pb.AuthenticateU
 	})
 
 	if err != nil {
+		if errors.Is(err, domain.ErrUserLocked) {
+			return nil, status.Errorf(codes.PermissionDenied, "Account '%s' is locked", req.Email)
+		}
+		if errors.Is(err, domain.ErrSecondFactorRequired) {
+			return nil, secondFactorRequired()
+		}
+		if errors.Is(err, domain.ErrHashingUnavailable) {
+			return nil, hashingUnavailable()
+		}
 		return nil, err
 
 	}
 
 	return &pb.AuthenticateUserResponse{
-		User: &pb.User{
-			Name:  user.User.Name,
-			Email: user.User.Email,
+		Token: user.Token,
+		User:  toPBUser(&user.User),
+	}, nil
+}
+
+func (s *UserServer) GetSession(ctx context.Context, req *pb.GetSessionRequest) (*pb.GetSessionResponse, error) {
+	session, err := s.api.GetSession(ctx, req.GetId())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrSessionNotFound):
+			return nil, status.Errorf(codes.NotFound, "Session not found")
+		case errors.Is(err, domain.ErrSessionEnded):
+			return nil, status.Errorf(codes.Unauthenticated, "Session was revoked or has expired")
+		}
+		s.log(ctx).Error("Failed to get session", zap.String("session_id", req.GetId()), zap.Error(err))
+		return nil, status.Errorf(codes.Internal, "Failed to get session")
+	}
+	return &pb.GetSessionResponse{
+		Session: &pb.Session{
+			Id:        session.ID.String(),
+			UserId:    session.UserID.String(),
+			CreatedAt: timestamppb.New(session.CreatedAt),
+			ExpiresAt: timestamppb.New(session.ExpiresAt),
 		},
 	}, nil
 }
+
+// toPBUser converts a domain user into its protobuf representation, leaving out the password hash
+func toPBUser(user *domain.User) *pb.User {
+	pbUser := &pb.User{
+		Id:          user.ID.String(),
+		Name:        user.Name,
+		Email:       user.Email,
+		Role:        pb.Role(user.Role),
+		IsActive:    user.IsActive,
+		CreatedAt:   timestamppb.New(user.CreatedAt),
+		Permissions: user.Permissions,
+
+		TwoFactorEnabled: user.TwoFactorEnabled,
+	}
+	if user.LastLogin != nil {
+		pbUser.LastLogin = timestamppb.New(*user.LastLogin)
+	}
+	return pbUser
+}
+
+// hashingUnavailable is the status of calls turned away because too many passwords are
+// waiting to be hashed; the client should back off briefly and retry
+func hashingUnavailable() error {
+	st := status.New(codes.Unavailable, "Too many requests being processed; try again shortly")
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Second)})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
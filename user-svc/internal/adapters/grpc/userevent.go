@@ -0,0 +1,91 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	pb "github.com/asadlive84/shopper-proto/golang/user"
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var userEventTypes = map[pb.UserEventType]domain.UserEventType{
+	pb.UserEventType_USER_EVENT_TYPE_CREATED:        domain.UserEventCreated,
+	pb.UserEventType_USER_EVENT_TYPE_UPDATED:        domain.UserEventUpdated,
+	pb.UserEventType_USER_EVENT_TYPE_DELETED:        domain.UserEventDeleted,
+	pb.UserEventType_USER_EVENT_TYPE_STATUS_CHANGED: domain.UserEventStatusChanged,
+}
+
+func (s *UserServer) WatchUsers(req *pb.WatchUsersRequest, stream pb.UserService_WatchUsersServer) error {
+	var filter domain.UserEventFilter
+	for _, id := range req.GetUserIds() {
+		userID, err := uuid.Parse(id)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "Invalid user ID '%s'", id)
+		}
+		filter.UserIDs = append(filter.UserIDs, userID)
+	}
+	for _, t := range req.GetTypes() {
+		eventType, ok := userEventTypes[t]
+		if !ok {
+			return status.Errorf(codes.InvalidArgument, "Invalid event type %s", t)
+		}
+		filter.Types = append(filter.Types, eventType)
+	}
+	resume := req.GetResumeToken() != ""
+	if resume {
+		after, err := strconv.ParseInt(req.GetResumeToken(), 10, 64)
+		if err != nil || after < 0 {
+			return status.Errorf(codes.InvalidArgument, "Invalid resume token")
+		}
+		filter.After = after
+	}
+
+	err := s.api.WatchUsers(stream.Context(), filter, resume, func(event *domain.UserEvent) error {
+		return stream.Send(toPBUserEvent(event))
+	})
+	if err != nil {
+		return s.watchUsersError(err)
+	}
+	return nil
+}
+
+// watchUsersError maps an error ending a WatchUsers stream onto a gRPC status
+func (s *UserServer) watchUsersError(err error) error {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, domain.ErrWatchClosed):
+		return status.Errorf(codes.Unavailable, "Server is shutting down; resume the watch")
+	case errors.Is(err, domain.ErrResumeTokenExpired):
+		return status.Errorf(codes.OutOfRange, "Resume token expired; start a new watch and resync with GetUser")
+	case errors.Is(err, domain.ErrDatabaseError):
+		s.logger.Error("Watching users failed", zap.Error(err))
+		return status.Errorf(codes.Unavailable, "User events are unavailable; resume the watch")
+	default:
+		if _, ok := status.FromError(err); ok {
+			return err // Sending on the stream failed
+		}
+		s.logger.Error("Watching users failed", zap.Error(err))
+		return status.Errorf(codes.Internal, "Internal server error while watching users")
+	}
+}
+
+func toPBUserEvent(event *domain.UserEvent) *pb.UserEvent {
+	res := &pb.UserEvent{
+		UserId:      event.UserID.String(),
+		OccurredAt:  timestamppb.New(event.OccurredAt),
+		ResumeToken: strconv.FormatInt(event.Sequence, 10),
+	}
+	for t, eventType := range userEventTypes {
+		if eventType == event.Type {
+			res.Type = t
+		}
+	}
+	return res
+}
@@ -0,0 +1,133 @@
+package hashing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/monitoring"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Pool runs bcrypt on a fixed number of workers fed by a bounded queue. A call finding
+// the queue full fails right away with ErrHashingUnavailable instead of piling up, so a
+// burst of logins cannot take every core away from the rest of the service.
+type Pool struct {
+	jobs chan *job
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// job is a hashing operation waiting in the queue
+type job struct {
+	ctx       context.Context
+	operation string
+	run       func()
+	queued    time.Time
+	done      chan struct{}
+}
+
+// Adapter starts a pool of workers hashing passwords, with room for queueSize waiting calls
+func Adapter(workers, queueSize int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	p := &Pool{
+		jobs: make(chan *job, queueSize),
+		stop: make(chan struct{}),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Hash returns the bcrypt hash of password
+func (p *Pool) Hash(ctx context.Context, password string) (string, error) {
+	var hash []byte
+	var err error
+	if qerr := p.do(ctx, "hash", func() {
+		hash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	}); qerr != nil {
+		return "", qerr
+	}
+	return string(hash), err
+}
+
+// Compare returns nil when password matches hash, and bcrypt.ErrMismatchedHashAndPassword
+// when it does not
+func (p *Pool) Compare(ctx context.Context, hash, password string) error {
+	var err error
+	if qerr := p.do(ctx, "compare", func() {
+		err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	}); qerr != nil {
+		return qerr
+	}
+	return err
+}
+
+// Close stops the workers once they finished their current job; calls made afterwards,
+// and those still queued, fail with ErrHashingUnavailable
+func (p *Pool) Close() {
+	p.once.Do(func() { close(p.stop) })
+	p.wg.Wait()
+}
+
+// do queues run and waits for a worker to finish it. When ctx ends first do returns its
+// error, and a worker that has not started on run yet skips it.
+func (p *Pool) do(ctx context.Context, operation string, run func()) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	j := &job{ctx: ctx, operation: operation, run: run, queued: time.Now(), done: make(chan struct{})}
+	select {
+	case <-p.stop:
+		return domain.ErrHashingUnavailable
+	default:
+	}
+	select {
+	case p.jobs <- j:
+		monitoring.PasswordHashQueueDepth.Set(float64(len(p.jobs)))
+	default:
+		monitoring.PasswordHashRejected.WithLabelValues(operation, "queue_full").Inc()
+		return domain.ErrHashingUnavailable
+	}
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.stop:
+		return domain.ErrHashingUnavailable
+	}
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case j := <-p.jobs:
+			monitoring.PasswordHashQueueDepth.Set(float64(len(p.jobs)))
+			monitoring.PasswordHashWait.WithLabelValues(j.operation).Observe(time.Since(j.queued).Seconds())
+			if j.ctx.Err() != nil {
+				// The caller gave up while the job was queued
+				monitoring.PasswordHashRejected.WithLabelValues(j.operation, "cancelled").Inc()
+				continue
+			}
+			start := time.Now()
+			monitoring.PasswordHashBusyWorkers.Inc()
+			j.run()
+			monitoring.PasswordHashBusyWorkers.Dec()
+			monitoring.PasswordHashDuration.WithLabelValues(j.operation).Observe(time.Since(start).Seconds())
+			close(j.done)
+		}
+	}
+}
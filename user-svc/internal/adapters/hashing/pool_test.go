@@ -0,0 +1,140 @@
+package hashing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// occupy hands the only worker of p a job that runs until the returned func is called
+func occupy(t *testing.T, p *Pool) (release func()) {
+	t.Helper()
+	started, unblock := make(chan struct{}), make(chan struct{})
+	go p.do(context.Background(), "hash", func() {
+		close(started)
+		<-unblock
+	})
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the worker never started the blocking job")
+	}
+	return func() { close(unblock) }
+}
+
+// waitQueued waits until n jobs are waiting in the queue of p
+func waitQueued(t *testing.T, p *Pool, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(p.jobs) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d jobs queued, want %d", len(p.jobs), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPoolRejects(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		setup func(t *testing.T, p *Pool)
+		want  error
+	}{
+		{
+			name: "queue full",
+			ctx:  context.Background(),
+			setup: func(t *testing.T, p *Pool) {
+				t.Cleanup(occupy(t, p))
+				go p.do(context.Background(), "hash", func() {})
+				waitQueued(t, p, 1)
+			},
+			want: domain.ErrHashingUnavailable,
+		},
+		{
+			name:  "closed",
+			ctx:   context.Background(),
+			setup: func(t *testing.T, p *Pool) { p.Close() },
+			want:  domain.ErrHashingUnavailable,
+		},
+		{
+			name:  "context already cancelled",
+			ctx:   cancelled,
+			setup: func(t *testing.T, p *Pool) {},
+			want:  context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Adapter(1, 1)
+			t.Cleanup(p.Close)
+			tt.setup(t, p)
+
+			ran := false
+			if err := p.do(tt.ctx, "hash", func() { ran = true }); !errors.Is(err, tt.want) {
+				t.Errorf("do = %v, want %v", err, tt.want)
+			}
+			if ran {
+				t.Error("a rejected job was run")
+			}
+		})
+	}
+}
+
+func TestPoolSkipsCancelledJobs(t *testing.T) {
+	p := Adapter(1, 2)
+	defer p.Close()
+	release := occupy(t, p)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan struct{}, 1)
+	result := make(chan error, 1)
+	go func() {
+		result <- p.do(ctx, "hash", func() { ran <- struct{}{} })
+	}()
+	waitQueued(t, p, 1)
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Fatalf("do of the cancelled job = %v, want %v", err, context.Canceled)
+	}
+
+	// Jobs are taken in order, so once the next one has run the cancelled one was skipped
+	release()
+	if err := p.do(context.Background(), "hash", func() {}); err != nil {
+		t.Fatalf("do after the cancelled job: %v", err)
+	}
+	select {
+	case <-ran:
+		t.Error("the worker ran a job whose caller had given up")
+	default:
+	}
+}
+
+func TestPoolHashAndCompare(t *testing.T) {
+	p := Adapter(2, 4)
+	defer p.Close()
+	ctx := context.Background()
+
+	hash, err := p.Hash(ctx, "correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	tests := []struct {
+		password string
+		want     error
+	}{
+		{password: "correct horse", want: nil},
+		{password: "battery staple", want: bcrypt.ErrMismatchedHashAndPassword},
+	}
+	for _, tt := range tests {
+		if err := p.Compare(ctx, hash, tt.password); !errors.Is(err, tt.want) {
+			t.Errorf("Compare(%q) = %v, want %v", tt.password, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingKey is an RSA key identified by the RFC 7638 thumbprint of its public half
+type signingKey struct {
+	id      string
+	private *rsa.PrivateKey
+}
+
+// KeySet holds the keys tokens are signed with. The first key signs new tokens; the
+// others are retired keys that stay published so tokens they signed still verify.
+type KeySet struct {
+	keys []signingKey
+}
+
+// LoadKeySet reads PEM-encoded RSA private keys from files, the signing key first.
+// Without files a throwaway key is generated, so tokens do not survive a restart.
+func LoadKeySet(files []string) (*KeySet, error) {
+	if len(files) == 0 {
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("generate signing key: %w", err)
+		}
+		return newKeySet(private), nil
+	}
+
+	privates := make([]*rsa.PrivateKey, 0, len(files))
+	for _, file := range files {
+		private, err := readPrivateKey(file)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", file, err)
+		}
+		privates = append(privates, private)
+	}
+	return newKeySet(privates...), nil
+}
+
+func newKeySet(privates ...*rsa.PrivateKey) *KeySet {
+	set := &KeySet{}
+	for _, private := range privates {
+		set.keys = append(set.keys, signingKey{id: thumbprint(&private.PublicKey), private: private})
+	}
+	return set
+}
+
+// Sign signs claims with the current signing key
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key := k.keys[0]
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.private)
+}
+
+// Verify parses a token signed by any key of the set and returns its claims
+func (k *KeySet) Verify(tokenString string, options ...jwt.ParserOption) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	options = append(options, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}))
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		for _, key := range k.keys {
+			if key.id == kid {
+				return &key.private.PublicKey, nil
+			}
+		}
+		return nil, errors.New("unknown signing key")
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// JSONWebKey is the public half of a signing key as published at the JWKS endpoint
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// JSONWebKeySet is the document served at the JWKS endpoint
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public keys of the set
+func (k *KeySet) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(k.keys))}
+	for _, key := range k.keys {
+		n, e := publicComponents(&key.private.PublicKey)
+		set.Keys = append(set.Keys, JSONWebKey{
+			KeyType:   "RSA",
+			Use:       "sig",
+			Algorithm: jwt.SigningMethodRS256.Alg(),
+			KeyID:     key.id,
+			Modulus:   n,
+			Exponent:  e,
+		})
+	}
+	return set
+}
+
+func readPrivateKey(file string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA key")
+	}
+	return private, nil
+}
+
+// publicComponents returns the base64url-encoded modulus and exponent of key
+func publicComponents(key *rsa.PublicKey) (n, e string) {
+	return base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint of key, used as its key ID
+func thumbprint(key *rsa.PublicKey) string {
+	n, e := publicComponents(key)
+	sum := sha256.Sum256([]byte(`{"e":"` + e + `","kty":"RSA","n":"` + n + `"}`))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,496 @@
+// Package oidc serves user-svc as an OpenID Connect provider over HTTP: discovery,
+// JWKS, the authorization-code flow with PKCE, the client credentials grant of service
+// clients, userinfo and dynamic client registration.
+package oidc
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/ports"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// Config holds the provider settings the HTTP endpoints need
+type Config struct {
+	Issuer            string
+	AccessTokenTTL    time.Duration
+	IDTokenTTL        time.Duration
+	RegistrationToken string // Empty disables dynamic client registration
+}
+
+// tenantClaim names the tenant of the user in access tokens. It is deliberately not the
+// gateway's tenant_id claim.
+const tenantClaim = "tid"
+
+type Server struct {
+	api    ports.APIPort
+	keys   *KeySet
+	cfg    Config
+	logger *zap.Logger
+}
+
+func NewServer(api ports.APIPort, keys *KeySet, cfg Config, logger *zap.Logger) *Server {
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	return &Server{api: api, keys: keys, cfg: cfg, logger: logger}
+}
+
+// Handler returns the HTTP handler serving every provider endpoint
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /jwks.json", s.jwks)
+	mux.HandleFunc("GET /authorize", s.authorizeForm)
+	mux.HandleFunc("POST /authorize", s.authorize)
+	mux.HandleFunc("POST /token", s.token)
+	mux.HandleFunc("GET /userinfo", s.userinfo)
+	mux.HandleFunc("POST /userinfo", s.userinfo)
+	mux.HandleFunc("POST /register", s.register)
+	return mux
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.cfg.Issuer,
+		"authorization_endpoint":                s.cfg.Issuer + "/authorize",
+		"token_endpoint":                        s.cfg.Issuer + "/token",
+		"userinfo_endpoint":                     s.cfg.Issuer + "/userinfo",
+		"jwks_uri":                              s.cfg.Issuer + "/jwks.json",
+		"registration_endpoint":                 s.cfg.Issuer + "/register",
+		"scopes_supported":                      domain.OIDCScopes,
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "client_credentials"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{jwt.SigningMethodRS256.Alg()},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported":                      []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "email", "name"},
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, s.keys.JWKS())
+}
+
+// authorizeForm validates an authorization request and shows the sign-in form
+func (s *Server) authorizeForm(w http.ResponseWriter, r *http.Request) {
+	req := authorizationRequest(r.URL.Query())
+	if !s.checkAuthorizationRequest(w, r, req) {
+		return
+	}
+	s.renderLogin(w, http.StatusOK, req, "")
+}
+
+// authorize signs the user in with the submitted form and redirects back to the client with a code
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Malformed form", http.StatusBadRequest)
+		return
+	}
+	req := authorizationRequest(r.PostForm)
+	if !s.checkAuthorizationRequest(w, r, req) {
+		return
+	}
+
+	code, err := s.api.Authorize(r.Context(), req, r.PostForm.Get("email"), r.PostForm.Get("password"))
+	switch {
+	case errors.Is(err, domain.ErrInvalidCredentials):
+		s.renderLogin(w, http.StatusUnauthorized, req, "Invalid email or password")
+		return
+	case errors.Is(err, domain.ErrUserLocked):
+		s.renderLogin(w, http.StatusForbidden, req, "This account is locked")
+		return
+	case errors.Is(err, domain.ErrSecondFactorRequired):
+		s.renderLogin(w, http.StatusUnauthorized, req, "This account requires a passkey, which this page does not support yet")
+		return
+	case errors.Is(err, domain.ErrHashingUnavailable):
+		w.Header().Set("Retry-After", "1")
+		s.renderLogin(w, http.StatusServiceUnavailable, req, "Too many logins right now; please try again in a moment")
+		return
+	case err != nil:
+		s.logger.Error("Authorization failed", zap.String("client_id", req.ClientID), zap.Error(err))
+		redirectError(w, r, req, "server_error", "")
+		return
+	}
+	redirect(w, r, req.RedirectURI, url.Values{"code": {code}, "state": {req.State}})
+}
+
+// checkAuthorizationRequest answers an invalid request itself and reports whether to go on.
+// Requests with an unknown client or redirect URI are never redirected back.
+func (s *Server) checkAuthorizationRequest(w http.ResponseWriter, r *http.Request, req *domain.AuthorizationRequest) bool {
+	_, err := s.api.ValidateAuthorizationRequest(r.Context(), req)
+	switch {
+	case errors.Is(err, domain.ErrClientNotFound), errors.Is(err, domain.ErrInvalidRedirectURI):
+		http.Error(w, "Unknown client or redirect URI", http.StatusBadRequest)
+		return false
+	case errors.Is(err, domain.ErrInvalidAuthorization):
+		redirectError(w, r, req, "invalid_request", err.Error())
+		return false
+	case err != nil:
+		s.logger.Error("Failed to validate authorization request", zap.String("client_id", req.ClientID), zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if r.FormValue("response_type") != "code" {
+		redirectError(w, r, req, "unsupported_response_type", "only the code response type is supported")
+		return false
+	}
+	return true
+}
+
+// token redeems an authorization code for an access token and an ID token, or issues a
+// service client its access token for the gRPC streams
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_request", "malformed form")
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		s.exchangeCode(w, r, clientID, clientSecret)
+	case "client_credentials":
+		s.clientCredentials(w, r, clientID, clientSecret)
+	default:
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type", "only authorization_code and client_credentials are supported")
+	}
+}
+
+// exchangeCode redeems an authorization code for an access token and an ID token
+func (s *Server) exchangeCode(w http.ResponseWriter, r *http.Request, clientID, clientSecret string) {
+	grant, err := s.api.ExchangeAuthorizationCode(r.Context(), clientID, clientSecret,
+		r.PostForm.Get("code"), r.PostForm.Get("redirect_uri"), r.PostForm.Get("code_verifier"))
+	switch {
+	case errors.Is(err, domain.ErrInvalidClient):
+		w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+		tokenError(w, http.StatusUnauthorized, "invalid_client", "")
+		return
+	case errors.Is(err, domain.ErrInvalidGrant):
+		tokenError(w, http.StatusBadRequest, "invalid_grant", "")
+		return
+	case err != nil:
+		s.logger.Error("Token exchange failed", zap.String("client_id", clientID), zap.Error(err))
+		tokenError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	accessToken, idToken, err := s.issueTokens(grant)
+	if err != nil {
+		s.logger.Error("Failed to sign tokens", zap.String("client_id", clientID), zap.Error(err))
+		tokenError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"id_token":     idToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(s.cfg.AccessTokenTTL.Seconds()),
+		"scope":        grant.Code.Scope,
+	})
+}
+
+// clientCredentials issues a service client an access token for the gRPC streams. The
+// token names the client rather than a user, and its audience is ServiceAudience, which
+// no user is ever issued a token for.
+func (s *Server) clientCredentials(w http.ResponseWriter, r *http.Request, clientID, clientSecret string) {
+	client, err := s.api.AuthenticateServiceClient(r.Context(), clientID, clientSecret)
+	switch {
+	case errors.Is(err, domain.ErrInvalidClient):
+		w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+		tokenError(w, http.StatusUnauthorized, "invalid_client", "")
+		return
+	case errors.Is(err, domain.ErrUnauthorizedClient):
+		tokenError(w, http.StatusBadRequest, "unauthorized_client", "only service clients can use client_credentials")
+		return
+	case err != nil:
+		s.logger.Error("Client authentication failed", zap.String("client_id", clientID), zap.Error(err))
+		tokenError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	now := time.Now()
+	accessToken, err := s.keys.Sign(jwt.MapClaims{
+		"iss":       s.cfg.Issuer,
+		"sub":       client.ID,
+		"aud":       ServiceAudience(s.cfg.Issuer),
+		"iat":       now.Unix(),
+		"exp":       now.Add(s.cfg.AccessTokenTTL).Unix(),
+		"client_id": client.ID,
+		tenantClaim: client.TenantID,
+	})
+	if err != nil {
+		s.logger.Error("Failed to sign tokens", zap.String("client_id", clientID), zap.Error(err))
+		tokenError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(s.cfg.AccessTokenTTL.Seconds()),
+	})
+}
+
+// issueTokens signs the access token and ID token of a grant. The access token is only
+// good for userinfo: its audience is the userinfo endpoint rather than the gateway, and it
+// carries the granted scope instead of the role and tenant claims of gateway tokens, so
+// a client can never use it to act as the user elsewhere in the shop.
+func (s *Server) issueTokens(grant *domain.OIDCGrant) (string, string, error) {
+	now := time.Now()
+	user, code := grant.User, grant.Code
+	accessToken, err := s.keys.Sign(jwt.MapClaims{
+		"iss":       s.cfg.Issuer,
+		"sub":       user.ID.String(),
+		"aud":       s.userinfoAudience(),
+		"iat":       now.Unix(),
+		"exp":       now.Add(s.cfg.AccessTokenTTL).Unix(),
+		"scope":     code.Scope,
+		"client_id": code.ClientID,
+		tenantClaim: code.TenantID,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	idClaims := jwt.MapClaims{
+		"iss":       s.cfg.Issuer,
+		"sub":       user.ID.String(),
+		"aud":       code.ClientID,
+		"iat":       now.Unix(),
+		"exp":       now.Add(s.cfg.IDTokenTTL).Unix(),
+		"auth_time": code.AuthTime.Unix(),
+	}
+	if code.Nonce != "" {
+		idClaims["nonce"] = code.Nonce
+	}
+	for k, v := range profileClaims(user, code.Scope) {
+		idClaims[k] = v
+	}
+	idToken, err := s.keys.Sign(idClaims)
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, idToken, nil
+}
+
+// userinfo returns the claims of the user an access token was issued to
+func (s *Server) userinfo(w http.ResponseWriter, r *http.Request) {
+	bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	// ID tokens, whose audience is the client, are not accepted in place of access tokens
+	claims, err := s.keys.Verify(bearer, jwt.WithIssuer(s.cfg.Issuer), jwt.WithAudience(s.userinfoAudience()), jwt.WithExpirationRequired())
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	sub, _ := claims["sub"].(string)
+	tenantID, _ := claims[tenantClaim].(string)
+	scope, _ := claims["scope"].(string)
+	user, err := s.api.GetUser(tenant.NewContext(r.Context(), tenantID), sub)
+	if err != nil || !user.IsActive {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	info := profileClaims(user, scope)
+	info["sub"] = sub
+	writeJSON(w, http.StatusOK, info)
+}
+
+// ServiceAudience is the audience of the access tokens of service clients: the gRPC API
+// of the provider with the given issuer
+func ServiceAudience(issuer string) string {
+	return strings.TrimSuffix(issuer, "/") + "/grpc"
+}
+
+// userinfoAudience is the audience of access tokens: the userinfo endpoint, the only
+// resource they grant access to
+func (s *Server) userinfoAudience() string {
+	return s.cfg.Issuer + "/userinfo"
+}
+
+// profileClaims returns the user claims released for the granted scope
+func profileClaims(user *domain.User, scope string) map[string]interface{} {
+	scopes := strings.Fields(scope)
+	claims := map[string]interface{}{}
+	if slices.Contains(scopes, domain.ScopeEmail) {
+		claims["email"] = user.Email
+	}
+	if slices.Contains(scopes, domain.ScopeProfile) {
+		claims["name"] = user.Name
+	}
+	return claims
+}
+
+// register implements dynamic client registration (RFC 7591), protected by the
+// registration token. Clients are registered in the tenant named by X-Tenant-ID.
+func (s *Server) register(w http.ResponseWriter, r *http.Request) {
+	bearer, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if s.cfg.RegistrationToken == "" ||
+		subtle.ConstantTimeCompare([]byte(bearer), []byte(s.cfg.RegistrationToken)) != 1 {
+		tokenError(w, http.StatusUnauthorized, "invalid_token", "a valid registration token is required")
+		return
+	}
+
+	var body struct {
+		ClientName              string   `json:"client_name"`
+		RedirectURIs            []string `json:"redirect_uris"`
+		TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+		GrantTypes              []string `json:"grant_types"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_client_metadata", "malformed JSON")
+		return
+	}
+	public := body.TokenEndpointAuthMethod == "none"
+	// A client registers for client_credentials alone; it cannot also sign users in
+	service := slices.Contains(body.GrantTypes, "client_credentials")
+
+	ctx := r.Context()
+	if id := r.Header.Get(tenant.MetadataKey); id != "" {
+		if !tenant.Valid(id) {
+			tokenError(w, http.StatusBadRequest, "invalid_client_metadata", "invalid tenant")
+			return
+		}
+		ctx = tenant.NewContext(ctx, id)
+	}
+	client, secret, err := s.api.RegisterOIDCClient(ctx, body.ClientName, body.RedirectURIs, public, service)
+	switch {
+	case errors.Is(err, domain.ErrInvalidRedirectURI):
+		tokenError(w, http.StatusBadRequest, "invalid_redirect_uri", err.Error())
+		return
+	case errors.Is(err, domain.ErrInvalidClientMetadata):
+		tokenError(w, http.StatusBadRequest, "invalid_client_metadata", err.Error())
+		return
+	case err != nil:
+		s.logger.Error("Client registration failed", zap.Error(err))
+		tokenError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	res := map[string]interface{}{
+		"client_id":                  client.ID,
+		"client_name":                client.Name,
+		"redirect_uris":              client.RedirectURIs,
+		"client_id_issued_at":        client.CreatedAt.Unix(),
+		"grant_types":                []string{"authorization_code"},
+		"response_types":             []string{"code"},
+		"token_endpoint_auth_method": "client_secret_basic",
+	}
+	if service {
+		res["grant_types"] = []string{"client_credentials"}
+		res["response_types"] = []string{}
+	}
+	if public {
+		res["token_endpoint_auth_method"] = "none"
+	} else {
+		res["client_secret"] = secret
+		res["client_secret_expires_at"] = 0
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusCreated, res)
+}
+
+func authorizationRequest(values url.Values) *domain.AuthorizationRequest {
+	return &domain.AuthorizationRequest{
+		ClientID:            values.Get("client_id"),
+		RedirectURI:         values.Get("redirect_uri"),
+		Scope:               values.Get("scope"),
+		State:               values.Get("state"),
+		Nonce:               values.Get("nonce"),
+		CodeChallenge:       values.Get("code_challenge"),
+		CodeChallengeMethod: values.Get("code_challenge_method"),
+	}
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign in</title></head>
+<body>
+<h1>Sign in</h1>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+<form method="post" action="authorize">
+<input type="hidden" name="response_type" value="code">
+<input type="hidden" name="client_id" value="{{.Request.ClientID}}">
+<input type="hidden" name="redirect_uri" value="{{.Request.RedirectURI}}">
+<input type="hidden" name="scope" value="{{.Request.Scope}}">
+<input type="hidden" name="state" value="{{.Request.State}}">
+<input type="hidden" name="nonce" value="{{.Request.Nonce}}">
+<input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+<label>Email <input type="email" name="email" required autofocus></label>
+<label>Password <input type="password" name="password" required></label>
+<button type="submit">Sign in</button>
+</form>
+</body>
+</html>
+`))
+
+func (s *Server) renderLogin(w http.ResponseWriter, status int, req *domain.AuthorizationRequest, message string) {
+	// The sign-in form must not be framed by another site
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := loginPage.Execute(w, map[string]interface{}{"Request": req, "Error": message}); err != nil {
+		s.logger.Error("Failed to render login page", zap.Error(err))
+	}
+}
+
+// redirect sends the browser back to the client's redirect URI with params added to its query
+func redirect(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	target, _ := url.Parse(redirectURI)
+	query := target.Query()
+	for k, v := range params {
+		if len(v) > 0 && v[0] != "" {
+			query.Set(k, v[0])
+		}
+	}
+	target.RawQuery = query.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func redirectError(w http.ResponseWriter, r *http.Request, req *domain.AuthorizationRequest, code, description string) {
+	redirect(w, r, req.RedirectURI, url.Values{
+		"error":             {code},
+		"error_description": {description},
+		"state":             {req.State},
+	})
+}
+
+// tokenError writes an OAuth 2.0 error response
+func tokenError(w http.ResponseWriter, status int, code, description string) {
+	body := map[string]string{"error": code}
+	if description != "" {
+		body["error_description"] = description
+	}
+	writeJSON(w, status, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -2,10 +2,19 @@ package rabbitmq
 
 import (
 	"context"
+	"errors"
+	"runtime/debug"
+	"strings"
+
+	"github.com/asadlive84/shopper/user-svc/internal/monitoring"
+	"github.com/asadlive84/shopper/user-svc/internal/requestid"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
@@ -38,7 +47,8 @@ func Adapter(url string, logger *zap.Logger) (*Client, error) {
 	}, nil
 }
 
-func (c *Client) Setup(exchange, queue string) error {
+// Setup declares the exchange and queue and binds the queue once per tenant routing key
+func (c *Client) Setup(exchange, queue string, tenants []string) error {
 	err := c.Channel.ExchangeDeclare(exchange, "direct", true, false, false, false, nil)
 	if err != nil {
 		c.Logger.Error("Failed to declare exchange", zap.String("exchange", exchange), zap.Error(err))
@@ -49,37 +59,58 @@ func (c *Client) Setup(exchange, queue string) error {
 		c.Logger.Error("Failed to declare queue", zap.String("queue", queue), zap.Error(err))
 		return err
 	}
-	err = c.Channel.QueueBind(q.Name, "", exchange, false, nil)
-	if err != nil {
-		c.Logger.Error("Failed to bind queue", zap.String("queue", q.Name), zap.String("exchange", exchange), zap.Error(err))
-		return err
+	for _, id := range tenants {
+		err = c.Channel.QueueBind(q.Name, id, exchange, false, nil)
+		if err != nil {
+			c.Logger.Error("Failed to bind queue", zap.String("queue", q.Name), zap.String("exchange", exchange), zap.String("tenant_id", id), zap.Error(err))
+			return err
+		}
 	}
-	c.Logger.Info("RabbitMQ setup completed", zap.String("exchange", exchange), zap.String("queue", queue))
+	c.Logger.Info("RabbitMQ setup completed", zap.String("exchange", exchange), zap.String("queue", queue), zap.Strings("tenants", tenants))
 	return nil
 }
 
 func (c *Client) Publish(ctx context.Context, exchange, message string) error {
-	ctx, span := c.Tracer.Start(ctx, "rabbitmq.publish",
+	return c.publish(ctx, exchange, message, message)
+}
+
+// PublishSecret publishes a message carrying a secret; logs and traces only show the
+// event name, the part of the message before the first colon
+func (c *Client) PublishSecret(ctx context.Context, exchange, message string) error {
+	event, _, _ := strings.Cut(message, ":")
+	return c.publish(ctx, exchange, message, event+":[redacted]")
+}
+
+// publish sends message to exchange, recording logged in its place in logs and traces
+func (c *Client) publish(ctx context.Context, exchange, message, logged string) error {
+	routingKey := tenant.RoutingKey(ctx)
+	ctx, span := c.Tracer.Start(ctx, exchange+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
 		trace.WithAttributes(
-			attribute.String("exchange", exchange),
-			attribute.String("message", message),
+			semconv.MessagingSystem("rabbitmq"),
+			semconv.MessagingOperationPublish,
+			semconv.MessagingDestinationName(exchange),
+			semconv.MessagingRabbitmqDestinationRoutingKey(routingKey),
+			semconv.MessagingMessagePayloadSizeBytes(len(message)),
+			attribute.String("message", logged),
 		))
 	defer span.End()
 
 	err := c.Channel.PublishWithContext(ctx,
-		exchange, "", false, false,
+		exchange, routingKey, false, false,
 		amqp.Publishing{
 			ContentType: "text/plain",
+			Headers:     messageHeaders(ctx),
 			Body:        []byte(message),
 		},
 	)
 	if err != nil {
-		c.Logger.Error("Failed to publish message", zap.String("exchange", exchange), zap.String("message", message), zap.Error(err))
+		requestid.Logger(ctx, c.Logger).Error("Failed to publish message", zap.String("exchange", exchange), zap.String("message", logged), zap.Error(err))
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return err
 	}
-	c.Logger.Info("Message published", zap.String("exchange", exchange), zap.String("message", message))
+	requestid.Logger(ctx, c.Logger).Info("Message published", zap.String("exchange", exchange), zap.String("message", logged))
 	return nil
 }
 
@@ -91,13 +122,25 @@ func (c *Client) Consume(queue string, consumerFunc func(context.Context, string
 	}
 	go func() {
 		for msg := range msgs {
-			ctx, span := c.Tracer.Start(context.Background(), "rabbitmq.consume",
+			// Processing continues the publisher's trace and links to its span
+			producer := messageContext(msg)
+			ctx := tenant.NewContext(producer, messageTenant(msg))
+			ctx = requestid.NewContext(ctx, messageRequestID(msg))
+			ctx, span := c.Tracer.Start(ctx, queue+" process",
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithLinks(trace.LinkFromContext(producer)),
 				trace.WithAttributes(
+					semconv.MessagingSystem("rabbitmq"),
+					semconv.MessagingOperationProcess,
+					semconv.MessagingDestinationName(msg.Exchange),
+					semconv.MessagingRabbitmqDestinationRoutingKey(msg.RoutingKey),
+					semconv.MessagingMessagePayloadSizeBytes(len(msg.Body)),
 					attribute.String("queue", queue),
+					attribute.String("tenant_id", tenant.FromContext(ctx)),
 					attribute.String("body", string(msg.Body)),
 				))
-			c.Logger.Info("Message consumed", zap.String("queue", queue), zap.String("body", string(msg.Body)))
-			consumerFunc(ctx, string(msg.Body))
+			requestid.Logger(ctx, c.Logger).Info("Message consumed", zap.String("queue", queue), zap.String("body", string(msg.Body)))
+			c.handle(ctx, queue, consumerFunc, string(msg.Body))
 			span.End()
 		}
 	}()
@@ -105,6 +148,93 @@ func (c *Client) Consume(queue string, consumerFunc func(context.Context, string
 	return nil
 }
 
+// handle passes a message to consumerFunc, recovering from a panic in it so that one bad
+// message does not stop the queue from being consumed
+func (c *Client) handle(ctx context.Context, queue string, consumerFunc func(context.Context, string), body string) {
+	defer func() {
+		if r := recover(); r != nil {
+			requestid.Logger(ctx, c.Logger).Error("Panic while consuming message",
+				zap.String("queue", queue),
+				zap.Any("panic", r),
+				zap.ByteString("stack", debug.Stack()))
+			monitoring.PanicsRecovered.WithLabelValues(queue).Inc()
+		}
+	}()
+	consumerFunc(ctx, body)
+}
+
+// messageHeaders returns the AMQP headers carrying the tenant, request ID and trace
+// context of ctx
+func messageHeaders(ctx context.Context) amqp.Table {
+	headers := amqp.Table{tenant.MetadataKey: tenant.FromContext(ctx)}
+	if id := requestid.FromContext(ctx); id != "" {
+		headers[requestid.MetadataKey] = id
+	}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(headers))
+	return headers
+}
+
+// messageContext returns a context carrying the trace context a message was published
+// under, if its publisher sent one
+func messageContext(msg amqp.Delivery) context.Context {
+	return otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier(msg.Headers))
+}
+
+// headerCarrier lets the text map propagator read and write AMQP headers
+type headerCarrier amqp.Table
+
+var _ propagation.TextMapCarrier = headerCarrier(nil)
+
+func (h headerCarrier) Get(key string) string {
+	value, _ := h[key].(string)
+	return value
+}
+
+func (h headerCarrier) Set(key, value string) {
+	h[key] = value
+}
+
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// messageRequestID returns the request ID a message was published under, or a new one
+// for publishers that do not set it
+func messageRequestID(msg amqp.Delivery) string {
+	if id, ok := msg.Headers[requestid.MetadataKey].(string); ok && requestid.Valid(id) {
+		return id
+	}
+	return requestid.New()
+}
+
+// messageTenant returns the tenant a message was published for, taken from its
+// header or, for publishers that do not set it, from the routing key
+func messageTenant(msg amqp.Delivery) string {
+	if id, ok := msg.Headers[tenant.MetadataKey].(string); ok && tenant.Valid(id) {
+		return id
+	}
+	if tenant.Valid(msg.RoutingKey) {
+		return msg.RoutingKey
+	}
+	return tenant.Default
+}
+
+// Ping reports an error once the connection or channel to RabbitMQ was closed. The client
+// does not reconnect, so a failing ping means publishing no longer works.
+func (c *Client) Ping(ctx context.Context) error {
+	if c.Conn.IsClosed() {
+		return errors.New("rabbitmq connection is closed")
+	}
+	if c.Channel.IsClosed() {
+		return errors.New("rabbitmq channel is closed")
+	}
+	return nil
+}
+
 func (c *Client) Close() {
 	c.Channel.Close()
 	c.Conn.Close()
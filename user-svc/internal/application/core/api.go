@@ -2,68 +2,99 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
 	"github.com/asadlive84/shopper/user-svc/internal/ports"
+	"github.com/asadlive84/shopper/user-svc/internal/requestid"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/google/uuid"
 
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// sessionTTL is how long a session issued by AuthenticateUser stays valid
+const sessionTTL = 24 * time.Hour
+
 type APIService struct {
 	db       ports.DBPort
 	mongoDB  ports.MongoDBPort
 	rabbitMQ ports.MessagingPort
+	hasher   ports.PasswordHasherPort
 	logger   *zap.Logger
+	erasures sync.Map // erasure request IDs currently being processed
+
+	passkeyRP *webauthn.WebAuthn // Nil until EnablePasskeys is called
+
+	userEvents  eventSignal   // Wakes up WatchUsers when a user event is recorded
+	watchesDone chan struct{} // Closed by CloseWatches
+	closeOnce   sync.Once
 }
 
-func NewApplication(db ports.DBPort, mongoDB ports.MongoDBPort, rabbitMQ ports.MessagingPort, logger *zap.Logger) *APIService {
+func NewApplication(db ports.DBPort, mongoDB ports.MongoDBPort, rabbitMQ ports.MessagingPort, hasher ports.PasswordHasherPort, logger *zap.Logger) *APIService {
 	return &APIService{
 		db:       db,
 		mongoDB:  mongoDB,
 		rabbitMQ: rabbitMQ,
+		hasher:   hasher,
 		logger:   logger,
+
+		watchesDone: make(chan struct{}),
 	}
 }
+
+// log returns the service logger annotated with the request ID carried by ctx
+func (s *APIService) log(ctx context.Context) *zap.Logger {
+	return requestid.Logger(ctx, s.logger)
+}
+
 func (s *APIService) CreateUser(ctx context.Context, req *domain.User) (*domain.User, error) {
 	// Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := s.hasher.Hash(ctx, req.Password)
 	if err != nil {
-		s.logger.Error("Failed to hash password", zap.Error(err))
+		s.log(ctx).Error("Failed to hash password", zap.Error(err))
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
+	role := req.Role
+	if role == domain.RoleUnspecified {
+		role = domain.RoleUser
+	}
+
 	// Create a new user object
 	user := &domain.User{
 		ID:       uuid.Must(uuid.NewRandom()),
 		Name:     req.Name,
 		Email:    req.Email,
-		Password: string(hashedPassword),
+		Password: hashedPassword,
+		Role:     role,
+		IsActive: true,
 	}
 
 	// Attempt to create the user in Postgres
 	err = s.db.CreateUser(ctx, user)
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			s.logger.Error("Duplicate email found", zap.String("email", user.Email))
+			s.log(ctx).Error("Duplicate email found", zap.String("email", user.Email))
 			return nil, fmt.Errorf("%w: %s", domain.ErrUserAlreadyExists, user.Email)
 		}
-		s.logger.Error("Failed to create user in Postgres", zap.Error(err))
+		s.log(ctx).Error("Failed to create user in Postgres", zap.Error(err))
 		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
 	}
 
 	// Publish to RabbitMQ
 	msg := "User created: " + user.Email
-	if err := s.rabbitMQ.Publish(ctx, "user_exchange", msg); err != nil {
-		s.logger.Error("Failed to publish to RabbitMQ", zap.Error(err))
+	if err := s.publishUserEvent(ctx, domain.UserEventCreated, user.ID, msg); err != nil {
+		s.log(ctx).Error("Failed to publish to RabbitMQ", zap.Error(err))
 	}
 
 	// Log in MongoDB
-	if err := s.mongoDB.LogMessage(ctx, msg); err != nil {
-		s.logger.Error("Failed to log message in MongoDB", zap.Error(err))
+	if err := s.mongoDB.LogMessage(ctx, user.ID.String(), msg); err != nil {
+		s.log(ctx).Error("Failed to log message in MongoDB", zap.Error(err))
 	}
 
 	return user, nil
@@ -72,7 +103,7 @@ func (s *APIService) CreateUser(ctx context.Context, req *domain.User) (*domain.
 func (s *APIService) GetUser(ctx context.Context, id string) (*domain.User, error) {
 	user, err := s.db.GetUser(ctx, id)
 	if err != nil {
-		s.logger.Error("Failed to get user from Postgres", zap.String("id", id), zap.Error(err))
+		s.log(ctx).Error("Failed to get user from Postgres", zap.String("id", id), zap.Error(err))
 		return nil, err
 	}
 	return user, nil
@@ -81,20 +112,64 @@ func (s *APIService) GetUser(ctx context.Context, id string) (*domain.User, erro
 func (s *APIService) AuthenticateUser(ctx context.Context, req *domain.User) (*domain.UserAutenticate, error) {
 	user, err := s.db.GetUserByEmail(ctx, req.Email)
 	if err != nil {
-		s.logger.Warn("User not found", zap.String("email", req.Email), zap.Error(err))
+		s.log(ctx).Warn("User not found", zap.String("email", req.Email), zap.Error(err))
 		return nil, err
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		s.logger.Warn("Invalid password", zap.String("email", req.Email))
+	if err := s.hasher.Compare(ctx, user.Password, req.Password); err != nil {
+		if !errors.Is(err, domain.ErrHashingUnavailable) && ctx.Err() == nil {
+			s.log(ctx).Warn("Invalid password", zap.String("email", req.Email))
+		}
+		return nil, err
+	}
+	if !user.IsActive {
+		s.log(ctx).Warn("Login attempt on locked account", zap.String("email", req.Email))
+		return nil, domain.ErrUserLocked
+	}
+	// A password alone is one factor; such users log in with a passkey instead
+	if user.TwoFactorEnabled {
+		return nil, domain.ErrSecondFactorRequired
+	}
+
+	session, err := s.startSession(ctx, user)
+	if err != nil {
 		return nil, err
 	}
 	return &domain.UserAutenticate{
-		User: domain.User{
-			ID:       user.ID,
-			Name:     user.Name,
-			Email:    user.Email,
-			Password: user.Password,
-		},
-		Token: "dummy-token",
+		User:  *user,
+		Token: session.ID.String(),
 	}, nil
 }
+
+// GetSession returns a login session while it is active
+func (s *APIService) GetSession(ctx context.Context, id string) (*domain.Session, error) {
+	session, err := s.db.GetSession(ctx, id)
+	if err != nil {
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	if !session.Active(time.Now()) {
+		return nil, domain.ErrSessionEnded
+	}
+	return session, nil
+}
+
+// startSession records a new login session for the user and bumps its last login time
+func (s *APIService) startSession(ctx context.Context, user *domain.User) (*domain.Session, error) {
+	now := time.Now()
+	session := &domain.Session{
+		ID:        uuid.Must(uuid.NewRandom()),
+		UserID:    user.ID,
+		ExpiresAt: now.Add(sessionTTL),
+	}
+	if err := s.db.CreateSession(ctx, session); err != nil {
+		s.log(ctx).Error("Failed to create session", zap.String("user_id", user.ID.String()), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	if err := s.db.TouchLastLogin(ctx, user.ID.String(), now); err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		s.log(ctx).Error("Failed to update last login", zap.String("user_id", user.ID.String()), zap.Error(err))
+	}
+	user.LastLogin = &now
+	return session, nil
+}
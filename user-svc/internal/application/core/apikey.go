@@ -0,0 +1,203 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// apiKeyPrefix starts every key so leaked keys are easy to recognize and scan for
+	apiKeyPrefix = "sk_"
+	// apiKeyPrefixLen is the length of the visible part: apiKeyPrefix plus 12 hex characters
+	apiKeyPrefixLen = len(apiKeyPrefix) + 12
+
+	defaultAPIKeyTTL = 90 * 24 * time.Hour
+	maxAPIKeyTTL     = 365 * 24 * time.Hour
+
+	// apiKeyTouchInterval limits how often a key's last-used time is written
+	apiKeyTouchInterval = time.Minute
+)
+
+// CreateAPIKey creates a key for the user and returns it with its secret, which is not stored
+func (s *APIService) CreateAPIKey(ctx context.Context, userID, name string, scopes []string, expiresAt *time.Time, orgID string) (*domain.APIKey, string, error) {
+	user, err := s.db.GetUser(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := validateScopes(scopes); err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	expiry := now.Add(defaultAPIKeyTTL)
+	if expiresAt != nil {
+		if !expiresAt.After(now) || expiresAt.Sub(now) > maxAPIKeyTTL {
+			return nil, "", fmt.Errorf("%w: keys must expire within %s", domain.ErrInvalidExpiry, maxAPIKeyTTL)
+		}
+		expiry = *expiresAt
+	}
+
+	key := &domain.APIKey{
+		ID:        uuid.Must(uuid.NewRandom()),
+		UserID:    user.ID,
+		Name:      name,
+		Scopes:    scopes,
+		ExpiresAt: expiry,
+	}
+	if orgID != "" {
+		membership, err := s.memberOf(ctx, userID, orgID)
+		if err != nil {
+			return nil, "", err
+		}
+		key.OrganizationID = &membership.OrganizationID
+	}
+
+	secret, err := newAPIKeySecret()
+	if err != nil {
+		return nil, "", err
+	}
+	key.Prefix = secret[:apiKeyPrefixLen]
+	key.Hash = hashToken(secret)
+	if err := s.db.CreateAPIKey(ctx, key); err != nil {
+		s.log(ctx).Error("Failed to create API key", zap.String("user_id", userID), zap.Error(err))
+		return nil, "", fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	s.audit(ctx, userID, "api_key.created", userID,
+		fmt.Sprintf("key=%s prefix=%s scopes=%s", key.ID, key.Prefix, strings.Join(scopes, " ")))
+	return key, secret, nil
+}
+
+func (s *APIService) ListAPIKeys(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	return s.db.ListAPIKeys(ctx, userID)
+}
+
+func (s *APIService) GetAPIKey(ctx context.Context, userID, id string) (*domain.APIKey, error) {
+	return s.db.GetAPIKey(ctx, userID, id)
+}
+
+// UpdateAPIKey renames a key and replaces its scopes; empty values leave the field unchanged
+func (s *APIService) UpdateAPIKey(ctx context.Context, userID, id, name string, scopes []string) (*domain.APIKey, error) {
+	key, err := s.db.GetAPIKey(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		key.Name = name
+	}
+	if len(scopes) > 0 {
+		if err := validateScopes(scopes); err != nil {
+			return nil, err
+		}
+		key.Scopes = scopes
+	}
+	if err := s.db.UpdateAPIKey(ctx, key); err != nil {
+		return nil, err
+	}
+	s.audit(ctx, userID, "api_key.updated", userID,
+		fmt.Sprintf("key=%s scopes=%s", key.ID, strings.Join(key.Scopes, " ")))
+	return key, nil
+}
+
+func (s *APIService) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	if err := s.db.RevokeAPIKey(ctx, userID, id); err != nil {
+		return err
+	}
+	s.audit(ctx, userID, "api_key.revoked", userID, "key="+id)
+	return nil
+}
+
+// AuthenticateAPIKey resolves a key presented by a client to its owner and records its use.
+// Unknown, revoked and expired keys all fail with ErrInvalidAPIKey.
+func (s *APIService) AuthenticateAPIKey(ctx context.Context, secret string) (*domain.APIKeyIdentity, error) {
+	if len(secret) <= apiKeyPrefixLen || !strings.HasPrefix(secret, apiKeyPrefix) {
+		return nil, domain.ErrInvalidAPIKey
+	}
+	key, err := s.db.GetAPIKeyByPrefix(ctx, secret[:apiKeyPrefixLen])
+	if err != nil {
+		if errors.Is(err, domain.ErrAPIKeyNotFound) {
+			return nil, domain.ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashToken(secret))) != 1 || !key.Usable(now) {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	user, err := s.db.GetUser(ctx, key.UserID.String())
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, domain.ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, domain.ErrUserLocked
+	}
+
+	identity := &domain.APIKeyIdentity{Key: key, User: user}
+	if key.OrganizationID != nil {
+		// Keys stop working for an organization once their owner leaves it
+		membership, err := s.db.GetMembership(ctx, key.OrganizationID.String(), user.ID.String())
+		if err != nil {
+			if errors.Is(err, domain.ErrNotMember) {
+				return nil, domain.ErrInvalidAPIKey
+			}
+			return nil, err
+		}
+		identity.Membership = membership
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.db.TouchAPIKey(ctx, key.ID, now); err != nil {
+			s.log(ctx).Error("Failed to record API key use", zap.String("key_id", key.ID.String()), zap.Error(err))
+		}
+		key.LastUsedAt = &now
+	}
+	return identity, nil
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("%w: at least one scope is required", domain.ErrInvalidScope)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(domain.APIKeyScopes, scope) {
+			return fmt.Errorf("%w: %q", domain.ErrInvalidScope, scope)
+		}
+	}
+	return nil
+}
+
+// newAPIKeySecret returns a key of the form sk_<12 hex chars>_<random>
+func newAPIKeySecret() (string, error) {
+	prefix := make([]byte, 6)
+	random := make([]byte, 24)
+	if _, err := rand.Read(prefix); err != nil {
+		return "", err
+	}
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + hex.EncodeToString(prefix) + "_" + base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// hashToken hashes a secret for storage: API keys, OIDC client secrets and authorization
+// codes, and the tokens of login links, user invites and email changes. All of them
+// carry at least 192 random bits, so a fast hash is enough.
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// AuditEntry records an administrative or security relevant action on a user
+type AuditEntry struct {
+	TenantID  string    `json:"tenant_id,omitempty"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	UserID    string    `json:"user_id,omitempty"`
+	Details   string    `json:"details,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// AuditFilter narrows down the audit entries returned by ListAudit
+type AuditFilter struct {
+	UserID string
+	Since  time.Time
+	Limit  int64
+}
@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailChange is a requested change of a user's email address. The address only
+// changes once the token mailed to the new address comes back; only its hash is stored.
+type EmailChange struct {
+	ID          uuid.UUID  `json:"id"`
+	TenantID    string     `json:"-"`
+	UserID      uuid.UUID  `json:"user_id"`
+	OldEmail    string     `json:"old_email"`
+	NewEmail    string     `json:"new_email"`
+	TokenHash   string     `json:"-"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+}
+
+var (
+	ErrInvalidEmailChange = errors.New("invalid or expired email change")
+	ErrSameEmail          = errors.New("new email address is the current one")
+)
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// IdempotentCall is a call made with an idempotency key. While the call runs the key is
+// locked; once it completes, its response is kept until ExpiresAt and replayed to retries
+// of the same request instead of running it again.
+type IdempotentCall struct {
+	TenantID     string
+	Key          string
+	Method       string // Full gRPC method name
+	Fingerprint  string // Hex SHA-256 of the method and the serialized request
+	ResponseType string // Full protobuf name of Response
+	Response     []byte
+	LockedUntil  time.Time
+	CreatedAt    time.Time
+	CompletedAt  *time.Time
+	ExpiresAt    time.Time
+}
+
+// Completed reports whether the call finished and its response can be replayed
+func (c *IdempotentCall) Completed() bool {
+	return c.CompletedAt != nil
+}
+
+var (
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different request")
+	ErrIdempotencyKeyInUse  = errors.New("request with this idempotency key is still in progress")
+)
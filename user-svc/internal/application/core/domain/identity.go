@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExternalIdentity links an account at an external identity provider, such as Google
+// or GitHub, to a user. The provider's subject identifier is what identifies the
+// account; the email is informational and may change at the provider.
+type ExternalIdentity struct {
+	ID          uuid.UUID  `json:"id"`
+	TenantID    string     `json:"-"`
+	UserID      uuid.UUID  `json:"user_id"`
+	Provider    string     `json:"provider"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// ExternalProfile is what an identity provider asserted about the account that logged in
+type ExternalProfile struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// ExternalLogin is the outcome of logging in with an external identity
+type ExternalLogin struct {
+	Identity *ExternalIdentity
+	User     User
+	Token    string
+	Created  bool // The user was created by this login
+}
+
+var (
+	ErrIdentityNotFound = errors.New("external identity not found")
+	ErrIdentityLinked   = errors.New("external identity is linked to another user")
+	ErrInvalidIdentity  = errors.New("invalid external identity")
+	ErrEmailNotVerified = errors.New("email address not verified by the identity provider")
+)
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PermissionImpersonate lets support staff act as a customer to debug their issues
+const PermissionImpersonate = "users:impersonate"
+
+// Impersonation is a short period in which ActorID acts as UserID. Tokens issued for
+// it are only honoured while it is active, so ending it cuts them off at once.
+type Impersonation struct {
+	ID        uuid.UUID
+	TenantID  string
+	ActorID   uuid.UUID
+	UserID    uuid.UUID
+	Reason    string
+	StartedAt time.Time
+	ExpiresAt time.Time
+	EndedAt   *time.Time
+}
+
+// Active reports whether the impersonation was neither ended nor has expired
+func (i *Impersonation) Active(now time.Time) bool {
+	return i.EndedAt == nil && now.Before(i.ExpiresAt)
+}
+
+// ImpersonationGrant is a started impersonation together with the impersonated user
+type ImpersonationGrant struct {
+	Impersonation Impersonation
+	User          User
+}
+
+var (
+	ErrImpersonationNotFound = errors.New("impersonation not found")
+	ErrImpersonationEnded    = errors.New("impersonation has ended")
+	ErrCannotImpersonate     = errors.New("user cannot be impersonated")
+)
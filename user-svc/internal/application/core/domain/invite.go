@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserInvite is an admin's invitation to join with a pre-assigned role. The invited
+// account exists from the start but stays inactive, without a usable password, until
+// the invitee accepts. Only a hash of the mailed token is stored.
+type UserInvite struct {
+	ID         uuid.UUID  `json:"id"`
+	TenantID   string     `json:"-"`
+	UserID     uuid.UUID  `json:"user_id"` // The pending account
+	Email      string     `json:"email"`
+	Role       Role       `json:"role"`
+	InvitedBy  uuid.UUID  `json:"invited_by"`
+	TokenHash  string     `json:"-"`
+	SendCount  int        `json:"send_count"` // Times the invitation was mailed, the first time included
+	CreatedAt  time.Time  `json:"created_at"`
+	SentAt     time.Time  `json:"sent_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+var (
+	ErrUserInviteNotFound = errors.New("user invite not found")
+	ErrInvalidUserInvite  = errors.New("invalid or expired user invite")
+)
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoginLink is a passwordless login token mailed to a user. Only hashes of the token
+// and of the device secret are stored; the link only works on the device that asked
+// for it, so a forwarded or intercepted email is not enough to log in.
+type LoginLink struct {
+	ID         uuid.UUID  `json:"id"`
+	TenantID   string     `json:"-"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Email      string     `json:"email"`
+	TokenHash  string     `json:"-"`
+	DeviceHash string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	UsedAt     *time.Time `json:"used_at,omitempty"`
+}
+
+var (
+	ErrInvalidLoginLink  = errors.New("invalid or expired login link")
+	ErrTooManyLoginLinks = errors.New("too many login links requested")
+)
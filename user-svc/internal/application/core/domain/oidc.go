@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes the OpenID Connect provider understands; ScopeOpenID must be requested by every
+// authorization request and unknown scopes are dropped from the grant
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+var OIDCScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+// OIDCClient is an application registered to sign users in through user-svc.
+// Public clients (browser and mobile apps) have no secret and must use PKCE.
+// Service clients are other services of the shop: they sign no user in and instead
+// get client credentials tokens for the gRPC streams of user-svc.
+type OIDCClient struct {
+	ID           string
+	TenantID     string
+	Name         string
+	SecretHash   string
+	RedirectURIs []string
+	Public       bool
+	Service      bool
+	CreatedAt    time.Time
+}
+
+// AllowsRedirect reports whether uri is one of the client's registered redirect URIs.
+// The comparison is exact, as required by the OAuth 2.0 security best practices.
+func (c *OIDCClient) AllowsRedirect(uri string) bool {
+	return slices.Contains(c.RedirectURIs, uri)
+}
+
+// AuthorizationRequest is an authorization-code request made by a client on behalf of a user
+type AuthorizationRequest struct {
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// Scopes returns the requested scopes
+func (r *AuthorizationRequest) Scopes() []string {
+	return strings.Fields(r.Scope)
+}
+
+// AuthorizationCode is a single-use code handed to a client after the user signed in.
+// Only a hash of the code is stored.
+type AuthorizationCode struct {
+	CodeHash      string
+	TenantID      string
+	ClientID      string
+	UserID        uuid.UUID
+	RedirectURI   string
+	Scope         string
+	Nonce         string
+	CodeChallenge string
+	AuthTime      time.Time
+	ExpiresAt     time.Time
+	UsedAt        *time.Time
+}
+
+// OIDCGrant is what a redeemed authorization code yields: the signed-in user and what they consented to
+type OIDCGrant struct {
+	Code *AuthorizationCode
+	User *User
+}
+
+var (
+	ErrClientNotFound        = errors.New("unknown client")
+	ErrInvalidClient         = errors.New("client authentication failed")
+	ErrInvalidRedirectURI    = errors.New("invalid redirect uri")
+	ErrInvalidAuthorization  = errors.New("invalid authorization request")
+	ErrInvalidGrant          = errors.New("invalid or expired authorization code")
+	ErrInvalidCredentials    = errors.New("invalid credentials")
+	ErrInvalidClientMetadata = errors.New("invalid client metadata")
+	ErrUnauthorizedClient    = errors.New("client is not allowed to use this grant")
+)
@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrgRole mirrors the OrganizationRole enum of the user proto
+type OrgRole int32
+
+const (
+	OrgRoleUnspecified OrgRole = 0
+	OrgRoleOwner       OrgRole = 1
+	OrgRoleAdmin       OrgRole = 2
+	OrgRoleBuyer       OrgRole = 3
+	OrgRoleViewer      OrgRole = 4
+)
+
+var orgRoleNames = map[OrgRole]string{
+	OrgRoleUnspecified: "unspecified",
+	OrgRoleOwner:       "owner",
+	OrgRoleAdmin:       "admin",
+	OrgRoleBuyer:       "buyer",
+	OrgRoleViewer:      "viewer",
+}
+
+func (r OrgRole) String() string {
+	if name, ok := orgRoleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("org_role(%d)", int32(r))
+}
+
+// Valid reports whether r is one of the assignable roles
+func (r OrgRole) Valid() bool {
+	return r >= OrgRoleOwner && r <= OrgRoleViewer
+}
+
+// CanManageMembers reports whether the role may invite, remove and re-role members
+func (r OrgRole) CanManageMembers() bool {
+	return r == OrgRoleOwner || r == OrgRoleAdmin
+}
+
+// ParseOrgRole converts a role name such as "buyer" into an OrgRole
+func ParseOrgRole(name string) (OrgRole, error) {
+	for role, roleName := range orgRoleNames {
+		if role != OrgRoleUnspecified && strings.EqualFold(roleName, name) {
+			return role, nil
+		}
+	}
+	return OrgRoleUnspecified, fmt.Errorf("%w: %q", ErrInvalidRole, name)
+}
+
+// Organization is a business customer account shared by its members
+type Organization struct {
+	ID        uuid.UUID
+	TenantID  string
+	Name      string
+	CreatedBy uuid.UUID
+	CreatedAt time.Time
+}
+
+// Membership links a user to an organization with a role
+type Membership struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	Role           OrgRole
+	JoinedAt       time.Time
+	Organization   *Organization // Set when listing a user's organizations
+}
+
+// OrgInvitation invites an email address to join an organization. It is accepted by
+// the signed-in user owning that address with the token mailed to it, so signing up
+// with an invited address is not enough to join.
+type OrgInvitation struct {
+	ID             uuid.UUID
+	OrganizationID uuid.UUID
+	Email          string
+	TokenHash      string // SHA-256 of the mailed token
+	Role           OrgRole
+	InvitedBy      uuid.UUID
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+	AcceptedAt     *time.Time
+	RevokedAt      *time.Time
+}
+
+// Pending reports whether the invitation can still be accepted at now
+func (i *OrgInvitation) Pending(now time.Time) bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && now.Before(i.ExpiresAt)
+}
+
+var (
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrNotMember            = errors.New("user is not a member of the organization")
+	ErrAlreadyMember        = errors.New("user is already a member of the organization")
+	ErrPermissionDenied     = errors.New("permission denied")
+	ErrLastOwner            = errors.New("organization must keep at least one owner")
+	ErrInvitationNotFound   = errors.New("invitation not found")
+	ErrInvitationExpired    = errors.New("invitation is no longer valid")
+)
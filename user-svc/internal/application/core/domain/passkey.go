@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Passkey is a WebAuthn credential registered by a user. Only the public key is held;
+// the private key never leaves the authenticator.
+type Passkey struct {
+	ID              uuid.UUID  `json:"id"`
+	TenantID        string     `json:"-"`
+	UserID          uuid.UUID  `json:"user_id"`
+	Name            string     `json:"name"`
+	CredentialID    []byte     `json:"credential_id"`
+	PublicKey       []byte     `json:"public_key"`
+	AttestationType string     `json:"attestation_type"`
+	Transports      []string   `json:"transports,omitempty"`
+	AAGUID          []byte     `json:"aaguid,omitempty"` // Identifies the authenticator model
+	SignCount       uint32     `json:"sign_count"`
+	BackupEligible  bool       `json:"backup_eligible"` // Synced passkey rather than a device-bound one
+	BackupState     bool       `json:"backup_state"`
+	CloneWarning    bool       `json:"clone_warning"` // A sign count went backwards; the passkey is disabled
+	CreatedAt       time.Time  `json:"created_at"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+}
+
+// PasskeyCeremonyKind tells registration and login ceremonies apart
+type PasskeyCeremonyKind string
+
+const (
+	PasskeyRegistration PasskeyCeremonyKind = "registration"
+	PasskeyLogin        PasskeyCeremonyKind = "login"
+)
+
+// PasskeyCeremony is the server side of a registration or login ceremony in progress:
+// the challenge the authenticator must sign, kept until the browser answers it
+type PasskeyCeremony struct {
+	ID        uuid.UUID
+	TenantID  string
+	UserID    *uuid.UUID // Nil for a login where the user is only known from the answer
+	Kind      PasskeyCeremonyKind
+	Session   []byte // Serialized WebAuthn session data
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// PasskeyChallenge starts a ceremony in the browser. Options is the JSON to pass to
+// navigator.credentials.create or get; the answer is sent back with CeremonyID.
+type PasskeyChallenge struct {
+	CeremonyID uuid.UUID
+	Options    []byte
+	ExpiresAt  time.Time
+}
+
+var (
+	ErrPasskeyNotFound      = errors.New("passkey not found")
+	ErrPasskeyExists        = errors.New("passkey already registered")
+	ErrInvalidPasskey       = errors.New("passkey verification failed")
+	ErrInvalidCeremony      = errors.New("invalid or expired passkey ceremony")
+	ErrPasskeyCloned        = errors.New("passkey may have been cloned")
+	ErrNoPasskeys           = errors.New("no passkey registered")
+	ErrLastPasskey          = errors.New("the last passkey cannot be removed while two-factor authentication is on")
+	ErrPasskeysDisabled     = errors.New("passkeys are not enabled")
+	ErrSecondFactorRequired = errors.New("second factor required")
+)
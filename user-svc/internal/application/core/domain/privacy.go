@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ErasureStatus int32
+
+// Values mirror the ErasureStatus enum of the user proto
+const (
+	ErasureStatusUnspecified ErasureStatus = 0
+	ErasureStatusPending     ErasureStatus = 1
+	ErasureStatusInProgress  ErasureStatus = 2
+	ErasureStatusCompleted   ErasureStatus = 3
+	ErasureStatusFailed      ErasureStatus = 4
+)
+
+// Erasure steps, executed in this order. Every step is idempotent so a failed or
+// interrupted request can simply be run again from the first unfinished step.
+const (
+	ErasureStepRevokeSessions     = "revoke_sessions"
+	ErasureStepRevokeAPIKeys      = "revoke_api_keys"
+	ErasureStepUnlinkIdentities   = "unlink_identities"
+	ErasureStepDeletePasskeys     = "delete_passkeys"
+	ErasureStepDeleteLoginLinks   = "delete_login_links"
+	ErasureStepDeleteUserInvites  = "delete_user_invites"
+	ErasureStepScrubLogs          = "scrub_logs"
+	ErasureStepDeleteEmailChanges = "delete_email_changes"
+	ErasureStepPseudonymizeAudit  = "pseudonymize_audit"
+	ErasureStepAnonymizeUser      = "anonymize_user"
+	ErasureStepPublishEvent       = "publish_event"
+)
+
+var ErasureSteps = []string{
+	ErasureStepRevokeSessions,
+	ErasureStepRevokeAPIKeys,
+	ErasureStepUnlinkIdentities,
+	ErasureStepDeletePasskeys,
+	ErasureStepDeleteLoginLinks,
+	ErasureStepDeleteUserInvites,
+	// Logs are scrubbed of every address the user had, which only the email changes record
+	ErasureStepScrubLogs,
+	ErasureStepDeleteEmailChanges,
+	ErasureStepPseudonymizeAudit,
+	ErasureStepAnonymizeUser,
+	ErasureStepPublishEvent,
+}
+
+// ErasureRequest tracks a right-to-erasure request until every step has completed
+type ErasureRequest struct {
+	ID             uuid.UUID
+	TenantID       string
+	UserID         uuid.UUID
+	Email          string // Original email; cleared once the request completes
+	Pseudonym      string // Random stand-in for the user in erased records; cleared once the request completes
+	Reason         string
+	Status         ErasureStatus
+	CompletedSteps []string
+	Error          string
+	RequestedAt    time.Time
+	CompletedAt    *time.Time
+}
+
+func (r *ErasureRequest) StepDone(step string) bool {
+	for _, done := range r.CompletedSteps {
+		if done == step {
+			return true
+		}
+	}
+	return false
+}
+
+// LogEntry is a free-text entry of the MongoDB logs collection
+type LogEntry struct {
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// DataExport is the archive handed to a user who asked for a copy of their data
+type DataExport struct {
+	Filename    string
+	ContentType string
+	Archive     []byte
+}
+
+var ErrErasureNotFound = errors.New("erasure request not found")
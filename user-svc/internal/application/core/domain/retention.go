@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// RetentionPolicy says how long each kind of Postgres artifact is kept; zero keeps it forever
+type RetentionPolicy struct {
+	Sessions           time.Duration
+	DeletedUsers       time.Duration
+	Invitations        time.Duration
+	APIKeys            time.Duration
+	AuthorizationCodes time.Duration
+	LoginLinks         time.Duration
+	PasskeyCeremonies  time.Duration
+	UserInvites        time.Duration
+	Impersonations     time.Duration
+	EmailChanges       time.Duration
+	IdempotencyKeys    time.Duration
+	UserEvents         time.Duration
+}
+
+// RetentionResult reports what a single retention run removed
+type RetentionResult struct {
+	Purged   map[string]int64
+	Duration time.Duration
+}
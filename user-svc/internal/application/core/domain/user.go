@@ -2,15 +2,71 @@ package domain
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// Role mirrors the Role enum of the user proto so the values can be stored as-is
+type Role int32
+
+const (
+	RoleUnspecified Role = 0
+	RoleAdmin       Role = 1
+	RoleUser        Role = 2
+	RoleModerator   Role = 3
+)
+
+var roleNames = map[Role]string{
+	RoleUnspecified: "unspecified",
+	RoleAdmin:       "admin",
+	RoleUser:        "user",
+	RoleModerator:   "moderator",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("role(%d)", int32(r))
+}
+
+// ParseRole converts a role name such as "admin" into a Role
+func ParseRole(name string) (Role, error) {
+	for role, roleName := range roleNames {
+		if role != RoleUnspecified && strings.EqualFold(roleName, name) {
+			return role, nil
+		}
+	}
+	return RoleUnspecified, fmt.Errorf("%w: %q", ErrInvalidRole, name)
+}
+
 type User struct {
-	ID       uuid.UUID
-	Name     string
-	Email    string
-	Password string
+	ID        uuid.UUID
+	TenantID  string
+	Name      string
+	Email     string
+	Password  string
+	Role      Role
+	IsActive  bool
+	CreatedAt time.Time
+	LastLogin *time.Time
+	// Logins must be confirmed with a passkey; a password alone is not enough
+	TwoFactorEnabled bool
+	// Grants beyond the role, such as PermissionImpersonate
+	Permissions []string
+}
+
+// HasPermission reports whether the user was granted permission
+func (u *User) HasPermission(permission string) bool {
+	for _, p := range u.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
 }
 
 type UserAutenticate struct {
@@ -18,7 +74,31 @@ type UserAutenticate struct {
 	Token string
 }
 
+// Session is a login session handed out by AuthenticateUser
+type Session struct {
+	ID        uuid.UUID  `json:"id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the session was neither revoked nor has expired. Access tokens
+// issued for a session are only honoured while it is active.
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
 var (
 	ErrUserAlreadyExists = errors.New("user already exists")
 	ErrDatabaseError     = errors.New("database error")
+	ErrUserNotFound      = errors.New("user not found")
+	ErrUserLocked        = errors.New("user account is locked")
+	ErrInvalidRole       = errors.New("invalid role")
+	ErrSessionNotFound   = errors.New("session not found")
+	ErrSessionEnded      = errors.New("session was revoked or has expired")
+
+	// ErrHashingUnavailable means too many passwords are waiting to be hashed; the call
+	// can be retried later
+	ErrHashingUnavailable = errors.New("password hashing unavailable")
 )
@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserEventType is the kind of change a UserEvent reports
+type UserEventType string
+
+const (
+	UserEventCreated       UserEventType = "created"
+	UserEventUpdated       UserEventType = "updated"
+	UserEventDeleted       UserEventType = "deleted"
+	UserEventStatusChanged UserEventType = "status_changed"
+)
+
+// UserEvent is a change to a user, recorded alongside the message published for it on
+// the user exchange. Sequence orders the events of all tenants.
+type UserEvent struct {
+	Sequence   int64
+	TenantID   string
+	Type       UserEventType
+	UserID     uuid.UUID
+	OccurredAt time.Time
+}
+
+// UserEventFilter selects the events a watch reports. Empty lists match everything.
+type UserEventFilter struct {
+	UserIDs []uuid.UUID
+	Types   []UserEventType
+	After   int64 // Only events with a higher sequence
+}
+
+var (
+	// ErrResumeTokenExpired means the events after a resume token were already purged
+	ErrResumeTokenExpired = errors.New("resume token expired")
+	// ErrWatchClosed means the service is shutting down; the watch can resume elsewhere
+	ErrWatchClosed = errors.New("watch closed by service shutdown")
+)
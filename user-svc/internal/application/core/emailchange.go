@@ -0,0 +1,100 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"go.uber.org/zap"
+)
+
+// emailChangeTTL is how long the confirmation mailed to a new address can be used
+const emailChangeTTL = 24 * time.Hour
+
+// RequestEmailChange starts changing the user's email address to newEmail. The current
+// password must be given; the new address gets a confirmation token and the old one a
+// notice, so the owner learns of a change they did not ask for.
+func (s *APIService) RequestEmailChange(ctx context.Context, userID, password, newEmail string) (time.Time, error) {
+	newEmail = strings.TrimSpace(newEmail)
+	user, err := s.db.GetUser(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !user.IsActive {
+		return time.Time{}, domain.ErrUserLocked
+	}
+	if err := s.hasher.Compare(ctx, user.Password, password); err != nil {
+		if errors.Is(err, domain.ErrHashingUnavailable) || ctx.Err() != nil {
+			return time.Time{}, err
+		}
+		s.log(ctx).Warn("Email change with invalid password", zap.String("user_id", userID))
+		return time.Time{}, domain.ErrInvalidCredentials
+	}
+	if strings.EqualFold(newEmail, user.Email) {
+		return time.Time{}, domain.ErrSameEmail
+	}
+	if _, err := s.db.GetUserByEmail(ctx, newEmail); err == nil {
+		return time.Time{}, fmt.Errorf("%w: %s", domain.ErrUserAlreadyExists, newEmail)
+	} else if !errors.Is(err, domain.ErrUserNotFound) {
+		return time.Time{}, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+
+	token, err := mailedToken(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	change := &domain.EmailChange{
+		UserID:    user.ID,
+		OldEmail:  user.Email,
+		NewEmail:  newEmail,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(emailChangeTTL),
+	}
+	if err := s.db.CreateEmailChange(ctx, change); err != nil {
+		s.log(ctx).Error("Failed to create email change", zap.String("user_id", userID), zap.Error(err))
+		return time.Time{}, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+
+	// The mailer turns the token into a link to a page that posts it to the gateway's POST /email/confirm
+	if err := s.rabbitMQ.PublishSecret(ctx, mailExchange, "email_change:"+change.NewEmail+":"+token); err != nil {
+		s.log(ctx).Error("Failed to publish email change", zap.String("user_id", userID), zap.Error(err))
+		return time.Time{}, err
+	}
+	if err := s.rabbitMQ.PublishSecret(ctx, mailExchange, "email_change_notice:"+change.OldEmail+":"+change.NewEmail); err != nil {
+		s.log(ctx).Error("Failed to publish email change notice", zap.String("user_id", userID), zap.Error(err))
+	}
+	s.audit(ctx, userID, "user.email_change_requested", userID, "email_change="+change.ID.String())
+	return change.ExpiresAt, nil
+}
+
+// ConfirmEmailChange swaps in the new address of the change the token was mailed for.
+// All sessions of the user are revoked, so they log in again with the new address: the
+// gateway checks the session of every access token with GetSession, which refuses
+// revoked ones. Login links still out for the old address stop working as well.
+func (s *APIService) ConfirmEmailChange(ctx context.Context, token string) (*domain.User, error) {
+	if token == "" {
+		return nil, domain.ErrInvalidEmailChange
+	}
+	change, err := s.db.ConfirmEmailChange(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidEmailChange) || errors.Is(err, domain.ErrUserAlreadyExists) {
+			s.log(ctx).Warn("Email change not confirmed", zap.Error(err))
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	userID := change.UserID.String()
+	user, err := s.db.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.publishUserEvent(ctx, domain.UserEventUpdated, change.UserID, "user_email_changed:"+userID); err != nil {
+		s.log(ctx).Error("Failed to publish to RabbitMQ", zap.Error(err))
+	}
+	s.audit(ctx, userID, "user.email_changed", userID, "email_change="+change.ID.String())
+	return user, nil
+}
@@ -0,0 +1,273 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/ports"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeDB keeps the records the tests touch in memory. Methods a test reaches without a
+// fake here panic on the nil embedded port, which points at the one to add.
+type fakeDB struct {
+	ports.DBPort
+
+	mu         sync.Mutex
+	users      map[uuid.UUID]*domain.User
+	sessions   map[uuid.UUID]*domain.Session
+	identities []*domain.ExternalIdentity
+	events     []*domain.UserEvent
+	passkeys   []*domain.Passkey
+	ceremonies map[uuid.UUID]*domain.PasskeyCeremony
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{
+		users:      map[uuid.UUID]*domain.User{},
+		sessions:   map[uuid.UUID]*domain.Session{},
+		ceremonies: map[uuid.UUID]*domain.PasskeyCeremony{},
+	}
+}
+
+func (f *fakeDB) CreateUser(ctx context.Context, user *domain.User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+	user.CreatedAt = time.Now()
+	stored := *user
+	f.users[user.ID] = &stored
+	return nil
+}
+
+func (f *fakeDB) GetUser(ctx context.Context, id string) (*domain.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, user := range f.users {
+		if user.ID.String() == id {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, domain.ErrUserNotFound
+}
+
+func (f *fakeDB) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, user := range f.users {
+		if user.Email == email {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, domain.ErrUserNotFound
+}
+
+func (f *fakeDB) TouchLastLogin(ctx context.Context, id string, at time.Time) error {
+	return nil
+}
+
+func (f *fakeDB) CreateSession(ctx context.Context, session *domain.Session) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	session.CreatedAt = time.Now()
+	stored := *session
+	f.sessions[session.ID] = &stored
+	return nil
+}
+
+func (f *fakeDB) CreateExternalIdentity(ctx context.Context, identity *domain.ExternalIdentity) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, linked := range f.identities {
+		if linked.Provider == identity.Provider && linked.Subject == identity.Subject {
+			return domain.ErrIdentityLinked
+		}
+	}
+	identity.ID = uuid.New()
+	identity.CreatedAt = time.Now()
+	stored := *identity
+	f.identities = append(f.identities, &stored)
+	return nil
+}
+
+func (f *fakeDB) GetExternalIdentity(ctx context.Context, provider, subject string) (*domain.ExternalIdentity, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, identity := range f.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			found := *identity
+			return &found, nil
+		}
+	}
+	return nil, domain.ErrIdentityNotFound
+}
+
+func (f *fakeDB) TouchExternalIdentity(ctx context.Context, id uuid.UUID, email string, at time.Time) error {
+	return nil
+}
+
+func (f *fakeDB) AppendUserEvent(ctx context.Context, event *domain.UserEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	event.Sequence = int64(len(f.events) + 1)
+	event.OccurredAt = time.Now()
+	stored := *event
+	f.events = append(f.events, &stored)
+	return nil
+}
+
+func (f *fakeDB) SetTwoFactor(ctx context.Context, id string, enabled bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, user := range f.users {
+		if user.ID.String() == id {
+			user.TwoFactorEnabled = enabled
+			return nil
+		}
+	}
+	return domain.ErrUserNotFound
+}
+
+func (f *fakeDB) CreatePasskey(ctx context.Context, passkey *domain.Passkey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, stored := range f.passkeys {
+		if bytes.Equal(stored.CredentialID, passkey.CredentialID) {
+			return domain.ErrPasskeyExists
+		}
+	}
+	passkey.ID = uuid.New()
+	passkey.CreatedAt = time.Now()
+	stored := *passkey
+	f.passkeys = append(f.passkeys, &stored)
+	return nil
+}
+
+func (f *fakeDB) ListPasskeys(ctx context.Context, userID string) ([]*domain.Passkey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var passkeys []*domain.Passkey
+	for _, passkey := range f.passkeys {
+		if passkey.UserID.String() == userID {
+			found := *passkey
+			passkeys = append(passkeys, &found)
+		}
+	}
+	return passkeys, nil
+}
+
+func (f *fakeDB) GetPasskeyByCredentialID(ctx context.Context, credentialID []byte) (*domain.Passkey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, passkey := range f.passkeys {
+		if bytes.Equal(passkey.CredentialID, credentialID) {
+			found := *passkey
+			return &found, nil
+		}
+	}
+	return nil, domain.ErrPasskeyNotFound
+}
+
+func (f *fakeDB) UpdatePasskeyUsage(ctx context.Context, passkey *domain.Passkey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, stored := range f.passkeys {
+		if stored.ID == passkey.ID {
+			stored.SignCount = passkey.SignCount
+			stored.BackupState = passkey.BackupState
+			stored.CloneWarning = passkey.CloneWarning
+			stored.LastUsedAt = passkey.LastUsedAt
+			return nil
+		}
+	}
+	return domain.ErrPasskeyNotFound
+}
+
+func (f *fakeDB) CreatePasskeyCeremony(ctx context.Context, ceremony *domain.PasskeyCeremony) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ceremony.ID = uuid.New()
+	ceremony.CreatedAt = time.Now()
+	stored := *ceremony
+	f.ceremonies[ceremony.ID] = &stored
+	return nil
+}
+
+func (f *fakeDB) ConsumePasskeyCeremony(ctx context.Context, id string, kind domain.PasskeyCeremonyKind) (*domain.PasskeyCeremony, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ceremony := range f.ceremonies {
+		if ceremony.ID.String() == id && ceremony.Kind == kind && ceremony.UsedAt == nil && time.Now().Before(ceremony.ExpiresAt) {
+			now := time.Now()
+			ceremony.UsedAt = &now
+			found := *ceremony
+			return &found, nil
+		}
+	}
+	return nil, domain.ErrInvalidCeremony
+}
+
+// fakeMongoDB collects the audit trail
+type fakeMongoDB struct {
+	ports.MongoDBPort
+
+	mu    sync.Mutex
+	audit []*domain.AuditEntry
+}
+
+func (f *fakeMongoDB) RecordAudit(ctx context.Context, entry *domain.AuditEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.audit = append(f.audit, entry)
+	return nil
+}
+
+func (f *fakeMongoDB) LogMessage(ctx context.Context, userID, msg string) error {
+	return nil
+}
+
+// fakeMessaging collects the messages published
+type fakeMessaging struct {
+	ports.MessagingPort
+
+	mu       sync.Mutex
+	messages []string
+}
+
+func (f *fakeMessaging) Publish(ctx context.Context, exchange, message string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, message)
+	return nil
+}
+
+func (f *fakeMessaging) PublishSecret(ctx context.Context, exchange, message string) error {
+	return f.Publish(ctx, exchange, message)
+}
+
+// plainHasher stands in for bcrypt, which is too slow to run in every test
+type plainHasher struct{}
+
+func (plainHasher) Hash(ctx context.Context, password string) (string, error) {
+	return "hashed:" + password, nil
+}
+
+func (plainHasher) Compare(ctx context.Context, hash, password string) error {
+	if hash != "hashed:"+password {
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+func newTestService(db *fakeDB) *APIService {
+	return NewApplication(db, &fakeMongoDB{}, &fakeMessaging{}, plainHasher{}, zap.NewNop())
+}
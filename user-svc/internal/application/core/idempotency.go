@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"go.uber.org/zap"
+)
+
+const (
+	// idempotencyTTL is how long the response of a call made with an idempotency key is replayed
+	idempotencyTTL = 24 * time.Hour
+	// idempotencyLockTimeout is how long a call holds its key before a retry may run it
+	// again; calls are expected to finish well within it
+	idempotencyLockTimeout = time.Minute
+)
+
+// BeginIdempotentCall claims key for a call of method with a request of the given
+// fingerprint. It returns nil when the caller got the key and must run the call, or the
+// completed earlier call of the same request, whose response the caller replays. A key
+// used for a different request is refused with ErrIdempotencyKeyReused, and one whose
+// call is still running with ErrIdempotencyKeyInUse.
+func (s *APIService) BeginIdempotentCall(ctx context.Context, key, method, fingerprint string) (*domain.IdempotentCall, error) {
+	now := time.Now()
+	call := &domain.IdempotentCall{
+		Key:         key,
+		Method:      method,
+		Fingerprint: fingerprint,
+		LockedUntil: now.Add(idempotencyLockTimeout),
+		ExpiresAt:   now.Add(idempotencyTTL),
+	}
+	held, err := s.db.ClaimIdempotencyKey(ctx, call)
+	if err != nil {
+		s.log(ctx).Error("Failed to claim idempotency key", zap.String("method", method), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	switch {
+	case held == nil:
+		return nil, nil
+	case held.Fingerprint != fingerprint:
+		s.log(ctx).Warn("Idempotency key reused for a different request", zap.String("method", method), zap.String("idempotency_key", key))
+		return nil, domain.ErrIdempotencyKeyReused
+	case !held.Completed():
+		return nil, domain.ErrIdempotencyKeyInUse
+	default:
+		return held, nil
+	}
+}
+
+// FinishIdempotentCall records the response of a call started with BeginIdempotentCall
+func (s *APIService) FinishIdempotentCall(ctx context.Context, key, method, fingerprint, responseType string, response []byte) error {
+	call := &domain.IdempotentCall{
+		Key:          key,
+		Method:       method,
+		Fingerprint:  fingerprint,
+		ResponseType: responseType,
+		Response:     response,
+	}
+	if err := s.db.CompleteIdempotentCall(ctx, call); err != nil {
+		s.log(ctx).Error("Failed to record idempotent call", zap.String("method", method), zap.Error(err))
+		return fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	return nil
+}
+
+// AbandonIdempotentCall frees the key of a call started with BeginIdempotentCall whose
+// outcome should not be replayed, such as a transient failure, so a retry runs it again
+func (s *APIService) AbandonIdempotentCall(ctx context.Context, key, method, fingerprint string) error {
+	call := &domain.IdempotentCall{Key: key, Method: method, Fingerprint: fingerprint}
+	if err := s.db.ReleaseIdempotencyKey(ctx, call); err != nil {
+		s.log(ctx).Error("Failed to release idempotency key", zap.String("method", method), zap.Error(err))
+		return fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"go.uber.org/zap"
+)
+
+// LinkExternalIdentity logs in with an account at an external identity provider. With a
+// userID the identity is linked to that user; otherwise the user it is already linked
+// to logs in. An unlinked identity is linked to the user owning its email address, but
+// only when the provider verified that address, so nobody can take over an account by
+// registering its email at a provider. Failing that, a new user is created.
+func (s *APIService) LinkExternalIdentity(ctx context.Context, profile *domain.ExternalProfile, userID string) (*domain.ExternalLogin, error) {
+	if profile.Provider == "" || profile.Subject == "" {
+		return nil, fmt.Errorf("%w: provider and subject are required", domain.ErrInvalidIdentity)
+	}
+	profile.Email = strings.TrimSpace(profile.Email)
+
+	login := &domain.ExternalLogin{}
+	identity, err := s.db.GetExternalIdentity(ctx, profile.Provider, profile.Subject)
+	switch {
+	case err == nil:
+		if userID != "" && identity.UserID.String() != userID {
+			return nil, domain.ErrIdentityLinked
+		}
+		user, err := s.db.GetUser(ctx, identity.UserID.String())
+		if err != nil {
+			return nil, err
+		}
+		login.User = *user
+	case errors.Is(err, domain.ErrIdentityNotFound):
+		user, created, err := s.externalIdentityOwner(ctx, profile, userID)
+		if err != nil {
+			return nil, err
+		}
+		identity = &domain.ExternalIdentity{
+			UserID:   user.ID,
+			Provider: profile.Provider,
+			Subject:  profile.Subject,
+			Email:    profile.Email,
+		}
+		if err := s.db.CreateExternalIdentity(ctx, identity); err != nil {
+			if errors.Is(err, domain.ErrIdentityLinked) {
+				return nil, err
+			}
+			s.log(ctx).Error("Failed to link external identity", zap.String("provider", profile.Provider), zap.Error(err))
+			return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+		}
+		s.audit(ctx, user.ID.String(), "identity.linked", user.ID.String(),
+			fmt.Sprintf("provider=%s subject=%s", profile.Provider, profile.Subject))
+		login.User = *user
+		login.Created = created
+	default:
+		s.log(ctx).Error("Failed to look up external identity", zap.String("provider", profile.Provider), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+
+	if !login.User.IsActive {
+		s.log(ctx).Warn("External login attempt on locked account", zap.String("user_id", login.User.ID.String()))
+		return nil, domain.ErrUserLocked
+	}
+	// Linking happens in an existing session; logging in needs the passkey as well
+	if userID == "" && login.User.TwoFactorEnabled {
+		return nil, domain.ErrSecondFactorRequired
+	}
+	session, err := s.startSession(ctx, &login.User)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := s.db.TouchExternalIdentity(ctx, identity.ID, profile.Email, now); err != nil {
+		s.log(ctx).Error("Failed to update external identity", zap.String("id", identity.ID.String()), zap.Error(err))
+	}
+	identity.Email = profile.Email
+	identity.LastLoginAt = &now
+	login.Identity = identity
+	login.Token = session.ID.String()
+	return login, nil
+}
+
+// externalIdentityOwner finds or creates the user a new external identity is linked to,
+// reporting whether it was created
+func (s *APIService) externalIdentityOwner(ctx context.Context, profile *domain.ExternalProfile, userID string) (*domain.User, bool, error) {
+	if userID != "" {
+		user, err := s.db.GetUser(ctx, userID)
+		return user, false, err
+	}
+	if profile.Email == "" {
+		return nil, false, fmt.Errorf("%w: the provider did not share an email address", domain.ErrInvalidIdentity)
+	}
+
+	user, err := s.db.GetUserByEmail(ctx, profile.Email)
+	switch {
+	case err == nil:
+		if !profile.EmailVerified {
+			return nil, false, domain.ErrEmailNotVerified
+		}
+		return user, false, nil
+	case !errors.Is(err, domain.ErrUserNotFound):
+		return nil, false, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+
+	// The user logs in through the provider only, so the password is random and never
+	// shown; a password can be set later through a password reset
+	password, err := randomToken(32)
+	if err != nil {
+		return nil, false, err
+	}
+	name := profile.Name
+	if name == "" {
+		name, _, _ = strings.Cut(profile.Email, "@")
+	}
+	user, err = s.CreateUser(ctx, &domain.User{Name: name, Email: profile.Email, Password: password})
+	if err != nil {
+		return nil, false, err
+	}
+	return user, true, nil
+}
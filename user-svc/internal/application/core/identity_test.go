@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+)
+
+func TestLinkExternalIdentityByEmailRequiresVerifiedEmail(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDB()
+	s := newTestService(db)
+	owner, err := s.CreateUser(ctx, &domain.User{Name: "Ada", Email: "ada@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profile := &domain.ExternalProfile{Provider: "google", Subject: "subject-1", Email: "ada@example.com"}
+	if _, err := s.LinkExternalIdentity(ctx, profile, ""); !errors.Is(err, domain.ErrEmailNotVerified) {
+		t.Fatalf("LinkExternalIdentity with an unverified email = %v, want %v", err, domain.ErrEmailNotVerified)
+	}
+	if len(db.identities) != 0 {
+		t.Fatalf("an unverified email linked %d identities", len(db.identities))
+	}
+
+	profile.EmailVerified = true
+	login, err := s.LinkExternalIdentity(ctx, profile, "")
+	if err != nil {
+		t.Fatalf("LinkExternalIdentity with a verified email: %v", err)
+	}
+	if login.User.ID != owner.ID || login.Created {
+		t.Errorf("logged in as %s (created %v), want the existing user %s", login.User.ID, login.Created, owner.ID)
+	}
+	if login.Identity.UserID != owner.ID {
+		t.Errorf("identity linked to %s, want %s", login.Identity.UserID, owner.ID)
+	}
+
+	// Once linked, the identity logs in by its subject whatever the provider says of the email
+	profile.EmailVerified = false
+	if _, err := s.LinkExternalIdentity(ctx, profile, ""); err != nil {
+		t.Errorf("LinkExternalIdentity of a linked identity: %v", err)
+	}
+}
+
+func TestLinkExternalIdentityCreatesUserForUnknownEmail(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDB()
+	s := newTestService(db)
+
+	profile := &domain.ExternalProfile{Provider: "google", Subject: "subject-1", Email: "grace@example.com"}
+	login, err := s.LinkExternalIdentity(ctx, profile, "")
+	if err != nil {
+		t.Fatalf("LinkExternalIdentity: %v", err)
+	}
+	if !login.Created || login.User.Email != "grace@example.com" {
+		t.Errorf("login = %+v, want a new user for grace@example.com", login.User)
+	}
+}
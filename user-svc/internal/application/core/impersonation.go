@@ -0,0 +1,108 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"go.uber.org/zap"
+)
+
+// impersonationTTL bounds an impersonation; support starts a new one if they need longer
+const impersonationTTL = 15 * time.Minute
+
+// Impersonate starts a period in which actorID acts as userID. The actor needs the
+// impersonate permission, and admins and other impersonators cannot be impersonated,
+// so the permission never grants more than a customer's view of the shop.
+func (s *APIService) Impersonate(ctx context.Context, actorID, userID, reason string) (*domain.ImpersonationGrant, error) {
+	actor, err := s.db.GetUser(ctx, actorID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, domain.ErrPermissionDenied
+		}
+		return nil, err
+	}
+	if !actor.IsActive || !actor.HasPermission(domain.PermissionImpersonate) {
+		s.log(ctx).Warn("Impersonation refused", zap.String("actor_id", actorID), zap.String("user_id", userID))
+		return nil, domain.ErrPermissionDenied
+	}
+	user, err := s.db.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == actor.ID || !user.IsActive || user.Role == domain.RoleAdmin || user.HasPermission(domain.PermissionImpersonate) {
+		s.log(ctx).Warn("Impersonation of protected user refused", zap.String("actor_id", actorID), zap.String("user_id", userID))
+		return nil, domain.ErrCannotImpersonate
+	}
+
+	imp := &domain.Impersonation{
+		ActorID:   actor.ID,
+		UserID:    user.ID,
+		Reason:    strings.TrimSpace(reason),
+		ExpiresAt: time.Now().Add(impersonationTTL),
+	}
+	if err := s.db.CreateImpersonation(ctx, imp); err != nil {
+		s.log(ctx).Error("Failed to create impersonation", zap.String("actor_id", actorID), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	s.log(ctx).Info("Impersonation started", zap.String("impersonation_id", imp.ID.String()),
+		zap.String("actor_id", actorID), zap.String("user_id", userID))
+	s.audit(ctx, actorID, "impersonation.started", userID,
+		fmt.Sprintf("impersonation=%s expires=%s reason=%q", imp.ID, imp.ExpiresAt.UTC().Format(time.RFC3339), imp.Reason))
+	return &domain.ImpersonationGrant{Impersonation: *imp, User: *user}, nil
+}
+
+// GetImpersonation returns an impersonation while it is active
+func (s *APIService) GetImpersonation(ctx context.Context, id string) (*domain.Impersonation, error) {
+	imp, err := s.db.GetImpersonation(ctx, id)
+	if err != nil {
+		if errors.Is(err, domain.ErrImpersonationNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	if !imp.Active(time.Now()) {
+		return nil, domain.ErrImpersonationEnded
+	}
+	return imp, nil
+}
+
+// EndImpersonation ends an impersonation started by actorID before it expires
+func (s *APIService) EndImpersonation(ctx context.Context, id, actorID string) (*domain.Impersonation, error) {
+	now := time.Now()
+	imp, err := s.db.EndImpersonation(ctx, id, actorID, now)
+	if err != nil {
+		if errors.Is(err, domain.ErrImpersonationNotFound) || errors.Is(err, domain.ErrImpersonationEnded) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	if now.After(imp.ExpiresAt) {
+		// Already over; record when it actually stopped being usable
+		now = imp.ExpiresAt
+	}
+	s.log(ctx).Info("Impersonation ended", zap.String("impersonation_id", id), zap.String("actor_id", actorID))
+	s.audit(ctx, actorID, "impersonation.ended", imp.UserID.String(),
+		fmt.Sprintf("impersonation=%s duration=%s", imp.ID, now.Sub(imp.StartedAt).Round(time.Second)))
+	return imp, nil
+}
+
+// endExpiredImpersonations audits the end of impersonations that ran until they expired.
+// Only an explicit end is audited as it happens; expiry is picked up by the retention
+// job, so the audit log shows every impersonation ending either way.
+func (s *APIService) endExpiredImpersonations(ctx context.Context, now time.Time) error {
+	imps, err := s.db.EndExpiredImpersonations(ctx, now)
+	if err != nil {
+		return err
+	}
+	for _, imp := range imps {
+		// The job runs for every tenant; the audit entry belongs to the impersonation's
+		s.audit(tenant.NewContext(ctx, imp.TenantID), imp.ActorID.String(), "impersonation.ended", imp.UserID.String(),
+			fmt.Sprintf("impersonation=%s duration=%s expired", imp.ID, imp.ExpiresAt.Sub(imp.StartedAt).Round(time.Second)))
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// userInviteTTL is how long a mailed invite can be accepted; resending starts it over
+const userInviteTTL = 7 * 24 * time.Hour
+
+// InviteUser creates an inactive account for email with the given role and mails the
+// invitee a token to activate it with a password of their own. Only admins can invite.
+func (s *APIService) InviteUser(ctx context.Context, actorID, email, name string, role domain.Role) (*domain.UserInvite, error) {
+	actor, err := s.requireAdmin(ctx, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if role == domain.RoleUnspecified {
+		role = domain.RoleUser
+	}
+	token, err := mailedToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The account has no password until the invite is accepted, so nobody can log in to it
+	user := &domain.User{
+		ID:    uuid.Must(uuid.NewRandom()),
+		Name:  strings.TrimSpace(name),
+		Email: strings.TrimSpace(email),
+		Role:  role,
+	}
+	invite := &domain.UserInvite{
+		Role:      role,
+		InvitedBy: actor.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(userInviteTTL),
+	}
+	if err := s.db.CreateUserInvite(ctx, user, invite); err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return nil, fmt.Errorf("%w: %s", domain.ErrUserAlreadyExists, user.Email)
+		}
+		s.log(ctx).Error("Failed to create user invite", zap.String("email", user.Email), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	if err := s.sendUserInvite(ctx, invite, token); err != nil {
+		return nil, err
+	}
+
+	msg := "User invited: " + invite.Email
+	if err := s.publishUserEvent(ctx, domain.UserEventCreated, invite.UserID, msg); err != nil {
+		s.log(ctx).Error("Failed to publish to RabbitMQ", zap.Error(err))
+	}
+	if err := s.mongoDB.LogMessage(ctx, invite.UserID.String(), msg); err != nil {
+		s.log(ctx).Error("Failed to log message in MongoDB", zap.Error(err))
+	}
+	s.audit(ctx, actorID, "user.invited", invite.UserID.String(),
+		fmt.Sprintf("invite=%s role=%s", invite.ID, role))
+	return invite, nil
+}
+
+// ListUserInvites returns the invites that are still open, expired ones included
+func (s *APIService) ListUserInvites(ctx context.Context, actorID string) ([]*domain.UserInvite, error) {
+	if _, err := s.requireAdmin(ctx, actorID); err != nil {
+		return nil, err
+	}
+	invites, err := s.db.ListUserInvites(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	return invites, nil
+}
+
+// ResendUserInvite mails an open invite again with a fresh token and expiry; the token
+// of the previous mail stops working
+func (s *APIService) ResendUserInvite(ctx context.Context, actorID, id string) (*domain.UserInvite, error) {
+	if _, err := s.requireAdmin(ctx, actorID); err != nil {
+		return nil, err
+	}
+	token, err := mailedToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	invite, err := s.db.RenewUserInvite(ctx, id, hashToken(token), time.Now().Add(userInviteTTL))
+	if err != nil {
+		if errors.Is(err, domain.ErrUserInviteNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	if err := s.sendUserInvite(ctx, invite, token); err != nil {
+		return nil, err
+	}
+	s.audit(ctx, actorID, "user.invite_resent", invite.UserID.String(),
+		fmt.Sprintf("invite=%s sends=%d", invite.ID, invite.SendCount))
+	return invite, nil
+}
+
+// RevokeUserInvite withdraws an open invite and deletes the pending account
+func (s *APIService) RevokeUserInvite(ctx context.Context, actorID, id string) error {
+	if _, err := s.requireAdmin(ctx, actorID); err != nil {
+		return err
+	}
+	invite, err := s.db.RevokeUserInvite(ctx, id)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserInviteNotFound) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	if err := s.publishUserEvent(ctx, domain.UserEventDeleted, invite.UserID, "user_invite_revoked:"+invite.UserID.String()); err != nil {
+		s.log(ctx).Error("Failed to publish to RabbitMQ", zap.Error(err))
+	}
+	s.audit(ctx, actorID, "user.invite_revoked", invite.UserID.String(), "invite="+invite.ID.String())
+	return nil
+}
+
+// AcceptUserInvite activates an invited account with the invitee's password, and name
+// unless it is empty, and logs the invitee in. The token is checked before the password
+// is hashed, so made-up tokens cannot make the service spend its hashing capacity.
+func (s *APIService) AcceptUserInvite(ctx context.Context, token, password, name string) (*domain.UserAutenticate, error) {
+	if token == "" {
+		return nil, domain.ErrInvalidUserInvite
+	}
+	tokenHash := hashToken(token)
+	if _, err := s.db.GetOpenUserInvite(ctx, tokenHash); err != nil {
+		if errors.Is(err, domain.ErrInvalidUserInvite) {
+			s.log(ctx).Warn("Invalid user invite presented")
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	hashedPassword, err := s.hasher.Hash(ctx, password)
+	if err != nil {
+		s.log(ctx).Error("Failed to hash password", zap.Error(err))
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+	// Accepting checks the invite again, as it may have been revoked or accepted meanwhile
+	invite, err := s.db.AcceptUserInvite(ctx, tokenHash, hashedPassword, name)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidUserInvite) {
+			s.log(ctx).Warn("Invalid user invite presented")
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+
+	user, err := s.db.GetUser(ctx, invite.UserID.String())
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.startSession(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := "User created: " + user.Email
+	if err := s.publishUserEvent(ctx, domain.UserEventStatusChanged, user.ID, msg); err != nil {
+		s.log(ctx).Error("Failed to publish to RabbitMQ", zap.Error(err))
+	}
+	if err := s.mongoDB.LogMessage(ctx, user.ID.String(), msg); err != nil {
+		s.log(ctx).Error("Failed to log message in MongoDB", zap.Error(err))
+	}
+	s.audit(ctx, user.ID.String(), "user.invite_accepted", user.ID.String(), "invite="+invite.ID.String())
+	return &domain.UserAutenticate{
+		User:  *user,
+		Token: session.ID.String(),
+	}, nil
+}
+
+// sendUserInvite hands the invite token to the mailer, which turns it into a link to
+// the shop's accept-invite page
+func (s *APIService) sendUserInvite(ctx context.Context, invite *domain.UserInvite, token string) error {
+	if err := s.rabbitMQ.PublishSecret(ctx, mailExchange, "user_invite:"+invite.Email+":"+token); err != nil {
+		s.log(ctx).Error("Failed to publish user invite", zap.String("invite_id", invite.ID.String()), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// requireAdmin loads the actor and checks that it is an active admin
+func (s *APIService) requireAdmin(ctx context.Context, actorID string) (*domain.User, error) {
+	actor, err := s.db.GetUser(ctx, actorID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, domain.ErrPermissionDenied
+		}
+		return nil, err
+	}
+	if !actor.IsActive || actor.Role != domain.RoleAdmin {
+		s.log(ctx).Warn("Admin action refused", zap.String("actor_id", actorID))
+		return nil, domain.ErrPermissionDenied
+	}
+	return actor, nil
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"go.uber.org/zap"
+)
+
+const (
+	// loginLinkTTL is how long a mailed login link can be redeemed
+	loginLinkTTL = 15 * time.Minute
+
+	// At most loginLinkLimit links are mailed to an address per loginLinkWindow, so the
+	// endpoint cannot be used to flood someone's inbox
+	loginLinkLimit  = 3
+	loginLinkWindow = 15 * time.Minute
+
+	// mailExchange receives the events the mailer sends emails for. It is separate from
+	// user_exchange because its messages carry secrets that must not reach other consumers.
+	mailExchange = "mail_exchange"
+)
+
+// RequestLoginLink mails a passwordless login link to email. The link is bound to
+// deviceSecret, a secret the requesting device keeps and presents again on redemption.
+// Unknown and locked addresses, and addresses over the rate limit, get no mail but the
+// same answer, so the endpoint does not reveal which addresses have accounts.
+func (s *APIService) RequestLoginLink(ctx context.Context, email, deviceSecret string) (time.Time, error) {
+	email = strings.TrimSpace(email)
+	expiresAt := time.Now().Add(loginLinkTTL)
+	if email == "" || deviceSecret == "" {
+		return time.Time{}, fmt.Errorf("%w: email and device are required", domain.ErrInvalidLoginLink)
+	}
+
+	user, err := s.db.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return expiresAt, nil
+		}
+		return time.Time{}, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	if !user.IsActive {
+		return expiresAt, nil
+	}
+
+	token, err := mailedToken(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	link := &domain.LoginLink{
+		UserID:     user.ID,
+		Email:      user.Email,
+		TokenHash:  hashToken(token),
+		DeviceHash: hashToken(deviceSecret),
+		ExpiresAt:  expiresAt,
+	}
+	if err := s.db.CreateLoginLink(ctx, link, loginLinkLimit, time.Now().Add(-loginLinkWindow)); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrTooManyLoginLinks):
+			// Refusing would tell the caller the address has an account
+			s.log(ctx).Warn("Login link rate limit reached", zap.String("user_id", user.ID.String()))
+			return expiresAt, nil
+		case errors.Is(err, domain.ErrUserNotFound):
+			return expiresAt, nil
+		}
+		s.log(ctx).Error("Failed to create login link", zap.String("user_id", user.ID.String()), zap.Error(err))
+		return time.Time{}, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+
+	// The mailer turns the token into a link to the gateway's GET /login/link
+	if err := s.rabbitMQ.PublishSecret(ctx, mailExchange, "login_link:"+user.Email+":"+token); err != nil {
+		s.log(ctx).Error("Failed to publish login link", zap.String("user_id", user.ID.String()), zap.Error(err))
+		return time.Time{}, err
+	}
+	s.audit(ctx, user.ID.String(), "login_link.requested", user.ID.String(), "link="+link.ID.String())
+	return expiresAt, nil
+}
+
+// mailedToken returns the secret of a link mailed to a user, bound to the tenant of ctx.
+// Only its hash is stored, and the hash covers the tenant, so a link cannot be redeemed
+// in another tenant by editing it.
+func mailedToken(ctx context.Context) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	return tenant.BindToken(ctx, token), nil
+}
+
+// RedeemLoginLink logs in with a mailed login link, from the device that requested it
+func (s *APIService) RedeemLoginLink(ctx context.Context, token, deviceSecret string) (*domain.UserAutenticate, error) {
+	if token == "" || deviceSecret == "" {
+		return nil, domain.ErrInvalidLoginLink
+	}
+	link, err := s.db.ConsumeLoginLink(ctx, hashToken(token), hashToken(deviceSecret))
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidLoginLink) {
+			s.log(ctx).Warn("Invalid login link presented")
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+
+	user, err := s.db.GetUser(ctx, link.UserID.String())
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		s.log(ctx).Warn("Login link redeemed for locked account", zap.String("user_id", user.ID.String()))
+		return nil, domain.ErrUserLocked
+	}
+	if user.TwoFactorEnabled {
+		return nil, domain.ErrSecondFactorRequired
+	}
+	session, err := s.startSession(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	s.audit(ctx, user.ID.String(), "login_link.redeemed", user.ID.String(), "link="+link.ID.String())
+	return &domain.UserAutenticate{
+		User:  *user,
+		Token: session.ID.String(),
+	}, nil
+}
@@ -0,0 +1,247 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// authorizationCodeTTL is how long a client has to redeem an authorization code
+const authorizationCodeTTL = time.Minute
+
+// RegisterOIDCClient registers an application with the OpenID Connect provider. Confidential
+// clients get a secret, returned only here; public clients get none and rely on PKCE.
+// Service clients authenticate with their secret alone, so they have no redirect URIs.
+func (s *APIService) RegisterOIDCClient(ctx context.Context, name string, redirectURIs []string, public, service bool) (*domain.OIDCClient, string, error) {
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: client_name is required", domain.ErrInvalidClientMetadata)
+	}
+	switch {
+	case service && public:
+		return nil, "", fmt.Errorf("%w: service clients must authenticate with a secret", domain.ErrInvalidClientMetadata)
+	case service && len(redirectURIs) > 0:
+		return nil, "", fmt.Errorf("%w: service clients take no redirect uris", domain.ErrInvalidRedirectURI)
+	case !service && len(redirectURIs) == 0:
+		return nil, "", fmt.Errorf("%w: at least one redirect uri is required", domain.ErrInvalidRedirectURI)
+	}
+	for _, uri := range redirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			return nil, "", err
+		}
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, "", err
+	}
+	client := &domain.OIDCClient{
+		ID:           id,
+		Name:         name,
+		RedirectURIs: redirectURIs,
+		Public:       public,
+		Service:      service,
+	}
+	var secret string
+	if !public {
+		if secret, err = randomToken(32); err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = hashToken(secret)
+	}
+	if err := s.db.CreateOIDCClient(ctx, client); err != nil {
+		s.log(ctx).Error("Failed to register OIDC client", zap.String("name", name), zap.Error(err))
+		return nil, "", fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	s.audit(ctx, "system", "oidc.client_registered", "",
+		fmt.Sprintf("client=%s name=%q public=%t service=%t", client.ID, name, public, service))
+	return client, secret, nil
+}
+
+// ValidateAuthorizationRequest checks an authorization request before the user is asked to
+// sign in and returns the client making it. ErrClientNotFound and ErrInvalidRedirectURI mean
+// the request must not be redirected back; other errors can be reported to the redirect URI.
+func (s *APIService) ValidateAuthorizationRequest(ctx context.Context, req *domain.AuthorizationRequest) (*domain.OIDCClient, error) {
+	client, err := s.db.GetOIDCClient(ctx, req.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if !client.AllowsRedirect(req.RedirectURI) {
+		return nil, domain.ErrInvalidRedirectURI
+	}
+	if !slices.Contains(req.Scopes(), domain.ScopeOpenID) {
+		return client, fmt.Errorf("%w: scope must include openid", domain.ErrInvalidAuthorization)
+	}
+	// PKCE is required of every client, confidential ones included
+	if req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) != 43 {
+		return client, fmt.Errorf("%w: an S256 code_challenge is required", domain.ErrInvalidAuthorization)
+	}
+	return client, nil
+}
+
+// Authorize signs the user in for a validated authorization request and returns the
+// authorization code to hand to the client
+func (s *APIService) Authorize(ctx context.Context, req *domain.AuthorizationRequest, email, password string) (string, error) {
+	client, err := s.ValidateAuthorizationRequest(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	ctx = tenant.NewContext(ctx, client.TenantID)
+
+	auth, err := s.AuthenticateUser(ctx, &domain.User{Email: email, Password: password})
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) || errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return "", domain.ErrInvalidCredentials
+		}
+		return "", err
+	}
+
+	code, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	err = s.db.CreateAuthorizationCode(ctx, &domain.AuthorizationCode{
+		CodeHash:      hashToken(code),
+		ClientID:      client.ID,
+		UserID:        auth.User.ID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         trimScopes(req.Scope, domain.OIDCScopes),
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      now,
+		ExpiresAt:     now.Add(authorizationCodeTTL),
+	})
+	if err != nil {
+		s.log(ctx).Error("Failed to store authorization code", zap.String("client_id", client.ID), zap.Error(err))
+		return "", fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	return code, nil
+}
+
+// ExchangeAuthorizationCode authenticates the client and redeems an authorization code.
+// Every mismatch with the original request fails with ErrInvalidGrant.
+func (s *APIService) ExchangeAuthorizationCode(ctx context.Context, clientID, clientSecret, code, redirectURI, codeVerifier string) (*domain.OIDCGrant, error) {
+	client, err := s.db.GetOIDCClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, domain.ErrClientNotFound) {
+			return nil, domain.ErrInvalidClient
+		}
+		return nil, err
+	}
+	if !client.Public && subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(hashToken(clientSecret))) != 1 {
+		return nil, domain.ErrInvalidClient
+	}
+	ctx = tenant.NewContext(ctx, client.TenantID)
+
+	authCode, err := s.db.ConsumeAuthorizationCode(ctx, hashToken(code))
+	if err != nil {
+		return nil, err
+	}
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	switch {
+	case authCode.ClientID != client.ID, authCode.RedirectURI != redirectURI:
+		return nil, domain.ErrInvalidGrant
+	case !time.Now().Before(authCode.ExpiresAt):
+		return nil, domain.ErrInvalidGrant
+	case subtle.ConstantTimeCompare([]byte(authCode.CodeChallenge), []byte(base64.RawURLEncoding.EncodeToString(challenge[:]))) != 1:
+		return nil, domain.ErrInvalidGrant
+	}
+
+	user, err := s.db.GetUser(ctx, authCode.UserID.String())
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, domain.ErrInvalidGrant
+		}
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, domain.ErrInvalidGrant
+	}
+	s.audit(ctx, user.ID.String(), "oidc.token_issued", user.ID.String(),
+		fmt.Sprintf("client=%s scope=%q", client.ID, authCode.Scope))
+	return &domain.OIDCGrant{Code: authCode, User: user}, nil
+}
+
+// AuthenticateServiceClient authenticates a service client for the client credentials
+// grant. Clients registered to sign users in fail with ErrUnauthorizedClient.
+func (s *APIService) AuthenticateServiceClient(ctx context.Context, clientID, clientSecret string) (*domain.OIDCClient, error) {
+	client, err := s.db.GetOIDCClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, domain.ErrClientNotFound) {
+			return nil, domain.ErrInvalidClient
+		}
+		return nil, err
+	}
+	if client.Public || subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(hashToken(clientSecret))) != 1 {
+		return nil, domain.ErrInvalidClient
+	}
+	if !client.Service {
+		return nil, domain.ErrUnauthorizedClient
+	}
+	ctx = tenant.NewContext(ctx, client.TenantID)
+	s.audit(ctx, "system", "oidc.service_token_issued", "", fmt.Sprintf("client=%s", client.ID))
+	return client, nil
+}
+
+// validateRedirectURI accepts absolute https URIs, and http ones on loopback hosts for
+// native apps and local development
+func validateRedirectURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" {
+		return fmt.Errorf("%w: %q", domain.ErrInvalidRedirectURI, uri)
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q must use https", domain.ErrInvalidRedirectURI, uri)
+}
+
+// randomToken returns n random bytes, base64url-encoded
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// randomHex returns n random bytes, hex-encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// trimScopes keeps the scopes of scope that are in allowed, in their original order
+func trimScopes(scope string, allowed []string) string {
+	var kept []string
+	for _, s := range strings.Fields(scope) {
+		if slices.Contains(allowed, s) {
+			kept = append(kept, s)
+		}
+	}
+	return strings.Join(kept, " ")
+}
@@ -0,0 +1,257 @@
+package core
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// invitationTTL is how long an organization invitation can be accepted
+const invitationTTL = 7 * 24 * time.Hour
+
+func (s *APIService) CreateOrganization(ctx context.Context, actorID, name string) (*domain.Organization, error) {
+	actor, err := s.db.GetUser(ctx, actorID)
+	if err != nil {
+		return nil, err
+	}
+	org := &domain.Organization{
+		ID:        uuid.Must(uuid.NewRandom()),
+		Name:      name,
+		CreatedBy: actor.ID,
+	}
+	if err := s.db.CreateOrganization(ctx, org); err != nil {
+		s.log(ctx).Error("Failed to create organization", zap.String("actor_id", actorID), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	s.audit(ctx, actorID, "organization.created", actorID, "organization="+org.ID.String())
+	return org, nil
+}
+
+// GetOrganization returns the organization together with the caller's membership
+func (s *APIService) GetOrganization(ctx context.Context, actorID, orgID string) (*domain.Organization, *domain.Membership, error) {
+	membership, err := s.memberOf(ctx, actorID, orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return membership.Organization, membership, nil
+}
+
+func (s *APIService) ListMyOrganizations(ctx context.Context, userID string) ([]*domain.Membership, error) {
+	return s.db.ListUserMemberships(ctx, userID)
+}
+
+func (s *APIService) ListOrganizationMembers(ctx context.Context, actorID, orgID string) ([]*domain.Membership, error) {
+	if _, err := s.memberOf(ctx, actorID, orgID); err != nil {
+		return nil, err
+	}
+	return s.db.ListMembers(ctx, orgID)
+}
+
+func (s *APIService) UpdateOrganizationMember(ctx context.Context, actorID, orgID, userID string, role domain.OrgRole) (*domain.Membership, error) {
+	actor, err := s.memberOf(ctx, actorID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	member, err := s.db.GetMembership(ctx, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canAssign(actor.Role, role) || !canManage(actor.Role, member.Role) {
+		return nil, domain.ErrPermissionDenied
+	}
+	// Demoting the last owner fails with ErrLastOwner
+	if err := s.db.UpdateMemberRole(ctx, orgID, userID, role); err != nil {
+		return nil, err
+	}
+	s.audit(ctx, actorID, "organization.member_role_changed", userID,
+		fmt.Sprintf("organization=%s role=%s previous=%s", orgID, role, member.Role))
+	member.Role = role
+	return member, nil
+}
+
+// RemoveOrganizationMember removes a member; any member may remove themselves to leave
+func (s *APIService) RemoveOrganizationMember(ctx context.Context, actorID, orgID, userID string) error {
+	actor, err := s.memberOf(ctx, actorID, orgID)
+	if err != nil {
+		return err
+	}
+	member, err := s.db.GetMembership(ctx, orgID, userID)
+	if err != nil {
+		return err
+	}
+	if actorID != userID && !canManage(actor.Role, member.Role) {
+		return domain.ErrPermissionDenied
+	}
+	// Removing the last owner fails with ErrLastOwner
+	if err := s.db.RemoveMember(ctx, orgID, userID); err != nil {
+		return err
+	}
+	s.audit(ctx, actorID, "organization.member_removed", userID, "organization="+orgID)
+	return nil
+}
+
+func (s *APIService) InviteOrganizationMember(ctx context.Context, actorID, orgID, email string, role domain.OrgRole) (*domain.OrgInvitation, error) {
+	actor, err := s.memberOf(ctx, actorID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if !canAssign(actor.Role, role) {
+		return nil, domain.ErrPermissionDenied
+	}
+	if invitee, err := s.db.GetUserByEmail(ctx, email); err == nil {
+		if _, err := s.db.GetMembership(ctx, orgID, invitee.ID.String()); err == nil {
+			return nil, domain.ErrAlreadyMember
+		}
+	}
+
+	token, err := mailedToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate invitation token: %w", err)
+	}
+	inv := &domain.OrgInvitation{
+		ID:             uuid.Must(uuid.NewRandom()),
+		OrganizationID: actor.OrganizationID,
+		Email:          email,
+		TokenHash:      hashToken(token),
+		Role:           role,
+		InvitedBy:      actor.UserID,
+		ExpiresAt:      time.Now().Add(invitationTTL),
+	}
+	if err := s.db.CreateInvitation(ctx, inv); err != nil {
+		s.log(ctx).Error("Failed to create invitation", zap.String("organization_id", orgID), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	// The mailer turns the token into a link to the invitation in the shop
+	if err := s.rabbitMQ.PublishSecret(ctx, mailExchange, "org_invitation:"+inv.Email+":"+token); err != nil {
+		s.log(ctx).Error("Failed to publish invitation", zap.String("invitation_id", inv.ID.String()), zap.Error(err))
+		return nil, err
+	}
+	s.audit(ctx, actorID, "organization.member_invited", "",
+		fmt.Sprintf("organization=%s invitation=%s role=%s", orgID, inv.ID, role))
+	return inv, nil
+}
+
+func (s *APIService) ListOrganizationInvitations(ctx context.Context, actorID, orgID string) ([]*domain.OrgInvitation, error) {
+	actor, err := s.memberOf(ctx, actorID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.Role.CanManageMembers() {
+		return nil, domain.ErrPermissionDenied
+	}
+	return s.db.ListPendingInvitations(ctx, orgID)
+}
+
+func (s *APIService) RevokeOrganizationInvitation(ctx context.Context, actorID, invitationID string) error {
+	inv, err := s.db.GetInvitation(ctx, invitationID)
+	if err != nil {
+		return err
+	}
+	actor, err := s.memberOf(ctx, actorID, inv.OrganizationID.String())
+	if err != nil {
+		if errors.Is(err, domain.ErrOrganizationNotFound) {
+			return domain.ErrInvitationNotFound
+		}
+		return err
+	}
+	if !actor.Role.CanManageMembers() {
+		return domain.ErrPermissionDenied
+	}
+	if err := s.db.RevokeInvitation(ctx, invitationID); err != nil {
+		return err
+	}
+	s.audit(ctx, actorID, "organization.invitation_revoked", "",
+		fmt.Sprintf("organization=%s invitation=%s", inv.OrganizationID, invitationID))
+	return nil
+}
+
+// ListMyInvitations returns the pending invitations addressed to the user's email
+func (s *APIService) ListMyInvitations(ctx context.Context, userID string) ([]*domain.OrgInvitation, error) {
+	user, err := s.db.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.db.ListPendingInvitationsForEmail(ctx, user.Email)
+}
+
+// AcceptOrganizationInvitation adds the user to the organization of an invitation
+// addressed to them, given the token mailed with it
+func (s *APIService) AcceptOrganizationInvitation(ctx context.Context, userID, invitationID, token string) (*domain.Membership, error) {
+	user, err := s.db.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	inv, err := s.db.GetInvitation(ctx, invitationID)
+	if err != nil {
+		return nil, err
+	}
+	// Someone else's invitation, or a wrong token, is reported as missing rather than forbidden
+	if !strings.EqualFold(inv.Email, user.Email) || inv.TokenHash == "" ||
+		subtle.ConstantTimeCompare([]byte(inv.TokenHash), []byte(hashToken(token))) != 1 {
+		return nil, domain.ErrInvitationNotFound
+	}
+	if !inv.Pending(time.Now()) {
+		return nil, domain.ErrInvitationExpired
+	}
+	if _, err := s.db.GetMembership(ctx, inv.OrganizationID.String(), userID); err == nil {
+		return nil, domain.ErrAlreadyMember
+	}
+
+	member := &domain.Membership{
+		OrganizationID: inv.OrganizationID,
+		UserID:         user.ID,
+		Role:           inv.Role,
+	}
+	if err := s.db.AcceptInvitation(ctx, inv, member); err != nil {
+		if errors.Is(err, domain.ErrInvitationExpired) {
+			return nil, err
+		}
+		s.log(ctx).Error("Failed to accept invitation", zap.String("invitation_id", invitationID), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	s.audit(ctx, userID, "organization.member_joined", userID,
+		fmt.Sprintf("organization=%s invitation=%s role=%s", inv.OrganizationID, invitationID, inv.Role))
+	return member, nil
+}
+
+// memberOf returns the actor's membership, with its organization. Organizations the actor
+// does not belong to are reported as not found so their existence is not revealed.
+func (s *APIService) memberOf(ctx context.Context, actorID, orgID string) (*domain.Membership, error) {
+	org, err := s.db.GetOrganization(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	membership, err := s.db.GetMembership(ctx, orgID, actorID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotMember) {
+			return nil, domain.ErrOrganizationNotFound
+		}
+		return nil, err
+	}
+	membership.Organization = org
+	return membership, nil
+}
+
+// canAssign reports whether a member with role actor may grant role to someone.
+// Only owners can create other owners.
+func canAssign(actor, role domain.OrgRole) bool {
+	if !actor.CanManageMembers() || !role.Valid() {
+		return false
+	}
+	return role != domain.OrgRoleOwner || actor == domain.OrgRoleOwner
+}
+
+// canManage reports whether a member with role actor may change or remove a member with role target
+func canManage(actor, target domain.OrgRole) bool {
+	if !actor.CanManageMembers() {
+		return false
+	}
+	return target != domain.OrgRoleOwner || actor == domain.OrgRoleOwner
+}
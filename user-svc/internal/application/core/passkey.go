@@ -0,0 +1,360 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// passkeyCeremonyTTL is how long the browser has to answer a passkey challenge
+const passkeyCeremonyTTL = 5 * time.Minute
+
+// EnablePasskeys turns on passkey registration and login for the WebAuthn relying party
+// rpID, normally the registrable domain of the shop. Passkeys are only accepted from
+// pages served from one of origins.
+func (s *APIService) EnablePasskeys(rpID, rpName string, origins []string) error {
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: passkeyCeremonyTTL, TimeoutUVD: passkeyCeremonyTTL}
+	rp, err := webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: rpName,
+		RPOrigins:     origins,
+		Timeouts:      webauthn.TimeoutsConfig{Login: timeout, Registration: timeout},
+	})
+	if err != nil {
+		return fmt.Errorf("webauthn relying party: %w", err)
+	}
+	s.passkeyRP = rp
+	return nil
+}
+
+// passkeyUser presents a user and their passkeys to the WebAuthn library. The user
+// handle stored on authenticators is the user ID, which carries no personal data.
+type passkeyUser struct {
+	user     *domain.User
+	passkeys []*domain.Passkey
+}
+
+func (u *passkeyUser) WebAuthnID() []byte          { return u.user.ID[:] }
+func (u *passkeyUser) WebAuthnName() string        { return u.user.Email }
+func (u *passkeyUser) WebAuthnDisplayName() string { return u.user.Name }
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.passkeys))
+	for _, p := range u.passkeys {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(p.Transports))
+		for _, t := range p.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              p.CredentialID,
+			PublicKey:       p.PublicKey,
+			AttestationType: p.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				UserPresent:    true,
+				UserVerified:   true,
+				BackupEligible: p.BackupEligible,
+				BackupState:    p.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:       p.AAGUID,
+				SignCount:    p.SignCount,
+				CloneWarning: p.CloneWarning,
+			},
+		})
+	}
+	return credentials
+}
+
+// loadPasskeyUser loads a user with their passkeys
+func (s *APIService) loadPasskeyUser(ctx context.Context, userID string) (*passkeyUser, error) {
+	user, err := s.db.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	passkeys, err := s.db.ListPasskeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	return &passkeyUser{user: user, passkeys: passkeys}, nil
+}
+
+// BeginPasskeyRegistration starts registering a new passkey for the user. Passkeys must
+// be discoverable and verify the user, by PIN or biometrics, so that one alone is a
+// complete multi-factor login.
+func (s *APIService) BeginPasskeyRegistration(ctx context.Context, userID string) (*domain.PasskeyChallenge, error) {
+	if s.passkeyRP == nil {
+		return nil, domain.ErrPasskeysDisabled
+	}
+	owner, err := s.loadPasskeyUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	creation, session, err := s.passkeyRP.BeginRegistration(owner,
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			UserVerification:   protocol.VerificationRequired,
+		}),
+		// Keeps the same authenticator from being registered twice
+		webauthn.WithExclusions(webauthn.Credentials(owner.WebAuthnCredentials()).CredentialDescriptors()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("begin passkey registration: %w", err)
+	}
+	return s.startPasskeyCeremony(ctx, domain.PasskeyRegistration, &owner.user.ID, creation, session)
+}
+
+// FinishPasskeyRegistration verifies the browser's answer to a registration challenge
+// and stores the new passkey
+func (s *APIService) FinishPasskeyRegistration(ctx context.Context, userID, ceremonyID, name string, response []byte) (*domain.Passkey, error) {
+	if s.passkeyRP == nil {
+		return nil, domain.ErrPasskeysDisabled
+	}
+	ceremony, challenge, err := s.consumePasskeyCeremony(ctx, ceremonyID, domain.PasskeyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.UserID == nil || ceremony.UserID.String() != userID {
+		return nil, domain.ErrInvalidCeremony
+	}
+	owner, err := s.loadPasskeyUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidPasskey, err)
+	}
+	credential, err := s.passkeyRP.CreateCredential(owner, *challenge, parsed)
+	if err != nil {
+		s.log(ctx).Warn("Passkey registration rejected", zap.String("user_id", userID), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidPasskey, err)
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = fmt.Sprintf("Passkey %d", len(owner.passkeys)+1)
+	}
+	passkey := &domain.Passkey{
+		UserID:          owner.user.ID,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+	for _, t := range credential.Transport {
+		passkey.Transports = append(passkey.Transports, string(t))
+	}
+	if err := s.db.CreatePasskey(ctx, passkey); err != nil {
+		if errors.Is(err, domain.ErrPasskeyExists) {
+			return nil, err
+		}
+		s.log(ctx).Error("Failed to store passkey", zap.String("user_id", userID), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	s.audit(ctx, userID, "passkey.registered", userID, "passkey="+passkey.ID.String())
+	return passkey, nil
+}
+
+// BeginPasskeyLogin starts a login with a passkey. The browser offers the user the
+// passkeys it holds for the shop, so no account needs to be named up front.
+func (s *APIService) BeginPasskeyLogin(ctx context.Context) (*domain.PasskeyChallenge, error) {
+	if s.passkeyRP == nil {
+		return nil, domain.ErrPasskeysDisabled
+	}
+	assertion, session, err := s.passkeyRP.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, fmt.Errorf("begin passkey login: %w", err)
+	}
+	return s.startPasskeyCeremony(ctx, domain.PasskeyLogin, nil, assertion, session)
+}
+
+// FinishPasskeyLogin verifies the browser's answer to a login challenge and logs the
+// passkey's owner in. The passkey verified the user, so this satisfies two-factor
+// authentication on its own. A sign count that did not increase means the passkey's
+// private key was copied; the passkey is then disabled and the login refused.
+func (s *APIService) FinishPasskeyLogin(ctx context.Context, ceremonyID string, response []byte) (*domain.UserAutenticate, error) {
+	if s.passkeyRP == nil {
+		return nil, domain.ErrPasskeysDisabled
+	}
+	_, challenge, err := s.consumePasskeyCeremony(ctx, ceremonyID, domain.PasskeyLogin)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidPasskey, err)
+	}
+	passkey, err := s.db.GetPasskeyByCredentialID(ctx, parsed.RawID)
+	if err != nil {
+		if errors.Is(err, domain.ErrPasskeyNotFound) {
+			return nil, domain.ErrInvalidPasskey
+		}
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	if passkey.CloneWarning {
+		s.log(ctx).Warn("Login attempt with disabled passkey", zap.String("passkey_id", passkey.ID.String()))
+		return nil, domain.ErrPasskeyCloned
+	}
+	owner, err := s.loadPasskeyUser(ctx, passkey.UserID.String())
+	if err != nil {
+		return nil, err
+	}
+	// The library checks that the user handle in the answer is the owner's
+	_, credential, err := s.passkeyRP.ValidatePasskeyLogin(func(_, _ []byte) (webauthn.User, error) {
+		return owner, nil
+	}, *challenge, parsed)
+	if err != nil {
+		s.log(ctx).Warn("Passkey login rejected", zap.String("passkey_id", passkey.ID.String()), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidPasskey, err)
+	}
+
+	now := time.Now()
+	passkey.SignCount = credential.Authenticator.SignCount
+	passkey.BackupState = credential.Flags.BackupState
+	passkey.CloneWarning = credential.Authenticator.CloneWarning
+	passkey.LastUsedAt = &now
+	if err := s.db.UpdatePasskeyUsage(ctx, passkey); err != nil {
+		s.log(ctx).Error("Failed to update passkey", zap.String("passkey_id", passkey.ID.String()), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	userID := owner.user.ID.String()
+	if passkey.CloneWarning {
+		s.log(ctx).Warn("Passkey sign count went backwards, disabling it", zap.String("user_id", userID), zap.String("passkey_id", passkey.ID.String()))
+		s.audit(ctx, "system", "passkey.clone_detected", userID, "passkey="+passkey.ID.String())
+		return nil, domain.ErrPasskeyCloned
+	}
+
+	if !owner.user.IsActive {
+		s.log(ctx).Warn("Passkey login attempt on locked account", zap.String("user_id", userID))
+		return nil, domain.ErrUserLocked
+	}
+	session, err := s.startSession(ctx, owner.user)
+	if err != nil {
+		return nil, err
+	}
+	s.audit(ctx, userID, "passkey.login", userID, "passkey="+passkey.ID.String())
+	return &domain.UserAutenticate{
+		User:  *owner.user,
+		Token: session.ID.String(),
+	}, nil
+}
+
+// ListPasskeys lists the passkeys of the user
+func (s *APIService) ListPasskeys(ctx context.Context, userID string) ([]*domain.Passkey, error) {
+	passkeys, err := s.db.ListPasskeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	return passkeys, nil
+}
+
+// DeletePasskey removes one of the user's passkeys. The last usable passkey cannot be
+// removed while two-factor authentication is on, or the user could no longer log in.
+func (s *APIService) DeletePasskey(ctx context.Context, userID, id string) error {
+	owner, err := s.loadPasskeyUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if owner.user.TwoFactorEnabled && usablePasskeys(owner.passkeys, id) == 0 {
+		return domain.ErrLastPasskey
+	}
+	if err := s.db.DeletePasskey(ctx, userID, id); err != nil {
+		if errors.Is(err, domain.ErrPasskeyNotFound) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	s.audit(ctx, userID, "passkey.deleted", userID, "passkey="+id)
+	return nil
+}
+
+// SetTwoFactor turns two-factor authentication on or off for the user. While it is on,
+// logins that did not use a passkey are refused.
+func (s *APIService) SetTwoFactor(ctx context.Context, userID string, enabled bool) error {
+	if enabled {
+		owner, err := s.loadPasskeyUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if usablePasskeys(owner.passkeys, "") == 0 {
+			return domain.ErrNoPasskeys
+		}
+	}
+	if err := s.db.SetTwoFactor(ctx, userID, enabled); err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	s.audit(ctx, userID, "user.two_factor_changed", userID, fmt.Sprintf("enabled=%t", enabled))
+	return nil
+}
+
+// usablePasskeys counts the passkeys that can still log in, leaving out the one with ID except
+func usablePasskeys(passkeys []*domain.Passkey, except string) int {
+	usable := 0
+	for _, p := range passkeys {
+		if !p.CloneWarning && p.ID.String() != except {
+			usable++
+		}
+	}
+	return usable
+}
+
+// startPasskeyCeremony stores the session of a ceremony and returns the challenge to send to the browser
+func (s *APIService) startPasskeyCeremony(ctx context.Context, kind domain.PasskeyCeremonyKind, userID *uuid.UUID, options interface{}, session *webauthn.SessionData) (*domain.PasskeyChallenge, error) {
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+	sessionJSON, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+	ceremony := &domain.PasskeyCeremony{
+		UserID:    userID,
+		Kind:      kind,
+		Session:   sessionJSON,
+		ExpiresAt: time.Now().Add(passkeyCeremonyTTL),
+	}
+	if err := s.db.CreatePasskeyCeremony(ctx, ceremony); err != nil {
+		s.log(ctx).Error("Failed to store passkey ceremony", zap.String("kind", string(kind)), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	return &domain.PasskeyChallenge{
+		CeremonyID: ceremony.ID,
+		Options:    optionsJSON,
+		ExpiresAt:  ceremony.ExpiresAt,
+	}, nil
+}
+
+// consumePasskeyCeremony takes a ceremony out of storage so its challenge cannot be answered twice
+func (s *APIService) consumePasskeyCeremony(ctx context.Context, id string, kind domain.PasskeyCeremonyKind) (*domain.PasskeyCeremony, *webauthn.SessionData, error) {
+	ceremony, err := s.db.ConsumePasskeyCeremony(ctx, id, kind)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCeremony) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	var session webauthn.SessionData
+	if err := json.Unmarshal(ceremony.Session, &session); err != nil {
+		return nil, nil, fmt.Errorf("passkey ceremony %s: %w", ceremony.ID, err)
+	}
+	return ceremony, &session, nil
+}
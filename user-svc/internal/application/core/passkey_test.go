@@ -0,0 +1,391 @@
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/google/uuid"
+)
+
+const (
+	testRPID   = "shop.example"
+	testOrigin = "https://shop.example"
+)
+
+// Authenticator data flags, https://www.w3.org/TR/webauthn-3/#authdata-flags
+const (
+	flagUserPresent        = 0x01
+	flagUserVerified       = 0x04
+	flagAttestedCredential = 0x40
+)
+
+// softAuthenticator is a software passkey: it answers registration and login challenges
+// like a platform authenticator that verified the user, with one ES256 key pair
+type softAuthenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32 // Reported with the next answer
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	rand.Read(credentialID)
+	return &softAuthenticator{t: t, key: key, credentialID: credentialID, signCount: 1}
+}
+
+// register answers the options of navigator.credentials.create
+func (a *softAuthenticator) register(options []byte) []byte {
+	a.t.Helper()
+	var creation struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			User      struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		} `json:"publicKey"`
+	}
+	a.decode(options, &creation)
+	userHandle, err := base64.RawURLEncoding.DecodeString(creation.PublicKey.User.ID)
+	if err != nil {
+		a.t.Fatalf("user handle: %v", err)
+	}
+	a.userHandle = userHandle
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	authData := a.authData(flagUserPresent | flagUserVerified | flagAttestedCredential)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, publicKey...)
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.encode(map[string]interface{}{
+		"clientDataJSON":    a.clientData("webauthn.create", creation.PublicKey.Challenge),
+		"attestationObject": b64(attestation),
+		"transports":        []string{"internal"},
+	})
+}
+
+// login answers the options of navigator.credentials.get
+func (a *softAuthenticator) login(options []byte) []byte {
+	a.t.Helper()
+	var request struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+		} `json:"publicKey"`
+	}
+	a.decode(options, &request)
+
+	clientData := a.clientData("webauthn.get", request.PublicKey.Challenge)
+	authData := a.authData(flagUserPresent | flagUserVerified)
+	clientDataHash := sha256.Sum256(mustDecode(a.t, clientData))
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.encode(map[string]interface{}{
+		"clientDataJSON":    clientData,
+		"authenticatorData": b64(authData),
+		"signature":         b64(signature),
+		"userHandle":        b64(a.userHandle),
+	})
+}
+
+// authData returns the authenticator data up to the sign count, which it advances
+func (a *softAuthenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	a.signCount++
+	return data
+}
+
+func (a *softAuthenticator) clientData(ceremony, challenge string) string {
+	data, err := json.Marshal(map[string]interface{}{
+		"type":        ceremony,
+		"challenge":   challenge,
+		"origin":      testOrigin,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return b64(data)
+}
+
+func (a *softAuthenticator) decode(options []byte, v interface{}) {
+	a.t.Helper()
+	if err := json.Unmarshal(options, v); err != nil {
+		a.t.Fatalf("challenge options: %v", err)
+	}
+}
+
+// encode wraps an authenticator response in the PublicKeyCredential the browser sends
+func (a *softAuthenticator) encode(response map[string]interface{}) []byte {
+	a.t.Helper()
+	credential, err := json.Marshal(map[string]interface{}{
+		"id":       b64(a.credentialID),
+		"rawId":    b64(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return credential
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func mustDecode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func newPasskeyService(t *testing.T) (*APIService, *fakeDB, *domain.User) {
+	t.Helper()
+	db := newFakeDB()
+	s := newTestService(db)
+	if err := s.EnablePasskeys(testRPID, "Shop", []string{testOrigin}); err != nil {
+		t.Fatal(err)
+	}
+	user, err := s.CreateUser(context.Background(), &domain.User{Name: "Ada", Email: "ada@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, db, user
+}
+
+func registerPasskey(t *testing.T, s *APIService, userID string, authenticator *softAuthenticator) *domain.Passkey {
+	t.Helper()
+	ctx := context.Background()
+	challenge, err := s.BeginPasskeyRegistration(ctx, userID)
+	if err != nil {
+		t.Fatalf("BeginPasskeyRegistration: %v", err)
+	}
+	passkey, err := s.FinishPasskeyRegistration(ctx, userID, challenge.CeremonyID.String(), "Laptop", authenticator.register(challenge.Options))
+	if err != nil {
+		t.Fatalf("FinishPasskeyRegistration: %v", err)
+	}
+	return passkey
+}
+
+func loginWithPasskey(t *testing.T, s *APIService, authenticator *softAuthenticator) (*domain.UserAutenticate, error) {
+	t.Helper()
+	ctx := context.Background()
+	challenge, err := s.BeginPasskeyLogin(ctx)
+	if err != nil {
+		t.Fatalf("BeginPasskeyLogin: %v", err)
+	}
+	return s.FinishPasskeyLogin(ctx, challenge.CeremonyID.String(), authenticator.login(challenge.Options))
+}
+
+func TestPasskeyRegistration(t *testing.T) {
+	s, _, user := newPasskeyService(t)
+	authenticator := newSoftAuthenticator(t)
+
+	passkey := registerPasskey(t, s, user.ID.String(), authenticator)
+	if passkey.UserID != user.ID || passkey.Name != "Laptop" || passkey.SignCount != 1 {
+		t.Errorf("passkey = %+v, want Laptop of %s with sign count 1", passkey, user.ID)
+	}
+	if string(authenticator.userHandle) != string(user.ID[:]) {
+		t.Errorf("user handle = %x, want the user ID", authenticator.userHandle)
+	}
+
+	// The same authenticator is excluded from registering again, and storage refuses it too
+	ctx := context.Background()
+	challenge, err := s.BeginPasskeyRegistration(ctx, user.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var options struct {
+		PublicKey struct {
+			ExcludeCredentials []struct {
+				ID string `json:"id"`
+			} `json:"excludeCredentials"`
+		} `json:"publicKey"`
+	}
+	if err := json.Unmarshal(challenge.Options, &options); err != nil {
+		t.Fatal(err)
+	}
+	if excluded := options.PublicKey.ExcludeCredentials; len(excluded) != 1 || excluded[0].ID != b64(authenticator.credentialID) {
+		t.Errorf("excludeCredentials = %+v, want the registered passkey", excluded)
+	}
+	_, err = s.FinishPasskeyRegistration(ctx, user.ID.String(), challenge.CeremonyID.String(), "", authenticator.register(challenge.Options))
+	if !errors.Is(err, domain.ErrPasskeyExists) {
+		t.Errorf("registering twice = %v, want %v", err, domain.ErrPasskeyExists)
+	}
+}
+
+func TestPasskeyRegistrationRejectsReplayedCeremony(t *testing.T) {
+	s, _, user := newPasskeyService(t)
+	ctx := context.Background()
+	challenge, err := s.BeginPasskeyRegistration(ctx, user.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := newSoftAuthenticator(t).register(challenge.Options)
+
+	other, err := s.CreateUser(ctx, &domain.User{Name: "Grace", Email: "grace@example.com", Password: "battery staple"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.FinishPasskeyRegistration(ctx, other.ID.String(), challenge.CeremonyID.String(), "", response); !errors.Is(err, domain.ErrInvalidCeremony) {
+		t.Errorf("finishing another user's registration = %v, want %v", err, domain.ErrInvalidCeremony)
+	}
+	if _, err := s.FinishPasskeyRegistration(ctx, user.ID.String(), challenge.CeremonyID.String(), "", response); !errors.Is(err, domain.ErrInvalidCeremony) {
+		t.Errorf("finishing a consumed registration = %v, want %v", err, domain.ErrInvalidCeremony)
+	}
+}
+
+func TestPasskeyDiscoverableLogin(t *testing.T) {
+	s, db, user := newPasskeyService(t)
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, s, user.ID.String(), authenticator)
+
+	login, err := loginWithPasskey(t, s, authenticator)
+	if err != nil {
+		t.Fatalf("FinishPasskeyLogin: %v", err)
+	}
+	if login.User.ID != user.ID {
+		t.Errorf("logged in as %s, want %s", login.User.ID, user.ID)
+	}
+	if _, ok := db.sessions[uuid.MustParse(login.Token)]; !ok {
+		t.Errorf("token %s is not a session", login.Token)
+	}
+	passkeys, _ := db.ListPasskeys(context.Background(), user.ID.String())
+	if passkeys[0].SignCount != 2 || passkeys[0].LastUsedAt == nil {
+		t.Errorf("passkey after login = %+v, want sign count 2 and a last use", passkeys[0])
+	}
+
+	// Another key under the registered credential ID does not log in
+	impostor := newSoftAuthenticator(t)
+	impostor.credentialID = authenticator.credentialID
+	impostor.userHandle = authenticator.userHandle
+	impostor.signCount = 10
+	if _, err := loginWithPasskey(t, s, impostor); !errors.Is(err, domain.ErrInvalidPasskey) {
+		t.Errorf("login with another key = %v, want %v", err, domain.ErrInvalidPasskey)
+	}
+}
+
+func TestPasskeyLoginDisablesClonedPasskey(t *testing.T) {
+	s, db, user := newPasskeyService(t)
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, s, user.ID.String(), authenticator)
+
+	authenticator.signCount = 5
+	if _, err := loginWithPasskey(t, s, authenticator); err != nil {
+		t.Fatalf("FinishPasskeyLogin: %v", err)
+	}
+
+	// A copy of the key still counts from where it was copied
+	authenticator.signCount = 3
+	if _, err := loginWithPasskey(t, s, authenticator); !errors.Is(err, domain.ErrPasskeyCloned) {
+		t.Fatalf("login with a sign count gone backwards = %v, want %v", err, domain.ErrPasskeyCloned)
+	}
+	passkeys, _ := db.ListPasskeys(context.Background(), user.ID.String())
+	if !passkeys[0].CloneWarning {
+		t.Error("passkey was not disabled")
+	}
+	if !audited(s, "passkey.clone_detected") {
+		t.Error("no passkey.clone_detected audit entry")
+	}
+
+	// The passkey stays disabled, even for the device with the higher count
+	authenticator.signCount = 10
+	if _, err := loginWithPasskey(t, s, authenticator); !errors.Is(err, domain.ErrPasskeyCloned) {
+		t.Errorf("login with a disabled passkey = %v, want %v", err, domain.ErrPasskeyCloned)
+	}
+}
+
+func TestPasskeyAsSecondFactor(t *testing.T) {
+	s, _, user := newPasskeyService(t)
+	ctx := context.Background()
+	userID := user.ID.String()
+	password := &domain.User{Email: "ada@example.com", Password: "correct horse"}
+
+	if err := s.SetTwoFactor(ctx, userID, true); !errors.Is(err, domain.ErrNoPasskeys) {
+		t.Fatalf("SetTwoFactor without a passkey = %v, want %v", err, domain.ErrNoPasskeys)
+	}
+	authenticator := newSoftAuthenticator(t)
+	passkey := registerPasskey(t, s, userID, authenticator)
+	if err := s.SetTwoFactor(ctx, userID, true); err != nil {
+		t.Fatalf("SetTwoFactor: %v", err)
+	}
+
+	if _, err := s.AuthenticateUser(ctx, password); !errors.Is(err, domain.ErrSecondFactorRequired) {
+		t.Errorf("password login = %v, want %v", err, domain.ErrSecondFactorRequired)
+	}
+	login, err := loginWithPasskey(t, s, authenticator)
+	if err != nil {
+		t.Fatalf("passkey login: %v", err)
+	}
+	if login.User.ID != user.ID {
+		t.Errorf("logged in as %s, want %s", login.User.ID, user.ID)
+	}
+	if err := s.DeletePasskey(ctx, userID, passkey.ID.String()); !errors.Is(err, domain.ErrLastPasskey) {
+		t.Errorf("deleting the last passkey = %v, want %v", err, domain.ErrLastPasskey)
+	}
+
+	if err := s.SetTwoFactor(ctx, userID, false); err != nil {
+		t.Fatalf("SetTwoFactor off: %v", err)
+	}
+	if _, err := s.AuthenticateUser(ctx, password); err != nil {
+		t.Errorf("password login without two-factor: %v", err)
+	}
+}
+
+func audited(s *APIService, action string) bool {
+	mongoDB := s.mongoDB.(*fakeMongoDB)
+	mongoDB.mu.Lock()
+	defer mongoDB.mu.Unlock()
+	for _, entry := range mongoDB.audit {
+		if entry.Action == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,349 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// erasureTimeout bounds a single run of the erasure workflow
+const erasureTimeout = 5 * time.Minute
+
+// ExportMyData assembles every record held about the user into a zip archive
+func (s *APIService) ExportMyData(ctx context.Context, userID string) (*domain.DataExport, error) {
+	user, err := s.db.GetUser(ctx, userID)
+	if err != nil {
+		s.log(ctx).Error("Failed to load user for export", zap.String("id", userID), zap.Error(err))
+		return nil, err
+	}
+	sessions, err := s.db.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	identities, err := s.db.ListExternalIdentities(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	passkeys, err := s.db.ListPasskeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	loginLinks, err := s.db.ListLoginLinks(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	invites, err := s.db.ListInvitesOfUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	emailChanges, err := s.db.ListEmailChanges(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	audit, err := s.mongoDB.ListAudit(ctx, domain.AuditFilter{UserID: userID})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	logs, err := s.mongoDB.ListLogs(ctx, userID, emailsOf(user.Email, emailChanges))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+
+	now := time.Now().UTC()
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", exportProfile{
+			ID:        user.ID.String(),
+			Name:      user.Name,
+			Email:     user.Email,
+			Role:      user.Role.String(),
+			Active:    user.IsActive,
+			CreatedAt: user.CreatedAt,
+			LastLogin: user.LastLogin,
+			TwoFactor: user.TwoFactorEnabled,
+		}},
+		{"sessions.json", sessions},
+		{"identities.json", identities},
+		{"passkeys.json", passkeys},
+		{"login_links.json", loginLinks},
+		{"invites.json", invites},
+		{"email_changes.json", emailChanges},
+		{"audit.json", audit},
+		{"logs.json", logs},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	manifest := exportManifest{UserID: userID, GeneratedAt: now}
+	for _, f := range files {
+		if err := writeJSONFile(zw, f.name, now, f.data); err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, f.name)
+	}
+	if err := writeJSONFile(zw, "manifest.json", now, manifest); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish export archive: %w", err)
+	}
+
+	s.audit(ctx, userID, "user.data_export", userID, "")
+	return &domain.DataExport{
+		Filename:    fmt.Sprintf("user-data-%s-%s.zip", userID, now.Format("20060102")),
+		ContentType: "application/zip",
+		Archive:     buf.Bytes(),
+	}, nil
+}
+
+type exportProfile struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Role      string     `json:"role"`
+	Active    bool       `json:"active"`
+	CreatedAt time.Time  `json:"created_at"`
+	LastLogin *time.Time `json:"last_login,omitempty"`
+	TwoFactor bool       `json:"two_factor_enabled"`
+}
+
+type exportManifest struct {
+	UserID      string    `json:"user_id"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Files       []string  `json:"files"`
+}
+
+func writeJSONFile(zw *zip.Writer, name string, modified time.Time, v interface{}) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("failed to add %s to export archive: %w", name, err)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// RequestErasure records an erasure request and starts working on it in the background.
+// If the user already has an unfinished request, that one is returned instead.
+func (s *APIService) RequestErasure(ctx context.Context, userID, reason string) (*domain.ErasureRequest, error) {
+	user, err := s.db.GetUser(ctx, userID)
+	if err != nil {
+		s.log(ctx).Error("Failed to load user for erasure", zap.String("id", userID), zap.Error(err))
+		return nil, err
+	}
+
+	unfinished, err := s.db.ListUnfinishedErasureRequests(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	for _, req := range unfinished {
+		if req.UserID == user.ID {
+			return req, nil
+		}
+	}
+
+	pseudonym, err := newPseudonym()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate pseudonym: %w", err)
+	}
+	req := &domain.ErasureRequest{
+		ID:        uuid.Must(uuid.NewRandom()),
+		UserID:    user.ID,
+		Email:     user.Email,
+		Pseudonym: pseudonym,
+		Reason:    reason,
+		Status:    domain.ErasureStatusPending,
+	}
+	if err := s.db.CreateErasureRequest(ctx, req); err != nil {
+		s.log(ctx).Error("Failed to create erasure request", zap.String("user_id", userID), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	s.audit(ctx, userID, "user.erasure_requested", userID, reason)
+
+	s.startErasure(*req)
+	return req, nil
+}
+
+func (s *APIService) GetErasureRequest(ctx context.Context, id string) (*domain.ErasureRequest, error) {
+	return s.db.GetErasureRequest(ctx, id)
+}
+
+// ResumeErasures restarts every erasure request that has not completed yet, e.g. after a restart
+func (s *APIService) ResumeErasures(ctx context.Context) error {
+	reqs, err := s.db.ListUnfinishedErasureRequests(ctx)
+	if err != nil {
+		return err
+	}
+	for _, req := range reqs {
+		s.log(ctx).Info("Resuming erasure request", zap.String("id", req.ID.String()), zap.Strings("completed_steps", req.CompletedSteps))
+		s.startErasure(*req)
+	}
+	return nil
+}
+
+func (s *APIService) startErasure(req domain.ErasureRequest) {
+	if _, running := s.erasures.LoadOrStore(req.ID, struct{}{}); running {
+		return
+	}
+	go func() {
+		defer s.erasures.Delete(req.ID)
+		ctx, cancel := context.WithTimeout(tenant.NewContext(context.Background(), req.TenantID), erasureTimeout)
+		defer cancel()
+		s.runErasure(ctx, &req)
+	}()
+}
+
+func (s *APIService) runErasure(ctx context.Context, req *domain.ErasureRequest) {
+	log := s.logger.With(
+		zap.String("erasure_id", req.ID.String()),
+		zap.String("tenant_id", req.TenantID),
+		zap.String("user_id", req.UserID.String()),
+	)
+	// Requests made before pseudonyms were kept on them get theirs now
+	if req.Pseudonym == "" {
+		pseudonym, err := newPseudonym()
+		if err != nil {
+			log.Error("Failed to generate pseudonym", zap.Error(err))
+			return
+		}
+		req.Pseudonym = pseudonym
+	}
+	pseudonym := req.Pseudonym
+
+	req.Status = domain.ErasureStatusInProgress
+	req.Error = ""
+	if err := s.db.UpdateErasureRequest(ctx, req); err != nil {
+		log.Error("Failed to mark erasure in progress", zap.Error(err))
+		return
+	}
+
+	for _, step := range domain.ErasureSteps {
+		if req.StepDone(step) {
+			continue
+		}
+		if err := s.runErasureStep(ctx, req, step, pseudonym); err != nil {
+			log.Error("Erasure step failed", zap.String("step", step), zap.Error(err))
+			req.Status = domain.ErasureStatusFailed
+			req.Error = fmt.Sprintf("%s: %v", step, err)
+			if err := s.db.UpdateErasureRequest(ctx, req); err != nil {
+				log.Error("Failed to record erasure failure", zap.Error(err))
+			}
+			return
+		}
+		req.CompletedSteps = append(req.CompletedSteps, step)
+		if err := s.db.UpdateErasureRequest(ctx, req); err != nil {
+			log.Error("Failed to record erasure progress", zap.String("step", step), zap.Error(err))
+			return
+		}
+		log.Info("Erasure step completed", zap.String("step", step))
+	}
+
+	now := time.Now()
+	req.Status = domain.ErasureStatusCompleted
+	req.Email = ""
+	req.Pseudonym = ""
+	req.CompletedAt = &now
+	if err := s.db.UpdateErasureRequest(ctx, req); err != nil {
+		log.Error("Failed to mark erasure completed", zap.Error(err))
+		return
+	}
+	s.audit(ctx, "system", "user.erasure_completed", pseudonym, "erasure_request="+req.ID.String())
+	log.Info("Erasure completed")
+}
+
+func (s *APIService) runErasureStep(ctx context.Context, req *domain.ErasureRequest, step, pseudonym string) error {
+	userID := req.UserID.String()
+	switch step {
+	case domain.ErasureStepRevokeSessions:
+		_, err := s.db.RevokeSessions(ctx, userID)
+		return err
+	case domain.ErasureStepRevokeAPIKeys:
+		_, err := s.db.RevokeAPIKeys(ctx, userID)
+		return err
+	case domain.ErasureStepUnlinkIdentities:
+		_, err := s.db.DeleteExternalIdentities(ctx, userID)
+		return err
+	case domain.ErasureStepDeletePasskeys:
+		_, err := s.db.DeletePasskeys(ctx, userID)
+		return err
+	case domain.ErasureStepDeleteLoginLinks:
+		_, err := s.db.DeleteLoginLinks(ctx, userID)
+		return err
+	case domain.ErasureStepDeleteUserInvites:
+		_, err := s.db.DeleteInvitesOfUser(ctx, userID)
+		return err
+	case domain.ErasureStepDeleteEmailChanges:
+		_, err := s.db.DeleteEmailChanges(ctx, userID)
+		return err
+	case domain.ErasureStepScrubLogs:
+		changes, err := s.db.ListEmailChanges(ctx, userID)
+		if err != nil {
+			return err
+		}
+		_, err = s.mongoDB.ScrubLogs(ctx, userID, emailsOf(req.Email, changes), pseudonym)
+		return err
+	case domain.ErasureStepPseudonymizeAudit:
+		_, err := s.mongoDB.PseudonymizeAudit(ctx, userID, pseudonym)
+		return err
+	case domain.ErasureStepAnonymizeUser:
+		return s.db.AnonymizeUser(ctx, userID, pseudonym)
+	case domain.ErasureStepPublishEvent:
+		return s.publishUserEvent(ctx, domain.UserEventDeleted, req.UserID, "user_erased:"+userID)
+	default:
+		return fmt.Errorf("unknown erasure step %q", step)
+	}
+}
+
+// emailsOf returns every address the user is known by: current, unless empty, and the
+// addresses of the user's email changes, each once
+func emailsOf(current string, changes []*domain.EmailChange) []string {
+	var emails []string
+	seen := make(map[string]bool)
+	add := func(email string) {
+		if key := strings.ToLower(email); email != "" && !seen[key] {
+			seen[key] = true
+			emails = append(emails, email)
+		}
+	}
+	add(current)
+	for _, change := range changes {
+		add(change.OldEmail)
+		add(change.NewEmail)
+	}
+	return emails
+}
+
+// newPseudonym returns a random pseudonym for an erasure. It is kept on the request so
+// re-running a step yields the same value, and dropped once the request completes, so
+// nothing derives it from the user's ID afterwards.
+func newPseudonym() (string, error) {
+	random, err := randomHex(8)
+	if err != nil {
+		return "", err
+	}
+	return "erased-" + random, nil
+}
+
+// audit records an audit entry; failures are logged but never fail the caller
+func (s *APIService) audit(ctx context.Context, actor, action, userID, details string) {
+	err := s.mongoDB.RecordAudit(ctx, &domain.AuditEntry{
+		Actor:   actor,
+		Action:  action,
+		UserID:  userID,
+		Details: details,
+	})
+	if err != nil {
+		s.log(ctx).Error("Failed to record audit entry", zap.String("action", action), zap.Error(err))
+	}
+}
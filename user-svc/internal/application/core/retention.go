@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/asadlive84/shopper/user-svc/internal/monitoring"
+	"go.uber.org/zap"
+)
+
+type retentionTarget struct {
+	name  string
+	keep  time.Duration
+	purge func(ctx context.Context, before time.Time) (int64, error)
+}
+
+func (s *APIService) retentionTargets(policy domain.RetentionPolicy) []retentionTarget {
+	return []retentionTarget{
+		{name: "sessions", keep: policy.Sessions, purge: s.db.PurgeSessions},
+		{name: "deleted_users", keep: policy.DeletedUsers, purge: s.db.PurgeDeletedUsers},
+		{name: "invitations", keep: policy.Invitations, purge: s.db.PurgeInvitations},
+		{name: "api_keys", keep: policy.APIKeys, purge: s.db.PurgeAPIKeys},
+		{name: "authorization_codes", keep: policy.AuthorizationCodes, purge: s.db.PurgeAuthorizationCodes},
+		{name: "login_links", keep: policy.LoginLinks, purge: s.db.PurgeLoginLinks},
+		{name: "passkey_ceremonies", keep: policy.PasskeyCeremonies, purge: s.db.PurgePasskeyCeremonies},
+		{name: "user_invites", keep: policy.UserInvites, purge: s.db.PurgeUserInvites},
+		{name: "impersonations", keep: policy.Impersonations, purge: s.db.PurgeImpersonations},
+		{name: "email_changes", keep: policy.EmailChanges, purge: s.db.PurgeEmailChanges},
+		{name: "idempotency_keys", keep: policy.IdempotencyKeys, purge: s.db.PurgeIdempotentCalls},
+		{name: "user_events", keep: policy.UserEvents, purge: s.db.PurgeUserEvents},
+	}
+}
+
+// RunRetention purges every Postgres artifact older than the policy allows, after
+// recording the end of impersonations that expired since the last run.
+// MongoDB collections are not handled here; their TTL indexes expire documents on their own.
+func (s *APIService) RunRetention(ctx context.Context, policy domain.RetentionPolicy) (*domain.RetentionResult, error) {
+	start := time.Now()
+	result := &domain.RetentionResult{Purged: map[string]int64{}}
+
+	var errs []error
+	if err := s.endExpiredImpersonations(ctx, start); err != nil {
+		s.log(ctx).Error("Failed to end expired impersonations", zap.Error(err))
+		errs = append(errs, fmt.Errorf("impersonations: %w", err))
+	}
+	for _, target := range s.retentionTargets(policy) {
+		if target.keep <= 0 {
+			continue
+		}
+		purged, err := target.purge(ctx, start.Add(-target.keep))
+		if err != nil {
+			s.log(ctx).Error("Retention purge failed", zap.String("target", target.name), zap.Error(err))
+			errs = append(errs, fmt.Errorf("%s: %w", target.name, err))
+			continue
+		}
+		result.Purged[target.name] = purged
+		monitoring.RetentionPurged.WithLabelValues(target.name).Add(float64(purged))
+	}
+	result.Duration = time.Since(start)
+	monitoring.RetentionRunDuration.Observe(result.Duration.Seconds())
+
+	err := errors.Join(errs...)
+	details := formatPurged(result.Purged)
+	if err != nil {
+		monitoring.RetentionRuns.WithLabelValues("error").Inc()
+		details += "; errors: " + err.Error()
+	} else {
+		monitoring.RetentionRuns.WithLabelValues("success").Inc()
+		monitoring.RetentionLastSuccess.SetToCurrentTime()
+	}
+	s.audit(ctx, "system", "retention.run", "", details)
+	s.log(ctx).Info("Retention run finished",
+		zap.Any("purged", result.Purged),
+		zap.Duration("duration", result.Duration),
+		zap.Error(err),
+	)
+	return result, err
+}
+
+// RunRetentionJob runs the retention job every interval until ctx is cancelled
+func (s *APIService) RunRetentionJob(ctx context.Context, interval time.Duration, policy domain.RetentionPolicy) {
+	if interval <= 0 {
+		s.log(ctx).Info("Retention job disabled")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.RunRetention(ctx, policy)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func formatPurged(purged map[string]int64) string {
+	names := make([]string, 0, len(purged))
+	for name := range purged {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, purged[name]))
+	}
+	return "purged " + strings.Join(parts, " ")
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// userEventPollInterval is how often a watch looks for events recorded by other replicas;
+	// events recorded by this one wake it up right away
+	userEventPollInterval = time.Second
+	// userEventBatch is how many events a watch reads at a time
+	userEventBatch = 100
+)
+
+// eventSignal wakes up every goroutine waiting for the next user event
+type eventSignal struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+// wait returns a channel that is closed by the next call to notify
+func (e *eventSignal) wait() <-chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ch == nil {
+		e.ch = make(chan struct{})
+	}
+	return e.ch
+}
+
+func (e *eventSignal) notify() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ch != nil {
+		close(e.ch)
+		e.ch = nil
+	}
+}
+
+// publishUserEvent records a change to a user for WatchUsers and publishes msg, the
+// message announcing it, on the user exchange. Failing to record the event is logged but
+// does not stop the message from going out.
+func (s *APIService) publishUserEvent(ctx context.Context, eventType domain.UserEventType, userID uuid.UUID, msg string) error {
+	event := &domain.UserEvent{Type: eventType, UserID: userID}
+	if err := s.db.AppendUserEvent(ctx, event); err != nil {
+		s.log(ctx).Error("Failed to record user event", zap.String("type", string(eventType)), zap.String("user_id", userID.String()), zap.Error(err))
+	} else {
+		s.userEvents.notify()
+	}
+	return s.rabbitMQ.Publish(ctx, "user_exchange", msg)
+}
+
+// WatchUsers calls send with every user event matching filter, oldest first, until ctx is
+// cancelled or send fails. Events after filter.After are replayed first; resume is false
+// when the watch starts with the events recorded from now on instead. A resume point
+// whose events were already purged is refused with ErrResumeTokenExpired.
+func (s *APIService) WatchUsers(ctx context.Context, filter domain.UserEventFilter, resume bool, send func(*domain.UserEvent) error) error {
+	oldest, newest, err := s.db.UserEventBounds(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+	}
+	if !resume {
+		filter.After = newest
+	} else if oldest > filter.After+1 {
+		return domain.ErrResumeTokenExpired
+	}
+
+	poll := time.NewTicker(userEventPollInterval)
+	defer poll.Stop()
+	for {
+		// Taken before reading, so an event recorded meanwhile still wakes the watch
+		wake := s.userEvents.wait()
+		events, err := s.db.ListUserEvents(ctx, filter, userEventBatch)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			s.log(ctx).Error("Failed to list user events", zap.Error(err))
+			return fmt.Errorf("%w: %v", domain.ErrDatabaseError, err)
+		}
+		// Events commit in sequence order, so nothing can turn up behind filter.After later
+		for _, event := range events {
+			if err := send(event); err != nil {
+				return err
+			}
+			filter.After = event.Sequence
+		}
+		if len(events) == userEventBatch {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.watchesDone:
+			return domain.ErrWatchClosed
+		case <-wake:
+		case <-poll.C:
+		}
+	}
+}
+
+// CloseWatches ends every running and future watch with ErrWatchClosed, so a graceful
+// stop of the gRPC server does not wait for them
+func (s *APIService) CloseWatches() {
+	s.closeOnce.Do(func() { close(s.watchesDone) })
+}
@@ -0,0 +1,190 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/monitoring"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Probe checks that one dependency of the service is usable
+type Probe struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Checker probes the dependencies of the service periodically. The service is ready while
+// every probe passes and it is not shutting down; this is reported through the standard
+// grpc.health.v1 service and the HTTP /healthz and /readyz endpoints.
+type Checker struct {
+	server   *health.Server
+	services []string // gRPC services whose status follows readiness; "" is the server as a whole
+	probes   []Probe
+	interval time.Duration
+	timeout  time.Duration
+	logger   *zap.Logger
+
+	mu       sync.RWMutex
+	results  map[string]error // Outcome of the last probe of each dependency
+	checked  bool             // Whether the probes ran at least once
+	draining bool             // Whether Shutdown was called
+}
+
+// NewChecker returns a Checker running probes every interval, each limited to timeout.
+// The given gRPC services, and the server as a whole, start out NOT_SERVING.
+func NewChecker(probes []Probe, interval, timeout time.Duration, logger *zap.Logger, services ...string) *Checker {
+	c := &Checker{
+		server:   health.NewServer(),
+		services: append([]string{""}, services...),
+		probes:   probes,
+		interval: interval,
+		timeout:  timeout,
+		logger:   logger,
+		results:  map[string]error{},
+	}
+	c.publish(false)
+	return c
+}
+
+// Register adds the grpc.health.v1 service to s
+func (c *Checker) Register(s *grpc.Server) {
+	healthpb.RegisterHealthServer(s, c.server)
+}
+
+// Run probes the dependencies right away and then every interval until ctx is cancelled
+func (c *Checker) Run(ctx context.Context) {
+	c.check(ctx)
+	if c.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.check(ctx)
+		}
+	}
+}
+
+func (c *Checker) check(ctx context.Context) {
+	results := make(map[string]error, len(c.probes))
+	for _, probe := range c.probes {
+		probeCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		err := probe.Check(probeCtx)
+		cancel()
+		results[probe.Name] = err
+		if err != nil {
+			monitoring.DependencyUp.WithLabelValues(probe.Name).Set(0)
+		} else {
+			monitoring.DependencyUp.WithLabelValues(probe.Name).Set(1)
+		}
+	}
+
+	c.mu.Lock()
+	for name, err := range results {
+		previous := c.results[name]
+		switch {
+		case err != nil && previous == nil:
+			c.logger.Warn("Dependency health probe failed", zap.String("dependency", name), zap.Error(err))
+		case err == nil && previous != nil:
+			c.logger.Info("Dependency healthy again", zap.String("dependency", name))
+		}
+	}
+	c.results = results
+	c.checked = true
+	ready := c.readyLocked()
+	c.mu.Unlock()
+
+	c.publish(ready)
+}
+
+// publish sets the serving status of every service. Once the health server was shut
+// down it ignores updates, so Shutdown cannot be undone by a late probe.
+func (c *Checker) publish(ready bool) {
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if ready {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	for _, service := range c.services {
+		c.server.SetServingStatus(service, status)
+	}
+}
+
+func (c *Checker) readyLocked() bool {
+	if c.draining || !c.checked {
+		return false
+	}
+	for _, err := range c.results {
+		if err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Ready reports whether the service should receive traffic
+func (c *Checker) Ready() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.readyLocked()
+}
+
+// Shutdown marks the service NOT_SERVING for good, so clients and load balancers stop
+// sending new calls before the gRPC server drains the ones in flight
+func (c *Checker) Shutdown() {
+	c.mu.Lock()
+	c.draining = true
+	c.mu.Unlock()
+	c.server.Shutdown()
+}
+
+// LivenessHandler serves /healthz: the process is up and able to answer
+func (c *Checker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok"})
+	})
+}
+
+// ReadinessHandler serves /readyz: 200 while the service is ready, 503 otherwise, with
+// the outcome of the last probe of every dependency
+func (c *Checker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.mu.RLock()
+		checks := make(map[string]string, len(c.results))
+		for name, err := range c.results {
+			checks[name] = "ok"
+			if err != nil {
+				checks[name] = err.Error()
+			}
+		}
+		ready, draining := c.readyLocked(), c.draining
+		c.mu.RUnlock()
+
+		body := map[string]interface{}{"status": "ready", "checks": checks}
+		code := http.StatusOK
+		switch {
+		case draining:
+			body["status"], code = "shutting down", http.StatusServiceUnavailable
+		case !ready:
+			body["status"], code = "not ready", http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, body)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
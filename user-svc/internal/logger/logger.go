@@ -2,15 +2,25 @@ package logger
 
 import (
 	"context"
+	"runtime/debug"
 	"time"
 
 	// "github.com/asadlive84/shopper/user-svc/internal/logger"
+	"github.com/asadlive84/shopper/user-svc/internal/monitoring"
+	"github.com/asadlive84/shopper/user-svc/internal/requestid"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 )
 
-func NewLogger() *zap.Logger {
-	logger, _ := zap.NewProduction()
+// NewLogger returns a production logger logging at level, which can be changed while
+// the service runs
+func NewLogger(level zap.AtomicLevel) *zap.Logger {
+	cfg := zap.NewProductionConfig()
+	cfg.Level = level
+	logger, _ := cfg.Build()
 	return logger
 }
 
@@ -31,16 +41,87 @@ func LoggingInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
 		if err != nil {
 			logger.Error("gRPC request failed",
 				zap.String("method", method),
+				zap.String("tenant_id", tenant.FromContext(ctx)),
+				zap.String("request_id", requestid.FromContext(ctx)),
 				zap.Duration("latency", latency),
 				zap.Error(err),
 			)
 		} else {
 			logger.Info("gRPC request succeeded",
 				zap.String("method", method),
+				zap.String("tenant_id", tenant.FromContext(ctx)),
+				zap.String("request_id", requestid.FromContext(ctx)),
 				zap.Duration("latency", latency),
 			)
 		}
 
 		return resp, err
 	}
-}
\ No newline at end of file
+}
+
+// LoggingStreamInterceptor logs gRPC streams once they end, with how long they were open
+// and how many messages went each way. Streams the client cancelled are not failures.
+func LoggingStreamInterceptor(logger *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		stream := monitoring.WrapServerStream(ss, ss.Context())
+		err := handler(srv, stream)
+
+		fields := []zap.Field{
+			zap.String("method", info.FullMethod),
+			zap.String("tenant_id", tenant.FromContext(stream.Context())),
+			zap.String("request_id", requestid.FromContext(stream.Context())),
+			zap.Duration("duration", time.Since(start)),
+			zap.Int("messages_sent", stream.Sent()),
+			zap.Int("messages_received", stream.Received()),
+		}
+		switch monitoring.StreamStatus(err) {
+		case "success":
+			logger.Info("gRPC stream finished", fields...)
+		case "cancelled":
+			logger.Info("gRPC stream cancelled by client", fields...)
+		default:
+			logger.Error("gRPC stream failed", append(fields, zap.Error(err))...)
+		}
+		return err
+	}
+}
+
+// RecoveryInterceptor turns a panic in a later interceptor or the handler into an
+// Internal error, logging it with its stack trace instead of crashing the service
+func RecoveryInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ctx, logger, info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamInterceptor turns a panic in a later interceptor or the stream handler
+// into an Internal error, logging it with its stack trace instead of crashing the service
+func RecoveryStreamInterceptor(logger *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ss.Context(), logger, info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// recovered logs the panic r raised while serving method and returns the error for the client
+func recovered(ctx context.Context, logger *zap.Logger, method string, r interface{}) error {
+	logger.Error("Panic while serving gRPC request",
+		zap.String("method", method),
+		zap.String("tenant_id", tenant.FromContext(ctx)),
+		zap.String("request_id", requestid.FromContext(ctx)),
+		zap.Any("panic", r),
+		zap.ByteString("stack", debug.Stack()),
+	)
+	monitoring.PanicsRecovered.WithLabelValues(method).Inc()
+	return status.Errorf(codes.Internal, "Internal server error (request %s)", requestid.FromContext(ctx))
+}
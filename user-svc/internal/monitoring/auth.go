@@ -1,54 +1,64 @@
 package monitoring
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"net/http"
-	"net/url"
+	"strings"
 
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"github.com/golang-jwt/jwt/v5"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
-type HydraClient struct {
-	httpClient *http.Client
-	adminURL   string
-}
+// tenantClaim names the tenant a token issued by user-svc is good for
+const tenantClaim = "tid"
 
-func NewHydraClient() *HydraClient {
-	return &HydraClient{
-		httpClient: &http.Client{},
-		adminURL:   "http://127.0.0.1:4445",
-	}
+// TokenVerifier checks the signature and validity of an access token and returns its claims
+type TokenVerifier interface {
+	Verify(token string, options ...jwt.ParserOption) (jwt.MapClaims, error)
 }
 
-func AuthInterceptor(hydra *HydraClient) grpc.UnaryServerInterceptor {
+// AuthInterceptor refuses calls without a valid bearer token. Options such as the issuer
+// and audience the token must have are passed on to the verifier.
+func AuthInterceptor(verifier TokenVerifier, options ...jwt.ParserOption) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok || len(md["authorization"]) == 0 {
-			return nil, status.Error(codes.Unauthenticated, "No token provided")
+		if err := authenticate(ctx, verifier, options); err != nil {
+			return nil, err
 		}
-		token := md["authorization"][0][7:] // Remove "Bearer " prefix
-		data := url.Values{}
-		data.Set("token", token)
-		reqIntrospect, _ := http.NewRequest("POST", hydra.adminURL+"/admin/oauth2/introspect", bytes.NewBufferString(data.Encode()))
-		reqIntrospect.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-		resp, err := hydra.httpClient.Do(reqIntrospect)
-		if err != nil {
-			return nil, status.Error(codes.Unauthenticated, "Failed to introspect token")
-		}
-		defer resp.Body.Close()
+		return handler(ctx, req)
+	}
+}
 
-		var introspectResp struct {
-			Active bool `json:"active"`
+// AuthStreamInterceptor is the stream equivalent of AuthInterceptor; the token is checked
+// once, when the stream opens
+func AuthStreamInterceptor(verifier TokenVerifier, options ...jwt.ParserOption) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := authenticate(ss.Context(), verifier, options); err != nil {
+			return err
 		}
-		json.NewDecoder(resp.Body).Decode(&introspectResp)
-		if !introspectResp.Active {
-			return nil, status.Error(codes.Unauthenticated, "Invalid token")
-		}
-		return handler(ctx, req)
+		return handler(srv, ss)
 	}
-}
\ No newline at end of file
+}
+
+// authenticate verifies the bearer token in the metadata of ctx. A token naming a tenant
+// is only good for calls made in that tenant.
+func authenticate(ctx context.Context, verifier TokenVerifier, options []jwt.ParserOption) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md["authorization"]) == 0 {
+		return status.Error(codes.Unauthenticated, "No token provided")
+	}
+	token, ok := strings.CutPrefix(md["authorization"][0], "Bearer ")
+	if !ok {
+		return status.Error(codes.Unauthenticated, "No token provided")
+	}
+	claims, err := verifier.Verify(token, append([]jwt.ParserOption{jwt.WithExpirationRequired()}, options...)...)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, "Invalid token")
+	}
+	if id, ok := claims[tenantClaim].(string); ok && id != tenant.FromContext(ctx) {
+		return status.Error(codes.PermissionDenied, "Token is not valid for this tenant")
+	}
+	return nil
+}
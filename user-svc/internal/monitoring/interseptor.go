@@ -5,11 +5,13 @@ import (
 
 	"context"
 
-	"go.opentelemetry.io/otel"
+	"github.com/asadlive84/shopper/user-svc/internal/requestid"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
 )
 
 // PrometheusInterceptor is a unary interceptor for monitoring gRPC requests
@@ -37,29 +39,84 @@ func PrometheusInterceptor() grpc.UnaryServerInterceptor {
 	}
 }
 
-const tracerName = "user-service"
-
-// TracingInterceptor adds tracing to gRPC requests
+// TracingInterceptor annotates the span the otelgrpc stats handler started for a call
+// with its tenant and request ID, and records the error the call failed with. It must run
+// after the tenant and request ID interceptors.
 func TracingInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-		// ট্রেসার পাওয়া
-		tracer := otel.Tracer(tracerName)
-
-		// কনটেক্সট থেকে প্যারেন্ট স্প্যান পাওয়া, নতুন স্প্যান শুরু
-		ctx, span := tracer.Start(ctx, info.FullMethod, trace.WithAttributes(
-			attribute.String("grpc.method", info.FullMethod),
-		))
-		defer span.End()
+		span := trace.SpanFromContext(ctx)
+		span.SetAttributes(callAttributes(ctx)...)
 
-		// হ্যান্ডলার কল
 		resp, err = handler(ctx, req)
-
-		// এরর থাকলে স্প্যানে রেকর্ড
 		if err != nil {
 			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
 		}
-
 		return resp, err
 	}
 }
+
+// callAttributes returns the span attributes of the tenant and request ID of a call
+func callAttributes(ctx context.Context) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.String("tenant_id", tenant.FromContext(ctx))}
+	if id := requestid.FromContext(ctx); id != "" {
+		attrs = append(attrs, attribute.String("request_id", id))
+	}
+	return attrs
+}
+
+// PrometheusStreamInterceptor is the stream counterpart of PrometheusInterceptor. Besides
+// the request count it records how long streams stay open, how many are open and how
+// many messages go through them.
+func PrometheusStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		method := info.FullMethod
+		StreamsOpen.WithLabelValues(method).Inc()
+		defer StreamsOpen.WithLabelValues(method).Dec()
+
+		sent := StreamMessages.WithLabelValues(method, "sent")
+		received := StreamMessages.WithLabelValues(method, "received")
+		err := handler(srv, WrapServerStream(ss, ss.Context()).
+			OnSend(func(int) { sent.Inc() }).
+			OnRecv(func(int) { received.Inc() }))
+
+		RequestCounter.WithLabelValues(method, method, StreamStatus(err)).Inc()
+		StreamDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// StreamStatus labels how a stream ended: "success", "cancelled" when the client went
+// away, which is how most long-lived streams end, or "error"
+func StreamStatus(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case grpcstatus.Code(err) == grpccodes.Canceled:
+		return "cancelled"
+	default:
+		return "error"
+	}
+}
+
+// TracingStreamInterceptor is the stream counterpart of TracingInterceptor. The stats
+// handler's span covers the whole stream; it also gets the number of messages sent and
+// received.
+func TracingStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		span := trace.SpanFromContext(ss.Context())
+		span.SetAttributes(callAttributes(ss.Context())...)
+
+		stream := WrapServerStream(ss, ss.Context())
+		err := handler(srv, stream)
+
+		span.SetAttributes(
+			attribute.Int("grpc.messages_sent", stream.Sent()),
+			attribute.Int("grpc.messages_received", stream.Received()),
+		)
+		if err != nil && grpcstatus.Code(err) != grpccodes.Canceled {
+			span.RecordError(err)
+		}
+		return err
+	}
+}
@@ -21,4 +21,153 @@ var (
 		},
 		[]string{"method", "endpoint"},
 	)
-)
\ No newline at end of file
+	RetentionPurged = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "user_service_retention_purged_total",
+			Help: "Number of records removed by the retention job",
+		},
+		[]string{"target"},
+	)
+	RetentionRuns = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "user_service_retention_runs_total",
+			Help: "Number of retention job runs",
+		},
+		[]string{"status"},
+	)
+	RetentionRunDuration = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "user_service_retention_run_duration_seconds",
+			Help:    "Duration of retention job runs in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+	RetentionLastSuccess = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "user_service_retention_last_success_timestamp_seconds",
+			Help: "Unix time of the last retention run that completed without errors",
+		},
+	)
+	IdempotentCalls = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "user_service_idempotent_calls_total",
+			Help: "Number of calls made with an idempotency key, by method and outcome",
+		},
+		[]string{"method", "outcome"},
+	)
+	StreamMessages = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "user_service_stream_messages_total",
+			Help: "Number of messages sent or received on gRPC streams",
+		},
+		[]string{"method", "direction"},
+	)
+	StreamDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "user_service_stream_duration_seconds",
+			Help:    "How long gRPC streams stayed open in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.1, 4, 10), // 100ms to about 7h
+		},
+		[]string{"method"},
+	)
+	StreamsOpen = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "user_service_streams_open",
+			Help: "Number of gRPC streams currently open",
+		},
+		[]string{"method"},
+	)
+	DependencyUp = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "user_service_dependency_up",
+			Help: "Whether the last health probe of a dependency succeeded (1) or failed (0)",
+		},
+		[]string{"dependency"},
+	)
+	PanicsRecovered = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "user_service_panics_recovered_total",
+			Help: "Number of panics recovered from, by gRPC method or queue",
+		},
+		[]string{"source"},
+	)
+	RateLimited = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "user_service_rate_limited_total",
+			Help: "Number of calls rejected by the rate limiter, by the limit hit (method, caller or overload)",
+		},
+		[]string{"method", "reason"},
+	)
+	RateLimitTokens = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "user_service_rate_limit_tokens",
+			Help: "Tokens left in the bucket shared by all callers of a method",
+		},
+		[]string{"method"},
+	)
+	RateLimitCallers = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "user_service_rate_limit_callers",
+			Help: "Number of callers the rate limiter currently keeps a bucket for",
+		},
+	)
+	LoadShedLimit = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "user_service_load_shed_limit",
+			Help: "Number of calls allowed in flight before new ones are shed",
+		},
+	)
+	LoadShedInFlight = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "user_service_load_shed_in_flight",
+			Help: "Number of calls in flight counted by the load shedder",
+		},
+	)
+	LoadShedLatency = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "user_service_load_shed_latency_seconds",
+			Help: "Moving average of call latency the load shedder adapts its limit to",
+		},
+	)
+	PasswordHashQueueDepth = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "user_service_password_hash_queue_depth",
+			Help: "Number of password hashing operations waiting for a worker",
+		},
+	)
+	PasswordHashBusyWorkers = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "user_service_password_hash_busy_workers",
+			Help: "Number of password hashing workers currently hashing",
+		},
+	)
+	PasswordHashWait = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "user_service_password_hash_wait_seconds",
+			Help:    "How long password hashing operations waited in the queue in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 14), // 1ms to about 8s
+		},
+		[]string{"operation"},
+	)
+	PasswordHashDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "user_service_password_hash_duration_seconds",
+			Help:    "How long password hashing operations took once started in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 10), // 10ms to about 5s
+		},
+		[]string{"operation"},
+	)
+	PasswordHashRejected = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "user_service_password_hash_rejected_total",
+			Help: "Number of password hashing operations not run, because the queue was full or the caller gave up",
+		},
+		[]string{"operation", "reason"},
+	)
+	TLSCertificateExpiry = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "user_service_tls_certificate_expiry_timestamp_seconds",
+			Help: "When the gRPC server certificate loaded last expires, as a Unix timestamp",
+		},
+	)
+)
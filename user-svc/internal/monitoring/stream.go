@@ -0,0 +1,73 @@
+package monitoring
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// ServerStream wraps a grpc.ServerStream for stream interceptors. It serves ctx in place
+// of the stream's own context, counts the messages going through and calls onSend and
+// onRecv, when set, for each of them. A stream sends from one goroutine and receives from
+// at most one other, so each counter is only written by one goroutine.
+type ServerStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	onSend   func(n int)
+	onRecv   func(n int)
+	sent     int
+	received int
+}
+
+// WrapServerStream returns ss serving ctx as its context
+func WrapServerStream(ss grpc.ServerStream, ctx context.Context) *ServerStream {
+	return &ServerStream{ServerStream: ss, ctx: ctx}
+}
+
+// OnSend sets fn to be called with the running count after every message sent
+func (s *ServerStream) OnSend(fn func(n int)) *ServerStream {
+	s.onSend = fn
+	return s
+}
+
+// OnRecv sets fn to be called with the running count after every message received
+func (s *ServerStream) OnRecv(fn func(n int)) *ServerStream {
+	s.onRecv = fn
+	return s
+}
+
+func (s *ServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *ServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+		if s.onSend != nil {
+			s.onSend(s.sent)
+		}
+	}
+	return err
+}
+
+func (s *ServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received++
+		if s.onRecv != nil {
+			s.onRecv(s.received)
+		}
+	}
+	return err
+}
+
+// Sent returns how many messages were sent so far
+func (s *ServerStream) Sent() int {
+	return s.sent
+}
+
+// Received returns how many messages were received so far
+func (s *ServerStream) Received() int {
+	return s.received
+}
@@ -0,0 +1,110 @@
+package mtls
+
+import (
+	"context"
+	"crypto/x509"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Authorizer lets through the calls of peers whose verified client certificate carries
+// one of the allowed subject alternative names. A nil Authorizer, used when mTLS is off,
+// lets every call through.
+type Authorizer struct {
+	allowed []string
+}
+
+// NewAuthorizer accepts DNS names, URIs such as SPIFFE IDs, email addresses and IP
+// addresses. A DNS name starting with "*." matches one label in its place. An empty list
+// lets through any peer whose certificate was issued by the CA.
+func NewAuthorizer(allowed []string) *Authorizer {
+	return &Authorizer{allowed: allowed}
+}
+
+// UnaryServerInterceptor refuses calls from peers that are not authorized
+func (a *Authorizer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := a.authorize(ctx); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor refuses streams from peers that are not authorized
+func (a *Authorizer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := a.authorize(ss.Context()); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func (a *Authorizer) authorize(ctx context.Context) error {
+	if a == nil {
+		return nil
+	}
+	cert, ok := peerCertificate(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "No peer certificate")
+	}
+	if len(a.allowed) == 0 || names(cert, a.allowed) {
+		return nil
+	}
+	return status.Error(codes.PermissionDenied, "Peer certificate is not authorized to call this service")
+}
+
+// PeerNamed reports whether the peer of ctx presented a verified client certificate
+// naming one of sans, matched like the allowed names of an Authorizer
+func PeerNamed(ctx context.Context, sans []string) bool {
+	cert, ok := peerCertificate(ctx)
+	return ok && names(cert, sans)
+}
+
+// peerCertificate returns the verified client certificate of the peer of ctx
+func peerCertificate(ctx context.Context) (*x509.Certificate, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return tlsInfo.State.VerifiedChains[0][0], true
+}
+
+// names reports whether cert names one of the given subject alternative names
+func names(cert *x509.Certificate, allowed []string) bool {
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, allowed := range allowed {
+		for _, san := range sans {
+			if matchSAN(allowed, san) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchSAN(allowed, san string) bool {
+	if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+		label, rest, found := strings.Cut(san, ".")
+		return found && label != "" && strings.EqualFold(rest, suffix)
+	}
+	return strings.EqualFold(allowed, san)
+}
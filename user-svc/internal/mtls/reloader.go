@@ -0,0 +1,142 @@
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/monitoring"
+	"go.uber.org/zap"
+)
+
+// Reloader holds a certificate, its key and the CA peers are verified against, loaded
+// from PEM files. Run reloads them whenever the files change, so rotated certificates are
+// picked up by new connections without a restart.
+type Reloader struct {
+	certFile, keyFile, caFile string
+	logger                    *zap.Logger
+
+	mu     sync.RWMutex
+	cert   *tls.Certificate
+	pool   *x509.CertPool
+	loaded string // Modification stamp of the files last loaded
+}
+
+// NewReloader loads the certificate and key, and the CA bundle
+func NewReloader(certFile, keyFile, caFile string, logger *zap.Logger) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, caFile: caFile, logger: logger}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Run checks the files for changes every interval until ctx is cancelled
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reload()
+		}
+	}
+}
+
+// reload loads the files again if any of them changed since they were last loaded. Files
+// that fail to load, such as ones caught halfway through being replaced, leave the
+// previous certificates in use and are tried again on the next check.
+func (r *Reloader) reload() {
+	stamp, err := r.stamp()
+	if err != nil {
+		r.logger.Error("Failed to check TLS certificate files", zap.Error(err))
+		return
+	}
+	r.mu.RLock()
+	unchanged := stamp == r.loaded
+	r.mu.RUnlock()
+	if unchanged {
+		return
+	}
+	if err := r.load(); err != nil {
+		r.logger.Error("Failed to reload TLS certificates; keeping the previous ones", zap.Error(err))
+		return
+	}
+	cert, _ := r.current()
+	r.logger.Info("Reloaded TLS certificates", zap.String("cert_file", r.certFile), zap.Time("not_after", cert.Leaf.NotAfter))
+}
+
+func (r *Reloader) load() error {
+	stamp, err := r.stamp()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("parse certificate: %w", err)
+		}
+	}
+	caPEM, err := os.ReadFile(r.caFile)
+	if err != nil {
+		return fmt.Errorf("read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return fmt.Errorf("no certificates found in %s", r.caFile)
+	}
+
+	r.mu.Lock()
+	r.cert, r.pool, r.loaded = &cert, pool, stamp
+	r.mu.Unlock()
+	monitoring.TLSCertificateExpiry.Set(float64(cert.Leaf.NotAfter.Unix()))
+	return nil
+}
+
+// stamp sums up the modification time and size of the files; it changes when they do
+func (r *Reloader) stamp() (string, error) {
+	var parts []string
+	for _, name := range []string{r.certFile, r.keyFile, r.caFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, fmt.Sprintf("%d/%d", info.ModTime().UnixNano(), info.Size()))
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// current returns the certificate and CA pool loaded last
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, r.pool
+}
+
+// ServerConfig returns a TLS configuration requiring clients to present a certificate
+// issued by the CA. Each handshake uses the certificates loaded last.
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := r.current()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				ClientCAs:    pool,
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				NextProtos:   []string{"h2"},
+			}, nil
+		},
+	}
+}
@@ -2,6 +2,8 @@ package ports
 
 import (
 	"context"
+	"time"
+
 	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
 )
 
@@ -9,4 +11,65 @@ type APIPort interface {
 	CreateUser(ctx context.Context, req *domain.User) (*domain.User, error)
 	GetUser(ctx context.Context, id string) (*domain.User, error)
 	AuthenticateUser(ctx context.Context, req *domain.User) (*domain.UserAutenticate, error)
+	GetSession(ctx context.Context, id string) (*domain.Session, error)
+	ExportMyData(ctx context.Context, userID string) (*domain.DataExport, error)
+	RequestErasure(ctx context.Context, userID, reason string) (*domain.ErasureRequest, error)
+	GetErasureRequest(ctx context.Context, id string) (*domain.ErasureRequest, error)
+
+	CreateOrganization(ctx context.Context, actorID, name string) (*domain.Organization, error)
+	GetOrganization(ctx context.Context, actorID, orgID string) (*domain.Organization, *domain.Membership, error)
+	ListMyOrganizations(ctx context.Context, userID string) ([]*domain.Membership, error)
+	ListOrganizationMembers(ctx context.Context, actorID, orgID string) ([]*domain.Membership, error)
+	UpdateOrganizationMember(ctx context.Context, actorID, orgID, userID string, role domain.OrgRole) (*domain.Membership, error)
+	RemoveOrganizationMember(ctx context.Context, actorID, orgID, userID string) error
+	InviteOrganizationMember(ctx context.Context, actorID, orgID, email string, role domain.OrgRole) (*domain.OrgInvitation, error)
+	ListOrganizationInvitations(ctx context.Context, actorID, orgID string) ([]*domain.OrgInvitation, error)
+	RevokeOrganizationInvitation(ctx context.Context, actorID, invitationID string) error
+	ListMyInvitations(ctx context.Context, userID string) ([]*domain.OrgInvitation, error)
+	AcceptOrganizationInvitation(ctx context.Context, userID, invitationID, token string) (*domain.Membership, error)
+
+	CreateAPIKey(ctx context.Context, userID, name string, scopes []string, expiresAt *time.Time, orgID string) (*domain.APIKey, string, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]*domain.APIKey, error)
+	GetAPIKey(ctx context.Context, userID, id string) (*domain.APIKey, error)
+	UpdateAPIKey(ctx context.Context, userID, id, name string, scopes []string) (*domain.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id string) error
+	AuthenticateAPIKey(ctx context.Context, secret string) (*domain.APIKeyIdentity, error)
+
+	RegisterOIDCClient(ctx context.Context, name string, redirectURIs []string, public, service bool) (*domain.OIDCClient, string, error)
+	ValidateAuthorizationRequest(ctx context.Context, req *domain.AuthorizationRequest) (*domain.OIDCClient, error)
+	Authorize(ctx context.Context, req *domain.AuthorizationRequest, email, password string) (string, error)
+	ExchangeAuthorizationCode(ctx context.Context, clientID, clientSecret, code, redirectURI, codeVerifier string) (*domain.OIDCGrant, error)
+	AuthenticateServiceClient(ctx context.Context, clientID, clientSecret string) (*domain.OIDCClient, error)
+
+	LinkExternalIdentity(ctx context.Context, profile *domain.ExternalProfile, userID string) (*domain.ExternalLogin, error)
+
+	RequestLoginLink(ctx context.Context, email, deviceSecret string) (time.Time, error)
+	RedeemLoginLink(ctx context.Context, token, deviceSecret string) (*domain.UserAutenticate, error)
+
+	BeginPasskeyRegistration(ctx context.Context, userID string) (*domain.PasskeyChallenge, error)
+	FinishPasskeyRegistration(ctx context.Context, userID, ceremonyID, name string, response []byte) (*domain.Passkey, error)
+	BeginPasskeyLogin(ctx context.Context) (*domain.PasskeyChallenge, error)
+	FinishPasskeyLogin(ctx context.Context, ceremonyID string, response []byte) (*domain.UserAutenticate, error)
+	ListPasskeys(ctx context.Context, userID string) ([]*domain.Passkey, error)
+	DeletePasskey(ctx context.Context, userID, id string) error
+	SetTwoFactor(ctx context.Context, userID string, enabled bool) error
+
+	InviteUser(ctx context.Context, actorID, email, name string, role domain.Role) (*domain.UserInvite, error)
+	ListUserInvites(ctx context.Context, actorID string) ([]*domain.UserInvite, error)
+	ResendUserInvite(ctx context.Context, actorID, id string) (*domain.UserInvite, error)
+	RevokeUserInvite(ctx context.Context, actorID, id string) error
+	AcceptUserInvite(ctx context.Context, token, password, name string) (*domain.UserAutenticate, error)
+
+	Impersonate(ctx context.Context, actorID, userID, reason string) (*domain.ImpersonationGrant, error)
+	GetImpersonation(ctx context.Context, id string) (*domain.Impersonation, error)
+	EndImpersonation(ctx context.Context, id, actorID string) (*domain.Impersonation, error)
+
+	RequestEmailChange(ctx context.Context, userID, password, newEmail string) (time.Time, error)
+	ConfirmEmailChange(ctx context.Context, token string) (*domain.User, error)
+
+	BeginIdempotentCall(ctx context.Context, key, method, fingerprint string) (*domain.IdempotentCall, error)
+	FinishIdempotentCall(ctx context.Context, key, method, fingerprint, responseType string, response []byte) error
+	AbandonIdempotentCall(ctx context.Context, key, method, fingerprint string) error
+
+	WatchUsers(ctx context.Context, filter domain.UserEventFilter, resume bool, send func(*domain.UserEvent) error) error
 }
@@ -2,16 +2,129 @@ package ports
 
 import (
 	"context"
-	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"time"
 
+	"github.com/asadlive84/shopper/user-svc/internal/application/core/domain"
+	"github.com/google/uuid"
 )
 
 type DBPort interface {
 	CreateUser(ctx context.Context, user *domain.User) error
 	GetUser(ctx context.Context, id string) (*domain.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
+	UpdatePassword(ctx context.Context, id string, hashedPassword string) error
+	SetActive(ctx context.Context, id string, active bool) error
+	UpdateRole(ctx context.Context, id string, role domain.Role) error
+	SetPermissions(ctx context.Context, id string, permissions []string) error
+	TouchLastLogin(ctx context.Context, id string, at time.Time) error
+	AnonymizeUser(ctx context.Context, id string, pseudonym string) error
+
+	CreateSession(ctx context.Context, session *domain.Session) error
+	GetSession(ctx context.Context, id string) (*domain.Session, error)
+	ListSessions(ctx context.Context, userID string) ([]*domain.Session, error)
+	RevokeSessions(ctx context.Context, userID string) (int64, error)
+
+	CreateErasureRequest(ctx context.Context, req *domain.ErasureRequest) error
+	UpdateErasureRequest(ctx context.Context, req *domain.ErasureRequest) error
+	GetErasureRequest(ctx context.Context, id string) (*domain.ErasureRequest, error)
+	ListUnfinishedErasureRequests(ctx context.Context) ([]*domain.ErasureRequest, error)
+
+	CreateOrganization(ctx context.Context, org *domain.Organization) error
+	GetOrganization(ctx context.Context, id string) (*domain.Organization, error)
+	GetMembership(ctx context.Context, orgID, userID string) (*domain.Membership, error)
+	ListUserMemberships(ctx context.Context, userID string) ([]*domain.Membership, error)
+	ListMembers(ctx context.Context, orgID string) ([]*domain.Membership, error)
+	UpdateMemberRole(ctx context.Context, orgID, userID string, role domain.OrgRole) error
+	RemoveMember(ctx context.Context, orgID, userID string) error
+	CreateInvitation(ctx context.Context, inv *domain.OrgInvitation) error
+	GetInvitation(ctx context.Context, id string) (*domain.OrgInvitation, error)
+	ListPendingInvitations(ctx context.Context, orgID string) ([]*domain.OrgInvitation, error)
+	ListPendingInvitationsForEmail(ctx context.Context, email string) ([]*domain.OrgInvitation, error)
+	AcceptInvitation(ctx context.Context, inv *domain.OrgInvitation, member *domain.Membership) error
+	RevokeInvitation(ctx context.Context, id string) error
+
+	CreateAPIKey(ctx context.Context, key *domain.APIKey) error
+	ListAPIKeys(ctx context.Context, userID string) ([]*domain.APIKey, error)
+	GetAPIKey(ctx context.Context, userID, id string) (*domain.APIKey, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error)
+	UpdateAPIKey(ctx context.Context, key *domain.APIKey) error
+	RevokeAPIKey(ctx context.Context, userID, id string) error
+	RevokeAPIKeys(ctx context.Context, userID string) (int64, error)
+	TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error
+
+	CreateOIDCClient(ctx context.Context, client *domain.OIDCClient) error
+	GetOIDCClient(ctx context.Context, id string) (*domain.OIDCClient, error)
+	CreateAuthorizationCode(ctx context.Context, code *domain.AuthorizationCode) error
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*domain.AuthorizationCode, error)
+
+	CreateExternalIdentity(ctx context.Context, identity *domain.ExternalIdentity) error
+	GetExternalIdentity(ctx context.Context, provider, subject string) (*domain.ExternalIdentity, error)
+	ListExternalIdentities(ctx context.Context, userID string) ([]*domain.ExternalIdentity, error)
+	TouchExternalIdentity(ctx context.Context, id uuid.UUID, email string, at time.Time) error
+	DeleteExternalIdentities(ctx context.Context, userID string) (int64, error)
+
+	CreateLoginLink(ctx context.Context, link *domain.LoginLink, limit int, since time.Time) error
+	ConsumeLoginLink(ctx context.Context, tokenHash, deviceHash string) (*domain.LoginLink, error)
+	ListLoginLinks(ctx context.Context, userID string) ([]*domain.LoginLink, error)
+	DeleteLoginLinks(ctx context.Context, userID string) (int64, error)
+
+	CreatePasskey(ctx context.Context, passkey *domain.Passkey) error
+	ListPasskeys(ctx context.Context, userID string) ([]*domain.Passkey, error)
+	GetPasskeyByCredentialID(ctx context.Context, credentialID []byte) (*domain.Passkey, error)
+	UpdatePasskeyUsage(ctx context.Context, passkey *domain.Passkey) error
+	DeletePasskey(ctx context.Context, userID, id string) error
+	DeletePasskeys(ctx context.Context, userID string) (int64, error)
+	SetTwoFactor(ctx context.Context, id string, enabled bool) error
+	CreatePasskeyCeremony(ctx context.Context, ceremony *domain.PasskeyCeremony) error
+	ConsumePasskeyCeremony(ctx context.Context, id string, kind domain.PasskeyCeremonyKind) (*domain.PasskeyCeremony, error)
+
+	CreateUserInvite(ctx context.Context, user *domain.User, invite *domain.UserInvite) error
+	GetUserInvite(ctx context.Context, id string) (*domain.UserInvite, error)
+	GetOpenUserInvite(ctx context.Context, tokenHash string) (*domain.UserInvite, error)
+	ListUserInvites(ctx context.Context) ([]*domain.UserInvite, error)
+	RenewUserInvite(ctx context.Context, id, tokenHash string, expiresAt time.Time) (*domain.UserInvite, error)
+	RevokeUserInvite(ctx context.Context, id string) (*domain.UserInvite, error)
+	AcceptUserInvite(ctx context.Context, tokenHash, passwordHash, name string) (*domain.UserInvite, error)
+	ListInvitesOfUser(ctx context.Context, userID string) ([]*domain.UserInvite, error)
+	DeleteInvitesOfUser(ctx context.Context, userID string) (int64, error)
+
+	CreateImpersonation(ctx context.Context, imp *domain.Impersonation) error
+	GetImpersonation(ctx context.Context, id string) (*domain.Impersonation, error)
+	EndImpersonation(ctx context.Context, id, actorID string, at time.Time) (*domain.Impersonation, error)
+	EndExpiredImpersonations(ctx context.Context, now time.Time) ([]*domain.Impersonation, error)
+
+	CreateEmailChange(ctx context.Context, change *domain.EmailChange) error
+	ConfirmEmailChange(ctx context.Context, tokenHash string) (*domain.EmailChange, error)
+	ListEmailChanges(ctx context.Context, userID string) ([]*domain.EmailChange, error)
+	DeleteEmailChanges(ctx context.Context, userID string) (int64, error)
+
+	ClaimIdempotencyKey(ctx context.Context, call *domain.IdempotentCall) (*domain.IdempotentCall, error)
+	CompleteIdempotentCall(ctx context.Context, call *domain.IdempotentCall) error
+	ReleaseIdempotencyKey(ctx context.Context, call *domain.IdempotentCall) error
+
+	AppendUserEvent(ctx context.Context, event *domain.UserEvent) error
+	ListUserEvents(ctx context.Context, filter domain.UserEventFilter, limit int) ([]*domain.UserEvent, error)
+	UserEventBounds(ctx context.Context) (oldest, newest int64, err error)
+
+	PurgeSessions(ctx context.Context, before time.Time) (int64, error)
+	PurgeDeletedUsers(ctx context.Context, before time.Time) (int64, error)
+	PurgeInvitations(ctx context.Context, before time.Time) (int64, error)
+	PurgeAPIKeys(ctx context.Context, before time.Time) (int64, error)
+	PurgeAuthorizationCodes(ctx context.Context, before time.Time) (int64, error)
+	PurgeLoginLinks(ctx context.Context, before time.Time) (int64, error)
+	PurgePasskeyCeremonies(ctx context.Context, before time.Time) (int64, error)
+	PurgeUserInvites(ctx context.Context, before time.Time) (int64, error)
+	PurgeImpersonations(ctx context.Context, before time.Time) (int64, error)
+	PurgeEmailChanges(ctx context.Context, before time.Time) (int64, error)
+	PurgeIdempotentCalls(ctx context.Context, before time.Time) (int64, error)
+	PurgeUserEvents(ctx context.Context, before time.Time) (int64, error)
 }
 
 type MongoDBPort interface {
-	LogMessage(ctx context.Context, msg string) error
-}
\ No newline at end of file
+	LogMessage(ctx context.Context, userID, msg string) error
+	RecordAudit(ctx context.Context, entry *domain.AuditEntry) error
+	ListAudit(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEntry, error)
+	PseudonymizeAudit(ctx context.Context, userID, pseudonym string) (int64, error)
+	ListLogs(ctx context.Context, userID string, emails []string) ([]*domain.LogEntry, error)
+	ScrubLogs(ctx context.Context, userID string, emails []string, replacement string) (int64, error)
+}
@@ -0,0 +1,12 @@
+package ports
+
+import "context"
+
+// PasswordHasherPort hashes and checks passwords off the calling goroutine, so that the
+// cost of hashing is bounded for the whole service
+type PasswordHasherPort interface {
+	Hash(ctx context.Context, password string) (string, error)
+	// Compare returns nil when password matches hash, and bcrypt.ErrMismatchedHashAndPassword
+	// when it does not
+	Compare(ctx context.Context, hash, password string) error
+}
@@ -4,6 +4,9 @@ import "context"
 
 type MessagingPort interface {
 	Publish(ctx context.Context, exchange, message string) error
+	// PublishSecret publishes a message carrying a secret, such as a login link for the
+	// mailer, keeping its body out of logs and traces
+	PublishSecret(ctx context.Context, exchange, message string) error
 	Consume(queue string, consumerFunc func(context.Context, string)) error
 	Close()
 }
\ No newline at end of file
@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/monitoring"
+	"github.com/asadlive84/shopper/user-svc/internal/tenant"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// RetryAfterKey is the trailer telling a rejected caller how many seconds to wait
+	RetryAfterKey = "retry-after"
+	// ForwardedForKey is the metadata key the gateway puts the address of its client in;
+	// it is only believed from peers trusted by Config.TrustForwardedFor
+	ForwardedForKey = "x-forwarded-for"
+	// shedRetryAfter is how long shed callers are asked to wait
+	shedRetryAfter = time.Second
+)
+
+// UnaryServerInterceptor rejects calls over their limits, and calls arriving while the
+// server is overloaded, with ResourceExhausted
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if l.exempt(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		if reason, wait, ok := l.allow(info.FullMethod, l.caller(ctx)); !ok {
+			return nil, rejected(info.FullMethod, reason, wait, func(md metadata.MD) error { return grpc.SetTrailer(ctx, md) })
+		}
+		shedder := l.shedder.Load()
+		if !shedder.acquire() {
+			return nil, rejected(info.FullMethod, "overload", shedRetryAfter, func(md metadata.MD) error { return grpc.SetTrailer(ctx, md) })
+		}
+		start := time.Now()
+		defer func() { shedder.release(time.Since(start), time.Now()) }()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects streams opened over their limits with ResourceExhausted.
+// Streams stay open for long, so they are not shed nor count as calls in flight.
+func (l *Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if l.exempt(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		if reason, wait, ok := l.allow(info.FullMethod, l.caller(ss.Context())); !ok {
+			return rejected(info.FullMethod, reason, wait, func(md metadata.MD) error { ss.SetTrailer(md); return nil })
+		}
+		return handler(srv, ss)
+	}
+}
+
+// caller identifies who a call is made on behalf of: the tenant together with the address
+// of the client a trusted gateway forwarded, or else of the peer making the call
+func (l *Limiter) caller(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if trust := l.cfg.Load().TrustForwardedFor; trust == nil || !trust(ctx) {
+		md = nil
+	}
+	if values := md.Get(ForwardedForKey); len(values) > 0 {
+		if client, _, _ := strings.Cut(values[0], ","); strings.TrimSpace(client) != "" {
+			return tenant.FromContext(ctx) + "/" + strings.TrimSpace(client)
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return tenant.FromContext(ctx) + "/" + host
+	}
+	return tenant.FromContext(ctx)
+}
+
+// rejected counts a rejected call and returns its error, with the wait as a RetryInfo
+// detail and, rounded up to seconds, in the retry-after trailer
+func rejected(method, reason string, wait time.Duration, setTrailer func(metadata.MD) error) error {
+	monitoring.RateLimited.WithLabelValues(method, reason).Inc()
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	_ = setTrailer(metadata.Pairs(RetryAfterKey, strconv.Itoa(seconds)))
+
+	msg := "Too many requests; try again later"
+	if reason == "overload" {
+		msg = "Server is overloaded; try again later"
+	}
+	st := status.New(codes.ResourceExhausted, msg)
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
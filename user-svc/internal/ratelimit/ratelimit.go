@@ -0,0 +1,218 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/monitoring"
+)
+
+// callerSweepInterval is how often buckets of callers that went quiet are dropped
+const callerSweepInterval = time.Minute
+
+// Rule is a token bucket refilled with Rate tokens per second up to Burst tokens. Every
+// call takes a token; the zero Rule does not limit anything.
+type Rule struct {
+	Rate  float64
+	Burst int
+}
+
+func (r Rule) enabled() bool {
+	return r.Rate > 0 && r.Burst > 0
+}
+
+// Config of a Limiter. Methods are named without their service, e.g. "CreateUser".
+type Config struct {
+	Default       Rule            // Limit of every method without its own, shared by all callers
+	Methods       map[string]Rule // Limits by method, shared by all callers
+	CallerDefault Rule            // Limit of each caller on every method without its own
+	CallerMethods map[string]Rule // Limits of each caller by method
+	// Services that are never limited nor shed, e.g. the health service
+	Exempt []string
+	// Reports whether the peer of a call may name the client it calls on behalf of in
+	// ForwardedForKey; nil trusts no peer
+	TrustForwardedFor func(ctx context.Context) bool
+
+	// Adaptive load shedding: calls in flight are capped by a limit between MinInFlight
+	// and MaxInFlight, lowered while their latency is above TargetLatency and raised
+	// again once it is back under. A zero MaxInFlight disables shedding.
+	MaxInFlight   int
+	MinInFlight   int
+	TargetLatency time.Duration
+}
+
+// Limiter rejects calls over the configured token bucket limits, per method and per
+// caller, and sheds calls while the server is overloaded
+type Limiter struct {
+	cfg     atomic.Pointer[Config]
+	now     func() time.Time
+	shedder atomic.Pointer[shedder] // Nil while shedding is disabled
+
+	mu        sync.Mutex
+	methods   map[string]*bucket
+	callers   map[callerKey]*callerBucket
+	lastSweep time.Time
+}
+
+type callerKey struct {
+	method string
+	caller string
+}
+
+type callerBucket struct {
+	bucket
+	rule Rule
+}
+
+func New(cfg Config) *Limiter {
+	l := &Limiter{
+		now:       time.Now,
+		methods:   make(map[string]*bucket),
+		callers:   make(map[callerKey]*callerBucket),
+		lastSweep: time.Now(),
+	}
+	l.SetConfig(cfg)
+	return l
+}
+
+// SetConfig replaces the limits of a running Limiter. Buckets keep their tokens, capped
+// at the new bursts, and calls in flight keep counting against the load shedder.
+func (l *Limiter) SetConfig(cfg Config) {
+	l.cfg.Store(&cfg)
+	current := l.shedder.Load()
+	if current != nil && cfg.MaxInFlight > 0 {
+		current.resize(cfg.MinInFlight, cfg.MaxInFlight, cfg.TargetLatency)
+		return
+	}
+	l.shedder.Store(newShedder(cfg.MinInFlight, cfg.MaxInFlight, cfg.TargetLatency))
+}
+
+// exempt reports whether fullMethod, e.g. "/user.UserService/CreateUser", belongs to an
+// exempt service
+func (l *Limiter) exempt(fullMethod string) bool {
+	service, _ := splitMethod(fullMethod)
+	for _, s := range l.cfg.Load().Exempt {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+// rules returns the limit shared by all callers of a method and the limit of each caller
+func (l *Limiter) rules(method string) (shared, perCaller Rule) {
+	cfg := l.cfg.Load()
+	shared, ok := cfg.Methods[method]
+	if !ok {
+		shared = cfg.Default
+	}
+	perCaller, ok = cfg.CallerMethods[method]
+	if !ok {
+		perCaller = cfg.CallerDefault
+	}
+	return shared, perCaller
+}
+
+// allow takes a token for a call to fullMethod by caller from both the method's and the
+// caller's bucket. When either is empty nothing is taken, and allow returns which limit
+// was hit and how long until the call would be allowed.
+func (l *Limiter) allow(fullMethod, caller string) (reason string, retryAfter time.Duration, ok bool) {
+	_, method := splitMethod(fullMethod)
+	shared, perCaller := l.rules(method)
+	if !shared.enabled() && !perCaller.enabled() {
+		return "", 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	var methodBucket *bucket
+	if shared.enabled() {
+		methodBucket = l.methods[fullMethod]
+		if methodBucket == nil {
+			methodBucket = newBucket(shared, now)
+			l.methods[fullMethod] = methodBucket
+		}
+		methodBucket.refill(shared, now)
+		defer func() {
+			monitoring.RateLimitTokens.WithLabelValues(fullMethod).Set(methodBucket.tokens)
+		}()
+	}
+	var userBucket *callerBucket
+	if perCaller.enabled() {
+		key := callerKey{method: fullMethod, caller: caller}
+		userBucket = l.callers[key]
+		if userBucket == nil {
+			userBucket = &callerBucket{bucket: *newBucket(perCaller, now), rule: perCaller}
+			l.callers[key] = userBucket
+			monitoring.RateLimitCallers.Set(float64(len(l.callers)))
+		}
+		userBucket.refill(perCaller, now)
+		userBucket.rule = perCaller
+	}
+
+	if userBucket != nil && userBucket.tokens < 1 {
+		return "caller", userBucket.wait(perCaller), false
+	}
+	if methodBucket != nil && methodBucket.tokens < 1 {
+		return "method", methodBucket.wait(shared), false
+	}
+	if userBucket != nil {
+		userBucket.tokens--
+	}
+	if methodBucket != nil {
+		methodBucket.tokens--
+	}
+	return "", 0, true
+}
+
+// sweep drops the buckets of callers that were quiet long enough for them to fill up
+// again, as a fresh bucket would be just the same
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < callerSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.callers {
+		if b.refill(b.rule, now); b.tokens >= float64(b.rule.Burst) {
+			delete(l.callers, key)
+		}
+	}
+	monitoring.RateLimitCallers.Set(float64(len(l.callers)))
+}
+
+// bucket holds the tokens left as of last
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newBucket returns a full bucket
+func newBucket(rule Rule, now time.Time) *bucket {
+	return &bucket{tokens: float64(rule.Burst), last: now}
+}
+
+// refill adds the tokens earned since the bucket was last refilled
+func (b *bucket) refill(rule Rule, now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(rule.Burst), b.tokens+elapsed*rule.Rate)
+	}
+	b.last = now
+}
+
+// wait returns how long until the bucket holds a whole token again
+func (b *bucket) wait(rule Rule) time.Duration {
+	return time.Duration((1 - b.tokens) / rule.Rate * float64(time.Second))
+}
+
+// splitMethod splits a full gRPC method name into its service and method
+func splitMethod(fullMethod string) (service, method string) {
+	service, method, _ = strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return service, method
+}
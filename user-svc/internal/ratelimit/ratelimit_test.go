@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testMethod = "/user.UserService/GetUser"
+
+// call is a call made at an offset from the start of a test and what allow should answer
+type call struct {
+	at         time.Duration
+	caller     string
+	ok         bool
+	reason     string
+	retryAfter time.Duration
+}
+
+func TestLimiterBuckets(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		calls []call
+	}{
+		{
+			name: "burst then empty",
+			cfg:  Config{Default: Rule{Rate: 2, Burst: 3}},
+			calls: []call{
+				{at: 0, ok: true},
+				{at: 0, ok: true},
+				{at: 0, ok: true},
+				{at: 0, reason: "method", retryAfter: 500 * time.Millisecond},
+			},
+		},
+		{
+			name: "refills at the rate",
+			cfg:  Config{Default: Rule{Rate: 2, Burst: 1}},
+			calls: []call{
+				{at: 0, ok: true},
+				{at: 250 * time.Millisecond, reason: "method", retryAfter: 250 * time.Millisecond},
+				{at: 500 * time.Millisecond, ok: true},
+				{at: 500 * time.Millisecond, reason: "method", retryAfter: 500 * time.Millisecond},
+			},
+		},
+		{
+			name: "refills no further than the burst",
+			cfg:  Config{Default: Rule{Rate: 10, Burst: 2}},
+			calls: []call{
+				{at: 0, ok: true},
+				{at: 0, ok: true},
+				{at: 10 * time.Second, ok: true},
+				{at: 10 * time.Second, ok: true},
+				{at: 10 * time.Second, reason: "method", retryAfter: 100 * time.Millisecond},
+			},
+		},
+		{
+			name: "method limit overrides the default",
+			cfg:  Config{Default: Rule{Rate: 100, Burst: 100}, Methods: map[string]Rule{"GetUser": {Rate: 1, Burst: 1}}},
+			calls: []call{
+				{at: 0, ok: true},
+				{at: 0, reason: "method", retryAfter: time.Second},
+			},
+		},
+		{
+			name: "each caller has a bucket",
+			cfg:  Config{CallerDefault: Rule{Rate: 1, Burst: 1}},
+			calls: []call{
+				{at: 0, caller: "a", ok: true},
+				{at: 0, caller: "a", reason: "caller", retryAfter: time.Second},
+				{at: 0, caller: "b", ok: true},
+				{at: time.Second, caller: "a", ok: true},
+			},
+		},
+		{
+			name: "a rejected caller takes no token from the method",
+			cfg:  Config{Default: Rule{Rate: 1, Burst: 2}, CallerDefault: Rule{Rate: 1, Burst: 1}},
+			calls: []call{
+				{at: 0, caller: "a", ok: true},
+				{at: 0, caller: "a", reason: "caller", retryAfter: time.Second},
+				{at: 0, caller: "b", ok: true},
+				{at: 0, caller: "c", reason: "method", retryAfter: time.Second},
+			},
+		},
+		{
+			name: "zero rules do not limit",
+			cfg:  Config{},
+			calls: []call{
+				{at: 0, ok: true},
+				{at: 0, ok: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			var now time.Time
+			l := New(tt.cfg)
+			l.now = func() time.Time { return now }
+			for i, c := range tt.calls {
+				now = start.Add(c.at)
+				reason, retryAfter, ok := l.allow(testMethod, c.caller)
+				if ok != c.ok || reason != c.reason || retryAfter != c.retryAfter {
+					t.Errorf("call %d at %s by %q = (%q, %s, %t), want (%q, %s, %t)",
+						i, c.at, c.caller, reason, retryAfter, ok, c.reason, c.retryAfter, c.ok)
+				}
+			}
+		})
+	}
+}
+
+func TestRejectedRetryAfter(t *testing.T) {
+	tests := []struct {
+		wait time.Duration
+		want string
+	}{
+		{wait: 0, want: "1"},
+		{wait: 100 * time.Millisecond, want: "1"},
+		{wait: time.Second, want: "1"},
+		{wait: time.Second + time.Millisecond, want: "2"},
+		{wait: 2500 * time.Millisecond, want: "3"},
+	}
+	for _, tt := range tests {
+		var trailer metadata.MD
+		err := rejected(testMethod, "method", tt.wait, func(md metadata.MD) error {
+			trailer = md
+			return nil
+		})
+		if got := trailer.Get(RetryAfterKey); len(got) != 1 || got[0] != tt.want {
+			t.Errorf("retry-after for %s = %v, want %s", tt.wait, got, tt.want)
+		}
+
+		st := status.Convert(err)
+		if st.Code() != codes.ResourceExhausted {
+			t.Errorf("code for %s = %s, want %s", tt.wait, st.Code(), codes.ResourceExhausted)
+		}
+		var delay time.Duration
+		for _, detail := range st.Details() {
+			if info, ok := detail.(*errdetails.RetryInfo); ok {
+				delay = info.RetryDelay.AsDuration()
+			}
+		}
+		if delay != tt.wait {
+			t.Errorf("RetryInfo delay for %s = %s, want the exact wait", tt.wait, delay)
+		}
+	}
+}
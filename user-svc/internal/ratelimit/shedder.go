@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/asadlive84/shopper/user-svc/internal/monitoring"
+)
+
+const (
+	// latencyWeight is the weight of the latest call in the moving average of latencies
+	latencyWeight = 0.1
+	// shedBackoff is the factor the in-flight limit is multiplied by when latency is too high
+	shedBackoff = 0.9
+)
+
+// shedder caps the calls in flight with an AIMD limit: each call finishing while the
+// average latency is on target raises the limit by 1/limit, about one per limit's worth of
+// calls, and the limit is cut by shedBackoff at most once per target latency while above
+type shedder struct {
+	min, max float64
+	target   time.Duration
+
+	mu           sync.Mutex
+	limit        float64
+	inFlight     int
+	latency      time.Duration // Moving average
+	lastDecrease time.Time
+}
+
+// newShedder returns a shedder, or nil when max is zero and nothing is shed
+func newShedder(min, max int, target time.Duration) *shedder {
+	if max <= 0 {
+		return nil
+	}
+	if min <= 0 || min > max {
+		min = 1
+	}
+	monitoring.LoadShedLimit.Set(float64(max))
+	return &shedder{min: float64(min), max: float64(max), target: target, limit: float64(max)}
+}
+
+// resize changes the bounds and target latency of the limit, moving the limit within the
+// new bounds
+func (s *shedder) resize(min, max int, target time.Duration) {
+	if min <= 0 || min > max {
+		min = 1
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.min, s.max, s.target = float64(min), float64(max), target
+	s.limit = math.Min(s.max, math.Max(s.min, s.limit))
+	monitoring.LoadShedLimit.Set(math.Floor(s.limit))
+}
+
+// acquire reserves a slot for a call, or reports false when the limit is reached
+func (s *shedder) acquire() bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inFlight >= int(s.limit) {
+		return false
+	}
+	s.inFlight++
+	monitoring.LoadShedInFlight.Set(float64(s.inFlight))
+	return true
+}
+
+// release frees the slot of a call that took latency and adapts the limit
+func (s *shedder) release(latency time.Duration, now time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight--
+	if s.latency == 0 {
+		s.latency = latency
+	} else {
+		s.latency = time.Duration(latencyWeight*float64(latency) + (1-latencyWeight)*float64(s.latency))
+	}
+
+	switch {
+	case s.target <= 0:
+	case s.latency > s.target:
+		if now.Sub(s.lastDecrease) >= s.target {
+			s.limit = math.Max(s.min, s.limit*shedBackoff)
+			s.lastDecrease = now
+		}
+	default:
+		s.limit = math.Min(s.max, s.limit+1/s.limit)
+	}
+
+	monitoring.LoadShedInFlight.Set(float64(s.inFlight))
+	monitoring.LoadShedLimit.Set(math.Floor(s.limit))
+	monitoring.LoadShedLatency.Set(s.latency.Seconds())
+}
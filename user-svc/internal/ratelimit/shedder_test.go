@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShedderBacksOff(t *testing.T) {
+	const target = 100 * time.Millisecond
+	start := time.Now()
+	tests := []struct {
+		name      string
+		latency   time.Duration
+		at        time.Duration
+		wantLimit float64
+	}{
+		{name: "slow call lowers the limit", latency: time.Second, at: 0, wantLimit: 9},
+		{name: "at most once per target latency", latency: time.Second, at: target / 2, wantLimit: 9},
+		{name: "lowered again a target later", latency: time.Second, at: target, wantLimit: 8.1},
+		{name: "fast call leaves a slow average", latency: time.Millisecond, at: 2 * target, wantLimit: 8.1 * shedBackoff},
+		{name: "never below the minimum", latency: time.Second, at: 30 * target, wantLimit: 7},
+	}
+
+	s := newShedder(7, 10, target)
+	for i := 0; i < 10; i++ {
+		if !s.acquire() {
+			t.Fatalf("call %d shed below the maximum", i)
+		}
+	}
+	if s.acquire() {
+		t.Fatal("call over the maximum was not shed")
+	}
+	for _, tt := range tests {
+		s.release(tt.latency, start.Add(tt.at))
+		if s.limit != tt.wantLimit {
+			t.Errorf("%s: limit = %v, want %v", tt.name, s.limit, tt.wantLimit)
+		}
+	}
+	// 5 calls still in flight, under the limit of 7
+	if !s.acquire() || !s.acquire() {
+		t.Error("call under the lowered limit was shed")
+	}
+	if s.acquire() {
+		t.Error("call over the lowered limit was not shed")
+	}
+}
+
+func TestShedderRecovers(t *testing.T) {
+	const target = 100 * time.Millisecond
+	now := time.Now()
+	s := newShedder(1, 10, target)
+	for i := 0; i < 30; i++ {
+		s.acquire()
+		now = now.Add(target)
+		s.release(time.Second, now)
+	}
+	if s.limit != 1 {
+		t.Fatalf("limit after sustained slow calls = %v, want the minimum", s.limit)
+	}
+
+	// Once the average latency is back on target, each call raises the limit by 1/limit
+	var calls int
+	for ; s.limit < 10 && calls < 1000; calls++ {
+		if !s.acquire() {
+			t.Fatalf("call %d shed with nothing in flight", calls)
+		}
+		now = now.Add(time.Millisecond)
+		s.release(time.Millisecond, now)
+	}
+	if s.limit != 10 {
+		t.Fatalf("limit after %d fast calls = %v, want the maximum", calls, s.limit)
+	}
+	s.acquire()
+	s.release(time.Millisecond, now)
+	if s.limit != 10 {
+		t.Errorf("limit rose to %v, above the maximum", s.limit)
+	}
+}
+
+func TestShedderDisabled(t *testing.T) {
+	s := newShedder(0, 0, time.Second)
+	if s != nil {
+		t.Fatal("newShedder with no maximum returned a shedder")
+	}
+	if !s.acquire() {
+		t.Error("a disabled shedder shed a call")
+	}
+	s.release(time.Hour, time.Now())
+}
@@ -0,0 +1,87 @@
+package requestid
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataKey is the gRPC metadata key (and AMQP header) carrying the request ID
+const MetadataKey = "x-request-id"
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type contextKey struct{}
+
+// New returns a fresh request ID
+func New() string {
+	return uuid.NewString()
+}
+
+// Valid reports whether id is a well-formed request ID
+func Valid(id string) bool {
+	return validID.MatchString(id)
+}
+
+// NewContext returns a copy of ctx carrying the request ID
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID carried by ctx, or "" when there is none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Logger returns logger annotated with the request ID carried by ctx, if any
+func Logger(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	if id := FromContext(ctx); id != "" {
+		return logger.With(zap.String("request_id", id))
+	}
+	return logger
+}
+
+// UnaryServerInterceptor moves the request ID from the incoming metadata into the context,
+// generating one when the caller sent none, and echoes it in the response header
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		id := fromIncoming(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataKey, id))
+		return handler(NewContext(ctx, id), req)
+	}
+}
+
+// StreamServerInterceptor moves the request ID from the incoming metadata into the stream's
+// context, generating one when the caller sent none, and echoes it in the response header
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		id := fromIncoming(ss.Context())
+		_ = ss.SetHeader(metadata.Pairs(MetadataKey, id))
+		return handler(srv, &serverStream{ServerStream: ss, ctx: NewContext(ss.Context(), id)})
+	}
+}
+
+// fromIncoming returns the request ID of the incoming metadata. A malformed one is replaced
+// rather than refused, since it only serves to correlate logs.
+func fromIncoming(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(MetadataKey); len(values) > 0 && Valid(values[0]) {
+		return values[0]
+	}
+	return New()
+}
+
+// serverStream is a grpc.ServerStream with a replaced context
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
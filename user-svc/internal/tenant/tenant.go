@@ -0,0 +1,97 @@
+package tenant
+
+import (
+	"context"
+	"regexp"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// MetadataKey is the gRPC metadata key (and AMQP header) carrying the tenant ID
+	MetadataKey = "x-tenant-id"
+	// Default is the tenant used when a request does not name one
+	Default = "default"
+)
+
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+type contextKey struct{}
+
+// Valid reports whether id is a well-formed tenant ID
+func Valid(id string) bool {
+	return validID.MatchString(id)
+}
+
+// NewContext returns a copy of ctx carrying the tenant ID
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant ID carried by ctx, or Default
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(contextKey{}).(string); ok && id != "" {
+		return id
+	}
+	return Default
+}
+
+// BindToken prefixes a token that is handed out of band, such as in a mailed link, with
+// the tenant of ctx. Whoever presents it again has no other way of telling the gateway
+// which tenant it belongs to. Tenant IDs never contain the ".", nor do the base64url
+// tokens it joins them with.
+func BindToken(ctx context.Context, token string) string {
+	return FromContext(ctx) + "." + token
+}
+
+// RoutingKey is the RabbitMQ routing key for messages published on behalf of the tenant in ctx
+func RoutingKey(ctx context.Context) string {
+	return FromContext(ctx)
+}
+
+// UnaryServerInterceptor moves the tenant ID from the incoming metadata into the context
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := fromIncoming(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func fromIncoming(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(MetadataKey)
+	if len(values) == 0 || values[0] == "" {
+		return NewContext(ctx, Default), nil
+	}
+	if !Valid(values[0]) {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid tenant ID '%s'", values[0])
+	}
+	return NewContext(ctx, values[0]), nil
+}
+
+// StreamServerInterceptor moves the tenant ID from the incoming metadata into the stream's context
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := fromIncoming(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream is a grpc.ServerStream with a replaced context
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -1,27 +1,148 @@
 package tracing
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// Exporters spans can be sent with
+const (
+	ExporterOTLPGRPC = "otlp-grpc" // OTLP over gRPC, e.g. to an OpenTelemetry Collector on :4317
+	ExporterOTLPHTTP = "otlp-http" // OTLP over HTTP, e.g. to an OpenTelemetry Collector on :4318
+	ExporterJaeger   = "jaeger"    // Jaeger's own collector protocol, for collectors without OTLP
+	ExporterStdout   = "stdout"    // One JSON document per span on standard output
+	ExporterNone     = "none"      // Spans are created and propagated but not exported
 )
 
-func InitTracer(serviceName, jaegerEndpoint string) (*sdktrace.TracerProvider, error) {
-	exporter, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(jaegerEndpoint)))
+// Exporters lists the valid values of Config.Exporter
+var Exporters = []string{ExporterOTLPGRPC, ExporterOTLPHTTP, ExporterJaeger, ExporterStdout, ExporterNone}
+
+// Config of the tracer provider
+type Config struct {
+	ServiceName string
+	Version     string // service.version; the module version when empty
+	Environment string // deployment.environment
+	Instance    string // service.instance.id; the host name when empty
+
+	Exporter string // One of Exporters
+	Endpoint string // Collector address or URL; the exporter's default when empty
+	Insecure bool   // Send spans to the collector without TLS
+	// Share of the traces started by this service that are sampled; traces continued from
+	// a caller follow the caller's decision
+	SampleRatio float64
+}
+
+// InitTracer installs a global tracer provider exporting spans as configured
+func InitTracer(cfg Config) (*sdktrace.TracerProvider, error) {
+	ctx := context.Background()
+	res, err := newResource(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(serviceName),
-		)),
-	)
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	}
+	switch cfg.Exporter {
+	case ExporterNone:
+	case ExporterStdout:
+		exporter, err := stdouttrace.New()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithSyncer(exporter))
+	default:
+		exporter, err := newExporter(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	tp := sdktrace.NewTracerProvider(opts...)
 	otel.SetTracerProvider(tp)
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 	return tp, nil
-}
\ No newline at end of file
+}
+
+// newExporter returns the exporter sending spans to a collector
+func newExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, error) {
+	url := strings.Contains(cfg.Endpoint, "://")
+	switch cfg.Exporter {
+	case ExporterOTLPGRPC:
+		var opts []otlptracegrpc.Option
+		switch {
+		case url:
+			opts = append(opts, otlptracegrpc.WithEndpointURL(cfg.Endpoint))
+		case cfg.Endpoint != "":
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case ExporterOTLPHTTP:
+		var opts []otlptracehttp.Option
+		switch {
+		case url:
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		case cfg.Endpoint != "":
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	case ExporterJaeger:
+		var opts []jaeger.CollectorEndpointOption
+		if cfg.Endpoint != "" {
+			opts = append(opts, jaeger.WithEndpoint(cfg.Endpoint))
+		}
+		return jaeger.New(jaeger.WithCollectorEndpoint(opts...))
+	}
+	return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+}
+
+// newResource describes the service in every span; OTEL_RESOURCE_ATTRIBUTES can add to it
+func newResource(ctx context.Context, cfg Config) (*resource.Resource, error) {
+	version := cfg.Version
+	if version == "" {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			version = info.Main.Version
+		}
+	}
+	instance := cfg.Instance
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+	attrs := []attribute.KeyValue{semconv.ServiceNameKey.String(cfg.ServiceName)}
+	if version != "" {
+		attrs = append(attrs, semconv.ServiceVersionKey.String(version))
+	}
+	if cfg.Environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironmentKey.String(cfg.Environment))
+	}
+	if instance != "" {
+		attrs = append(attrs, semconv.ServiceInstanceIDKey.String(instance))
+	}
+	return resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithFromEnv(),
+		resource.WithAttributes(attrs...),
+	)
+}